@@ -51,6 +51,25 @@ type Action interface {
 	Run(ctx ActionCtx) (store.Status, error)
 }
 
+// ReadOnlyAction is implemented by actions that may not modify the
+// store, they run without taking the store lock
+type ReadOnlyAction interface {
+	ReadOnly() bool
+}
+
+// lockStore takes the store lock for actions that modify the store
+// and returns the function releasing it
+func lockStore(ctx ActionCtx, action Action) (func(), error) {
+	s := ctx.StoreCtx().Store
+	if ro, ok := action.(ReadOnlyAction); s == nil || (ok && ro.ReadOnly()) {
+		return func() {}, nil
+	}
+	if err := s.AcquireLock(); err != nil {
+		return nil, err
+	}
+	return func() { _ = s.ReleaseLock() }, nil
+}
+
 type Actx struct {
 	ctx  *store.Context
 	cmd  *cobra.Command
@@ -117,6 +136,17 @@ func run(ctx ActionCtx, action interface{}) error {
 	if !ok {
 		return fmt.Errorf("action provided is not an Action")
 	}
+	// actions run by another action make their changes in its dry run
+	var dryRun *store.DryRun
+	if DryRunFlag && store.ActiveDryRun() == nil {
+		d, restore, err := startDryRun(ctx)
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+		defer restore()
+		dryRun = d
+	}
 	if err := e.SetDefaults(ctx); err != nil {
 		return err
 	}
@@ -137,11 +167,45 @@ func run(ctx ActionCtx, action interface{}) error {
 		}
 	}
 
-	if err := e.Validate(ctx); err != nil {
+	// the store is held while the action is validated and run so that
+	// concurrent invocations don't interleave their changes
+	unlock, err := lockStore(ctx, e)
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	rs, err := e.Run(ctx)
+	// claims rejected by the store policy are reported with the reasons
+	var rs store.Status
+	err = e.Validate(ctx)
+	if err == nil {
+		var stopPolicy func()
+		if stopPolicy, err = enforcePolicy(ctx); err != nil {
+			return err
+		}
+		defer stopPolicy()
+		rs, err = e.Run(ctx)
+		if rs == nil {
+			rs = rejectedReport(err)
+		}
+		rs, err = unrecordedWarning(rs, err)
+	} else if rs = rejectedReport(err); rs == nil {
+		return err
+	}
+	var changes []*store.Change
+	if dryRun != nil {
+		var derr error
+		if changes, derr = dryRun.Changes(); derr != nil && err == nil {
+			err = derr
+		}
+	}
+	if StructuredOutput() {
+		return writeStatusOutput(rs, changes, err)
+	}
+	// a failed action reports the error instead of the changes
+	if dryRun != nil && err == nil {
+		defer printDryRun(ctx.CurrentCmd(), changes)
+	}
 	if rs != nil {
 		ctx.CurrentCmd().Println(rs.Message())
 		sum, ok := rs.(store.Summarizer)
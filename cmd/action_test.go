@@ -16,7 +16,12 @@
 package cmd
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
@@ -230,3 +235,26 @@ func TestActionMessage(t *testing.T) {
 	require.NoError(t, err)
 	require.Contains(t, "This is a test message", out)
 }
+
+func TestActionReadOnlySkipsLock(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	old := store.LockTimeout
+	store.LockTimeout = 200 * time.Millisecond
+	defer func() { store.LockTimeout = old }()
+
+	// the parent of the test is alive and holds the store
+	h, _ := os.Hostname()
+	d, err := json.Marshal(map[string]interface{}{"pid": os.Getppid(), "host": h, "time": time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ts.Store.Dir, store.LockName), d, 0600))
+
+	_, _, err = ExecuteCmd(createDescribeAccountCmd())
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createEditAccount(), "--conns", "10")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), store.ErrLockTimeout.Error())
+}
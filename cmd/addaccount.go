@@ -25,6 +25,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -41,11 +42,16 @@ func CreateAddAccountCmd() *cobra.Command {
 			if err := RunAction(cmd, args, &params); err != nil {
 				return err
 			}
+			if DryRunFlag {
+				return nil
+			}
 			return GetConfig().SetAccount(params.name)
 		},
 	}
 	cmd.Flags().StringVarP(&params.name, "name", "n", "", "account name")
 	cmd.Flags().StringVarP(&params.keyPath, "public-key", "k", "", "public key identifying the account")
+	cmd.Flags().Int64VarP(&params.conns.NumberValue, "conns", "", -1, "set maximum active connections for the account (-1 is unlimited)")
+	cmd.Flags().StringVarP(&params.data.Value, "data", "", "-1", "set maximum data in bytes for the account (-1 is unlimited)")
 	params.TimeParams.BindFlags(cmd)
 
 	return cmd
@@ -58,11 +64,13 @@ func init() {
 type AddAccountParams struct {
 	SignerParams
 	TimeParams
-	token    string
 	name     string
 	generate bool
 	keyPath  string
 	akp      nkeys.KeyPair
+	conns    NumberParams
+	data     DataParams
+	limits   jwt.OperatorLimits
 }
 
 func (p *AddAccountParams) SetDefaults(ctx ActionCtx) error {
@@ -126,13 +134,11 @@ func (p *AddAccountParams) PreInteractive(ctx ActionCtx) error {
 func (p *AddAccountParams) Load(ctx ActionCtx) error {
 	var err error
 	if p.generate {
+		// the key is stored when the account is added
 		p.akp, err = nkeys.CreateAccount()
 		if err != nil {
 			return err
 		}
-		if p.keyPath, err = ctx.StoreCtx().KeyStore.Store(p.akp); err != nil {
-			return err
-		}
 	} else {
 		p.akp, err = p.resolveAccountNKey(p.keyPath)
 		if err != nil {
@@ -213,6 +219,22 @@ func (p *AddAccountParams) Validate(ctx ActionCtx) error {
 		return err
 	}
 
+	if err = p.data.Valid(); err != nil {
+		return err
+	}
+	p.limits = jwt.OperatorLimits{
+		Subs:            -1,
+		Conn:            p.conns.NumberValue,
+		LeafNodeConn:    -1,
+		Imports:         -1,
+		Exports:         -1,
+		Payload:         -1,
+		WildcardExports: true,
+	}
+	if p.limits.Data, err = p.data.NumberValue(); err != nil {
+		return fmt.Errorf("error parsing %s: %s", "data", p.data.Value)
+	}
+
 	// the account doesn't exist, so insure self signed works
 	p.SignerParams.ForceManagedAccountKey(ctx, p.akp)
 	if err := p.SignerParams.Resolve(ctx); err != nil {
@@ -230,42 +252,65 @@ func (p *AddAccountParams) Validate(ctx ActionCtx) error {
 	if !ok {
 		return errors.New("invalid account signer")
 	}
-	return nil
+	return p.checkPolicy(ctx)
 }
 
-func (p *AddAccountParams) Run(ctx ActionCtx) (store.Status, error) {
-	var err error
+// checkPolicy checks the account against the store policy before its key is stored
+func (p *AddAccountParams) checkPolicy(ctx ActionCtx) error {
 	pk, err := p.akp.PublicKey()
 	if err != nil {
-		return nil, err
+		return err
 	}
-
 	ac := jwt.NewAccountClaims(pk)
 	ac.Name = p.name
+	ac.Limits = p.limits
 	if p.TimeParams.IsStartChanged() {
 		ac.NotBefore, _ = p.TimeParams.StartDate()
 	}
-
 	if p.TimeParams.IsExpiryChanged() {
 		ac.Expires, _ = p.TimeParams.ExpiryDate()
 	}
+	return checkPolicy(ctx, ac)
+}
 
-	signer := p.akp
-	if !ctx.StoreCtx().Store.IsManaged() || p.signerKP != nil {
-		signer = p.signerKP
-	}
-	p.token, err = ac.Encode(signer)
+func (p *AddAccountParams) Run(ctx ActionCtx) (store.Status, error) {
+	pk, err := p.akp.PublicKey()
 	if err != nil {
 		return nil, err
 	}
 
+	if p.generate {
+		if p.keyPath, err = ctx.StoreCtx().KeyStore.Store(p.akp); err != nil {
+			return nil, err
+		}
+	}
+	opts := &api.AccountOptions{Key: p.akp, Signer: p.signerKP, Limits: &p.limits}
+	if p.TimeParams.IsStartChanged() {
+		opts.NotBefore, _ = p.TimeParams.StartDate()
+	}
+	if p.TimeParams.IsExpiryChanged() {
+		opts.Expires, _ = p.TimeParams.ExpiryDate()
+	}
+	if ctx.StoreCtx().Store.IsManaged() && p.signerKP == nil {
+		opts.Signer = p.akp
+	}
+	rs, err := apiFor(ctx).CreateAccount(p.name, opts)
+
 	r := store.NewDetailedReport(false)
 	if p.generate {
 		r.AddOK("generated and stored account key %q", pk)
 	}
-	StoreAccountAndUpdateStatus(ctx, p.token, r)
+	if err := addAccountStatus(r, rs, err); err != nil {
+		return nil, err
+	}
 	if r.HasNoErrors() {
+		if ctx.CurrentCmd().Flags().Changed("conns") {
+			r.AddOK("set max connections to %d", p.limits.Conn)
+		}
+		if ctx.CurrentCmd().Flags().Changed("data") {
+			r.AddOK("set max data to %d bytes", p.limits.Data)
+		}
 		r.AddOK("added account %q", p.name)
 	}
-	return r, err
+	return r, nil
 }
@@ -24,6 +24,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -286,19 +287,17 @@ func (p *AddExportParams) Validate(ctx ActionCtx) error {
 }
 
 func (p *AddExportParams) Run(ctx ActionCtx) (store.Status, error) {
-	token, err := p.claim.Encode(p.signerKP)
-	if err != nil {
-		return nil, err
-	}
-
 	visibility := "public"
 	if p.export.TokenReq {
 		visibility = "private"
 	}
 	r := store.NewDetailedReport(false)
-	StoreAccountAndUpdateStatus(ctx, token, r)
+	rs, err := apiFor(ctx).AddExport(p.AccountContextParams.Name, &p.export, &api.SignOptions{Signer: p.signerKP})
+	if err := addAccountStatus(r, rs, err); err != nil {
+		return nil, err
+	}
 	if r.HasNoErrors() {
 		r.AddOK("added %s %s export %q", visibility, p.export.Type, p.export.Name)
 	}
-	return r, err
+	return r, nil
 }
@@ -23,6 +23,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -225,28 +226,34 @@ func (p *AddImportParams) generateToken(ctx ActionCtx, c *AccountExportChoice) e
 		return err
 	}
 
-	var ap GenerateActivationParams
-	ap.Name = c.Name
-	ap.claims = srcAC
-	ap.accountKey.publicKey = ctx.StoreCtx().Account.PublicKey
-	ap.export = *c.Selection
-	ap.subject = p.remote
-
 	// collect the possible signers
 	var signers []string
 	signers = append(signers, srcAC.Subject)
 	signers = append(signers, srcAC.SigningKeys...)
 
-	ap.SignerParams.SetPrompt(fmt.Sprintf("select the signing key for account %q [%s]", srcAC.Name, srcAC.Subject))
-	if err := ap.SelectFromSigners(ctx, signers); err != nil {
+	var sp SignerParams
+	sp.SetPrompt(fmt.Sprintf("select the signing key for account %q [%s]", srcAC.Name, srcAC.Subject))
+	if err := sp.SelectFromSigners(ctx, signers); err != nil {
 		return err
 	}
 
-	if _, err := ap.Run(ctx); err != nil {
+	// the activation is signed for the subject as entered
+	act := jwt.NewActivationClaims(ctx.StoreCtx().Account.PublicKey)
+	act.Name = p.remote
+	act.Activation.ImportSubject = jwt.Subject(p.remote)
+	act.Activation.ImportType = c.Selection.Type
+	spub, err := sp.signerKP.PublicKey()
+	if err != nil {
 		return err
 	}
-
-	p.token = []byte(ap.Token())
+	if srcAC.Subject != spub {
+		act.IssuerAccount = srcAC.Subject
+	}
+	token, err := act.Encode(sp.signerKP)
+	if err != nil {
+		return err
+	}
+	p.token = []byte(token)
 	return p.initFromActivation(ctx)
 }
 
@@ -507,37 +514,20 @@ func (p *AddImportParams) filter(kind jwt.ExportType, imports jwt.Imports) jwt.I
 }
 
 func (p *AddImportParams) Run(ctx ActionCtx) (store.Status, error) {
-	var err error
-	p.claim.Imports.Add(p.createImport())
-
-	token, err := p.claim.Encode(p.signerKP)
-	if err != nil {
-		return nil, err
-	}
-
-	ac, err := jwt.DecodeAccountClaims(token)
-	if err != nil {
-		return nil, err
-	}
-
-	var vr jwt.ValidationResults
-	ac.Validate(&vr)
-	errs := vr.Errors()
-	if len(errs) > 0 {
-		return nil, errs[0]
-	}
-
 	kind := jwt.Stream
 	if p.service {
 		kind = jwt.Service
 	}
 
+	rs, err := apiFor(ctx).AddImport(p.AccountContextParams.Name, p.createImport(), &api.SignOptions{Signer: p.signerKP})
 	r := store.NewDetailedReport(false)
-	StoreAccountAndUpdateStatus(ctx, token, r)
+	if err := addAccountStatus(r, rs, err); err != nil {
+		return nil, err
+	}
 	if r.HasNoErrors() {
 		r.AddOK("added %s import %q", kind, p.remote)
 	}
-	return r, err
+	return r, nil
 }
 
 func (p *AddImportParams) createImport() *jwt.Import {
@@ -23,6 +23,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -39,6 +40,9 @@ func createAddOperatorCmd() *cobra.Command {
 			if err := RunStoreLessAction(cmd, args, &params); err != nil {
 				return err
 			}
+			if DryRunFlag {
+				return nil
+			}
 			return GetConfig().SetOperator(params.name)
 		},
 	}
@@ -223,13 +227,8 @@ func (p *AddOperatorParams) Validate(ctx ActionCtx) error {
 	}
 
 	if p.generate {
-		p.signerKP, err = nkeys.CreateOperator()
-		if err != nil {
-			return err
-		}
-		if p.keyPath, err = ctx.StoreCtx().KeyStore.Store(p.signerKP); err != nil {
-			return err
-		}
+		// the key is generated and stored when the operator is created
+		return nil
 	}
 
 	if p.keyPath != "" {
@@ -247,67 +246,46 @@ func (p *AddOperatorParams) Validate(ctx ActionCtx) error {
 }
 
 func (p *AddOperatorParams) Run(_ ActionCtx) (store.Status, error) {
-	operator := &store.NamedKey{Name: p.name, KP: p.signerKP}
-	s, err := store.CreateStore(p.name, GetConfig().StoreRoot, operator)
-	if err != nil {
-		return nil, err
-	}
-
-	if p.token == "" {
-		ctx, err := s.GetContext()
-		if err != nil {
+	dir := GetConfig().StoreRoot
+	ks := store.NewKeyStore(p.name)
+	r := store.NewDetailedReport(false)
+	if p.token != "" {
+		_, rs, err := api.ImportOperator(dir, p.name, p.token, &ks)
+		if rs == nil {
 			return nil, err
 		}
-		if p.generate {
-			p.keyPath, err = ctx.KeyStore.Store(p.signerKP)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		if p.Start != "" || p.Expiry != "" {
-			oc, err := ctx.Store.ReadOperatorClaim()
-			if err != nil {
-				return nil, err
-			}
-			if p.Start != "" {
-				oc.NotBefore, err = p.TimeParams.StartDate()
-				if err != nil {
-					return nil, err
-				}
-			}
-			if p.Expiry != "" {
-				oc.Expires, err = p.TimeParams.ExpiryDate()
-				if err != nil {
-					return nil, err
-				}
-			}
-			p.token, err = oc.Encode(p.signerKP)
-			if err != nil {
-				return nil, err
-			}
+		r.Add(rs)
+		if err != nil {
+			r.AddFromError(err)
+			return r, err
 		}
+		r.AddOK("imported operator %q", p.name)
+		return r, nil
 	}
 
-	r := store.NewDetailedReport(false)
-	if p.generate && p.signerKP != nil {
-		pk, _ := p.signerKP.PublicKey()
-		r.AddOK("generated and stored operator key %q", pk)
+	var err error
+	opts := &api.OperatorOptions{Key: p.signerKP}
+	if p.Start != "" {
+		if opts.NotBefore, err = p.TimeParams.StartDate(); err != nil {
+			return nil, err
+		}
 	}
-	// not in an action ctx - storing on self-created store
-	rs, err := s.StoreClaim([]byte(p.token))
-	if rs != nil {
-		r.Add(rs)
+	if p.Expiry != "" {
+		if opts.Expires, err = p.TimeParams.ExpiryDate(); err != nil {
+			return nil, err
+		}
 	}
+	n, err := api.CreateOperator(dir, p.name, &ks, opts)
 	if err != nil {
-		r.AddFromError(err)
+		return nil, err
 	}
-	if r.HasNoErrors() {
-		verb := "added"
-		if p.jwtPath != "" {
-			verb = "imported"
+	if p.generate {
+		oc, err := n.Store().ReadOperatorClaim()
+		if err != nil {
+			return nil, err
 		}
-		r.AddOK("%s operator %q", verb, p.name)
+		r.AddOK("generated and stored operator key %q", oc.Subject)
 	}
-	return r, err
+	r.AddOK("added operator %q", p.name)
+	return r, nil
 }
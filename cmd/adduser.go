@@ -26,6 +26,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -242,7 +243,13 @@ func (p *AddUserParams) Validate(ctx ActionCtx) error {
 		return fmt.Errorf("the user %q already exists", p.userName)
 	}
 
-	return nil
+	// check the user against the store policy before its key is stored
+	uc, err := p.generateUserClaim(ctx)
+	if err != nil {
+		return err
+	}
+	uc.Issuer = ctx.StoreCtx().Account.PublicKey
+	return checkPolicy(ctx, uc)
 }
 
 func (p *AddUserParams) Run(ctx ActionCtx) (store.Status, error) {
@@ -251,20 +258,22 @@ func (p *AddUserParams) Run(ctx ActionCtx) (store.Status, error) {
 		return nil, err
 	}
 
-	token, err := uc.Encode(p.signerKP)
-	if err != nil {
+	n := apiFor(ctx)
+	// the generated key is stored below
+	opts := &api.UserOptions{
+		Key:         p.kp,
+		Signer:      p.signerKP,
+		NotBefore:   uc.NotBefore,
+		Expires:     uc.Expires,
+		Permissions: uc.Permissions,
+		Tags:        uc.Tags,
+		Src:         uc.Src,
+	}
+	if uc, err = n.CreateUser(p.AccountContextParams.Name, p.userName, opts); err != nil {
 		return nil, err
 	}
 
 	r := store.NewDetailedReport(false)
-	st, err := ctx.StoreCtx().Store.StoreClaim([]byte(token))
-	if err != nil {
-		r.AddFromError(err)
-		return st, err
-	}
-	if st != nil {
-		r.Add(st)
-	}
 
 	// store the key
 	if p.pkOrPath == "" {
@@ -281,16 +290,11 @@ func (p *AddUserParams) Run(ctx ActionCtx) (store.Status, error) {
 	// if they gave us a seed, it stored - try to get it
 	ks := ctx.StoreCtx().KeyStore
 	if ks.HasPrivateKey(pk) {
-		d, err := GenerateConfig(ctx.StoreCtx().Store, p.AccountContextParams.Name, p.userName, p.kp)
+		p.credsFilePath, err = n.StoreCreds(p.AccountContextParams.Name, p.userName, p.kp)
 		if err != nil {
-			r.AddError("unable to save creds: %v", err)
+			r.AddError("error storing creds: %v", err)
 		} else {
-			p.credsFilePath, err = ks.MaybeStoreUserCreds(p.AccountContextParams.Name, p.userName, d)
-			if err != nil {
-				r.AddError("error storing creds: %v", err)
-			} else {
-				r.AddOK("generated user creds file %#q", AbbrevHomePaths(p.credsFilePath))
-			}
+			r.AddOK("generated user creds file %#q", AbbrevHomePaths(p.credsFilePath))
 		}
 	} else {
 		r.AddOK("skipped generating creds file - user private key is not available")
@@ -348,6 +352,11 @@ func (p *AddUserParams) generateUserClaim(ctx ActionCtx) (*jwt.UserClaims, error
 	uc.Tags.Add(p.tags...)
 	sort.Strings(uc.Tags)
 
+	var srcList jwt.StringList
+	srcList.Add(p.src...)
+	sort.Strings(srcList)
+	uc.Src = strings.Join(srcList, ",")
+
 	return uc, nil
 }
 
@@ -0,0 +1,396 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+)
+
+// OperatorOptions are the options of a new operator
+type OperatorOptions struct {
+	// Key is the operator key, if nil a key is generated
+	Key       nkeys.KeyPair
+	NotBefore int64
+	Expires   int64
+}
+
+// AccountOptions are the options of a new account
+type AccountOptions struct {
+	// Key is the account key, if nil a key is generated
+	Key nkeys.KeyPair
+	// Signer is the operator key or signing key that signs the account,
+	// if nil a key in the keystore signs it
+	Signer    nkeys.KeyPair
+	NotBefore int64
+	Expires   int64
+	// Limits of the account, if nil the account is unlimited
+	Limits *jwt.OperatorLimits
+}
+
+// SignOptions are the options of operations that sign a JWT
+type SignOptions struct {
+	// Signer is the issuer key or signing key that signs the JWT,
+	// if nil a key in the keystore signs it
+	Signer nkeys.KeyPair
+}
+
+// ActivationOptions are the options of an activation token
+type ActivationOptions struct {
+	// Signer is the account key or signing key that signs the activation,
+	// if nil a key in the keystore signs it
+	Signer    nkeys.KeyPair
+	NotBefore int64
+	Expires   int64
+}
+
+func signerOf(opts *SignOptions) nkeys.KeyPair {
+	if opts == nil {
+		return nil
+	}
+	return opts.Signer
+}
+
+// CreateAccount adds an account to the operator. Generated keys are stored
+// in the keystore. The status has the responses of the account server of
+// a managed operator.
+func (n *NSC) CreateAccount(name string, opts *AccountOptions) (store.Status, error) {
+	if opts == nil {
+		opts = &AccountOptions{}
+	}
+	if name == "" {
+		return nil, errors.New("account name is required")
+	}
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if n.store.HasAccount(name) {
+		return nil, fmt.Errorf("account %q already exists", name)
+	}
+	kp, err := keyOrGenerate(opts.Key, nkeys.PrefixByteAccount)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := kp.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	ac := jwt.NewAccountClaims(pk)
+	ac.Name = name
+	ac.NotBefore = opts.NotBefore
+	ac.Expires = opts.Expires
+	if opts.Limits != nil {
+		ac.Limits = *opts.Limits
+	}
+	signer := opts.Signer
+	if signer == nil && opts.Key == nil && n.store.IsManaged() {
+		// the generated key isn't in the keystore yet
+		signer = kp
+	}
+	token, err := n.encodeAccount(ac, signer)
+	if err != nil {
+		return nil, err
+	}
+	// don't leave the generated key behind if the store rejects the account
+	if err := n.store.CheckClaim([]byte(token)); err != nil {
+		return nil, err
+	}
+	if opts.Key == nil {
+		if _, err := n.keystore.Store(kp); err != nil {
+			return nil, err
+		}
+	}
+	return n.storeToken(ac.Name, token)
+}
+
+// remoteSubject returns the subject of an import in the exporting account
+func remoteSubject(im *jwt.Import) string {
+	if im.Type == jwt.Service {
+		return string(im.To)
+	}
+	return string(im.Subject)
+}
+
+// AddExport adds the export to the account
+func (n *NSC) AddExport(account string, e *jwt.Export, opts *SignOptions) (store.Status, error) {
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ac, err := n.readAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range ac.Exports {
+		if v.Type == e.Type && v.Subject == e.Subject {
+			return nil, fmt.Errorf("account %q already exports %s %q", account, e.Type, e.Subject)
+		}
+	}
+	if e.Type == jwt.Service && e.ResponseType == "" {
+		e.ResponseType = jwt.ResponseTypeSingleton
+	}
+	ac.Exports.Add(e)
+	return n.storeAccount(ac, signerOf(opts))
+}
+
+// EditExport calls fn with the export of the account with the subject
+// and stores the account with the changes fn made. Changes that add
+// validation issues to the exports of the account are rejected.
+func (n *NSC) EditExport(account string, subject string, fn func(e *jwt.Export) error, opts *SignOptions) (store.Status, error) {
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ac, err := n.readAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	var export *jwt.Export
+	for _, v := range ac.Exports {
+		if string(v.Subject) == subject {
+			export = v
+			break
+		}
+	}
+	if export == nil {
+		return nil, fmt.Errorf("account %q doesn't export %q", account, subject)
+	}
+	var before jwt.ValidationResults
+	if err := ac.Exports.Validate(&before); err != nil {
+		return nil, err
+	}
+	if err := fn(export); err != nil {
+		return nil, err
+	}
+	if err := newExportIssue(ac.Exports, &before); err != nil {
+		return nil, err
+	}
+	return n.storeAccount(ac, signerOf(opts))
+}
+
+// newExportIssue returns the first issue of the exports that isn't one
+// of the issues the exports had before
+func newExportIssue(exports jwt.Exports, before *jwt.ValidationResults) error {
+	var vr jwt.ValidationResults
+	if err := exports.Validate(&vr); err != nil {
+		return err
+	}
+	for _, i := range vr.Issues {
+		known := false
+		for _, b := range before.Issues {
+			if b.Description == i.Description {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return errors.New(i.Error())
+		}
+	}
+	return nil
+}
+
+// AddImport adds the import to the account. Imports of private exports
+// have the activation token or its URL in the import.
+func (n *NSC) AddImport(account string, im *jwt.Import, opts *SignOptions) (store.Status, error) {
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ac, err := n.readAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	if im.Account == ac.Subject {
+		return nil, errors.New("export issuer is this account")
+	}
+	remote := remoteSubject(im)
+	for _, v := range ac.Imports {
+		if v.Type == im.Type && v.Account == im.Account && remoteSubject(v) == remote {
+			return nil, fmt.Errorf("account already imports %s %q from %s", im.Type, remote, im.Account)
+		}
+	}
+	ac.Imports.Add(im)
+	token, err := n.encodeAccount(ac, signerOf(opts))
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAccount(token); err != nil {
+		return nil, err
+	}
+	return n.storeToken(ac.Name, token)
+}
+
+// EditImport calls fn with the import of the subject from the source
+// account and stores the account with the changes fn made
+func (n *NSC) EditImport(account string, srcAccount string, subject string, fn func(im *jwt.Import) error, opts *SignOptions) (store.Status, error) {
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ac, err := n.readAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	var im *jwt.Import
+	for _, v := range ac.Imports {
+		if v.Account == srcAccount && remoteSubject(v) == subject {
+			im = v
+			break
+		}
+	}
+	if im == nil {
+		return nil, fmt.Errorf("account %q doesn't import %q from %s", account, subject, srcAccount)
+	}
+	if err := fn(im); err != nil {
+		return nil, err
+	}
+	token, err := n.encodeAccount(ac, signerOf(opts))
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAccount(token); err != nil {
+		return nil, err
+	}
+	return n.storeToken(ac.Name, token)
+}
+
+// privateExport returns the private export of the account that contains the subject
+func privateExport(ac *jwt.AccountClaims, subject string) (*jwt.Export, error) {
+	sub := jwt.Subject(subject)
+	var vr jwt.ValidationResults
+	sub.Validate(&vr)
+	if len(vr.Issues) > 0 {
+		return nil, errors.New(vr.Issues[0].Description)
+	}
+	for _, e := range ac.Exports {
+		if !e.TokenReq {
+			continue
+		}
+		if sub == e.Subject || sub.IsContainedIn(e.Subject) {
+			if e.Type == jwt.Service && sub.HasWildCards() {
+				return nil, fmt.Errorf("services cannot have wildcards %q", subject)
+			}
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("a private export for %q was not found in account %q", subject, ac.Name)
+}
+
+// findExport returns the export of the kind with the subject, or else
+// the first export of the kind that contains the subject
+func findExport(ac *jwt.AccountClaims, kind jwt.ExportType, subject string) *jwt.Export {
+	sub := jwt.Subject(subject)
+	for _, e := range ac.Exports {
+		if e.Type == kind && e.Subject == sub {
+			return e
+		}
+	}
+	for _, e := range ac.Exports {
+		if e.Type == kind && sub.IsContainedIn(e.Subject) {
+			return e
+		}
+	}
+	return nil
+}
+
+// GenerateActivation returns an activation token for the target account
+// to import the subject from a private export of the account
+func (n *NSC) GenerateActivation(account string, subject string, target string, opts *ActivationOptions) (string, error) {
+	if opts == nil {
+		opts = &ActivationOptions{}
+	}
+	if !store.IsPublicKey(nkeys.PrefixByteAccount, target) {
+		return "", fmt.Errorf("%q is not an account public key", target)
+	}
+	unlock, err := n.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	ac, err := n.readAccount(account)
+	if err != nil {
+		return "", err
+	}
+	e, err := privateExport(ac, subject)
+	if err != nil {
+		return "", err
+	}
+	kp, err := n.signer(opts.Signer, append([]string{ac.Subject}, ac.SigningKeys...)...)
+	if err != nil {
+		return "", err
+	}
+	spk, err := kp.PublicKey()
+	if err != nil {
+		return "", err
+	}
+
+	act := jwt.NewActivationClaims(target)
+	act.NotBefore = opts.NotBefore
+	act.Expires = opts.Expires
+	act.Name = subject
+	act.Activation.ImportSubject = jwt.Subject(subject)
+	act.Activation.ImportType = e.Type
+	if spk != ac.Subject {
+		act.IssuerAccount = ac.Subject
+	}
+	return act.Encode(kp)
+}
+
+// RevokeActivation revokes the activations of the export with the subject
+// issued to the target account before the time, if at is zero before now
+func (n *NSC) RevokeActivation(account string, subject string, kind jwt.ExportType, target string, at time.Time, opts *SignOptions) (store.Status, error) {
+	if !store.IsPublicKey(nkeys.PrefixByteAccount, target) {
+		return nil, fmt.Errorf("%q is not an account public key", target)
+	}
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ac, err := n.readAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	export := findExport(ac, kind, subject)
+	if export == nil {
+		return nil, fmt.Errorf("account %q doesn't have a %s export for %q", account, kind, subject)
+	}
+	if at.IsZero() {
+		export.Revoke(target)
+	} else {
+		export.RevokeAt(target, at)
+	}
+	return n.storeAccount(ac, signerOf(opts))
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package api performs the operations of nsc on a store and a keystore
+// without the command line. Programs embedding nsc create an NSC with
+// New, or CreateOperator for a new operator, and call its methods instead
+// of running the nsc commands. The methods of an NSC are safe for
+// concurrent use, operations on a store are serialized within the process
+// even if they are made by different NSC values, and other processes are
+// kept out by the lock file of the store while it is modified.
+package api
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+)
+
+// NSC performs operations on an operator store. Keys are read from
+// and stored in the keystore.
+type NSC struct {
+	store    *store.Store
+	keystore *store.KeyStore
+}
+
+// New returns an NSC for the store and the keystore
+func New(s *store.Store, ks *store.KeyStore) *NSC {
+	return &NSC{store: s, keystore: ks}
+}
+
+// CreateOperator creates the store of a new operator in dir, a generated
+// operator key is stored in the keystore
+func CreateOperator(dir string, name string, ks *store.KeyStore, opts *OperatorOptions) (*NSC, error) {
+	if opts == nil {
+		opts = &OperatorOptions{}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("operator name is required")
+	}
+	kp, err := keyOrGenerate(opts.Key, nkeys.PrefixByteOperator)
+	if err != nil {
+		return nil, err
+	}
+	s, err := store.CreateStore(name, dir, &store.NamedKey{Name: name, KP: kp})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Key == nil {
+		if _, err := ks.Store(kp); err != nil {
+			return nil, err
+		}
+	}
+	if opts.NotBefore != 0 || opts.Expires != 0 {
+		oc, err := s.ReadOperatorClaim()
+		if err != nil {
+			return nil, err
+		}
+		oc.NotBefore = opts.NotBefore
+		oc.Expires = opts.Expires
+		token, err := oc.Encode(kp)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.StoreClaim([]byte(token)); err != nil {
+			return nil, err
+		}
+	}
+	return New(s, ks), nil
+}
+
+// ImportOperator creates the store of an operator in dir from the operator
+// JWT, the operator is managed by its account server
+func ImportOperator(dir string, name string, token string, ks *store.KeyStore) (*NSC, store.Status, error) {
+	if _, err := jwt.DecodeOperatorClaims(token); err != nil {
+		return nil, nil, fmt.Errorf("error importing operator jwt: %v", err)
+	}
+	if name == "" {
+		return nil, nil, fmt.Errorf("operator name is required")
+	}
+	s, err := store.CreateStore(name, dir, &store.NamedKey{Name: name})
+	if err != nil {
+		return nil, nil, err
+	}
+	rs, err := s.StoreClaim([]byte(token))
+	if err != nil {
+		return nil, rs, err
+	}
+	return New(s, ks), rs, nil
+}
+
+// Store returns the store of the operator
+func (n *NSC) Store() *store.Store {
+	return n.store
+}
+
+// KeyStore returns the keystore
+func (n *NSC) KeyStore() *store.KeyStore {
+	return n.keystore
+}
+
+// stores serializes the operations on each store within the process, the
+// lock file of a store only keeps other processes out
+var stores = struct {
+	sync.Mutex
+	m map[interface{}]*sync.Mutex
+}{m: make(map[interface{}]*sync.Mutex)}
+
+// storeMutex returns the mutex of the store. Stores in a directory are
+// identified by the directory, so that all the NSC values and stores
+// loaded from it share the mutex.
+func storeMutex(s *store.Store) *sync.Mutex {
+	var key interface{} = s
+	if s.Dir != "" {
+		dir, err := filepath.Abs(s.Dir)
+		if err != nil {
+			dir = s.Dir
+		}
+		key = dir
+	}
+	stores.Lock()
+	defer stores.Unlock()
+	mu, ok := stores.m[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		stores.m[key] = mu
+	}
+	return mu
+}
+
+// lock serializes the operations on the store and prevents other
+// processes from modifying it until the returned function is called
+func (n *NSC) lock() (func(), error) {
+	mu := storeMutex(n.store)
+	mu.Lock()
+	if err := n.store.AcquireLock(); err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		_ = n.store.ReleaseLock()
+		mu.Unlock()
+	}, nil
+}
+
+// keyOrGenerate returns the key if set or generates a key of the kind
+func keyOrGenerate(kp nkeys.KeyPair, kind nkeys.PrefixByte) (nkeys.KeyPair, error) {
+	if kp == nil {
+		return nkeys.CreatePair(kind)
+	}
+	if !store.KeyPairTypeOk(kind, kp) {
+		return nil, fmt.Errorf("key is not a valid %s key", kindName(kind))
+	}
+	return kp, nil
+}
+
+func kindName(kind nkeys.PrefixByte) string {
+	switch kind {
+	case nkeys.PrefixByteOperator:
+		return "operator"
+	case nkeys.PrefixByteAccount:
+		return "account"
+	case nkeys.PrefixByteUser:
+		return "user"
+	}
+	return kind.String()
+}
+
+// signer returns the key that signs a JWT issued by one of the keys. The
+// key set in the options must be one of them, otherwise the first of the
+// keys with a seed in the keystore is used.
+func (n *NSC) signer(kp nkeys.KeyPair, keys ...string) (nkeys.KeyPair, error) {
+	if kp != nil {
+		pk, err := kp.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			if k == pk {
+				return kp, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not one of the signing keys %s", pk, strings.Join(keys, ", "))
+	}
+	for _, k := range keys {
+		if n.keystore.HasPrivateKey(k) {
+			return n.keystore.GetKeyPair(k)
+		}
+	}
+	return nil, fmt.Errorf("unable to resolve any of the following signing keys in the keystore: %s", strings.Join(keys, ", "))
+}
+
+// encodeAccount signs the account with the operator key or one of its
+// signing keys. Accounts of a managed operator are self-signed.
+func (n *NSC) encodeAccount(ac *jwt.AccountClaims, kp nkeys.KeyPair) (string, error) {
+	oc, err := n.store.ReadOperatorClaim()
+	if err != nil {
+		return "", err
+	}
+	keys := append([]string{oc.Subject}, oc.SigningKeys...)
+	if n.store.IsManaged() {
+		keys = append([]string{ac.Subject}, keys...)
+	}
+	signer, err := n.signer(kp, keys...)
+	if err != nil {
+		return "", err
+	}
+	return ac.Encode(signer)
+}
+
+// validateAccount validates the account as decoded, imports are
+// validated with their activations
+func validateAccount(token string) error {
+	ac, err := jwt.DecodeAccountClaims(token)
+	if err != nil {
+		return err
+	}
+	var vr jwt.ValidationResults
+	ac.Validate(&vr)
+	if errs := vr.Errors(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// storeAccount signs and stores the account
+func (n *NSC) storeAccount(ac *jwt.AccountClaims, signer nkeys.KeyPair) (store.Status, error) {
+	token, err := n.encodeAccount(ac, signer)
+	if err != nil {
+		return nil, err
+	}
+	return n.storeToken(ac.Name, token)
+}
+
+// storeToken stores the account JWT. The status has the responses
+// of the account server of a managed operator.
+func (n *NSC) storeToken(name string, token string) (store.Status, error) {
+	rs, err := n.store.StoreClaim([]byte(token))
+	if err != nil {
+		return rs, err
+	}
+	if rs != nil && rs.Code() == store.ERR {
+		return rs, fmt.Errorf("error storing account %q: %s", name, rs.Message())
+	}
+	return rs, nil
+}
+
+// readAccount reads the account, accounts that don't exist are an error
+func (n *NSC) readAccount(name string) (*jwt.AccountClaims, error) {
+	if !n.store.HasAccount(name) {
+		return nil, store.NewAccountNotExistErr(name)
+	}
+	return n.store.ReadAccountClaim(name)
+}
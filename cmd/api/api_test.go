@@ -0,0 +1,395 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestOperator(t *testing.T, name string) (*NSC, func()) {
+	dir, err := ioutil.TempDir("", "api_test")
+	require.NoError(t, err)
+	ks := &store.KeyStore{Env: name, Dir: filepath.Join(dir, "keys")}
+	n, err := CreateOperator(filepath.Join(dir, "stores"), name, ks, nil)
+	require.NoError(t, err)
+	return n, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func Test_CreateOperator(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+
+	oc, err := n.Store().ReadOperatorClaim()
+	require.NoError(t, err)
+	require.Equal(t, "O", oc.Name)
+	require.True(t, n.KeyStore().HasPrivateKey(oc.Subject))
+	require.True(t, strings.HasPrefix(n.KeyStore().GetKeyPath(oc.Subject), n.KeyStore().Dir))
+}
+
+func Test_CreateAccountAndUser(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+
+	_, err := n.CreateAccount("A", nil)
+	require.NoError(t, err)
+	_, err = n.CreateAccount("A", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `account "A" already exists`)
+
+	ac, err := n.Store().ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.True(t, n.KeyStore().HasPrivateKey(ac.Subject))
+
+	opts := &UserOptions{Tags: []string{"a"}}
+	opts.Permissions.Pub.Allow.Add("foo.>")
+	uc, err := n.CreateUser("A", "U", opts)
+	require.NoError(t, err)
+	require.Equal(t, ac.Subject, uc.Issuer)
+	require.Empty(t, uc.IssuerAccount)
+
+	uc, err = n.Store().ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo.>"}, []string(uc.Pub.Allow))
+	require.Equal(t, []string{"a"}, []string(uc.Tags))
+
+	d, err := n.Creds("A", "U")
+	require.NoError(t, err)
+	kp, err := jwt.ParseDecoratedNKey(d)
+	require.NoError(t, err)
+	pk, err := kp.PublicKey()
+	require.NoError(t, err)
+	require.Equal(t, uc.Subject, pk)
+
+	fp, err := n.StoreCreds("A", "U", nil)
+	require.NoError(t, err)
+	require.FileExists(t, fp)
+
+	_, err = n.CreateUser("B", "U", nil)
+	require.Error(t, err)
+}
+
+func Test_CreateRejectedKeepsNoKeys(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+
+	_, err := n.CreateAccount("A", nil)
+	require.NoError(t, err)
+	keys, err := n.KeyStore().AllKeys()
+	require.NoError(t, err)
+
+	n.Store().Check = func(data []byte) *store.Report {
+		r := store.NewDetailedReport(false)
+		r.Label = "rejected"
+		r.AddError("no more entities")
+		return r
+	}
+	_, err = n.CreateAccount("B", nil)
+	require.Error(t, err)
+	_, ok := err.(*store.RejectedError)
+	require.True(t, ok)
+	_, err = n.CreateUser("A", "U", nil)
+	require.Error(t, err)
+	_, ok = err.(*store.RejectedError)
+	require.True(t, ok)
+
+	require.False(t, n.Store().HasAccount("B"))
+	after, err := n.KeyStore().AllKeys()
+	require.NoError(t, err)
+	require.ElementsMatch(t, keys, after)
+}
+
+func Test_CreateAccountWithLimits(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+
+	limits := jwt.OperatorLimits{Subs: -1, Conn: 10, LeafNodeConn: -1, Imports: -1, Exports: -1, Data: -1, Payload: -1}
+	_, err := n.CreateAccount("A", &AccountOptions{Limits: &limits})
+	require.NoError(t, err)
+	ac, err := n.Store().ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), ac.Limits.Conn)
+	require.Equal(t, int64(-1), ac.Limits.Data)
+}
+
+func Test_CreateUserWithSigningKey(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+
+	_, err := n.CreateAccount("A", nil)
+	require.NoError(t, err)
+	sk, err := nkeys.CreateAccount()
+	require.NoError(t, err)
+	spk, err := sk.PublicKey()
+	require.NoError(t, err)
+
+	// the signing key must be a signing key of the account
+	_, err = n.CreateUser("A", "U", &UserOptions{Signer: sk})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not one of the signing keys")
+
+	ac, err := n.Store().ReadAccountClaim("A")
+	require.NoError(t, err)
+	ac.SigningKeys.Add(spk)
+	_, err = n.storeAccount(ac, nil)
+	require.NoError(t, err)
+
+	uc, err := n.CreateUser("A", "U", &UserOptions{Signer: sk})
+	require.NoError(t, err)
+	require.Equal(t, spk, uc.Issuer)
+	require.Equal(t, ac.Subject, uc.IssuerAccount)
+}
+
+func Test_PrivateImport(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+
+	_, err := n.CreateAccount("A", nil)
+	require.NoError(t, err)
+	_, err = n.CreateAccount("B", nil)
+	require.NoError(t, err)
+	a, err := n.Store().ReadAccountClaim("A")
+	require.NoError(t, err)
+	b, err := n.Store().ReadAccountClaim("B")
+	require.NoError(t, err)
+
+	_, err = n.AddExport("A", &jwt.Export{Subject: "q.>", Type: jwt.Service, TokenReq: true}, nil)
+	require.NoError(t, err)
+	_, err = n.AddExport("A", &jwt.Export{Subject: "q.>", Type: jwt.Service}, nil)
+	require.Error(t, err)
+
+	_, err = n.GenerateActivation("A", "x.y", b.Subject, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `a private export for "x.y" was not found in account "A"`)
+
+	token, err := n.GenerateActivation("A", "q.b", b.Subject, nil)
+	require.NoError(t, err)
+	act, err := jwt.DecodeActivationClaims(token)
+	require.NoError(t, err)
+	require.Equal(t, a.Subject, act.Issuer)
+	require.Equal(t, b.Subject, act.Subject)
+	require.Equal(t, jwt.Subject("q.b"), act.ImportSubject)
+
+	// services swap the local and remote subjects
+	im := &jwt.Import{Account: a.Subject, Subject: "q", To: "q.b", Type: jwt.Service, Token: token}
+	_, err = n.AddImport("B", im, nil)
+	require.NoError(t, err)
+	_, err = n.AddImport("B", &jwt.Import{Account: a.Subject, Subject: "q2", To: "q.b", Type: jwt.Service, Token: token}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "account already imports")
+
+	_, err = n.EditImport("B", a.Subject, "q.b", func(im *jwt.Import) error {
+		im.Name = "query"
+		return nil
+	}, nil)
+	require.NoError(t, err)
+	b, err = n.Store().ReadAccountClaim("B")
+	require.NoError(t, err)
+	require.Len(t, b.Imports, 1)
+	require.Equal(t, "query", b.Imports[0].Name)
+
+	_, err = n.EditExport("A", "q.>", func(e *jwt.Export) error {
+		e.Name = "queries"
+		return nil
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = n.RevokeActivation("A", "q.>", jwt.Service, b.Subject, time.Time{}, nil)
+	require.NoError(t, err)
+	a, err = n.Store().ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, "queries", a.Exports[0].Name)
+	require.Contains(t, a.Exports[0].Revocations, b.Subject)
+}
+
+func Test_RevokeUser(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+
+	_, err := n.CreateAccount("A", nil)
+	require.NoError(t, err)
+	uc, err := n.CreateUser("A", "U", nil)
+	require.NoError(t, err)
+
+	at := time.Now().Add(time.Minute)
+	_, err = n.RevokeUser("A", "U", at, nil)
+	require.NoError(t, err)
+	ac, err := n.Store().ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.True(t, ac.IsRevokedAt(uc.Subject, time.Now()))
+}
+
+func Test_MemResolverConfig(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+
+	_, err := n.MemResolverConfig("")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `operator "O" has no accounts`)
+
+	_, err = n.CreateAccount("A", nil)
+	require.NoError(t, err)
+	_, err = n.CreateAccount("SYS", nil)
+	require.NoError(t, err)
+	sys, err := n.Store().ReadAccountClaim("SYS")
+	require.NoError(t, err)
+
+	d, err := n.MemResolverConfig("SYS")
+	require.NoError(t, err)
+	conf := string(d)
+	require.Contains(t, conf, `// Operator "O"`)
+	require.Contains(t, conf, "resolver: MEMORY")
+	require.Contains(t, conf, fmt.Sprintf("system_account: %s", sys.Subject))
+	require.Contains(t, conf, `// Account "A"`)
+	require.Contains(t, conf, `// Account "SYS"`)
+}
+
+func Test_ConcurrentUse(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+	_, err := n.CreateAccount("A", nil)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_, err := n.CreateUser("A", fmt.Sprintf("U%d", i), nil)
+			errs <- err
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, err := n.AddExport("A", &jwt.Export{Subject: jwt.Subject(fmt.Sprintf("s%d", i)), Type: jwt.Stream}, nil)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	users, err := n.Store().ListEntries(store.Accounts, "A", store.Users)
+	require.NoError(t, err)
+	require.Len(t, users, 10)
+	// no read-modify-write cycle lost an export
+	ac, err := n.Store().ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Len(t, ac.Exports, 10)
+}
+
+func Test_ConcurrentUseOfLoadedStores(t *testing.T) {
+	n, done := createTestOperator(t, "O")
+	defer done()
+	_, err := n.CreateAccount("A", nil)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, 30)
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every caller loads the store like the commands do
+			s, err := store.LoadStore(n.Store().Dir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			<-start
+			_, err = New(s, n.KeyStore()).AddExport("A", &jwt.Export{Subject: jwt.Subject(fmt.Sprintf("s%d", i)), Type: jwt.Stream}, nil)
+			errs <- err
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	ac, err := n.Store().ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Len(t, ac.Exports, 30)
+}
+
+func Test_CreateOperatorWithKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ks := &store.KeyStore{Env: "O", Dir: filepath.Join(dir, "keys")}
+	kp, err := nkeys.CreateOperator()
+	require.NoError(t, err)
+	n, err := CreateOperator(filepath.Join(dir, "stores"), "O", ks, &OperatorOptions{Key: kp})
+	require.NoError(t, err)
+
+	pk, err := kp.PublicKey()
+	require.NoError(t, err)
+	oc, err := n.Store().ReadOperatorClaim()
+	require.NoError(t, err)
+	require.Equal(t, pk, oc.Subject)
+	// keys set in the options are not stored
+	require.False(t, ks.HasPrivateKey(pk))
+
+	_, err = CreateOperator(filepath.Join(dir, "stores"), "O", ks, nil)
+	require.Error(t, err)
+	keys, err := ks.AllKeys()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
+func Test_ImportOperator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ks := &store.KeyStore{Env: "O", Dir: filepath.Join(dir, "keys")}
+	kp, err := nkeys.CreateOperator()
+	require.NoError(t, err)
+	pk, err := kp.PublicKey()
+	require.NoError(t, err)
+	oc := jwt.NewOperatorClaims(pk)
+	oc.Name = "O"
+	oc.AccountServerURL = "http://localhost:9090/jwt/v1"
+	token, err := oc.Encode(kp)
+	require.NoError(t, err)
+
+	_, _, err = ImportOperator(filepath.Join(dir, "stores"), "O", "garbage", ks)
+	require.Error(t, err)
+
+	n, _, err := ImportOperator(filepath.Join(dir, "stores"), "X", token, ks)
+	require.NoError(t, err)
+	require.True(t, n.Store().IsManaged())
+	require.Equal(t, "X", n.Store().GetName())
+	oc, err = n.Store().ReadOperatorClaim()
+	require.NoError(t, err)
+	require.Equal(t, pk, oc.Subject)
+}
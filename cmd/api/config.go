@@ -0,0 +1,164 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+)
+
+// ConfigBuilder generates a server configuration from JWTs
+type ConfigBuilder interface {
+	Add(rawClaim []byte) error
+	Generate() ([]byte, error)
+}
+
+// ServerConfig adds the operator, account and user JWTs of the store to
+// the builder and returns the configuration it generates
+func (n *NSC) ServerConfig(b ConfigBuilder) ([]byte, error) {
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	s := n.store
+	op, err := s.Read(store.JwtName(s.GetName()))
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Add(op); err != nil {
+		return nil, err
+	}
+
+	names, err := s.ListSubContainers(store.Accounts)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("operator %q has no accounts", s.GetName())
+	}
+	for _, a := range names {
+		d, err := s.Read(store.Accounts, a, store.JwtName(a))
+		if err != nil {
+			return nil, err
+		}
+		if err := b.Add(d); err != nil {
+			return nil, err
+		}
+		users, err := s.ListEntries(store.Accounts, a, store.Users)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			d, err := s.Read(store.Accounts, a, store.Users, store.JwtName(u))
+			if err != nil {
+				return nil, err
+			}
+			if err := b.Add(d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.Generate()
+}
+
+type memResolverBuilder struct {
+	operator   string
+	accounts   []string
+	sysAccount string
+}
+
+func (b *memResolverBuilder) Add(rawClaim []byte) error {
+	gc, err := jwt.DecodeGeneric(string(rawClaim))
+	if err != nil {
+		return err
+	}
+	switch gc.Type {
+	case jwt.OperatorClaim:
+		b.operator = string(rawClaim)
+	case jwt.AccountClaim:
+		b.accounts = append(b.accounts, string(rawClaim))
+	}
+	return nil
+}
+
+func (b *memResolverBuilder) Generate() ([]byte, error) {
+	return MemResolverConfig(b.operator, b.accounts, b.sysAccount)
+}
+
+// MemResolverConfig returns the configuration of a server resolving
+// the accounts of the store from memory. The system account is the
+// name of an account or empty.
+func (n *NSC) MemResolverConfig(systemAccount string) ([]byte, error) {
+	b := &memResolverBuilder{}
+	if systemAccount != "" {
+		ac, err := n.store.ReadAccountClaim(systemAccount)
+		if err != nil {
+			return nil, fmt.Errorf("error reading account %q: %v", systemAccount, err)
+		}
+		b.sysAccount = ac.Subject
+	}
+	return n.ServerConfig(b)
+}
+
+// MemResolverConfig returns the configuration of a server with the operator
+// JWT that preloads the account JWTs. The system account is a public key
+// or empty.
+func MemResolverConfig(operator string, accounts []string, sysAccount string) ([]byte, error) {
+	if operator == "" {
+		return nil, errors.New("operator is not set")
+	}
+	oc, err := jwt.DecodeOperatorClaims(operator)
+	if err != nil {
+		return nil, err
+	}
+	claims := make(map[string]*jwt.AccountClaims)
+	tokens := make(map[string]string)
+	var keys []string
+	for _, t := range accounts {
+		ac, err := jwt.DecodeAccountClaims(t)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := claims[ac.Subject]; !ok {
+			keys = append(keys, ac.Subject)
+		}
+		claims[ac.Subject] = ac
+		tokens[ac.Subject] = t
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("// Operator %q\n", oc.Name))
+	buf.WriteString(fmt.Sprintf("operator: %s\n\n", operator))
+	if sysAccount != "" {
+		buf.WriteString(fmt.Sprintf("system_account: %s\n\n", sysAccount))
+	}
+	buf.WriteString("resolver: MEMORY\n\n")
+	buf.WriteString("resolver_preload: {\n")
+	for _, k := range keys {
+		buf.WriteString(fmt.Sprintf("  // Account %q\n", claims[k].Name))
+		buf.WriteString(fmt.Sprintf("  %s: %s\n\n", k, tokens[k]))
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+)
+
+// UserOptions are the options of a new user
+type UserOptions struct {
+	// Key is the user key, if nil a key is generated
+	Key nkeys.KeyPair
+	// Signer is the account key or signing key that signs the user,
+	// if nil a key in the keystore signs it
+	Signer      nkeys.KeyPair
+	NotBefore   int64
+	Expires     int64
+	Permissions jwt.Permissions
+	Tags        []string
+	// Src is a comma separated list of the networks the user can connect from
+	Src string
+}
+
+// CreateUser adds a user to the account and returns its claims. Generated
+// keys are stored in the keystore, StoreCreds stores the creds file.
+func (n *NSC) CreateUser(account string, name string, opts *UserOptions) (*jwt.UserClaims, error) {
+	if opts == nil {
+		opts = &UserOptions{}
+	}
+	if name == "" {
+		return nil, errors.New("user name is required")
+	}
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ac, err := n.readAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	if n.store.Has(store.Accounts, account, store.Users, store.JwtName(name)) {
+		return nil, fmt.Errorf("user %q already exists in account %q", name, account)
+	}
+	kp, err := keyOrGenerate(opts.Key, nkeys.PrefixByteUser)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := kp.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	signer, err := n.signer(opts.Signer, append([]string{ac.Subject}, ac.SigningKeys...)...)
+	if err != nil {
+		return nil, err
+	}
+	spk, err := signer.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	uc := jwt.NewUserClaims(pk)
+	uc.Name = name
+	uc.NotBefore = opts.NotBefore
+	uc.Expires = opts.Expires
+	uc.Permissions = opts.Permissions
+	uc.Tags.Add(opts.Tags...)
+	uc.Src = opts.Src
+	if spk != ac.Subject {
+		uc.IssuerAccount = ac.Subject
+	}
+	token, err := uc.Encode(signer)
+	if err != nil {
+		return nil, err
+	}
+	// don't leave the generated key behind if the store rejects the user
+	if err := n.store.CheckClaim([]byte(token)); err != nil {
+		return nil, err
+	}
+	if opts.Key == nil {
+		if _, err := n.keystore.Store(kp); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := n.store.StoreClaim([]byte(token)); err != nil {
+		return nil, err
+	}
+	return uc, nil
+}
+
+// StoreCreds stores the creds file of the user in the keystore and returns
+// its path. The key is the user key, if nil it is read from the keystore.
+func (n *NSC) StoreCreds(account string, user string, kp nkeys.KeyPair) (string, error) {
+	unlock, err := n.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	d, err := n.creds(account, user, kp)
+	if err != nil {
+		return "", err
+	}
+	return n.keystore.MaybeStoreUserCreds(account, user, d)
+}
+
+// Creds returns the creds file of the user, the seed of the user must be
+// in the keystore
+func (n *NSC) Creds(account string, user string) ([]byte, error) {
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return n.creds(account, user, nil)
+}
+
+func (n *NSC) creds(account string, user string, kp nkeys.KeyPair) ([]byte, error) {
+	if !n.store.Has(store.Accounts, account, store.Users, store.JwtName(user)) {
+		return nil, fmt.Errorf("user %q not found in %q", user, account)
+	}
+	d, err := n.store.Read(store.Accounts, account, store.Users, store.JwtName(user))
+	if err != nil {
+		return nil, err
+	}
+	if kp == nil {
+		uc, err := jwt.DecodeUserClaims(string(d))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding user %q in %q jwt: %v", user, account, err)
+		}
+		if kp, err = n.keystore.GetKeyPair(uc.Subject); err != nil {
+			return nil, err
+		}
+		if kp == nil {
+			return nil, fmt.Errorf("the seed of user %q is not in the keystore", user)
+		}
+	}
+	return FormatCreds(d, kp)
+}
+
+// FormatCreds returns the creds file for the user JWT and key
+func FormatCreds(userJwt []byte, kp nkeys.KeyPair) ([]byte, error) {
+	if kp == nil {
+		return nil, errors.New("userKey was not provided")
+	}
+	seed, err := kp.Seed()
+	if err != nil {
+		return nil, fmt.Errorf("error getting seed: %v", err)
+	}
+	return jwt.FormatUserConfig(string(userJwt), seed)
+}
+
+// RevokeUser revokes the credentials of the user issued before the time,
+// if at is zero before now
+func (n *NSC) RevokeUser(account string, user string, at time.Time, opts *SignOptions) (store.Status, error) {
+	unlock, err := n.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ac, err := n.readAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	uc, err := n.store.ReadUserClaim(account, user)
+	if err != nil {
+		return nil, err
+	}
+	if at.IsZero() {
+		ac.Revoke(uc.Subject)
+	} else {
+		ac.RevokeAt(uc.Subject, at)
+	}
+	return n.storeAccount(ac, signerOf(opts))
+}
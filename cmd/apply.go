@@ -0,0 +1,946 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cli "github.com/nats-io/cliprompts/v2"
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	GetRootCmd().AddCommand(createApplyCmd())
+}
+
+func createApplyCmd() *cobra.Command {
+	var params ApplyParams
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply a topology of accounts, exports, imports and users to the operator",
+		Long: `Apply a topology of accounts, exports, imports and users to the operator
+
+The topology is a YAML or JSON file that declares the accounts of the
+operator. The plan to create, edit and delete accounts, exports, imports
+and users is shown and confirmed before it is executed with the add, edit
+and delete commands. Accounts, exports, imports and users that are not
+declared are deleted.
+
+Imports reference accounts in the topology by name, or accounts outside
+of the operator by public key. Imports of private exports in the topology
+are activated with a generated activation token.
+
+accounts:
+  - name: A
+    limits:
+      conns: 10
+    exports:
+      - subject: q.>
+        type: service
+        private: true
+  - name: B
+    imports:
+      - account: A
+        subject: q.a
+        type: service
+    users:
+      - name: u
+        allow_pub: [q.a]
+        deny_sub: [">"]
+        limits:
+          payload: 1024
+          src: [192.168.1.0/24]`,
+		Example: `nsc apply -f topology.yaml
+nsc apply -f topology.yaml --dry-run
+nsc apply -f topology.yaml --yes`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().StringVarP(&params.file, "file", "f", "", "topology file")
+	cmd.Flags().BoolVarP(&params.yes, "yes", "y", false, "apply the plan without asking for confirmation")
+	return cmd
+}
+
+type ApplyParams struct {
+	file     string
+	yes      bool
+	topology *Topology
+	accounts map[string]*jwt.AccountClaims
+	users    map[string]map[string]*jwt.UserClaims
+	names    map[string]string
+	steps    []*applyStep
+	// keyKind is the kind of the key specified with --private-key
+	keyKind nkeys.PrefixByte
+}
+
+// applyStep is a change in the plan, it is made by running one or more commands
+type applyStep struct {
+	op     string
+	what   string
+	fields []string
+	run    func(ctx ActionCtx) error
+}
+
+func (s *applyStep) String() string {
+	m := s.what
+	if len(s.fields) > 0 {
+		m = fmt.Sprintf("%s - %s", m, strings.Join(s.fields, ", "))
+	}
+	return m
+}
+
+func (s *applyStep) done() string {
+	switch s.op {
+	case store.CreateOp:
+		return "created " + s.what
+	case store.RemoveOp:
+		return "deleted " + s.what
+	}
+	return "edited " + s.what
+}
+
+func (p *ApplyParams) SetDefaults(ctx ActionCtx) error {
+	if p.file == "" {
+		ctx.CurrentCmd().SilenceUsage = false
+		return errors.New("a topology file is required")
+	}
+	return nil
+}
+
+func (p *ApplyParams) PreInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *ApplyParams) Load(ctx ActionCtx) error {
+	d, err := Read(p.file)
+	if err != nil {
+		return err
+	}
+	if p.topology, err = ReadTopology(d); err != nil {
+		return err
+	}
+
+	s := ctx.StoreCtx().Store
+	accounts, err := s.ListSubContainers(store.Accounts)
+	if err != nil {
+		return err
+	}
+	p.accounts = make(map[string]*jwt.AccountClaims)
+	p.users = make(map[string]map[string]*jwt.UserClaims)
+	p.names = make(map[string]string)
+	for _, n := range accounts {
+		ac, err := s.ReadAccountClaim(n)
+		if err != nil {
+			return err
+		}
+		p.accounts[n] = ac
+		p.names[ac.Subject] = n
+		users, err := s.ListEntries(store.Accounts, n, store.Users)
+		if err != nil {
+			return err
+		}
+		p.users[n] = make(map[string]*jwt.UserClaims)
+		for _, u := range users {
+			uc, err := s.ReadUserClaim(n, u)
+			if err != nil {
+				return err
+			}
+			p.users[n][u] = uc
+		}
+	}
+	return nil
+}
+
+func (p *ApplyParams) PostInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *ApplyParams) Validate(ctx ActionCtx) error {
+	if err := p.topology.Validate(ctx.StoreCtx().Store.GetName()); err != nil {
+		return err
+	}
+	if KeyPathFlag != "" {
+		kp, err := store.ResolveKey(KeyPathFlag)
+		if err != nil {
+			return err
+		}
+		if kp == nil {
+			return fmt.Errorf("%#q - no such file or directory", AbbrevHomePaths(KeyPathFlag))
+		}
+		if p.keyKind, err = store.KeyType(kp); err != nil {
+			return err
+		}
+		if p.keyKind != nkeys.PrefixByteOperator && p.keyKind != nkeys.PrefixByteAccount {
+			return errors.New("the private key must be an operator or account key")
+		}
+	}
+	p.plan()
+	return p.checkPolicy(ctx)
+}
+
+// checkPolicy rehearses the plan in a dry run and checks the JWTs it
+// stores against the store policy, so that the store is not left half
+// changed when the policy rejects a JWT stored by a later step. When
+// apply is a dry run, the commands check the JWTs as they store them.
+func (p *ApplyParams) checkPolicy(ctx ActionCtx) error {
+	pol, err := storePolicy(ctx)
+	if err != nil || pol == nil || len(p.steps) == 0 || store.ActiveDryRun() != nil {
+		return err
+	}
+	d, restore, err := startDryRun(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	defer restore()
+	config := GetConfig()
+	dryRun, override, account := DryRunFlag, PolicyOverrideFlag, config.Account
+	DryRunFlag, PolicyOverrideFlag = true, true
+	defer func() {
+		DryRunFlag, PolicyOverrideFlag, config.Account = dryRun, override, account
+	}()
+
+	for _, step := range p.steps {
+		if err := step.run(ctx); err != nil {
+			return fmt.Errorf("unable to apply %s: %v", step.String(), err)
+		}
+	}
+	changes, err := d.Changes()
+	if err != nil {
+		return err
+	}
+	s := ctx.StoreCtx().Store
+	r := pol.newReport()
+	for _, c := range changes {
+		if (c.Op != store.CreateOp && c.Op != store.UpdateOp) || !store.IsJwtName(c.Path) {
+			continue
+		}
+		data, err := d.ReadFile(c.Path)
+		if err != nil {
+			return err
+		}
+		if cr := pol.Check(s, data); cr != nil {
+			r.Add(cr.Details...)
+		}
+	}
+	if r.HasErrors() {
+		return &store.RejectedError{Report: r}
+	}
+	return nil
+}
+
+func (p *ApplyParams) add(op string, what string, fields []string, run func(ctx ActionCtx) error) {
+	p.steps = append(p.steps, &applyStep{op: op, what: what, fields: fields, run: run})
+}
+
+func (p *ApplyParams) accountRef(ref string) string {
+	if n, ok := p.names[ref]; ok && p.topology.Account(n) != nil {
+		return n
+	}
+	return ref
+}
+
+// importedSubjects returns the remote and local subjects of an import,
+// services store the local subject as the subject
+func importedSubjects(im *jwt.Import) (string, string) {
+	if im.IsService() {
+		return string(im.To), string(im.Subject)
+	}
+	return string(im.Subject), string(im.To)
+}
+
+// declaredImport returns the import in the topology matching the import in the account
+func (p *ApplyParams) declaredImport(a *TopologyAccount, im *jwt.Import) *TopologyImport {
+	remote, _ := importedSubjects(im)
+	for _, v := range a.Imports {
+		kind, _ := exportType(v.Type)
+		if kind == im.Type && v.Subject == remote && p.accountRef(v.Account) == p.accountRef(im.Account) {
+			return v
+		}
+	}
+	return nil
+}
+
+// importChanges returns the fields of the import that differ from the topology
+func (p *ApplyParams) importChanges(im *jwt.Import, v *TopologyImport) []string {
+	var fields []string
+	remote, local := importedSubjects(im)
+	name := v.Name
+	if name == "" {
+		name = remote
+	}
+	if name != im.Name {
+		fields = append(fields, "name")
+	}
+	to := v.LocalSubject
+	if to == "" && im.IsService() {
+		to = remote
+	}
+	if to != local {
+		fields = append(fields, "local_subject")
+	}
+	if p.privateImport(v) != (im.Token != "") {
+		fields = append(fields, "activation")
+	}
+	return fields
+}
+
+// privateImport returns true if the import requires an activation
+func (p *ApplyParams) privateImport(v *TopologyImport) bool {
+	src := p.topology.Account(p.accountRef(v.Account))
+	if src == nil {
+		return false
+	}
+	e := src.Export(v.Subject)
+	return e != nil && e.Private
+}
+
+func exportName(e *TopologyExport) string {
+	if e.Name == "" {
+		return e.Subject
+	}
+	return e.Name
+}
+
+func responseType(e *TopologyExport) string {
+	if e.ResponseType == "" {
+		return string(jwt.ResponseTypeSingleton)
+	}
+	return e.ResponseType
+}
+
+// exportChanges returns the fields of the export that differ from the topology
+func exportChanges(x *jwt.Export, e *TopologyExport) []string {
+	var fields []string
+	kind, _ := exportType(e.Type)
+	if exportName(e) != x.Name {
+		fields = append(fields, "name")
+	}
+	if kind != x.Type {
+		fields = append(fields, "type")
+	}
+	if e.Private != x.TokenReq {
+		fields = append(fields, "private")
+	}
+	if kind == jwt.Service {
+		rt := string(x.ResponseType)
+		if rt == "" {
+			rt = string(jwt.ResponseTypeSingleton)
+		}
+		if responseType(e) != rt {
+			fields = append(fields, "response_type")
+		}
+	}
+	return fields
+}
+
+func exportFlags(e *TopologyExport) []string {
+	kind, _ := exportType(e.Type)
+	args := []string{"--name", exportName(e), fmt.Sprintf("--private=%t", e.Private), fmt.Sprintf("--service=%t", kind == jwt.Service)}
+	if kind == jwt.Service {
+		args = append(args, "--response-type", responseType(e))
+	}
+	return args
+}
+
+func limit(v *int64) int64 {
+	if v == nil {
+		return jwt.NoLimit
+	}
+	return *v
+}
+
+// accountEdits returns the flags to edit the account to match the
+// topology and the fields that change, new accounts have no claim
+func accountEdits(a *TopologyAccount, ac *jwt.AccountClaims) ([]string, []string) {
+	var args []string
+	var fields []string
+	current := jwt.OperatorLimits{
+		Subs:            jwt.NoLimit,
+		Conn:            jwt.NoLimit,
+		LeafNodeConn:    jwt.NoLimit,
+		Imports:         jwt.NoLimit,
+		Exports:         jwt.NoLimit,
+		Data:            jwt.NoLimit,
+		Payload:         jwt.NoLimit,
+		WildcardExports: true,
+	}
+	var keys, tags []string
+	if ac != nil {
+		current = ac.Limits
+		keys = ac.SigningKeys
+		tags = ac.Tags
+	}
+	l := a.Limits
+	for _, v := range []struct {
+		field   string
+		flag    string
+		current int64
+		value   int64
+	}{
+		{"conns", "conns", current.Conn, limit(l.Conns)},
+		{"leaf_conns", "leaf-conns", current.LeafNodeConn, limit(l.LeafConns)},
+		{"subscriptions", "subscriptions", current.Subs, limit(l.Subscriptions)},
+		{"imports", "imports", current.Imports, limit(l.Imports)},
+		{"exports", "exports", current.Exports, limit(l.Exports)},
+		{"data", "data", current.Data, limit(l.Data)},
+		{"payload", "payload", current.Payload, limit(l.Payload)},
+	} {
+		if v.current != v.value {
+			args = append(args, fmt.Sprintf("--%s=%d", v.flag, v.value))
+			fields = append(fields, "limits."+v.field)
+		}
+	}
+	wc := l.WildcardExports == nil || *l.WildcardExports
+	if wc != current.WildcardExports {
+		fields = append(fields, "limits.wildcard_exports")
+	}
+
+	add, rm := listChanges(keys, a.SigningKeys)
+	for _, k := range add {
+		args = append(args, "--sk", k)
+	}
+	for _, k := range rm {
+		args = append(args, "--rm-sk", k)
+	}
+	if len(add) > 0 || len(rm) > 0 {
+		fields = append(fields, "signing_keys")
+	}
+	add, rm = listChanges(tags, lowerCase(a.Tags))
+	for _, t := range add {
+		args = append(args, "--tag", t)
+	}
+	for _, t := range rm {
+		args = append(args, "--rm-tag", t)
+	}
+	if len(add) > 0 || len(rm) > 0 {
+		fields = append(fields, "tags")
+	}
+	// edit account resets wildcard exports unless it is set
+	if len(fields) > 0 {
+		args = append(args, fmt.Sprintf("--wildcard-exports=%t", wc))
+	}
+	return args, fields
+}
+
+func lowerCase(a []string) []string {
+	var v []string
+	for _, s := range a {
+		v = append(v, strings.ToLower(s))
+	}
+	return v
+}
+
+func contains(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// listChanges returns the values to add to and remove from the current list
+func listChanges(current []string, declared []string) ([]string, []string) {
+	var add, rm []string
+	for _, v := range declared {
+		if !contains(current, v) && !contains(add, v) {
+			add = append(add, v)
+		}
+	}
+	for _, v := range current {
+		if !contains(declared, v) {
+			rm = append(rm, v)
+		}
+	}
+	return add, rm
+}
+
+var userPermissionFlags = []string{"allow-pub", "allow-sub", "deny-pub", "deny-sub"}
+var userPermissionFields = []string{"allow_pub", "allow_sub", "deny_pub", "deny_sub"}
+
+func (u *TopologyUser) permissions() [][]string {
+	return [][]string{u.AllowPub, u.AllowSub, u.DenyPub, u.DenySub}
+}
+
+// userEdits returns the flags to remove permissions and the flags to add
+// permissions, tags and limits so that the user matches the topology. Edit user
+// removes a subject from all permissions after adding, so the removals
+// are a separate edit.
+func userEdits(u *TopologyUser, uc *jwt.UserClaims) ([]string, []string, []string) {
+	var rmArgs, args, fields []string
+	current := [][]string{uc.Pub.Allow, uc.Sub.Allow, uc.Pub.Deny, uc.Sub.Deny}
+	declared := u.permissions()
+	var removed []string
+	for i := range current {
+		_, rm := listChanges(current[i], declared[i])
+		for _, s := range rm {
+			if !contains(removed, s) {
+				removed = append(removed, s)
+				rmArgs = append(rmArgs, "--rm", s)
+			}
+		}
+	}
+	for i := range current {
+		var kept []string
+		for _, s := range current[i] {
+			if !contains(removed, s) {
+				kept = append(kept, s)
+			}
+		}
+		add, _ := listChanges(kept, declared[i])
+		for _, s := range add {
+			args = append(args, "--"+userPermissionFlags[i], s)
+		}
+		if a, r := listChanges(current[i], declared[i]); len(a) > 0 || len(r) > 0 {
+			fields = append(fields, userPermissionFields[i])
+		}
+	}
+	add, rm := listChanges(uc.Tags, lowerCase(u.Tags))
+	for _, t := range add {
+		args = append(args, "--tag", t)
+	}
+	for _, t := range rm {
+		args = append(args, "--rm-tag", t)
+	}
+	if len(add) > 0 || len(rm) > 0 {
+		fields = append(fields, "tags")
+	}
+	largs, lfields := userLimitEdits(u, uc)
+	return rmArgs, append(args, largs...), append(fields, lfields...)
+}
+
+// userLimitEdits returns the edit user flags and the changed fields to
+// make the limits of the user match the topology, uc is nil for new users.
+func userLimitEdits(u *TopologyUser, uc *jwt.UserClaims) ([]string, []string) {
+	var args, fields []string
+	payload := int64(jwt.NoLimit)
+	var src []string
+	if uc != nil {
+		if uc.Limits.Payload > 0 {
+			payload = uc.Limits.Payload
+		}
+		for _, v := range strings.Split(uc.Src, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				src = append(src, v)
+			}
+		}
+	}
+	l := u.Limits
+	if v := limit(l.Payload); v != payload {
+		args = append(args, fmt.Sprintf("--payload=%d", v))
+		fields = append(fields, "limits.payload")
+	}
+	add, rm := listChanges(src, l.Src)
+	for _, v := range add {
+		args = append(args, "--source-network", v)
+	}
+	for _, v := range rm {
+		args = append(args, "--rm-source-network", v)
+	}
+	if len(add) > 0 || len(rm) > 0 {
+		fields = append(fields, "limits.src")
+	}
+	return args, fields
+}
+
+func userFlags(u *TopologyUser) []string {
+	var args []string
+	for i, l := range u.permissions() {
+		for _, s := range l {
+			args = append(args, "--"+userPermissionFlags[i], s)
+		}
+	}
+	for _, t := range u.Tags {
+		args = append(args, "--tag", t)
+	}
+	return args
+}
+
+func sortedAccounts(m map[string]*jwt.AccountClaims) []string {
+	var names []string
+	for n := range m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// plan computes the steps to make the store match the topology. Deletions
+// come first so that subjects and names can be reused, then accounts are
+// created and edited before the exports, imports and users they contain.
+func (p *ApplyParams) plan() {
+	t := p.topology
+	p.steps = nil
+
+	for _, n := range sortedAccounts(p.accounts) {
+		a := t.Account(n)
+		if a == nil {
+			continue
+		}
+		for _, im := range p.accounts[n].Imports {
+			if p.declaredImport(a, im) != nil {
+				continue
+			}
+			remote, _ := importedSubjects(im)
+			p.add(store.RemoveOp, fmt.Sprintf("%s import %q in account %q", im.Type.String(), remote, n), nil, p.deleteImport(n, im))
+		}
+		var users []string
+		for u := range p.users[n] {
+			users = append(users, u)
+		}
+		sort.Strings(users)
+		for _, u := range users {
+			if a.User(u) == nil {
+				p.add(store.RemoveOp, fmt.Sprintf("user %q in account %q", u, n), nil,
+					p.runCmdStep(nkeys.PrefixByteAccount, createDeleteUserCmd, "--account", n, "--name", u))
+			}
+		}
+		for _, x := range p.accounts[n].Exports {
+			if a.declaredExport(x) == nil {
+				p.add(store.RemoveOp, fmt.Sprintf("%s export %q in account %q", x.Type.String(), x.Subject, n), nil,
+					p.runCmdStep(nkeys.PrefixByteAccount, createDeleteExportCmd, "--account", n, "--subject", string(x.Subject)))
+			}
+		}
+	}
+	for _, n := range sortedAccounts(p.accounts) {
+		if t.Account(n) == nil {
+			p.add(store.RemoveOp, fmt.Sprintf("account %q", n), nil,
+				p.runCmdStep(nkeys.PrefixByteOperator, createDeleteAccountCmd, "--name", n, "--force"))
+		}
+	}
+
+	for _, a := range t.Accounts {
+		ac, ok := p.accounts[a.Name]
+		if !ok {
+			args, _ := accountEdits(a, nil)
+			run := p.runCmdStep(nkeys.PrefixByteOperator, CreateAddAccountCmd, "--name", a.Name)
+			if len(args) > 0 {
+				run = runSteps(run, p.runCmdStep(nkeys.PrefixByteOperator, createEditAccount, append([]string{"--name", a.Name}, args...)...))
+			}
+			p.add(store.CreateOp, fmt.Sprintf("account %q", a.Name), nil, run)
+			continue
+		}
+		if args, fields := accountEdits(a, ac); len(fields) > 0 {
+			p.add(store.UpdateOp, fmt.Sprintf("account %q", a.Name), fields,
+				p.runCmdStep(nkeys.PrefixByteOperator, createEditAccount, append([]string{"--name", a.Name}, args...)...))
+		}
+	}
+
+	for _, a := range t.Accounts {
+		for _, e := range a.Exports {
+			what := fmt.Sprintf("%s export %q in account %q", e.kind().String(), e.Subject, a.Name)
+			x := p.currentExport(a.Name, e.Subject)
+			if x == nil {
+				p.add(store.CreateOp, what, nil,
+					p.runCmdStep(nkeys.PrefixByteAccount, createAddExportCmd, append([]string{"--account", a.Name, "--subject", e.Subject}, exportFlags(e)...)...))
+			} else if fields := exportChanges(x, e); len(fields) > 0 {
+				p.add(store.UpdateOp, what, fields,
+					p.runCmdStep(nkeys.PrefixByteAccount, createEditExportCmd, append([]string{"--account", a.Name, "--subject", e.Subject}, exportFlags(e)...)...))
+			}
+		}
+	}
+
+	for _, a := range t.Accounts {
+		for _, v := range a.Imports {
+			what := fmt.Sprintf("%s import %q in account %q", v.kind().String(), v.Subject, a.Name)
+			im := p.currentImport(a, v)
+			if im == nil {
+				p.add(store.CreateOp, what, nil, p.addImport(a.Name, v))
+			} else if fields := p.importChanges(im, v); len(fields) > 0 {
+				p.add(store.UpdateOp, what, fields, runSteps(p.deleteImport(a.Name, im), p.addImport(a.Name, v)))
+			}
+		}
+	}
+
+	for _, a := range t.Accounts {
+		for _, u := range a.Users {
+			what := fmt.Sprintf("user %q in account %q", u.Name, a.Name)
+			uc := p.users[a.Name][u.Name]
+			if uc == nil {
+				run := p.runCmdStep(nkeys.PrefixByteAccount, CreateAddUserCmd, append([]string{"--account", a.Name, "--name", u.Name}, userFlags(u)...)...)
+				if args, _ := userLimitEdits(u, nil); len(args) > 0 {
+					run = runSteps(run, p.runCmdStep(nkeys.PrefixByteAccount, createEditUserCmd, append([]string{"--account", a.Name, "--name", u.Name}, args...)...))
+				}
+				p.add(store.CreateOp, what, nil, run)
+				continue
+			}
+			rmArgs, args, fields := userEdits(u, uc)
+			if len(fields) == 0 {
+				continue
+			}
+			var runs []func(ctx ActionCtx) error
+			if len(rmArgs) > 0 {
+				runs = append(runs, p.runCmdStep(nkeys.PrefixByteAccount, createEditUserCmd, append([]string{"--account", a.Name, "--name", u.Name}, rmArgs...)...))
+			}
+			if len(args) > 0 {
+				runs = append(runs, p.runCmdStep(nkeys.PrefixByteAccount, createEditUserCmd, append([]string{"--account", a.Name, "--name", u.Name}, args...)...))
+			}
+			p.add(store.UpdateOp, what, fields, runSteps(runs...))
+		}
+	}
+}
+
+func (e *TopologyExport) kind() jwt.ExportType {
+	kind, _ := exportType(e.Type)
+	return kind
+}
+
+func (v *TopologyImport) kind() jwt.ExportType {
+	kind, _ := exportType(v.Type)
+	return kind
+}
+
+// User returns the user with the name, nil if the account doesn't declare it
+func (a *TopologyAccount) User(name string) *TopologyUser {
+	for _, u := range a.Users {
+		if u.Name == name {
+			return u
+		}
+	}
+	return nil
+}
+
+func (a *TopologyAccount) declaredExport(x *jwt.Export) *TopologyExport {
+	for _, e := range a.Exports {
+		if e.Subject == string(x.Subject) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (p *ApplyParams) currentExport(account string, subject string) *jwt.Export {
+	ac, ok := p.accounts[account]
+	if !ok {
+		return nil
+	}
+	for _, x := range ac.Exports {
+		if string(x.Subject) == subject {
+			return x
+		}
+	}
+	return nil
+}
+
+func (p *ApplyParams) currentImport(a *TopologyAccount, v *TopologyImport) *jwt.Import {
+	ac, ok := p.accounts[a.Name]
+	if !ok {
+		return nil
+	}
+	for _, im := range ac.Imports {
+		if p.declaredImport(a, im) == v {
+			return im
+		}
+	}
+	return nil
+}
+
+func (p *ApplyParams) deleteImport(account string, im *jwt.Import) func(ctx ActionCtx) error {
+	return p.runCmdStep(nkeys.PrefixByteAccount, createDeleteImportCmd, "--account", account, "--subject", string(im.Subject), "--src-account", im.Account)
+}
+
+// addImport imports from an account in the topology or outside of the operator.
+// The source account may be created by the plan, so its key is resolved when
+// the import is added.
+func (p *ApplyParams) addImport(account string, v *TopologyImport) func(ctx ActionCtx) error {
+	return func(ctx ActionCtx) error {
+		var args []string
+		if v.Name != "" {
+			args = append(args, "--name", v.Name)
+		}
+		if v.LocalSubject != "" {
+			args = append(args, "--local-subject", v.LocalSubject)
+		}
+		s := ctx.StoreCtx().Store
+		src := p.accountRef(v.Account)
+		srcKey := src
+		var srcAC *jwt.AccountClaims
+		if p.topology.Account(src) != nil {
+			var err error
+			if srcAC, err = s.ReadAccountClaim(src); err != nil {
+				return err
+			}
+			srcKey = srcAC.Subject
+		}
+		if srcAC == nil || !p.privateImport(v) {
+			args = append(args, "--src-account", srcKey, "--remote-subject", v.Subject)
+			if v.kind() == jwt.Service {
+				args = append(args, "--service")
+			}
+			return p.runCmd(nkeys.PrefixByteAccount, createAddImportCmd(), append([]string{"--account", account}, args...)...)
+		}
+		ac, err := s.ReadAccountClaim(account)
+		if err != nil {
+			return err
+		}
+		token, err := generateImportActivation(ctx, src, srcAC, ac.Subject, v.Subject)
+		if err != nil {
+			return err
+		}
+		dir, err := ioutil.TempDir("", "nsc_apply")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		fp := filepath.Join(dir, "activation.jwt")
+		// the token is only read by add import, it is not an output of the
+		// command so it is written even if this is a dry run
+		if err := ioutil.WriteFile(fp, []byte(token), 0600); err != nil {
+			return err
+		}
+		return p.runCmd(nkeys.PrefixByteAccount, createAddImportCmd(), append([]string{"--account", account, "--token", fp}, args...)...)
+	}
+}
+
+// generateImportActivation generates an activation for the target account
+// signed with the key of the source account or one of its signing keys
+func generateImportActivation(ctx ActionCtx, src string, srcAC *jwt.AccountClaims, target string, subject string) (string, error) {
+	ks := ctx.StoreCtx().KeyStore
+	var ap GenerateActivationParams
+	ap.Name = src
+	ap.claims = srcAC
+	ap.accountKey.publicKey = target
+	ap.subject = subject
+	for _, e := range srcAC.Exports {
+		if jwt.Subject(subject).IsContainedIn(e.Subject) {
+			ap.export = *e
+			break
+		}
+	}
+	for _, k := range append([]string{srcAC.Subject}, srcAC.SigningKeys...) {
+		if ks.HasPrivateKey(k) {
+			kp, err := ks.GetKeyPair(k)
+			if err != nil {
+				return "", err
+			}
+			ap.signerKP = kp
+			break
+		}
+	}
+	if ap.signerKP == nil {
+		return "", fmt.Errorf("unable to generate an activation for %q - the keystore doesn't have a key for account %q", subject, src)
+	}
+	if _, err := ap.Run(ctx); err != nil {
+		return "", err
+	}
+	return ap.Token(), nil
+}
+
+func runSteps(runs ...func(ctx ActionCtx) error) func(ctx ActionCtx) error {
+	return func(ctx ActionCtx) error {
+		for _, run := range runs {
+			if err := run(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (p *ApplyParams) runCmdStep(signer nkeys.PrefixByte, create func() *cobra.Command, args ...string) func(ctx ActionCtx) error {
+	return func(_ ActionCtx) error {
+		return p.runCmd(signer, create(), args...)
+	}
+}
+
+// runCmd runs the command as if it was invoked from the command line.
+// Commands run by another command don't prompt or print structured
+// output. The key specified for the outer command is used by the
+// commands signed by a key of its kind, other commands use the keystore.
+func (p *ApplyParams) runCmd(signer nkeys.PrefixByte, cmd *cobra.Command, args ...string) error {
+	interactive, keyPath, output := InteractiveFlag, KeyPathFlag, OutputFlag
+	InteractiveFlag, OutputFlag = false, TextOutput
+	if p.keyKind != signer {
+		KeyPathFlag = ""
+	}
+	defer func() {
+		InteractiveFlag, KeyPathFlag, OutputFlag = interactive, keyPath, output
+	}()
+
+	var buf bytes.Buffer
+	cmd.SetOutput(&buf)
+	cmd.SetArgs(append([]string{}, args...))
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	if _, err := cmd.ExecuteC(); err != nil {
+		return fmt.Errorf("%s %s: %v", cmd.Name(), strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+func (p *ApplyParams) printPlan(cmd *cobra.Command) {
+	counts := make(map[string]int)
+	for _, s := range p.steps {
+		counts[s.op]++
+	}
+	cmd.Printf("plan: %d to create, %d to edit, %d to delete\n", counts[store.CreateOp], counts[store.UpdateOp], counts[store.RemoveOp])
+	symbols := map[string]string{store.CreateOp: "+", store.UpdateOp: "~", store.RemoveOp: "-"}
+	for _, s := range p.steps {
+		cmd.Printf("  %s %s\n", symbols[s.op], s.String())
+	}
+}
+
+func (p *ApplyParams) Run(ctx ActionCtx) (store.Status, error) {
+	r := store.NewDetailedReport(true)
+	if len(p.steps) == 0 {
+		r.AddOK("no changes - operator %q matches the topology", ctx.StoreCtx().Store.GetName())
+		return r, nil
+	}
+	p.printPlan(ctx.CurrentCmd())
+	if !p.yes && !DryRunFlag {
+		if !canPrompt() {
+			return nil, errors.New("specify --yes to apply the plan")
+		}
+		ok, err := cli.Confirm("apply the plan", false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("apply cancelled")
+		}
+	}
+
+	// the commands change the current account
+	config := GetConfig()
+	account := config.Account
+	defer func() {
+		if config.Account != account {
+			_ = config.SetAccount(account)
+		}
+	}()
+	for _, s := range p.steps {
+		if err := s.run(ctx); err != nil {
+			r.AddError("unable to apply %s: %v", s.String(), err)
+			return r, err
+		}
+		r.AddOK(s.done())
+	}
+	return r, nil
+}
@@ -0,0 +1,291 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+const testTopology = `
+accounts:
+  - name: A
+    limits:
+      conns: 10
+    exports:
+      - subject: q.>
+        type: service
+        private: true
+      - subject: s.>
+  - name: B
+    tags: [Team]
+    imports:
+      - account: A
+        subject: q.b
+        type: service
+      - account: A
+        subject: s.>
+        local_subject: a
+    users:
+      - name: u
+        allow_pub: [q.b]
+        allow_sub: [a.>, _INBOX.>]
+        deny_sub: [a.secret]
+`
+
+func writeTopology(t *testing.T, ts *TestStore, topology string) string {
+	fp := filepath.Join(ts.Dir, "topology.yaml")
+	require.NoError(t, ioutil.WriteFile(fp, []byte(topology), 0600))
+	return fp
+}
+
+func Test_ApplyCreates(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	fp := writeTopology(t, ts, testTopology)
+	_, stderr, err := ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "plan: 7 to create, 0 to edit, 0 to delete")
+	require.Contains(t, stderr, `+ service import "q.b" in account "B"`)
+
+	a, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), a.Limits.Conn)
+	require.Len(t, a.Exports, 2)
+
+	b, err := ts.Store.ReadAccountClaim("B")
+	require.NoError(t, err)
+	require.Equal(t, jwt.TagList{"team"}, b.Tags)
+	require.Len(t, b.Imports, 2)
+	for _, im := range b.Imports {
+		require.Equal(t, a.Subject, im.Account)
+		if im.IsService() {
+			// the private export was activated
+			require.NotEmpty(t, im.Token)
+			ac, err := jwt.DecodeActivationClaims(im.Token)
+			require.NoError(t, err)
+			require.Equal(t, b.Subject, ac.Subject)
+			require.Equal(t, jwt.Subject("q.b"), ac.ImportSubject)
+		} else {
+			require.Empty(t, im.Token)
+			require.Equal(t, jwt.Subject("a"), im.To)
+		}
+	}
+
+	uc, err := ts.Store.ReadUserClaim("B", "u")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"q.b"}, uc.Pub.Allow)
+	require.ElementsMatch(t, []string{"a.>", "_INBOX.>"}, uc.Sub.Allow)
+	require.ElementsMatch(t, []string{"a.secret"}, uc.Sub.Deny)
+
+	// applying again doesn't change anything
+	_, stderr, err = ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `no changes - operator "O" matches the topology`)
+}
+
+func Test_ApplyEditsAndDeletes(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	fp := writeTopology(t, ts, testTopology)
+	_, _, err := ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+	require.NoError(t, err)
+	ts.AddAccount(t, "C")
+	ts.AddUser(t, "B", "v")
+
+	writeTopology(t, ts, `
+accounts:
+  - name: A
+    exports:
+      - subject: q.>
+        type: service
+  - name: B
+    imports:
+      - account: A
+        subject: q.b
+        type: service
+    users:
+      - name: u
+        allow_pub: [q.b, a.secret]
+        deny_sub: [a.secret]
+`)
+	_, stderr, err := ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `- stream import "s.>" in account "B"`)
+	require.Contains(t, stderr, `- user "v" in account "B"`)
+	require.Contains(t, stderr, `- stream export "s.>" in account "A"`)
+	require.Contains(t, stderr, `- account "C"`)
+	require.Contains(t, stderr, `~ account "A" - limits.conns`)
+	require.Contains(t, stderr, `~ account "B" - tags`)
+	require.Contains(t, stderr, `~ service export "q.>" in account "A" - private`)
+	require.Contains(t, stderr, `~ service import "q.b" in account "B" - activation`)
+	require.Contains(t, stderr, `~ user "u" in account "B" - allow_pub, allow_sub`)
+
+	require.False(t, ts.Store.HasAccount("C"))
+	require.False(t, ts.Store.Has("accounts", "B", "users", "v.jwt"))
+
+	a, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), a.Limits.Conn)
+	require.True(t, a.Limits.WildcardExports)
+	require.Len(t, a.Exports, 1)
+	require.False(t, a.Exports[0].TokenReq)
+
+	b, err := ts.Store.ReadAccountClaim("B")
+	require.NoError(t, err)
+	require.Empty(t, b.Tags)
+	require.Len(t, b.Imports, 1)
+	require.Empty(t, b.Imports[0].Token)
+
+	uc, err := ts.Store.ReadUserClaim("B", "u")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"q.b", "a.secret"}, uc.Pub.Allow)
+	require.Empty(t, uc.Sub.Allow)
+	require.ElementsMatch(t, []string{"a.secret"}, uc.Sub.Deny)
+
+	_, stderr, err = ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "no changes")
+}
+
+func Test_ApplyUserLimits(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	fp := writeTopology(t, ts, `
+accounts:
+  - name: A
+    users:
+      - name: u
+        limits:
+          payload: 1024
+          src: [192.168.1.0/24]
+`)
+	_, _, err := ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+	require.NoError(t, err)
+	uc, err := ts.Store.ReadUserClaim("A", "u")
+	require.NoError(t, err)
+	require.Equal(t, int64(1024), uc.Limits.Payload)
+	require.Equal(t, "192.168.1.0/24", uc.Src)
+
+	writeTopology(t, ts, `
+accounts:
+  - name: A
+    users:
+      - name: u
+        limits:
+          src: [10.0.0.0/8]
+`)
+	_, stderr, err := ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `~ user "u" in account "A" - limits.payload, limits.src`)
+	uc, err = ts.Store.ReadUserClaim("A", "u")
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), uc.Limits.Payload)
+	require.Equal(t, "10.0.0.0/8", uc.Src)
+
+	_, stderr, err = ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "no changes")
+}
+
+func Test_ApplyWithPrivateKey(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	ts.AddUser(t, "A", "u")
+	seed, err := ts.GetAccountKey(t, "A").Seed()
+	require.NoError(t, err)
+	kp := filepath.Join(ts.Dir, "account.nk")
+	require.NoError(t, ioutil.WriteFile(kp, seed, 0600))
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.NoError(t, ts.KeyStore.Remove(ac.Subject))
+
+	fp := writeTopology(t, ts, `
+accounts:
+  - name: A
+    users:
+      - name: u
+        allow_pub: [q]
+      - name: v
+`)
+	_, _, err = ExecuteCmd(HoistRootFlags(createApplyCmd()), "--file", fp, "--yes", "-K", kp)
+	require.NoError(t, err)
+	uc, err := ts.Store.ReadUserClaim("A", "u")
+	require.NoError(t, err)
+	require.Equal(t, []string{"q"}, []string(uc.Pub.Allow))
+	require.True(t, ts.Store.Has("accounts", "A", "users", "v.jwt"))
+}
+
+func Test_ApplyDryRun(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	fp := writeTopology(t, ts, testTopology)
+	_, stderr, err := ExecuteCmd(HoistRootFlags(createApplyCmd()), "--file", fp, "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `+ account "A"`)
+	require.Contains(t, stderr, "[dry-run] the following changes were not made")
+	require.Contains(t, stderr, filepath.Join("accounts", "B", "users", "u.jwt"))
+	require.False(t, ts.Store.HasAccount("A"))
+	require.False(t, ts.Store.HasAccount("B"))
+}
+
+func Test_ApplyRequiresConfirmation(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	fp := writeTopology(t, ts, testTopology)
+	_, _, err := ExecuteCmd(createApplyCmd(), "--file", fp)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "specify --yes to apply the plan")
+	require.False(t, ts.Store.HasAccount("A"))
+}
+
+func Test_ApplyValidation(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	tests := []struct {
+		topology string
+		err      string
+	}{
+		{"accounts:\n  - name: A\n  - name: a\n", `account "a" is declared more than once`},
+		{"operator: X\n", `the topology is for operator "X"`},
+		{"accounts:\n  - name: A\n    limit: {}\n", "error parsing the topology"},
+		{"accounts:\n  - name: A\n    imports:\n      - account: B\n        subject: q\n", `account "B" is not declared in the topology`},
+		{"accounts:\n  - name: A\n    exports:\n      - subject: q\n  - name: B\n    imports:\n      - account: A\n        subject: q\n        type: service\n", `account "A" exports "q" as a stream`},
+		{"accounts:\n  - name: A\n    exports:\n      - subject: q\n  - name: B\n    imports:\n      - account: A\n        subject: r\n", `account "A" doesn't export "r"`},
+		{"accounts:\n  - name: A\n    exports:\n      - subject: q\n        type: queue\n", `type "queue" must be stream or service`},
+		{"accounts:\n  - name: A\n    signing_keys: [foo]\n", `signing key "foo" is not an account public key`},
+		{"accounts:\n  - name: A\n    users:\n      - name: u\n        limits:\n          src: [foo]\n", `invalid cidr "foo"`},
+		{"accounts:\n  - name: A\n    users:\n      - name: u\n        limits:\n          payload: -2\n", `user "u": payload limit -2 is invalid`},
+	}
+	for _, tc := range tests {
+		fp := writeTopology(t, ts, tc.topology)
+		_, _, err := ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+		require.Error(t, err, tc.topology)
+		require.Contains(t, err.Error(), tc.err)
+	}
+}
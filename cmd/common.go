@@ -36,6 +36,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -97,6 +98,10 @@ func Write(fp string, data []byte) error {
 	var err error
 	var f *os.File
 
+	if d := store.ActiveDryRun(); d != nil && !IsStdOut(fp) {
+		return d.AddFile(fp, data)
+	}
+
 	f, err = GetOutput(fp)
 	if err != nil {
 		return err
@@ -117,6 +122,14 @@ func Write(fp string, data []byte) error {
 	return nil
 }
 
+// WriteFile writes the file, or records it if a dry run is active
+func WriteFile(fp string, data []byte, perm os.FileMode) error {
+	if d := store.ActiveDryRun(); d != nil {
+		return d.AddFile(fp, data)
+	}
+	return ioutil.WriteFile(fp, data, perm)
+}
+
 func ReadJson(fp string, v interface{}) error {
 	data, err := Read(fp)
 	if err != nil {
@@ -280,6 +293,10 @@ func SeedNKeyValidatorMatching(kind nkeys.PrefixByte, pukeys []string) cli.Valid
 			return fmt.Errorf("%q is not an expected signing key %v", v, pukeys)
 		}
 
+		// external signers never reveal the seed
+		if store.IsExternalKey(nk) {
+			return nil
+		}
 		_, err = nk.Seed()
 		if err != nil {
 			return err
@@ -346,6 +363,10 @@ func IsValidDir(dir string) error {
 func MaybeMakeDir(dir string) error {
 	fi, err := os.Stat(dir)
 	if err != nil && os.IsNotExist(err) {
+		// files written by a dry run are only recorded
+		if store.ActiveDryRun() != nil {
+			return nil
+		}
 		if err := os.MkdirAll(dir, 0700); err != nil {
 			return fmt.Errorf("error creating %#q: %v", dir, err)
 		}
@@ -535,13 +556,34 @@ func DiffAccountLimits(a *jwt.AccountClaims, b *jwt.AccountClaims) store.Status
 	return r
 }
 
+// apiFor returns the API for the store and keystore of the action
+func apiFor(ctx ActionCtx) *api.NSC {
+	sc := ctx.StoreCtx()
+	return api.New(sc.Store, &sc.KeyStore)
+}
+
+// addAccountStatus adds the status of an API call storing an account to the
+// report. Errors without a status happened before the account was stored
+// and are returned.
+func addAccountStatus(status *store.Report, rs store.Status, err error) error {
+	if rs == nil {
+		return err
+	}
+	status.Add(rs)
+	if err != nil && rejectedReport(err) == nil {
+		status.AddFromError(err)
+	}
+	return nil
+}
+
 func StoreAccountAndUpdateStatus(ctx ActionCtx, token string, status *store.Report) {
 	rs, err := ctx.StoreCtx().Store.StoreClaim([]byte(token))
 	// the order of the messages benefits from adding the status first
 	if rs != nil {
 		status.Add(rs)
 	}
-	if err != nil {
+	// the status of a rejected JWT has the reasons
+	if err != nil && rejectedReport(err) == nil {
 		status.AddFromError(err)
 	}
 }
@@ -17,8 +17,6 @@ package cmd
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 	"sort"
 
@@ -122,7 +120,7 @@ func (c *ContextConfig) LoadStore(operatorName string) (*store.Store, error) {
 }
 
 func (c *ContextConfig) ListOperators() []string {
-	infos, err := ioutil.ReadDir(c.StoreRoot)
+	infos, err := store.ReadDir(c.StoreRoot)
 	if err != nil {
 		return nil
 	}
@@ -130,8 +128,7 @@ func (c *ContextConfig) ListOperators() []string {
 	for _, v := range infos {
 		name := store.SafeName(filepath.Base(v.Name()))
 		fp := filepath.Join(c.StoreRoot, name, store.NSCFile)
-		info, err := os.Stat(fp)
-		if err == nil && info != nil {
+		if store.Exists(fp) {
 			operators = append(operators, v.Name())
 		}
 	}
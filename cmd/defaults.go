@@ -236,6 +236,10 @@ func (d *ToolConfig) load() error {
 }
 
 func (d *ToolConfig) Save() error {
+	// a dry run doesn't change the context
+	if DryRunFlag {
+		return nil
+	}
 	d.SetDefaults()
 	return WriteJson(d.configFile(), d)
 }
@@ -18,7 +18,6 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"os"
 	"time"
 
 	cli "github.com/nats-io/cliprompts/v2"
@@ -44,8 +43,8 @@ nsc delete account -i
 
 	cmd.Flags().StringVarP(&params.AccountContextParams.Name, "name", "n", "", "name of account to delete")
 	cmd.Flags().BoolVarP(&params.revoke, "revoke", "R", true, "revoke users before deleting")
-	cmd.Flags().BoolVarP(&params.rmNkeys, "rm-nkey", "D", false, "delete user keys")
-	cmd.Flags().BoolVarP(&params.rmCreds, "rm-creds", "C", false, "delete users creds")
+	cmd.Flags().BoolVarP(&params.rmNkeys, "rm-nkey", "D", false, "move account and user keys to the trash")
+	cmd.Flags().BoolVarP(&params.rmCreds, "rm-creds", "C", false, "move users creds to the trash")
 	cmd.Flags().BoolVarP(&params.force, "force", "F", false, "managed accounts must supply --force")
 
 	return cmd
@@ -59,6 +58,7 @@ type DeleteAccountParams struct {
 	AccountContextParams
 	SignerParams
 	ac      *jwt.AccountClaims
+	token   []byte
 	force   bool
 	revoke  bool
 	rmCreds bool
@@ -103,6 +103,11 @@ func (p *DeleteAccountParams) Load(ctx ActionCtx) error {
 	if err != nil {
 		return err
 	}
+	// the trash keeps the account as it was before it was expired
+	p.token, err = s.ReadRawAccountClaim(p.AccountContextParams.Name)
+	if err != nil {
+		return err
+	}
 
 	p.users, err = s.ListEntries(store.Accounts, p.AccountContextParams.Name, store.Users)
 	if err != nil {
@@ -124,9 +129,9 @@ func (p *DeleteAccountParams) PostInteractive(ctx ActionCtx) error {
 		}
 	}
 
-	m := "deleting account or account nkey files cannot be undone - continue"
+	m := "the account and account nkeys are moved to the trash until purged - continue"
 	if len(p.users) > 0 {
-		m = "deleting accounts, users, nkeys or creds files cannot be undone - continue"
+		m = "the account, users, nkeys and creds files are moved to the trash until purged - continue"
 	}
 	ok, err := cli.Confirm(m, false)
 	if err != nil {
@@ -155,6 +160,7 @@ func (p *DeleteAccountParams) Run(ctx ActionCtx) (store.Status, error) {
 	r := store.NewReport(store.OK, "delete account")
 	r.Opt = store.DetailsOnly
 	s := ctx.StoreCtx().Store
+	e := s.NewTrashEntry(jwt.AccountClaim, p.AccountContextParams.Name, "")
 	for _, n := range p.users {
 		uc, err := s.ReadUserClaim(p.AccountContextParams.Name, n)
 		if err != nil {
@@ -172,35 +178,8 @@ func (p *DeleteAccountParams) Run(ctx ActionCtx) (store.Status, error) {
 				ru.AddOK("user is already revoked")
 			}
 		}
-		if err := s.Delete(store.Accounts, p.AccountContextParams.Name, store.Users, store.JwtName(n)); err != nil {
+		if err := trashUser(ctx, e, p.AccountContextParams.Name, n, uc, p.rmNkeys, p.rmCreds, ru); err != nil {
 			ru.AddFromError(err)
-		} else {
-			ru.AddOK("user deleted")
-		}
-
-		if p.rmNkeys {
-			if ctx.StoreCtx().KeyStore.HasPrivateKey(uc.Subject) {
-				if err := ctx.StoreCtx().KeyStore.Remove(uc.Subject); err != nil {
-					ru.AddFromError(err)
-				} else {
-					ru.AddOK("deleted private key")
-				}
-			} else {
-				ru.AddOK("private key is not stored")
-			}
-		}
-
-		if p.rmCreds {
-			fp := ctx.StoreCtx().KeyStore.GetUserCredsPath(p.AccountContextParams.Name, n)
-			if _, err := os.Stat(fp); os.IsNotExist(err) {
-				ru.AddOK("creds file is not stored")
-			} else {
-				if err := os.Remove(fp); err != nil {
-					ru.AddError("error deleting creds file %s: %v", fp, err)
-				} else {
-					ru.AddOK("removed creds file")
-				}
-			}
 		}
 	}
 
@@ -224,31 +203,18 @@ func (p *DeleteAccountParams) Run(ctx ActionCtx) (store.Status, error) {
 	r.AddOK("expired account %q", p.AccountContextParams.Name)
 
 	if p.rmNkeys {
-		// delete the account nkeys
+		// move the account nkeys to the trash
 		for _, sk := range p.ac.SigningKeys {
-			if ctx.StoreCtx().KeyStore.HasPrivateKey(sk) {
-				if err := ctx.StoreCtx().KeyStore.Remove(sk); err != nil {
-					r.AddFromError(err)
-				} else {
-					r.AddOK("deleted signing key %q", sk)
-				}
-			} else {
-				r.AddOK("signing key %q is not stored", sk)
-			}
-		}
-		if ctx.StoreCtx().KeyStore.HasPrivateKey(p.ac.Subject) {
-			if err := ctx.StoreCtx().KeyStore.Remove(p.ac.Subject); err != nil {
-				r.AddFromError(err)
-			} else {
-				r.AddOK("deleted privated key %q", p.ac.Subject)
-			}
-		} else {
-			r.AddOK("private key %q is not stored", p.ac.Subject)
+			trashKey(ctx, e, fmt.Sprintf("signing key %q", sk), sk, r)
 		}
+		trashKey(ctx, e, fmt.Sprintf("private key %q", p.ac.Subject), p.ac.Subject, r)
 	}
 
-	// delete the jwt
-	if err := s.Delete(store.Accounts, p.AccountContextParams.Name, store.JwtName(p.AccountContextParams.Name)); err != nil {
+	// move the jwt to the trash
+	fp := []string{store.Accounts, p.AccountContextParams.Name, store.JwtName(p.AccountContextParams.Name)}
+	if err := s.TrashFile(e, p.token, fp...); err != nil {
+		r.AddFromError(err)
+	} else if err := s.Delete(fp...); err != nil {
 		r.AddFromError(err)
 	} else {
 		r.AddOK("deleted account")
@@ -260,5 +226,11 @@ func (p *DeleteAccountParams) Run(ctx ActionCtx) (store.Status, error) {
 		r.AddOK("deleted account directory")
 	}
 
+	if err := s.SaveTrashEntry(e); err != nil {
+		r.AddFromError(err)
+	} else {
+		r.AddOK("moved to trash entry %q", e.ID)
+	}
+
 	return r, nil
 }
@@ -18,9 +18,9 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"os"
 
 	cli "github.com/nats-io/cliprompts/v2"
+	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
@@ -33,6 +33,7 @@ func createDeleteUserCmd() *cobra.Command {
 		Short: "Delete an user",
 		Args:  cobra.MaximumNArgs(1),
 		Example: `nsc delete user -n name
+nsc delete user -a account -n name
 nsc delete user -i`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return RunAction(cmd, args, &params)
@@ -40,8 +41,9 @@ nsc delete user -i`,
 	}
 	cmd.Flags().StringSliceVarP(&params.names, "name", "n", nil, "name of user(s) to delete")
 	cmd.Flags().BoolVarP(&params.revoke, "revoke", "R", false, "revoke user before deleting")
-	cmd.Flags().BoolVarP(&params.rmNKey, "rm-nkey", "D", false, "delete the user key")
-	cmd.Flags().BoolVarP(&params.rmCreds, "rm-creds", "C", false, "delete the user creds")
+	cmd.Flags().BoolVarP(&params.rmNKey, "rm-nkey", "D", false, "move the user key to the trash")
+	cmd.Flags().BoolVarP(&params.rmCreds, "rm-creds", "C", false, "move the user creds to the trash")
+	params.AccountContextParams.BindFlags(cmd)
 
 	return cmd
 }
@@ -133,9 +135,9 @@ func (p *DeleteUserParams) Load(ctx ActionCtx) error {
 func (p *DeleteUserParams) PostInteractive(ctx ActionCtx) error {
 	var err error
 
-	m := "deleting a user, nkey or creds file cannot be undone - continue"
+	m := "the user, nkey and creds file are moved to the trash until purged - continue"
 	if len(p.names) > 1 {
-		m = "deleting users, nkeys or creds files cannot be undone - continue"
+		m = "the users, nkeys and creds files are moved to the trash until purged - continue"
 	}
 	ok, err := cli.Confirm(m, false)
 	if err != nil {
@@ -202,34 +204,16 @@ func (p *DeleteUserParams) Run(ctx ActionCtx) (store.Status, error) {
 			}
 		}
 
-		if err := s.Delete(store.Accounts, p.AccountContextParams.Name, store.Users, store.JwtName(n)); err != nil {
+		e := s.NewTrashEntry(jwt.UserClaim, n, p.AccountContextParams.Name)
+		if err := trashUser(ctx, e, p.AccountContextParams.Name, n, uc, p.rmNKey, p.rmCreds, ru); err != nil {
 			ru.AddFromError(err)
-		} else {
-			ru.AddOK("user deleted")
-		}
-		if p.rmNKey {
-			if ctx.StoreCtx().KeyStore.HasPrivateKey(uc.Subject) {
-				if err := ctx.StoreCtx().KeyStore.Remove(uc.Subject); err != nil {
-					ru.AddFromError(err)
-				} else {
-					ru.AddOK("deleted private key")
-				}
-			} else {
-				ru.AddOK("private key is not stored")
-			}
+			continue
 		}
-		if p.rmCreds {
-			fp := ctx.StoreCtx().KeyStore.GetUserCredsPath(p.AccountContextParams.Name, n)
-			if _, err := os.Stat(fp); os.IsNotExist(err) {
-				ru.AddOK("creds file is not stored")
-			} else {
-				if err := os.Remove(fp); err != nil {
-					ru.AddError("error deleting creds file %s: %v", fp, err)
-				} else {
-					ru.AddOK("removed creds file")
-				}
-			}
+		if err := s.SaveTrashEntry(e); err != nil {
+			ru.AddFromError(err)
+			continue
 		}
+		ru.AddOK("moved to trash entry %q", e.ID)
 	}
 
 	if revoked {
@@ -76,6 +76,28 @@ func init() {
 	describeCmd.PersistentFlags().StringVarP(&JsonPath, "field", "F", "", "extract value from specified field using json structure")
 }
 
+// describeJson returns true if the JWT body is displayed as JSON or
+// in the structured output format
+func describeJson() bool {
+	return Json || JsonPath != "" || StructuredOutput()
+}
+
+// claimBody decodes the body of the JWT, extracts the requested field
+// and converts it to the output format
+func claimBody(token []byte) ([]byte, error) {
+	d, err := bodyAsJson(token)
+	if err != nil {
+		return nil, err
+	}
+	if JsonPath != "" {
+		d, err = GetField(d, JsonPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return jsonAsOutput(d)
+}
+
 func bodyAsJson(data []byte) ([]byte, error) {
 	chunks := bytes.Split(data, []byte{'.'})
 	if len(chunks) != 3 {
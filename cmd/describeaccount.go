@@ -64,22 +64,16 @@ func (p *DescribeAccountParams) Load(ctx ActionCtx) error {
 	if err = p.AccountContextParams.Validate(ctx); err != nil {
 		return err
 	}
-	if Json || Raw || JsonPath != "" {
+	if describeJson() || Raw {
 		p.raw, err = ctx.StoreCtx().Store.ReadRawAccountClaim(p.AccountContextParams.Name)
 		if err != nil {
 			return err
 		}
-		if Json || JsonPath != "" {
-			p.raw, err = bodyAsJson(p.raw)
+		if describeJson() {
+			p.raw, err = claimBody(p.raw)
 			if err != nil {
 				return err
 			}
-			if JsonPath != "" {
-				p.raw, err = GetField(p.raw, JsonPath)
-				if err != nil {
-					return err
-				}
-			}
 		}
 	} else {
 		ac, err := ctx.StoreCtx().Store.ReadAccountClaim(p.AccountContextParams.Name)
@@ -100,8 +94,12 @@ func (p *DescribeAccountParams) PostInteractive(_ ActionCtx) error {
 	return nil
 }
 
+func (p *DescribeAccountParams) ReadOnly() bool {
+	return true
+}
+
 func (p *DescribeAccountParams) Run(_ ActionCtx) (store.Status, error) {
-	if Raw || Json || JsonPath != "" {
+	if Raw || describeJson() {
 		if !IsStdOut(p.outputFile) {
 			var err error
 			p.raw, err = jwt.DecorateJWT(string(p.raw))
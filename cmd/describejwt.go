@@ -95,7 +95,22 @@ func (p *DescribeFile) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *DescribeFile) ReadOnly() bool {
+	return true
+}
+
 func (p *DescribeFile) Run(ctx ActionCtx) (store.Status, error) {
+	if describeJson() {
+		d, err := claimBody([]byte(p.token))
+		if err != nil {
+			return nil, err
+		}
+		if err := Write(p.outputFile, append(d, '\n')); err != nil {
+			return nil, err
+		}
+		return p.wrote("claims"), nil
+	}
+
 	var describer Describer
 	switch p.kind {
 	case jwt.AccountClaim:
@@ -131,9 +146,12 @@ func (p *DescribeFile) Run(ctx ActionCtx) (store.Status, error) {
 	if err := Write(p.outputFile, []byte(describer.Describe())); err != nil {
 		return nil, err
 	}
-	var s store.Status
-	if !IsStdOut(p.outputFile) {
-		s = store.OKStatus("wrote account description to %#q", AbbrevHomePaths(p.outputFile))
+	return p.wrote("description"), nil
+}
+
+func (p *DescribeFile) wrote(what string) store.Status {
+	if IsStdOut(p.outputFile) {
+		return nil
 	}
-	return s, nil
+	return store.OKStatus("wrote %s %s to %#q", p.kind, what, AbbrevHomePaths(p.outputFile))
 }
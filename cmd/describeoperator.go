@@ -82,23 +82,16 @@ func (p *DescribeOperatorParams) PreInteractive(_ ActionCtx) error {
 
 func (p *DescribeOperatorParams) Load(ctx ActionCtx) error {
 	var err error
-	if Json || Raw || JsonPath != "" {
+	if describeJson() || Raw {
 		p.raw, err = ctx.StoreCtx().Store.ReadRawOperatorClaim()
 		if err != nil {
 			return err
 		}
-		if Json || JsonPath != "" {
-			p.raw, err = bodyAsJson(p.raw)
+		if describeJson() {
+			p.raw, err = claimBody(p.raw)
 			if err != nil {
 				return err
 			}
-
-			if JsonPath != "" {
-				p.raw, err = GetField(p.raw, JsonPath)
-				if err != nil {
-					return err
-				}
-			}
 		}
 	} else {
 		oc, err := ctx.StoreCtx().Store.ReadOperatorClaim()
@@ -118,8 +111,12 @@ func (p *DescribeOperatorParams) PostInteractive(_ ActionCtx) error {
 	return nil
 }
 
+func (p *DescribeOperatorParams) ReadOnly() bool {
+	return true
+}
+
 func (p *DescribeOperatorParams) Run(_ ActionCtx) (store.Status, error) {
-	if Raw || Json || JsonPath != "" {
+	if Raw || describeJson() {
 		if !IsStdOut(p.outputFile) {
 			var err error
 			p.raw, err = jwt.DecorateJWT(string(p.raw))
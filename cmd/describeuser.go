@@ -92,22 +92,16 @@ func (p *DescribeUserParams) Load(ctx ActionCtx) error {
 		return fmt.Errorf("user is required")
 	}
 
-	if Json || Raw || JsonPath != "" {
+	if describeJson() || Raw {
 		p.raw, err = ctx.StoreCtx().Store.ReadRawUserClaim(p.AccountContextParams.Name, p.user)
 		if err != nil {
 			return err
 		}
-		if Json || JsonPath != "" {
-			p.raw, err = bodyAsJson(p.raw)
+		if describeJson() {
+			p.raw, err = claimBody(p.raw)
 			if err != nil {
 				return err
 			}
-			if JsonPath != "" {
-				p.raw, err = GetField(p.raw, JsonPath)
-				if err != nil {
-					return err
-				}
-			}
 		}
 	} else {
 		uc, err := ctx.StoreCtx().Store.ReadUserClaim(p.AccountContextParams.Name, p.user)
@@ -127,8 +121,12 @@ func (p *DescribeUserParams) PostInteractive(_ ActionCtx) error {
 	return nil
 }
 
+func (p *DescribeUserParams) ReadOnly() bool {
+	return true
+}
+
 func (p *DescribeUserParams) Run(_ ActionCtx) (store.Status, error) {
-	if Raw || Json || JsonPath != "" {
+	if Raw || describeJson() {
 		if !IsStdOut(p.outputFile) {
 			var err error
 			p.raw, err = jwt.DecorateJWT(string(p.raw))
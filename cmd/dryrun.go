@@ -0,0 +1,75 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+// DryRunFlag runs actions keeping the changes to the stores and the
+// keystore in memory, and reports the changes instead of making them
+var DryRunFlag bool
+
+// startDryRun keeps the changes made by the action in memory. The
+// returned function restores the store of the action.
+func startDryRun(ctx ActionCtx) (*store.DryRun, func(), error) {
+	d, err := store.StartDryRun()
+	if err != nil {
+		return nil, nil, err
+	}
+	restore := func() {}
+	if s := ctx.StoreCtx().Store; s != nil {
+		// changes are not recorded
+		v := s.Versioner
+		s.Versioner = nil
+		restore = func() { s.Versioner = v }
+	}
+	return d, restore, nil
+}
+
+// printDryRun lists the changes that were not made
+func printDryRun(cmd *cobra.Command, changes []*store.Change) {
+	if len(changes) == 0 {
+		cmd.Println("[dry-run] no changes were made")
+		return
+	}
+	cmd.Println("[dry-run] the following changes were not made:")
+	for _, c := range changes {
+		cmd.Printf("  %-6s %s\n", c.Op, AbbrevHomePaths(c.Path))
+		for _, f := range c.Fields {
+			switch {
+			case f.Old == nil:
+				cmd.Printf("         + %s: %s\n", f.Field, fieldValue(f.New))
+			case f.New == nil:
+				cmd.Printf("         - %s: %s\n", f.Field, fieldValue(f.Old))
+			default:
+				cmd.Printf("         ~ %s: %s -> %s\n", f.Field, fieldValue(f.Old), fieldValue(f.New))
+			}
+		}
+	}
+}
+
+func fieldValue(v interface{}) string {
+	d, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(d)
+}
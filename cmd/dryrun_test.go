@@ -0,0 +1,215 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DryRunEditAccount(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	before, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(createEditAccount()), "--conns", "10", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "[dry-run] the following changes were not made")
+	require.Contains(t, stderr, filepath.Join("accounts", "A", "A.jwt"))
+	require.Contains(t, stderr, "~ nats.limits.conn: -1 -> 10")
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, before.ID, ac.ID)
+	require.Equal(t, int64(-1), ac.Limits.Conn)
+	require.Nil(t, store.ActiveDryRun())
+}
+
+func Test_DryRunAddUser(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	keys, err := ts.KeyStore.AllKeys()
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(CreateAddUserCmd()), "--name", "U", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "create "+AbbrevHomePaths(filepath.Join(ts.Store.Dir, "accounts", "A", "users", "U.jwt")))
+	require.Contains(t, stderr, "+ name: \"U\"")
+	require.Contains(t, stderr, "U.creds")
+	require.Contains(t, stderr, ".nk")
+
+	require.False(t, ts.Store.Has("accounts", "A", "users", "U.jwt"))
+	after, err := ts.KeyStore.AllKeys()
+	require.NoError(t, err)
+	require.Equal(t, keys, after)
+	require.Empty(t, ts.KeyStore.GetUserCredsPath("A", "U"))
+}
+
+func Test_DryRunDeleteAccount(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(createDeleteAccountCmd()), "--name", "A", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "remove "+AbbrevHomePaths(filepath.Join(ts.Store.Dir, "accounts", "A", "A.jwt")))
+	require.True(t, ts.Store.HasAccount("A"))
+	trash, err := ts.Store.ListTrash()
+	require.NoError(t, err)
+	require.Empty(t, trash)
+}
+
+func Test_DryRunNoChanges(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(createDescribeAccountCmd()), "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "[dry-run] no changes were made")
+}
+
+func Test_DryRunOutputFile(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	fp := filepath.Join(ts.Dir, "A.txt")
+	_, stderr, err := ExecuteCmd(HoistRootFlags(createDescribeAccountCmd()), "--output-file", fp, "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "create "+AbbrevHomePaths(fp))
+	_, err = os.Stat(fp)
+	require.True(t, os.IsNotExist(err))
+}
+
+func Test_DryRunManagedAccount(t *testing.T) {
+	as, m := RunTestAccountServer(t)
+	defer as.Close()
+
+	ts := NewTestStoreWithOperatorJWT(t, string(m["operator"]))
+	defer ts.Done(t)
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(CreateAddAccountCmd()), "--name", "A", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "push   "+as.URL)
+	require.False(t, ts.Store.HasAccount("A"))
+	// only the operator is on the account server
+	require.Len(t, m, 1)
+}
+
+func Test_DryRunJsonOutput(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	stdout, _, err := ExecuteCmd(HoistRootFlags(createEditAccount()), "--conns", "10", "--dry-run", "--output", "json")
+	require.NoError(t, err)
+
+	var out statusOutput
+	require.NoError(t, json.Unmarshal([]byte(stdout), &out))
+	require.True(t, out.DryRun)
+	require.Len(t, out.Changes, 1)
+	c := out.Changes[0]
+	require.Equal(t, store.UpdateOp, c.Op)
+	require.Equal(t, filepath.Join(ts.Store.Dir, "accounts", "A", "A.jwt"), c.Path)
+	require.Len(t, c.Fields, 1)
+	require.Equal(t, "nats.limits.conn", c.Fields[0].Field)
+	require.Equal(t, float64(-1), c.Fields[0].Old)
+	require.Equal(t, float64(10), c.Fields[0].New)
+}
+
+func Test_DryRunWritesNothing(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	files := func() []string {
+		var paths []string
+		require.NoError(t, filepath.Walk(ts.Dir, func(fp string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && info.Name() != store.LockName {
+				paths = append(paths, fp)
+			}
+			return err
+		}))
+		return paths
+	}
+	before := files()
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(CreateAddAccountCmd()), "--name", "B", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, filepath.Join("accounts", "B", "B.jwt"))
+	require.Contains(t, stderr, ".nk")
+	require.Equal(t, before, files())
+}
+
+func Test_DryRunAddOperatorNewKeyStore(t *testing.T) {
+	ts := NewEmptyStore(t)
+	defer ts.Done(t)
+	// the keystore directory doesn't exist yet
+	keys := filepath.Join(ts.Dir, "new", "keys")
+	require.NoError(t, os.Setenv(store.NKeysPathEnv, keys))
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(createAddOperatorCmd()), "--name", "O", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, filepath.Join("O", "O.jwt"))
+	require.Contains(t, stderr, ".nk")
+	_, err = os.Stat(keys)
+	require.True(t, os.IsNotExist(err))
+}
+
+func Test_DryRunKeysRestore(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	_, apk, akp := CreateAccountKey(t)
+	_, err := ts.KeyStore.Store(akp)
+	require.NoError(t, err)
+
+	dir := filepath.Join(ts.Dir, "shares")
+	_, _, err = ExecuteCmd(createKeysBackupCmd(), "--shares", "2", "--threshold", "2", "--dir", dir, apk)
+	require.NoError(t, err)
+	require.NoError(t, ts.KeyStore.Remove(apk))
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(createKeysRestoreCmd()), filepath.Join(dir, keyShareName(apk, 1)), filepath.Join(dir, keyShareName(apk, 2)), "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "[dry-run] the following changes were not made")
+	require.Contains(t, stderr, ".nk")
+	_, err = os.Stat(ts.KeyStore.GetKeyPath(apk))
+	require.True(t, os.IsNotExist(err))
+}
+
+func Test_DryRunKeysEncryptRejected(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	defer os.Unsetenv(store.NKeysPassphraseEnv)
+	require.NoError(t, os.Setenv(store.NKeysPassphraseEnv, "a passphrase"))
+
+	_, _, err := ExecuteCmd(HoistRootFlags(createKeysEncryptCmd()), "--dry-run")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--dry-run is not supported")
+	require.False(t, store.IsEncryptedKeyStore())
+
+	_, _, err = ExecuteCmd(HoistRootFlags(createKeysPassphraseCmd()), "--dry-run")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--dry-run is not supported")
+}
@@ -287,7 +287,8 @@ func (p *EditAccountParams) Run(ctx ActionCtx) (store.Status, error) {
 			r.Add(DiffAccountLimits(p.claim, bc))
 		}
 	}
-	r.AddOK("edited account %q", p.AccountContextParams.Name)
-
+	if r.HasNoErrors() {
+		r.AddOK("edited account %q", p.AccountContextParams.Name)
+	}
 	return r, err
 }
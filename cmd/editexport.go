@@ -23,6 +23,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -305,15 +306,26 @@ func (p *EditExportParams) syncOptions(ctx ActionCtx) {
 }
 
 func (p *EditExportParams) Run(ctx ActionCtx) (store.Status, error) {
-	old := *p.claim.Exports[p.index]
-	// old vr
-	var vr jwt.ValidationResults
-	if err := p.claim.Exports.Validate(&vr); err != nil {
+	r := store.NewDetailedReport(false)
+	// the subject may have been changed interactively
+	subject := string(p.claim.Exports[p.index].Subject)
+	var export jwt.Export
+	rs, err := apiFor(ctx).EditExport(p.AccountContextParams.Name, subject, func(e *jwt.Export) error {
+		p.edit(r, *e, &export)
+		*e = export
+		return nil
+	}, &api.SignOptions{Signer: p.signerKP})
+	if err := addAccountStatus(r, rs, err); err != nil {
 		return nil, err
 	}
+	if r.HasNoErrors() {
+		r.AddOK("edited %s export %q", export.Type, export.Name)
+	}
+	return r, nil
+}
 
-	r := store.NewDetailedReport(false)
-	var export jwt.Export
+// edit sets the export from the options and reports how it differs from old
+func (p *EditExportParams) edit(r *store.Report, old jwt.Export, export *jwt.Export) {
 	export.Name = p.name
 	if export.Name != old.Name {
 		r.AddOK("changed export name to %s", export.Name)
@@ -373,40 +385,4 @@ func (p *EditExportParams) Run(ctx ActionCtx) (store.Status, error) {
 			r.AddOK("changed response type to %s", p.responseType)
 		}
 	}
-
-	p.claim.Exports[p.index] = &export
-
-	var vr2 jwt.ValidationResults
-	if err := p.claim.Exports.Validate(&vr2); err != nil {
-		return nil, err
-	}
-	// filter out all the old validations
-	uvr := jwt.CreateValidationResults()
-	if len(vr.Issues) > 0 {
-		for _, nis := range vr.Issues {
-			for _, is := range vr2.Issues {
-				if nis.Description == is.Description {
-					continue
-				}
-			}
-			uvr.Add(nis)
-		}
-	} else {
-		uvr = &vr2
-	}
-	// fail validation
-	if len(uvr.Issues) > 0 {
-		return nil, errors.New(uvr.Issues[0].Error())
-	}
-
-	token, err := p.claim.Encode(p.signerKP)
-	if err != nil {
-		return nil, err
-	}
-
-	StoreAccountAndUpdateStatus(ctx, token, r)
-	if r.HasNoErrors() {
-		r.AddOK("edited %s export %q", export.Type, export.Name)
-	}
-	return r, err
 }
@@ -342,10 +342,10 @@ func (p *EditUserParams) Run(ctx ActionCtx) (store.Status, error) {
 		r.Add(rs)
 	}
 	if err != nil {
-		r.AddFromError(err)
-	}
-	if rs != nil {
-		r.Add(rs)
+		if rs == nil {
+			r.AddFromError(err)
+		}
+		return r, nil
 	}
 	ks := ctx.StoreCtx().KeyStore
 	if ks.HasPrivateKey(p.claim.Subject) {
@@ -18,7 +18,6 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -116,6 +115,10 @@ func (p *ExportKeysParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *ExportKeysParams) ReadOnly() bool {
+	return true
+}
+
 func (p *ExportKeysParams) Run(ctx ActionCtx) (store.Status, error) {
 	ctx.CurrentCmd().SilenceUsage = true
 
@@ -162,7 +165,7 @@ func (p *ExportKeysParams) Run(ctx ActionCtx) (store.Status, error) {
 
 	for _, j := range wj {
 		if j.filepath != "" {
-			j.err = ioutil.WriteFile(j.filepath, j.data, 0700)
+			j.err = WriteFile(j.filepath, j.data, 0700)
 			if j.err != nil {
 				sr.AddError("error exporting %q: %v", j.description, j.err)
 			} else {
@@ -0,0 +1,391 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createExportTopologyCmd() *cobra.Command {
+	var params ExportTopologyParams
+	cmd := &cobra.Command{
+		Use:   "topology",
+		Short: "Export the operator, its accounts and users as a YAML or JSON document",
+		Long: `Export the operator, its accounts and users as a YAML or JSON document
+
+The document has the names, public keys, limits, exports, imports and
+their activations, permissions, tags, signing keys, revocations and
+expiration of the operator, every account and every user. Seeds are
+not exported. The format is YAML unless --format or --output is json.`,
+		Example: `nsc export topology
+nsc export topology --format json --output-file operator.json`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().StringVarP(&params.format, "format", "", "", fmt.Sprintf("document format: %s or %s", YamlOutput, JsonOutput))
+	cmd.Flags().StringVarP(&params.outputFile, "output-file", "o", "--", "output file, '--' is stdout")
+	return cmd
+}
+
+func init() {
+	exportCmd.AddCommand(createExportTopologyCmd())
+}
+
+// OperatorDocument describes an operator, its accounts and their users
+type OperatorDocument struct {
+	Name                string             `json:"name"`
+	PublicKey           string             `json:"public_key"`
+	IssuedAt            int64              `json:"issued_at,omitempty"`
+	NotBefore           int64              `json:"not_before,omitempty"`
+	Expires             int64              `json:"expires,omitempty"`
+	Tags                []string           `json:"tags,omitempty"`
+	SigningKeys         []string           `json:"signing_keys,omitempty"`
+	AccountServerURL    string             `json:"account_server_url,omitempty"`
+	OperatorServiceURLs []string           `json:"operator_service_urls,omitempty"`
+	Accounts            []*AccountDocument `json:"accounts,omitempty"`
+}
+
+type AccountDocument struct {
+	Name        string             `json:"name"`
+	PublicKey   string             `json:"public_key"`
+	Issuer      string             `json:"issuer"`
+	IssuedAt    int64              `json:"issued_at,omitempty"`
+	NotBefore   int64              `json:"not_before,omitempty"`
+	Expires     int64              `json:"expires,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	SigningKeys []string           `json:"signing_keys,omitempty"`
+	Limits      LimitsDocument     `json:"limits"`
+	Exports     []*ExportDocument  `json:"exports,omitempty"`
+	Imports     []*ImportDocument  `json:"imports,omitempty"`
+	Revocations []listedRevocation `json:"revocations,omitempty"`
+	Users       []*UserDocument    `json:"users,omitempty"`
+}
+
+// LimitsDocument has the limits of an account, -1 is unlimited
+type LimitsDocument struct {
+	Conns           int64 `json:"conns"`
+	LeafConns       int64 `json:"leaf_conns"`
+	Subscriptions   int64 `json:"subscriptions"`
+	Imports         int64 `json:"imports"`
+	Exports         int64 `json:"exports"`
+	Data            int64 `json:"data"`
+	Payload         int64 `json:"payload"`
+	WildcardExports bool  `json:"wildcard_exports"`
+}
+
+type ExportDocument struct {
+	Name         string              `json:"name,omitempty"`
+	Subject      string              `json:"subject"`
+	Type         string              `json:"type"`
+	Private      bool                `json:"private,omitempty"`
+	ResponseType string              `json:"response_type,omitempty"`
+	Latency      *jwt.ServiceLatency `json:"latency,omitempty"`
+	Revocations  []listedRevocation  `json:"revocations,omitempty"`
+}
+
+// ImportDocument describes an import, the account is the public key of the
+// exporting account and its name if it belongs to the operator
+type ImportDocument struct {
+	Name         string              `json:"name,omitempty"`
+	Account      string              `json:"account"`
+	AccountName  string              `json:"account_name,omitempty"`
+	Subject      string              `json:"subject"`
+	LocalSubject string              `json:"local_subject,omitempty"`
+	Type         string              `json:"type"`
+	Activation   *ActivationDocument `json:"activation,omitempty"`
+}
+
+// ActivationDocument is the decoded activation token of a private import
+type ActivationDocument struct {
+	ID            string `json:"id,omitempty"`
+	URL           string `json:"url,omitempty"`
+	Issuer        string `json:"issuer,omitempty"`
+	IssuerAccount string `json:"issuer_account,omitempty"`
+	Subject       string `json:"subject,omitempty"`
+	ImportSubject string `json:"import_subject,omitempty"`
+	IssuedAt      int64  `json:"issued_at,omitempty"`
+	NotBefore     int64  `json:"not_before,omitempty"`
+	Expires       int64  `json:"expires,omitempty"`
+	Revoked       bool   `json:"revoked,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+type UserDocument struct {
+	Name           string            `json:"name"`
+	PublicKey      string            `json:"public_key"`
+	Issuer         string            `json:"issuer"`
+	IssuerAccount  string            `json:"issuer_account,omitempty"`
+	IssuedAt       int64             `json:"issued_at,omitempty"`
+	NotBefore      int64             `json:"not_before,omitempty"`
+	Expires        int64             `json:"expires,omitempty"`
+	Revoked        bool              `json:"revoked,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	AllowPub       []string          `json:"allow_pub,omitempty"`
+	AllowSub       []string          `json:"allow_sub,omitempty"`
+	DenyPub        []string          `json:"deny_pub,omitempty"`
+	DenySub        []string          `json:"deny_sub,omitempty"`
+	Response       *ResponseDocument `json:"response,omitempty"`
+	Payload        int64             `json:"payload,omitempty"`
+	SourceNetworks string            `json:"source_networks,omitempty"`
+	Times          []jwt.TimeRange   `json:"times,omitempty"`
+}
+
+type ResponseDocument struct {
+	MaxMsgs int    `json:"max"`
+	TTL     string `json:"ttl,omitempty"`
+}
+
+// NewOperatorDocument reads the operator, its accounts and users from the store
+func NewOperatorDocument(s *store.Store) (*OperatorDocument, error) {
+	oc, err := s.ReadOperatorClaim()
+	if err != nil {
+		return nil, err
+	}
+	doc := &OperatorDocument{
+		Name:                oc.Name,
+		PublicKey:           oc.Subject,
+		IssuedAt:            oc.IssuedAt,
+		NotBefore:           oc.NotBefore,
+		Expires:             oc.Expires,
+		Tags:                oc.Tags,
+		SigningKeys:         oc.SigningKeys,
+		AccountServerURL:    oc.AccountServerURL,
+		OperatorServiceURLs: oc.OperatorServiceURLs,
+	}
+
+	names, err := s.ListSubContainers(store.Accounts)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[string]*jwt.AccountClaims)
+	for _, n := range names {
+		ac, err := s.ReadAccountClaim(n)
+		if err != nil {
+			return nil, err
+		}
+		accounts[ac.Subject] = ac
+	}
+	for _, n := range names {
+		ad, err := newAccountDocument(s, n, accounts)
+		if err != nil {
+			return nil, err
+		}
+		doc.Accounts = append(doc.Accounts, ad)
+	}
+	return doc, nil
+}
+
+// newAccountDocument describes the account, the accounts of the operator
+// by public key resolve the names of imported accounts and activation revocations
+func newAccountDocument(s *store.Store, name string, accounts map[string]*jwt.AccountClaims) (*AccountDocument, error) {
+	ac, err := s.ReadAccountClaim(name)
+	if err != nil {
+		return nil, err
+	}
+	doc := &AccountDocument{
+		Name:        name,
+		PublicKey:   ac.Subject,
+		Issuer:      ac.Issuer,
+		IssuedAt:    ac.IssuedAt,
+		NotBefore:   ac.NotBefore,
+		Expires:     ac.Expires,
+		Tags:        ac.Tags,
+		SigningKeys: ac.SigningKeys,
+		Limits: LimitsDocument{
+			Conns:           ac.Limits.Conn,
+			LeafConns:       ac.Limits.LeafNodeConn,
+			Subscriptions:   ac.Limits.Subs,
+			Imports:         ac.Limits.Imports,
+			Exports:         ac.Limits.Exports,
+			Data:            ac.Limits.Data,
+			Payload:         ac.Limits.Payload,
+			WildcardExports: ac.Limits.WildcardExports,
+		},
+	}
+	if len(ac.Revocations) > 0 {
+		doc.Revocations = revocationList(ac.Revocations)
+	}
+	for _, e := range ac.Exports {
+		ed := &ExportDocument{
+			Name:         e.Name,
+			Subject:      string(e.Subject),
+			Type:         e.Type.String(),
+			Private:      e.TokenReq,
+			ResponseType: string(e.ResponseType),
+			Latency:      e.Latency,
+		}
+		if len(e.Revocations) > 0 {
+			ed.Revocations = revocationList(e.Revocations)
+		}
+		doc.Exports = append(doc.Exports, ed)
+	}
+	for _, im := range ac.Imports {
+		doc.Imports = append(doc.Imports, newImportDocument(im, accounts))
+	}
+
+	users, err := s.ListEntries(store.Accounts, name, store.Users)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range users {
+		uc, err := s.ReadUserClaim(name, n)
+		if err != nil {
+			return nil, err
+		}
+		doc.Users = append(doc.Users, newUserDocument(n, ac, uc))
+	}
+	return doc, nil
+}
+
+func newImportDocument(im *jwt.Import, accounts map[string]*jwt.AccountClaims) *ImportDocument {
+	remote, local := importedSubjects(im)
+	doc := &ImportDocument{
+		Name:         im.Name,
+		Account:      im.Account,
+		Subject:      remote,
+		LocalSubject: local,
+		Type:         im.Type.String(),
+	}
+	src := accounts[im.Account]
+	if src != nil {
+		doc.AccountName = src.Name
+	}
+	if im.Token == "" {
+		return doc
+	}
+	id := NewImportDescriber(*im)
+	doc.Activation = &ActivationDocument{}
+	if id.IsRemoteImport() {
+		doc.Activation.URL = im.Token
+	}
+	act, err := id.LoadActivation()
+	if err != nil {
+		doc.Activation.Error = err.Error()
+		return doc
+	}
+	doc.Activation.ID = act.ID
+	doc.Activation.Issuer = act.Issuer
+	doc.Activation.IssuerAccount = act.IssuerAccount
+	doc.Activation.Subject = act.Subject
+	doc.Activation.ImportSubject = string(act.ImportSubject)
+	doc.Activation.IssuedAt = act.IssuedAt
+	doc.Activation.NotBefore = act.NotBefore
+	doc.Activation.Expires = act.Expires
+	if src != nil {
+		for _, e := range src.Exports {
+			if e.Type == im.Type && jwt.Subject(remote).IsContainedIn(e.Subject) {
+				doc.Activation.Revoked = e.IsRevokedAt(act.Subject, time.Unix(act.IssuedAt, 0))
+				break
+			}
+		}
+	}
+	return doc
+}
+
+func newUserDocument(name string, ac *jwt.AccountClaims, uc *jwt.UserClaims) *UserDocument {
+	doc := &UserDocument{
+		Name:           name,
+		PublicKey:      uc.Subject,
+		Issuer:         uc.Issuer,
+		IssuerAccount:  uc.IssuerAccount,
+		IssuedAt:       uc.IssuedAt,
+		NotBefore:      uc.NotBefore,
+		Expires:        uc.Expires,
+		Revoked:        ac.IsRevokedAt(uc.Subject, time.Unix(uc.IssuedAt, 0)),
+		Tags:           uc.Tags,
+		AllowPub:       uc.Pub.Allow,
+		AllowSub:       uc.Sub.Allow,
+		DenyPub:        uc.Pub.Deny,
+		DenySub:        uc.Sub.Deny,
+		Payload:        uc.Limits.Payload,
+		SourceNetworks: uc.Src,
+		Times:          uc.Times,
+	}
+	if uc.Resp != nil {
+		doc.Response = &ResponseDocument{MaxMsgs: uc.Resp.MaxMsgs}
+		if uc.Resp.Expires > 0 {
+			doc.Response.TTL = uc.Resp.Expires.String()
+		}
+	}
+	return doc
+}
+
+type ExportTopologyParams struct {
+	format     string
+	outputFile string
+	doc        *OperatorDocument
+}
+
+func (p *ExportTopologyParams) SetDefaults(_ ActionCtx) error {
+	if p.format == "" {
+		p.format = YamlOutput
+		if OutputFlag == JsonOutput {
+			p.format = JsonOutput
+		}
+	}
+	return nil
+}
+
+func (p *ExportTopologyParams) PreInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *ExportTopologyParams) Load(ctx ActionCtx) error {
+	var err error
+	p.doc, err = NewOperatorDocument(ctx.StoreCtx().Store)
+	return err
+}
+
+func (p *ExportTopologyParams) PostInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *ExportTopologyParams) Validate(_ ActionCtx) error {
+	if p.format != YamlOutput && p.format != JsonOutput {
+		return fmt.Errorf("format must be %s or %s", YamlOutput, JsonOutput)
+	}
+	return nil
+}
+
+func (p *ExportTopologyParams) ReadOnly() bool {
+	return true
+}
+
+func (p *ExportTopologyParams) Run(_ ActionCtx) (store.Status, error) {
+	d, err := json.MarshalIndent(p.doc, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	if d, err = jsonAs(d, outputFormat(p.format)); err != nil {
+		return nil, err
+	}
+	if err := Write(p.outputFile, append(d, '\n')); err != nil {
+		return nil, err
+	}
+	if IsStdOut(p.outputFile) {
+		return nil, nil
+	}
+	return store.OKStatus("wrote topology of operator %q to %#q", p.doc.Name, AbbrevHomePaths(p.outputFile)), nil
+}
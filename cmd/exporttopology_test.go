@@ -0,0 +1,150 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_ExportTopology(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	ts.AddExport(t, "A", jwt.Stream, "private.>", false)
+	ts.AddAccount(t, "B")
+	ts.AddImport(t, "A", "private.>", "B")
+	ts.AddUser(t, "B", "U")
+	ts.AddUser(t, "B", "V")
+	// credentials issued in the same second as the revocation are not revoked
+	at := strconv.FormatInt(time.Now().Unix()+60, 10)
+	_, _, err := ExecuteCmd(createRevokeUserCmd(), "--account", "B", "--name", "V", "--at", at)
+	require.NoError(t, err)
+
+	stdout, _, err := ExecuteCmd(createExportTopologyCmd(), "--format", "json")
+	require.NoError(t, err)
+	require.False(t, regexp.MustCompile(`S[OAU][A-Z2-7]{56}`).MatchString(stdout), "the document contains a seed")
+
+	var doc OperatorDocument
+	require.NoError(t, json.Unmarshal([]byte(stdout), &doc))
+	require.Equal(t, "O", doc.Name)
+	require.Equal(t, ts.GetOperatorPublicKey(t), doc.PublicKey)
+	require.Len(t, doc.Accounts, 2)
+
+	a, b := doc.Accounts[0], doc.Accounts[1]
+	require.Equal(t, "A", a.Name)
+	require.Equal(t, ts.GetAccountPublicKey(t, "A"), a.PublicKey)
+	require.Len(t, a.Exports, 1)
+	require.Equal(t, "private.>", a.Exports[0].Subject)
+	require.Equal(t, "stream", a.Exports[0].Type)
+	require.True(t, a.Exports[0].Private)
+
+	require.Equal(t, "B", b.Name)
+	require.Len(t, b.Imports, 1)
+	im := b.Imports[0]
+	require.Equal(t, a.PublicKey, im.Account)
+	require.Equal(t, "A", im.AccountName)
+	require.NotNil(t, im.Activation)
+	require.Empty(t, im.Activation.Error)
+	require.Equal(t, a.PublicKey, im.Activation.Issuer)
+	require.Equal(t, b.PublicKey, im.Activation.Subject)
+	require.Equal(t, "private.>", im.Activation.ImportSubject)
+	require.False(t, im.Activation.Revoked)
+
+	require.Len(t, b.Users, 2)
+	require.Equal(t, "U", b.Users[0].Name)
+	require.False(t, b.Users[0].Revoked)
+	require.Equal(t, "V", b.Users[1].Name)
+	require.True(t, b.Users[1].Revoked)
+	require.Len(t, b.Revocations, 1)
+	require.Equal(t, b.Users[1].PublicKey, b.Revocations[0].PublicKey)
+}
+
+func Test_ExportTopologyRevokedActivation(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	ts.AddExport(t, "A", jwt.Stream, "private.>", false)
+	ts.AddAccount(t, "B")
+	ts.AddImport(t, "A", "private.>", "B")
+	at := strconv.FormatInt(time.Now().Unix()+60, 10)
+	_, _, err := ExecuteCmd(createRevokeActivationCmd(), "--account", "A", "--subject", "private.>",
+		"--target-account", ts.GetAccountPublicKey(t, "B"), "--at", at)
+	require.NoError(t, err)
+
+	stdout, _, err := ExecuteCmd(createExportTopologyCmd(), "--format", "json")
+	require.NoError(t, err)
+	var doc OperatorDocument
+	require.NoError(t, json.Unmarshal([]byte(stdout), &doc))
+	require.Len(t, doc.Accounts[0].Exports[0].Revocations, 1)
+	require.True(t, doc.Accounts[1].Imports[0].Activation.Revoked)
+}
+
+func Test_ExportTopologyYaml(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	ts.AddUser(t, "A", "U")
+
+	stdout, _, err := ExecuteCmd(createExportTopologyCmd())
+	require.NoError(t, err)
+	require.False(t, strings.HasPrefix(strings.TrimSpace(stdout), "{"))
+
+	var m map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(stdout), &m))
+	require.Equal(t, "O", m["name"])
+	accounts, ok := m["accounts"].([]interface{})
+	require.True(t, ok)
+	require.Len(t, accounts, 1)
+}
+
+func Test_ExportTopologyOutputFile(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	fp := filepath.Join(ts.Dir, "operator.json")
+	_, stderr, err := ExecuteCmd(createExportTopologyCmd(), "--format", "json", "--output-file", fp)
+	require.NoError(t, err)
+	require.Contains(t, stderr, "wrote topology of operator \"O\"")
+
+	d, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	var doc OperatorDocument
+	require.NoError(t, json.Unmarshal(d, &doc))
+	require.Equal(t, "A", doc.Accounts[0].Name)
+}
+
+func Test_ExportTopologyBadFormat(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	_, _, err := ExecuteCmd(createExportTopologyCmd(), "--format", "xml")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "format must be yaml or json")
+}
@@ -26,6 +26,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -224,25 +225,20 @@ func (p *GenerateActivationParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *GenerateActivationParams) ReadOnly() bool {
+	return true
+}
+
 func (p *GenerateActivationParams) Run(ctx ActionCtx) (store.Status, error) {
 	var err error
-	p.activation = jwt.NewActivationClaims(p.accountKey.publicKey)
-	p.activation.NotBefore, _ = p.timeParams.StartDate()
-	p.activation.Expires, _ = p.timeParams.ExpiryDate()
-	p.activation.Name = p.subject
-	// p.subject is subset of the export
-	p.activation.Activation.ImportSubject = jwt.Subject(p.subject)
-	p.activation.Activation.ImportType = p.export.Type
-
-	spub, err := p.signerKP.PublicKey()
+	opts := &api.ActivationOptions{Signer: p.signerKP}
+	opts.NotBefore, _ = p.timeParams.StartDate()
+	opts.Expires, _ = p.timeParams.ExpiryDate()
+	p.token, err = apiFor(ctx).GenerateActivation(p.AccountContextParams.Name, p.subject, p.accountKey.publicKey, opts)
 	if err != nil {
 		return nil, err
 	}
-	if p.claims.Subject != spub {
-		p.activation.IssuerAccount = p.claims.Subject
-	}
-
-	p.token, err = p.activation.Encode(p.signerKP)
+	p.activation, err = jwt.DecodeActivationClaims(p.token)
 	if err != nil {
 		return nil, err
 	}
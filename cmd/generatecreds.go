@@ -16,12 +16,12 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/nats-io/jwt"
 
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -139,6 +139,10 @@ func (p *GenerateCredsParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *GenerateCredsParams) ReadOnly() bool {
+	return true
+}
+
 func (p *GenerateCredsParams) Run(ctx ActionCtx) (store.Status, error) {
 	d, err := GenerateConfig(ctx.StoreCtx().Store, p.AccountContextParams.Name, p.user, p.entityKP)
 	if err != nil {
@@ -160,14 +164,7 @@ func GenerateConfig(s *store.Store, account string, user string, userKey nkeys.K
 		if err != nil {
 			return nil, err
 		}
-		if userKey == nil {
-			return nil, errors.New("userKey was not provided")
-		}
-		seed, err := userKey.Seed()
-		if err != nil {
-			return nil, fmt.Errorf("error getting seed: %v", err)
-		}
-		return jwt.FormatUserConfig(string(d), seed)
+		return api.FormatCreds(d, userKey)
 	}
 	return nil, fmt.Errorf("unable to find user jwt")
 }
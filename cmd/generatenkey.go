@@ -125,6 +125,10 @@ func (p *GenerateNKeysParam) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *GenerateNKeysParam) ReadOnly() bool {
+	return true
+}
+
 func (p *GenerateNKeysParam) Run(ctx ActionCtx) (store.Status, error) {
 	var err error
 	var jobs []*KP
@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -122,6 +123,10 @@ func (p *GenerateServerConfigParams) checkFile(fp string) (string, error) {
 	if err == nil {
 		// file exists, if force - delete it
 		if p.force {
+			// a dry run records the file as updated
+			if store.ActiveDryRun() != nil {
+				return afp, nil
+			}
 			if err := os.Remove(afp); err != nil {
 				return "", err
 			}
@@ -185,41 +190,12 @@ func (p *GenerateServerConfigParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
-func (p *GenerateServerConfigParams) Run(ctx ActionCtx) (store.Status, error) {
-	s := ctx.StoreCtx().Store
-
-	op, err := s.Read(store.JwtName(s.GetName()))
-	if err != nil {
-		return nil, err
-	}
-	p.generator.Add(op)
-
-	names, err := GetConfig().ListAccounts()
-	if err != nil {
-		return nil, err
-	}
-	if len(names) == 0 {
-		return nil, fmt.Errorf("operator %q has no accounts", GetConfig().Operator)
-	}
-
-	for _, n := range names {
-		d, err := s.Read(store.Accounts, n, store.JwtName(n))
-		if err != nil {
-			return nil, err
-		}
-		p.generator.Add(d)
-
-		users, err := s.ListEntries(store.Accounts, n, store.Users)
-		for _, u := range users {
-			d, err := s.Read(store.Accounts, n, store.Users, store.JwtName(u))
-			if err != nil {
-				return nil, err
-			}
-			p.generator.Add(d)
-		}
-	}
+func (p *GenerateServerConfigParams) ReadOnly() bool {
+	return true
+}
 
-	d, err := p.generator.Generate()
+func (p *GenerateServerConfigParams) Run(ctx ActionCtx) (store.Status, error) {
+	d, err := generateServerConfig(ctx.StoreCtx().Store, p.generator)
 	if err != nil {
 		return nil, err
 	}
@@ -232,6 +208,12 @@ func (p *GenerateServerConfigParams) Run(ctx ActionCtx) (store.Status, error) {
 	return nil, err
 }
 
+// generateServerConfig adds the operator, account and user JWTs in the
+// store to the generator and returns the configuration
+func generateServerConfig(s *store.Store, g ServerConfigGenerator) ([]byte, error) {
+	return api.New(s, nil).ServerConfig(g)
+}
+
 type ServerConfigGenerator interface {
 	Add(rawClaim []byte) error
 	Generate() ([]byte, error)
@@ -0,0 +1,295 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+	"github.com/xlab/tablewriter"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show past versions of operator, account and user JWTs",
+	Long: `Show past versions of operator, account and user JWTs.
+
+History is only available once the operator store is versioned.
+A versioned operator directory is a git repository, every change
+to the store is committed recording the nsc command line, the
+entity that changed and the ID of its new JWT.
+
+Use 'nsc history enable' to start versioning the current operator,
+and 'nsc rollback' to restore a previous version.`,
+}
+
+func init() {
+	GetRootCmd().AddCommand(historyCmd)
+	historyCmd.AddCommand(createHistoryEnableCmd())
+	historyCmd.AddCommand(createHistoryCmd(jwt.OperatorClaim))
+	historyCmd.AddCommand(createHistoryCmd(jwt.AccountClaim))
+	historyCmd.AddCommand(createHistoryCmd(jwt.UserClaim))
+}
+
+func createHistoryEnableCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "enable",
+		Short:        "Start recording changes to the current operator in a git repository",
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := GetStore()
+			if err != nil {
+				return err
+			}
+			if err := s.AcquireLock(); err != nil {
+				return err
+			}
+			defer s.ReleaseLock()
+			if s.IsVersioned() {
+				cmd.Printf("operator %q is already versioned\n", s.GetName())
+				return nil
+			}
+			if err := s.EnableVersioning(); err != nil {
+				return fmt.Errorf("error enabling versioning: %v", err)
+			}
+			cmd.Printf("changes to operator %q are recorded in %#q\n", s.GetName(), AbbrevHomePaths(s.Dir))
+			return nil
+		},
+	}
+	return cmd
+}
+
+func createHistoryCmd(kind string) *cobra.Command {
+	var params HistoryParams
+	params.kind = kind
+	cmd := &cobra.Command{
+		Use:          kind,
+		Short:        fmt.Sprintf("Show the past versions of an %s JWT", kind),
+		Args:         MaxArgs(1),
+		SilenceUsage: true,
+		Example: fmt.Sprintf(`nsc history %s
+nsc history %s --revision <id> (prints the JWT at the revision)`, kind, kind),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	params.bindFlags(cmd)
+	cmd.Flags().StringVarP(&params.revision, "revision", "r", "", "print the JWT recorded at the specified revision")
+	return cmd
+}
+
+// VersionedEntityParams identifies the operator, account or user
+// JWT targeted by the history and rollback commands
+type VersionedEntityParams struct {
+	AccountContextParams
+	kind string
+	name string
+}
+
+func (p *VersionedEntityParams) bindFlags(cmd *cobra.Command) {
+	switch p.kind {
+	case jwt.AccountClaim:
+		cmd.Flags().StringVarP(&p.AccountContextParams.Name, "name", "n", "", "account name")
+	case jwt.UserClaim:
+		cmd.Flags().StringVarP(&p.name, "name", "n", "", "user name")
+		p.AccountContextParams.BindFlags(cmd)
+	}
+}
+
+func (p *VersionedEntityParams) SetDefaults(ctx ActionCtx) error {
+	switch p.kind {
+	case jwt.AccountClaim:
+		p.AccountContextParams.Name = NameFlagOrArgument(p.AccountContextParams.Name, ctx)
+		return p.AccountContextParams.SetDefaults(ctx)
+	case jwt.UserClaim:
+		p.name = NameFlagOrArgument(p.name, ctx)
+		return p.AccountContextParams.SetDefaults(ctx)
+	}
+	return nil
+}
+
+func (p *VersionedEntityParams) Edit(ctx ActionCtx) error {
+	var err error
+	switch p.kind {
+	case jwt.AccountClaim:
+		return p.AccountContextParams.Edit(ctx)
+	case jwt.UserClaim:
+		if err = p.AccountContextParams.Edit(ctx); err != nil {
+			return err
+		}
+		if p.name == "" {
+			p.name, err = ctx.StoreCtx().PickUser(p.AccountContextParams.Name)
+		}
+	}
+	return err
+}
+
+func (p *VersionedEntityParams) Validate(ctx ActionCtx) error {
+	if !ctx.StoreCtx().Store.IsVersioned() {
+		return fmt.Errorf("%v - enable it with '%s history enable'", store.ErrNotVersioned, GetToolName())
+	}
+	switch p.kind {
+	case jwt.AccountClaim:
+		return p.AccountContextParams.Validate(ctx)
+	case jwt.UserClaim:
+		if err := p.AccountContextParams.Validate(ctx); err != nil {
+			return err
+		}
+		if p.name == "" {
+			n := ctx.StoreCtx().DefaultUser(p.AccountContextParams.Name)
+			if n != nil {
+				p.name = *n
+			}
+		}
+		if p.name == "" {
+			ctx.CurrentCmd().SilenceUsage = false
+			return errors.New("user name is required")
+		}
+	}
+	return nil
+}
+
+// EntityName returns the name of the targeted entity
+func (p *VersionedEntityParams) EntityName(ctx ActionCtx) string {
+	switch p.kind {
+	case jwt.AccountClaim:
+		return p.AccountContextParams.Name
+	case jwt.UserClaim:
+		return p.name
+	default:
+		return ctx.StoreCtx().Store.GetName()
+	}
+}
+
+// Path returns the store path of the JWT for the targeted entity
+func (p *VersionedEntityParams) Path(ctx ActionCtx) []string {
+	switch p.kind {
+	case jwt.AccountClaim:
+		n := p.AccountContextParams.Name
+		return []string{store.Accounts, n, store.JwtName(n)}
+	case jwt.UserClaim:
+		return []string{store.Accounts, p.AccountContextParams.Name, store.Users, store.JwtName(p.name)}
+	default:
+		return []string{store.JwtName(ctx.StoreCtx().Store.GetName())}
+	}
+}
+
+type HistoryParams struct {
+	VersionedEntityParams
+	revision  string
+	revisions []store.Revision
+	token     []byte
+}
+
+func (p *HistoryParams) SetDefaults(ctx ActionCtx) error {
+	return p.VersionedEntityParams.SetDefaults(ctx)
+}
+
+func (p *HistoryParams) PreInteractive(ctx ActionCtx) error {
+	return p.VersionedEntityParams.Edit(ctx)
+}
+
+func (p *HistoryParams) Load(ctx ActionCtx) error {
+	var err error
+	if err = p.VersionedEntityParams.Validate(ctx); err != nil {
+		return err
+	}
+	s := ctx.StoreCtx().Store
+	if p.revision != "" {
+		p.token, err = s.ReadRevision(p.revision, p.Path(ctx)...)
+		if err != nil {
+			return fmt.Errorf("error reading %s %q at revision %q: %v", p.kind, p.EntityName(ctx), p.revision, err)
+		}
+		return nil
+	}
+	p.revisions, err = s.History(p.Path(ctx)...)
+	if err != nil {
+		return err
+	}
+	if len(p.revisions) == 0 {
+		return fmt.Errorf("no history recorded for %s %q", p.kind, p.EntityName(ctx))
+	}
+	return nil
+}
+
+func (p *HistoryParams) PostInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *HistoryParams) Validate(_ ActionCtx) error {
+	return nil
+}
+
+func (p *HistoryParams) ReadOnly() bool {
+	return true
+}
+
+// historyOutput is the structured output of history
+type historyOutput struct {
+	Kind      string            `json:"kind"`
+	Name      string            `json:"name"`
+	Revision  string            `json:"revision,omitempty"`
+	JWT       string            `json:"jwt,omitempty"`
+	Revisions []historyRevision `json:"revisions,omitempty"`
+}
+
+type historyRevision struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	JwtID   string    `json:"jwt_id"`
+	Command string    `json:"command"`
+}
+
+func (p *HistoryParams) Run(ctx ActionCtx) (store.Status, error) {
+	if StructuredOutput() {
+		out := historyOutput{Kind: p.kind, Name: p.EntityName(ctx), Revision: p.revision, JWT: string(p.token)}
+		for _, r := range p.revisions {
+			out.Revisions = append(out.Revisions, historyRevision{ID: r.ID, Time: r.Time, JwtID: r.JwtID, Command: r.Command})
+		}
+		return structuredResult{out}, nil
+	}
+	if p.revision != "" {
+		return nil, Write("--", append(p.token, '\n'))
+	}
+	table := tablewriter.CreateTable()
+	table.UTF8Box()
+	table.AddTitle(fmt.Sprintf("History for %s %s", p.kind, p.EntityName(ctx)))
+	table.AddHeaders("Revision", "Date", "JWT ID", "Command")
+	for _, r := range p.revisions {
+		table.AddRow(r.ShortID(), r.Time.Format(time.RFC3339), r.JwtID, r.Command)
+	}
+	return nil, Write("--", []byte(table.Render()))
+}
+
+// unrecordedWarning reports a change that was made to the store but
+// not recorded by its versioner as a warning instead of a failure
+func unrecordedWarning(rs store.Status, err error) (store.Status, error) {
+	var ue *store.UnrecordedError
+	if !errors.As(err, &ue) {
+		return rs, err
+	}
+	r := store.NewDetailedReport(false)
+	if rs != nil {
+		r.Add(rs)
+	}
+	r.Add(store.FromError(ue))
+	return r, nil
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func requireGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+}
+
+func Test_HistoryRequiresVersioning(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	_, _, err := ExecuteCmd(createHistoryCmd(jwt.AccountClaim), "A")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "history enable")
+}
+
+func Test_HistoryAccount(t *testing.T) {
+	requireGit(t)
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	_, _, err := ExecuteCmd(createHistoryEnableCmd())
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createEditAccount(), "--tag", "a")
+	require.NoError(t, err)
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+
+	stdout, _, err := ExecuteCmd(createHistoryCmd(jwt.AccountClaim), "A")
+	require.NoError(t, err)
+	require.Contains(t, stdout, ac.ID)
+
+	s, err := GetStore()
+	require.NoError(t, err)
+	revs, err := s.History("accounts", "A", "A.jwt")
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	require.Equal(t, jwt.AccountClaim, revs[0].Kind)
+	require.Equal(t, "A", revs[0].Name)
+	require.Equal(t, ac.ID, revs[0].JwtID)
+}
+
+func Test_HistoryUnrecordedChangeWarns(t *testing.T) {
+	requireGit(t)
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	_, _, err := ExecuteCmd(createHistoryEnableCmd())
+	require.NoError(t, err)
+
+	// git can't stage anything while another git process holds the index
+	lock := filepath.Join(ts.Store.Dir, ".git", "index.lock")
+	require.NoError(t, ioutil.WriteFile(lock, nil, 0600))
+	defer os.Remove(lock)
+
+	_, stderr, err := ExecuteCmd(createEditAccount(), "--tag", "a")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "was changed but the change was not recorded")
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Contains(t, ac.Tags, "a")
+}
+
+func Test_HistoryRedactsSeeds(t *testing.T) {
+	requireGit(t)
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	_, _, err := ExecuteCmd(createHistoryEnableCmd())
+	require.NoError(t, err)
+
+	seed, err := ts.OperatorKey.Seed()
+	require.NoError(t, err)
+	args := []string{"A", "--tag", "x", "-K", string(seed)}
+	oargs := os.Args
+	os.Args = append([]string{"nsc", "edit", "account"}, args...)
+	defer func() { os.Args = oargs }()
+	_, _, err = ExecuteCmd(HoistRootFlags(createEditAccount()), args...)
+	require.NoError(t, err)
+
+	stdout, _, err := ExecuteCmd(createHistoryCmd(jwt.AccountClaim), "A")
+	require.NoError(t, err)
+	require.NotContains(t, stdout, string(seed))
+
+	out, err := exec.Command("git", "-C", ts.Store.Dir, "log", "--format=%B").CombinedOutput()
+	require.NoError(t, err)
+	require.NotContains(t, string(out), string(seed))
+	require.Contains(t, string(out), "-K [redacted]")
+}
+
+func Test_RollbackAccount(t *testing.T) {
+	requireGit(t)
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	_, _, err := ExecuteCmd(createHistoryEnableCmd())
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createEditAccount(), "--tag", "a")
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createEditAccount(), "--tag", "b")
+	require.NoError(t, err)
+
+	s, err := GetStore()
+	require.NoError(t, err)
+	revs, err := s.History("accounts", "A", "A.jwt")
+	require.NoError(t, err)
+	require.Len(t, revs, 3)
+
+	_, _, err = ExecuteCmd(createRollbackCmd(jwt.AccountClaim), "A", "--revision", revs[1].ShortID())
+	require.NoError(t, err)
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, revs[1].JwtID, ac.ID)
+	require.ElementsMatch(t, []string{"a"}, ac.Tags)
+
+	_, _, err = ExecuteCmd(createRollbackCmd(jwt.AccountClaim), "A", "--revision", revs[1].ShortID())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "already at revision")
+}
+
+func Test_RollbackUser(t *testing.T) {
+	requireGit(t)
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddUser(t, "A", "U")
+	_, _, err := ExecuteCmd(createHistoryEnableCmd())
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createEditUserCmd(), "--allow-pub", "foo")
+	require.NoError(t, err)
+
+	s, err := GetStore()
+	require.NoError(t, err)
+	revs, err := s.History("accounts", "A", "users", "U.jwt")
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+
+	_, _, err = ExecuteCmd(createRollbackCmd(jwt.UserClaim), "U", "--revision", revs[1].ID)
+	require.NoError(t, err)
+
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Empty(t, uc.Pub.Allow)
+}
+
+func Test_HistoryJsonOutput(t *testing.T) {
+	requireGit(t)
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	_, _, err := ExecuteCmd(createHistoryEnableCmd())
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createEditAccount(), "--tag", "a")
+	require.NoError(t, err)
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+
+	stdout, _, err := ExecuteCmd(HoistRootFlags(createHistoryCmd(jwt.AccountClaim)), "A", "--output", "json")
+	require.NoError(t, err)
+	var out historyOutput
+	require.NoError(t, json.Unmarshal([]byte(stdout), &out))
+	require.Equal(t, jwt.AccountClaim, out.Kind)
+	require.Equal(t, "A", out.Name)
+	require.Len(t, out.Revisions, 2)
+	require.Equal(t, ac.ID, out.Revisions[0].JwtID)
+	require.Empty(t, out.JWT)
+
+	rev := out.Revisions[1].ID
+	stdout, _, err = ExecuteCmd(HoistRootFlags(createHistoryCmd(jwt.AccountClaim)), "A", "--revision", rev, "--output", "json")
+	require.NoError(t, err)
+	out = historyOutput{}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &out))
+	require.Equal(t, rev, out.Revision)
+	old, err := jwt.DecodeAccountClaims(out.JWT)
+	require.NoError(t, err)
+	require.Empty(t, old.Tags)
+}
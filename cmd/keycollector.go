@@ -94,7 +94,7 @@ func (p *KeyCollectorParams) handleAccount(ctx ActionCtx, parent string, name st
 
 	var keys KeyList
 
-	ac, err := s.ReadAccountClaim(name)
+	ac, err := p.indexedEntity(s, store.NewAccountNotExistErr(name), store.Accounts, name, store.JwtName(name))
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +102,7 @@ func (p *KeyCollectorParams) handleAccount(ctx ActionCtx, parent string, name st
 	aki.Parent = parent
 	aki.Name = ac.Name
 	aki.ExpectedKind = nkeys.PrefixByteAccount
-	aki.Pub = ac.Subject
+	aki.Pub = ac.Key
 	aki.Resolve(ks)
 	keys = append(keys, &aki)
 
@@ -121,7 +121,7 @@ func (p *KeyCollectorParams) handleUsers(ctx ActionCtx, account string) (KeyList
 	var keys KeyList
 
 	s := ctx.StoreCtx().Store
-	ac, err := s.ReadAccountClaim(account)
+	ac, err := p.indexedEntity(s, store.NewAccountNotExistErr(account), store.Accounts, account, store.JwtName(account))
 	if err != nil {
 		return nil, err
 	}
@@ -136,7 +136,7 @@ func (p *KeyCollectorParams) handleUsers(ctx ActionCtx, account string) (KeyList
 		if err != nil {
 			return nil, err
 		}
-		uk.Parent = ac.Subject
+		uk.Parent = ac.Key
 		keys = append(keys, uk)
 	}
 	return keys, nil
@@ -146,18 +146,31 @@ func (p *KeyCollectorParams) handleUser(ctx ActionCtx, account string, name stri
 	s := ctx.StoreCtx().Store
 	ks := ctx.StoreCtx().KeyStore
 
-	uc, err := s.ReadUserClaim(account, name)
+	uc, err := p.indexedEntity(s, store.NewUserNotExistErr(name), store.Accounts, account, store.Users, store.JwtName(name))
 	if err != nil {
 		return nil, err
 	}
 	var uki Key
 	uki.Name = uc.Name
-	uki.Pub = uc.Subject
+	uki.Pub = uc.Key
 	uki.ExpectedKind = nkeys.PrefixByteUser
 	uki.Resolve(ks)
 	return &uki, nil
 }
 
+// indexedEntity returns the keys of the JWT at the specified path from the
+// store's key index, rather than decoding the JWT
+func (p *KeyCollectorParams) indexedEntity(s *store.Store, notFound error, name ...string) (*store.IndexEntry, error) {
+	e, err := s.IndexedEntity(name...)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, notFound
+	}
+	return e, nil
+}
+
 func (p *KeyCollectorParams) Run(ctx ActionCtx) (KeyList, error) {
 	keys, err := p.handleOperator(ctx)
 	if err != nil {
@@ -274,10 +287,10 @@ type Key struct {
 }
 
 func (k *Key) Resolve(ks store.KeyStore) {
-	kp, _ := ks.GetKeyPair(k.Pub)
-	if kp != nil {
+	// encrypted keys are resolved without the passphrase
+	pk, _ := ks.GetPublicKey(k.Pub)
+	if pk != "" {
 		k.KeyPath = ks.GetKeyPath(k.Pub)
-		pk, _ := kp.PublicKey()
 		k.Invalid = pk != k.Pub
 	}
 }
@@ -289,12 +302,17 @@ func (k *Key) HasKey() bool {
 type Keys struct {
 	KeyList
 	MessageFn func(ks Keys) string
+	DataFn    func(ks Keys) interface{}
 }
 
 func (keys Keys) Message() string {
 	return keys.MessageFn(keys)
 }
 
+func (keys Keys) Structured() interface{} {
+	return keys.DataFn(keys)
+}
+
 type KeyList []*Key
 
 func (ks KeyList) Code() store.StatusCode {
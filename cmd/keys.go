@@ -33,24 +33,25 @@ func init() {
 func createMigrateKeysCmd() *cobra.Command {
 	var cmd = &cobra.Command{
 		Use:   "migrate",
-		Short: "migrates keystore to new layout, original keystore is preserved",
+		Short: "migrates keystore to new layout, original keystore is preserved (see 'store upgrade')",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			migration, err := store.KeysNeedMigration()
+			dir := store.GetKeysDir()
+			steps, err := store.KeyStoreUpgrades.Pending(dir)
 			if err != nil {
 				return err
 			}
-			if !migration {
-				cmd.Printf("keystore %#q does not need migration\n", AbbrevHomePaths(store.GetKeysDir()))
+			if len(steps) == 0 {
+				cmd.Printf("keystore %#q does not need migration\n", AbbrevHomePaths(dir))
 				return nil
 			}
 
-			old, err := store.Migrate()
+			backup, _, err := store.KeyStoreUpgrades.Upgrade(dir)
 			if err != nil {
 				return err
 			}
-			cmd.Printf("keystore %#q was migrated - old store was renamed to %#q - remove at your convenience\n",
-				AbbrevHomePaths(store.GetKeysDir()),
-				AbbrevHomePaths(old))
+			cmd.Printf("keystore %#q was migrated - the original keystore was saved to %#q - remove at your convenience\n",
+				AbbrevHomePaths(dir),
+				AbbrevHomePaths(backup))
 
 			return nil
 		},
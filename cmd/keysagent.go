@@ -0,0 +1,142 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	keysCmd.AddCommand(createKeysAgentCmd())
+}
+
+func createKeysAgentCmd() *cobra.Command {
+	var socket string
+	var timeout time.Duration
+	var cmd = &cobra.Command{
+		Use:   "agent",
+		Short: "Run an agent that signs with the operator and account keys",
+		Long: fmt.Sprintf(`Run an agent that signs with the operator and account keys
+
+The agent reads the operator and account keys in the keystore once,
+and holds them in memory. Other nsc commands sign JWTs with the agent
+instead of reading the keys, so an encrypted keystore is unlocked only
+when the agent starts. The agent only signs, seeds are never sent to
+its clients. New keys are encrypted by the agent.
+
+The agent listens on a Unix socket only accessible to the current user.
+Commands find the agent with %s, or at the default socket in the
+keystore. When no requests are made within the timeout, the keys are
+wiped and the agent exits.`, store.NscAgentSockEnv),
+		Example: `nsc keys agent
+nsc keys agent --timeout 8h
+nsc keys agent --socket /tmp/nsc.sock`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := startKeyAgent(socket, timeout)
+			if err != nil {
+				return err
+			}
+			cmd.Printf("agent holding %d keys is listening on %#q\n", len(a.Keys()), AbbrevHomePaths(socket))
+			if socket != store.AgentSocketPath() {
+				cmd.Printf("set %s=%s to use the agent\n", store.NscAgentSockEnv, socket)
+			}
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(sigs)
+			go func() {
+				select {
+				case <-sigs:
+					a.Close()
+				case <-a.Done():
+				}
+			}()
+			if err := a.Serve(); err != nil {
+				return err
+			}
+			cmd.Println("agent stopped")
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&socket, "socket", "", store.AgentSocketPath(), "path to the agent socket")
+	cmd.Flags().DurationVarP(&timeout, "timeout", "", time.Hour, "stop the agent after the time without requests, 0 never stops")
+	return cmd
+}
+
+// startKeyAgent loads the operator and account keys into a new agent
+// listening on the socket
+func startKeyAgent(socket string, timeout time.Duration) (*store.Agent, error) {
+	var pp string
+	if store.IsEncryptedKeyStore() {
+		pp = os.Getenv(store.NKeysPassphraseEnv)
+		if pp == "" {
+			var err error
+			if pp, err = promptPassphrase(); err != nil {
+				return nil, err
+			}
+		}
+		if err := store.VerifyPassphrase(pp); err != nil {
+			return nil, err
+		}
+		store.SetPassphrase(pp)
+		defer store.ClearPassphrase()
+	}
+
+	ks := store.NewKeyStore(GetConfig().Operator)
+	pubs, err := ks.AllKeys()
+	if err != nil {
+		return nil, err
+	}
+	var keys []nkeys.KeyPair
+	for _, pk := range pubs {
+		if !strings.HasPrefix(pk, "O") && !strings.HasPrefix(pk, "A") {
+			continue
+		}
+		seed, err := ks.GetSeed(pk)
+		if err != nil {
+			return nil, fmt.Errorf("error reading key %q: %v", pk, err)
+		}
+		kp, err := nkeys.FromSeed([]byte(seed))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing key %q: %v", pk, err)
+		}
+		keys = append(keys, kp)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("keystore %#q doesn't contain operator or account keys", AbbrevHomePaths(store.GetKeysDir()))
+	}
+
+	a, err := store.NewAgent(keys, pp, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Listen(socket); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_KeysAgent(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	defer encryptTestKeyStore(t, "a passphrase")()
+
+	sock := filepath.Join(MakeTempDir(t), store.AgentSocketName)
+	require.NoError(t, os.Setenv(store.NscAgentSockEnv, sock))
+	defer os.Unsetenv(store.NscAgentSockEnv)
+	defer store.ResetAgent()
+	a, err := startKeyAgent(sock, time.Minute)
+	require.NoError(t, err)
+	defer a.Close()
+	go a.Serve()
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Contains(t, a.Keys(), ac.Subject)
+	require.Contains(t, a.Keys(), ac.Issuer)
+
+	// the keystore is locked, the agent signs
+	require.NoError(t, os.Unsetenv(store.NKeysPassphraseEnv))
+	store.ClearPassphrase()
+	_, _, err = ExecuteCmd(createEditAccount(), "--tag", "x")
+	require.NoError(t, err)
+	ac, err = ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Contains(t, ac.Tags, "x")
+
+	ts.AddUser(t, "A", "U")
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, ac.Subject, uc.Issuer)
+	d, err := ioutil.ReadFile(ts.KeyStore.GetKeyPath(uc.Subject))
+	require.NoError(t, err)
+	require.True(t, store.IsSealed(d))
+}
+
+func Test_KeysAgentRequiresPassphrase(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	defer encryptTestKeyStore(t, "a passphrase")()
+	require.NoError(t, os.Unsetenv(store.NKeysPassphraseEnv))
+
+	_, err := startKeyAgent(filepath.Join(MakeTempDir(t), store.AgentSocketName), time.Minute)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "keystore is encrypted")
+}
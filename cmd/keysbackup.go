@@ -0,0 +1,220 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+// KeyShareExtension is the extension of key share files
+const KeyShareExtension = ".share"
+
+func init() {
+	keysCmd.AddCommand(createKeysBackupCmd())
+	keysCmd.AddCommand(createKeysRestoreCmd())
+}
+
+// KeyShare is a file with one of the shares of a seed
+type KeyShare struct {
+	Version   int    `json:"version"`
+	PublicKey string `json:"public_key"`
+	Index     int    `json:"index"`
+	Shares    int    `json:"shares"`
+	Threshold int    `json:"threshold"`
+	Data      []byte `json:"data"`
+}
+
+func keyShareName(pk string, i int) string {
+	return fmt.Sprintf("%s.%d%s", pk, i, KeyShareExtension)
+}
+
+func createKeysBackupCmd() *cobra.Command {
+	var shares int
+	var threshold int
+	var dir string
+	var force bool
+	var cmd = &cobra.Command{
+		Use:   "backup",
+		Short: "Split a seed into shares for backup",
+		Long: `Split a seed into shares for backup
+
+The seed of the public key is split with Shamir's secret sharing into
+the specified number of share files. Any threshold number of shares
+restore the seed with 'nsc keys restore', fewer shares reveal nothing
+about it. Give each share to a different person.`,
+		Example: `nsc keys backup --shares 5 --threshold 3 <public key>
+nsc keys backup --shares 3 --threshold 2 --dir /media/usb <public key>`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pk := args[0]
+			if _, err := store.PubKeyType(pk); err != nil {
+				return fmt.Errorf("%q is not a public key", pk)
+			}
+			ks := store.NewKeyStore(GetConfig().Operator)
+			if !ks.HasPrivateKey(pk) {
+				return fmt.Errorf("the keystore doesn't contain the seed for %q", pk)
+			}
+			seed, err := ks.GetSeed(pk)
+			if err != nil {
+				return err
+			}
+			parts, err := store.SplitSecret([]byte(seed), shares, threshold)
+			if err != nil {
+				return err
+			}
+			if err := MaybeMakeDir(dir); err != nil {
+				return err
+			}
+			var files []string
+			for i := range parts {
+				fp := filepath.Join(dir, keyShareName(pk, i+1))
+				if _, err := os.Stat(fp); err == nil && !force {
+					return fmt.Errorf("%#q already exists - use --force to overwrite it", AbbrevHomePaths(fp))
+				}
+				files = append(files, fp)
+			}
+			for i, d := range parts {
+				share := KeyShare{Version: 1, PublicKey: pk, Index: i + 1, Shares: shares, Threshold: threshold, Data: d}
+				j, err := json.MarshalIndent(share, "", " ")
+				if err != nil {
+					return err
+				}
+				if err := ioutil.WriteFile(files[i], j, 0600); err != nil {
+					return err
+				}
+				cmd.Printf("wrote share %d of %d to %#q\n", i+1, shares, AbbrevHomePaths(files[i]))
+			}
+			cmd.Printf("%d of the %d shares restore the seed for %q\n", threshold, shares, pk)
+			return nil
+		},
+	}
+	cmd.Flags().IntVarP(&shares, "shares", "", 5, "number of shares")
+	cmd.Flags().IntVarP(&threshold, "threshold", "", 3, "number of shares required to restore the seed")
+	cmd.Flags().StringVarP(&dir, "dir", "", ".", "directory for the share files")
+	cmd.Flags().BoolVarP(&force, "force", "F", false, "overwrite existing share files")
+	return cmd
+}
+
+// readKeyShares reads the share files, they must be for the same key
+func readKeyShares(files []string) ([]KeyShare, error) {
+	var shares []KeyShare
+	for _, fp := range files {
+		d, err := ioutil.ReadFile(fp)
+		if err != nil {
+			return nil, err
+		}
+		var s KeyShare
+		if err := json.Unmarshal(d, &s); err != nil {
+			return nil, fmt.Errorf("%#q is not a key share: %v", fp, err)
+		}
+		if s.Version != 1 || s.PublicKey == "" || len(s.Data) == 0 {
+			return nil, fmt.Errorf("%#q is not a key share", fp)
+		}
+		if len(shares) > 0 && s.PublicKey != shares[0].PublicKey {
+			return nil, fmt.Errorf("%#q is a share for %q not %q", fp, s.PublicKey, shares[0].PublicKey)
+		}
+		shares = append(shares, s)
+	}
+	if len(shares) == 0 {
+		return nil, errors.New("specify the share files")
+	}
+	if len(shares) < shares[0].Threshold {
+		return nil, fmt.Errorf("%d shares are required to restore %q, only %d specified", shares[0].Threshold, shares[0].PublicKey, len(shares))
+	}
+	return shares, nil
+}
+
+func createKeysRestoreCmd() *cobra.Command {
+	var params KeysRestoreParams
+	var cmd = &cobra.Command{
+		Use:   "restore",
+		Short: "Restore a seed from backup shares",
+		Long: `Restore a seed from backup shares
+
+The shares written by 'nsc keys backup' are combined, the resulting seed
+is verified against the public key of the shares and stored in the
+keystore.`,
+		Example:      `nsc keys restore <share file> <share file> <share file>`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunMaybeStorelessAction(cmd, args, &params)
+		},
+	}
+	return cmd
+}
+
+type KeysRestoreParams struct {
+	pk string
+	kp nkeys.KeyPair
+}
+
+func (p *KeysRestoreParams) SetDefaults(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysRestoreParams) PreInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysRestoreParams) Load(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysRestoreParams) PostInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysRestoreParams) Validate(ctx ActionCtx) error {
+	shares, err := readKeyShares(ctx.Args())
+	if err != nil {
+		return err
+	}
+	p.pk = shares[0].PublicKey
+	var parts [][]byte
+	for _, s := range shares {
+		parts = append(parts, s.Data)
+	}
+	seed, err := store.CombineShares(parts)
+	if err != nil {
+		return err
+	}
+	kp, err := nkeys.FromSeed(seed)
+	if err != nil || !store.Match(p.pk, kp) {
+		return fmt.Errorf("the shares don't restore the seed for %q - a share is damaged or from another backup", p.pk)
+	}
+	p.kp = kp
+	return nil
+}
+
+func (p *KeysRestoreParams) Run(ctx ActionCtx) (store.Status, error) {
+	ks := store.NewKeyStore(GetConfig().Operator)
+	fp, err := ks.Store(p.kp)
+	if err != nil {
+		return nil, err
+	}
+	return store.OKStatus("restored the seed for %q to %#q", p.pk, AbbrevHomePaths(fp)), nil
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_KeysBackupRestore(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	opk := ts.GetOperatorPublicKey(t)
+	seed, err := ts.KeyStore.GetSeed(opk)
+	require.NoError(t, err)
+
+	dir := filepath.Join(ts.Dir, "shares")
+	_, stderr, err := ExecuteCmd(createKeysBackupCmd(), "--shares", "5", "--threshold", "3", "--dir", dir, opk)
+	require.NoError(t, err)
+	require.Contains(t, stderr, "3 of the 5 shares")
+	for i := 1; i <= 5; i++ {
+		require.FileExists(t, filepath.Join(dir, keyShareName(opk, i)))
+	}
+
+	// shares are not overwritten
+	_, _, err = ExecuteCmd(createKeysBackupCmd(), "--dir", dir, opk)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "already exists")
+
+	require.NoError(t, ts.KeyStore.Remove(opk))
+	require.False(t, ts.KeyStore.HasPrivateKey(opk))
+
+	// not enough shares
+	_, _, err = ExecuteCmd(createKeysRestoreCmd(), filepath.Join(dir, keyShareName(opk, 1)), filepath.Join(dir, keyShareName(opk, 2)))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "3 shares are required")
+
+	_, _, err = ExecuteCmd(createKeysRestoreCmd(), filepath.Join(dir, keyShareName(opk, 5)), filepath.Join(dir, keyShareName(opk, 2)), filepath.Join(dir, keyShareName(opk, 4)))
+	require.NoError(t, err)
+	restored, err := ts.KeyStore.GetSeed(opk)
+	require.NoError(t, err)
+	require.Equal(t, seed, restored)
+}
+
+func Test_KeysRestoreMixedShares(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	opk := ts.GetOperatorPublicKey(t)
+	apk := ts.GetAccountPublicKey(t, "A")
+
+	dir := filepath.Join(ts.Dir, "shares")
+	_, _, err := ExecuteCmd(createKeysBackupCmd(), "--shares", "2", "--threshold", "2", "--dir", dir, opk)
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createKeysBackupCmd(), "--shares", "2", "--threshold", "2", "--dir", dir, apk)
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createKeysRestoreCmd(), filepath.Join(dir, keyShareName(opk, 1)), filepath.Join(dir, keyShareName(apk, 2)))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is a share for")
+}
+
+func Test_KeysBackupRequiresSeed(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	_, upk, _ := CreateUserKey(t)
+	_, _, err := ExecuteCmd(createKeysBackupCmd(), "--dir", ts.Dir, upk)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "doesn't contain the seed")
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mattn/go-isatty"
+	cli "github.com/nats-io/cliprompts/v2"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+// NKeysNewPassphraseEnv names the environment variable with the new
+// passphrase when changing the passphrase of the keystore
+const NKeysNewPassphraseEnv = "NKEYS_NEW_PASSPHRASE"
+
+func init() {
+	keysCmd.AddCommand(createKeysEncryptCmd())
+	keysCmd.AddCommand(createKeysPassphraseCmd())
+	store.PassphraseSource = promptPassphrase
+}
+
+// canPrompt returns true if the user can be asked for a passphrase
+func canPrompt() bool {
+	if InteractiveFlag {
+		return true
+	}
+	return isatty.IsTerminal(os.Stdin.Fd())
+}
+
+func promptPassphrase() (string, error) {
+	if !canPrompt() {
+		return "", store.ErrKeyStoreLocked
+	}
+	return cli.Password(fmt.Sprintf("passphrase for keystore %s", AbbrevHomePaths(store.GetKeysDir())))
+}
+
+// newPassphrase reads a new passphrase from the environment variable,
+// or asks the user to enter it twice
+func newPassphrase(env string) (string, error) {
+	if v := os.Getenv(env); v != "" {
+		return v, nil
+	}
+	if !canPrompt() {
+		return "", fmt.Errorf("set %s or run interactively to enter the passphrase", env)
+	}
+	v, err := cli.Password("new keystore passphrase", cli.Val(func(s string) error {
+		if len(s) < 8 {
+			return errors.New("passphrase must be at least 8 characters")
+		}
+		return nil
+	}))
+	if err != nil {
+		return "", err
+	}
+	c, err := cli.Password("repeat the new keystore passphrase")
+	if err != nil {
+		return "", err
+	}
+	if v != c {
+		return "", errors.New("passphrases don't match")
+	}
+	return v, nil
+}
+
+// errKeyStoreDryRun rejects dry runs of commands that rewrite the keys
+// in place, their changes can't be kept in memory
+var errKeyStoreDryRun = errors.New("--dry-run is not supported - the keys in the keystore are rewritten in place")
+
+func createKeysEncryptCmd() *cobra.Command {
+	var params KeysEncryptParams
+	var cmd = &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt the keys in the keystore with a passphrase",
+		Long: fmt.Sprintf(`Encrypt the keys in the keystore with a passphrase
+
+Seeds are encrypted with AES-256-GCM using a key derived from the
+passphrase with scrypt. Once the keystore is encrypted, new keys are
+stored encrypted and keys are decrypted as they are used. The passphrase
+is read from %s, or entered when prompted.
+
+Creds files are not encrypted, they are read by NATS clients.`, store.NKeysPassphraseEnv),
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunMaybeStorelessAction(cmd, args, &params)
+		},
+	}
+	return cmd
+}
+
+type KeysEncryptParams struct {
+	passphrase string
+}
+
+func (p *KeysEncryptParams) SetDefaults(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysEncryptParams) PreInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysEncryptParams) Load(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysEncryptParams) PostInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysEncryptParams) Validate(ctx ActionCtx) error {
+	if DryRunFlag {
+		return errKeyStoreDryRun
+	}
+	var err error
+	p.passphrase, err = newPassphrase(store.NKeysPassphraseEnv)
+	return err
+}
+
+func (p *KeysEncryptParams) Run(ctx ActionCtx) (store.Status, error) {
+	ks := store.NewKeyStore(GetConfig().Operator)
+	keys, err := ks.EncryptKeyStore(p.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return store.OKStatus("encrypted %d keys in keystore %#q", len(keys), AbbrevHomePaths(store.GetKeysDir())), nil
+}
+
+func createKeysPassphraseCmd() *cobra.Command {
+	var params KeysPassphraseParams
+	var cmd = &cobra.Command{
+		Use:   "passphrase",
+		Short: "Change the passphrase of an encrypted keystore",
+		Long: fmt.Sprintf(`Change the passphrase of an encrypted keystore
+
+The current passphrase is read from %s and the new passphrase from %s,
+or they are entered when prompted.`, store.NKeysPassphraseEnv, NKeysNewPassphraseEnv),
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunMaybeStorelessAction(cmd, args, &params)
+		},
+	}
+	return cmd
+}
+
+type KeysPassphraseParams struct {
+	old        string
+	passphrase string
+}
+
+func (p *KeysPassphraseParams) SetDefaults(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysPassphraseParams) PreInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysPassphraseParams) Load(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysPassphraseParams) PostInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *KeysPassphraseParams) Validate(ctx ActionCtx) error {
+	if DryRunFlag {
+		return errKeyStoreDryRun
+	}
+	if !store.IsEncryptedKeyStore() {
+		return fmt.Errorf("keystore %#q is not encrypted - encrypt it with '%s keys encrypt'", AbbrevHomePaths(store.GetKeysDir()), GetToolName())
+	}
+	p.old = os.Getenv(store.NKeysPassphraseEnv)
+	if p.old == "" {
+		var err error
+		if p.old, err = promptPassphrase(); err != nil {
+			return err
+		}
+	}
+	if err := store.VerifyPassphrase(p.old); err != nil {
+		return err
+	}
+	var err error
+	p.passphrase, err = newPassphrase(NKeysNewPassphraseEnv)
+	return err
+}
+
+func (p *KeysPassphraseParams) Run(ctx ActionCtx) (store.Status, error) {
+	ks := store.NewKeyStore(GetConfig().Operator)
+	keys, err := ks.ChangePassphrase(p.old, p.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	r := store.NewReport(store.OK, "changed the passphrase of %d keys in keystore %#q", len(keys), AbbrevHomePaths(store.GetKeysDir()))
+	if os.Getenv(store.NKeysPassphraseEnv) != "" {
+		r.AddWarning("update %s with the new passphrase", store.NKeysPassphraseEnv)
+	}
+	return r, nil
+}
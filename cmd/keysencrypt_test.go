@@ -0,0 +1,110 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func encryptTestKeyStore(t *testing.T, passphrase string) func() {
+	kdf := store.DefaultKDF
+	store.DefaultKDF.N = 1 << 10
+	require.NoError(t, os.Setenv(store.NKeysPassphraseEnv, passphrase))
+	_, _, err := ExecuteCmd(createKeysEncryptCmd())
+	require.NoError(t, err)
+	store.ClearPassphrase()
+	return func() {
+		store.DefaultKDF = kdf
+		require.NoError(t, os.Unsetenv(store.NKeysPassphraseEnv))
+		require.NoError(t, os.Unsetenv(NKeysNewPassphraseEnv))
+	}
+}
+
+func Test_KeysEncrypt(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	defer encryptTestKeyStore(t, "a passphrase")()
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	d, err := ioutil.ReadFile(ts.KeyStore.GetKeyPath(ac.Subject))
+	require.NoError(t, err)
+	require.True(t, store.IsSealed(d))
+
+	// keys are decrypted as they are used
+	ts.AddUser(t, "A", "U")
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, ac.Subject, uc.Issuer)
+	d, err = ioutil.ReadFile(ts.KeyStore.GetKeyPath(uc.Subject))
+	require.NoError(t, err)
+	require.True(t, store.IsSealed(d))
+	require.FileExists(t, ts.KeyStore.GetUserCredsPath("A", "U"))
+
+	// keys are listed without the passphrase
+	require.NoError(t, os.Unsetenv(store.NKeysPassphraseEnv))
+	store.ClearPassphrase()
+	_, stderr, err := ExecuteCmd(createListKeysCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, uc.Subject)
+	require.NotContains(t, stderr, "!")
+
+	_, _, err = ExecuteCmd(createEditAccount(), "--tag", "x")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "keystore is encrypted")
+}
+
+func Test_KeysEncryptRequiresPassphrase(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	_, _, err := ExecuteCmd(createKeysEncryptCmd())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), store.NKeysPassphraseEnv)
+	require.False(t, store.IsEncryptedKeyStore())
+}
+
+func Test_KeysPassphrase(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	_, _, err := ExecuteCmd(createKeysPassphraseCmd())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not encrypted")
+
+	defer encryptTestKeyStore(t, "a passphrase")()
+
+	require.NoError(t, os.Setenv(NKeysNewPassphraseEnv, "new passphrase"))
+	_, stderr, err := ExecuteCmd(createKeysPassphraseCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, "changed the passphrase")
+	require.NoError(t, store.VerifyPassphrase("new passphrase"))
+
+	store.ClearPassphrase()
+	require.NoError(t, os.Setenv(store.NKeysPassphraseEnv, "new passphrase"))
+	ts.AddUser(t, "A", "U")
+
+	store.ClearPassphrase()
+	require.NoError(t, os.Setenv(store.NKeysPassphraseEnv, "a passphrase"))
+	_, _, err = ExecuteCmd(createKeysPassphraseCmd())
+	require.Error(t, err)
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+	"github.com/xlab/tablewriter"
+)
+
+func init() {
+	keysCmd.AddCommand(createKeysUsageCmd())
+}
+
+func createKeysUsageCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "usage",
+		Short: "Show where a public key is used",
+		Long: `Show where a public key is used
+
+All operators in the store directory are searched for the operator,
+account or user public key. Usages are the subject and issuer of JWTs,
+signing keys, import source accounts, activations, revocations, the
+keystore and the creds files that contain the key.`,
+		Example:      `nsc keys usage <public key>`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pk := args[0]
+			if _, err := store.PubKeyType(pk); err != nil {
+				return fmt.Errorf("%q is not a public key", pk)
+			}
+			usages, err := findKeyUsages(pk)
+			if err != nil {
+				return err
+			}
+			if StructuredOutput() {
+				if usages == nil {
+					usages = []KeyUsage{}
+				}
+				return WriteOutput(usages)
+			}
+			if len(usages) == 0 {
+				cmd.Printf("key %q is not used\n", pk)
+				return nil
+			}
+			table := tablewriter.CreateTable()
+			table.UTF8Box()
+			table.AddTitle(fmt.Sprintf("Usages of %s", pk))
+			table.AddHeaders("Operator", "Entity", "Usage")
+			for _, u := range usages {
+				table.AddRow(u.Operator, u.Entity, u.Usage)
+			}
+			return Write("--", []byte(table.Render()))
+		},
+	}
+	return cmd
+}
+
+// KeyUsage is a place where a public key is used
+type KeyUsage struct {
+	Operator string `json:"operator,omitempty"`
+	Entity   string `json:"entity"`
+	Usage    string `json:"usage"`
+}
+
+type keyUsages struct {
+	pk       string
+	operator string
+	usages   []KeyUsage
+}
+
+func (u *keyUsages) add(entity string, format string, args ...interface{}) {
+	u.usages = append(u.usages, KeyUsage{Operator: u.operator, Entity: entity, Usage: fmt.Sprintf(format, args...)})
+}
+
+// findKeyUsages searches the operators in the store root, the keystore
+// and the creds files for the public key
+func findKeyUsages(pk string) ([]KeyUsage, error) {
+	u := &keyUsages{pk: pk}
+	config := GetConfig()
+	for _, o := range config.ListOperators() {
+		if o == "" {
+			continue
+		}
+		s, err := config.LoadStore(o)
+		if err != nil {
+			return nil, err
+		}
+		u.operator = o
+		if err := u.operatorUsages(s); err != nil {
+			return nil, err
+		}
+	}
+	u.operator = ""
+	if err := u.keyStoreUsages(); err != nil {
+		return nil, err
+	}
+	return u.usages, nil
+}
+
+func (u *keyUsages) operatorUsages(s *store.Store) error {
+	oc, err := s.ReadOperatorClaim()
+	if err != nil {
+		return err
+	}
+	entity := fmt.Sprintf("operator %s", oc.Name)
+	if oc.Subject == u.pk {
+		u.add(entity, "subject")
+	}
+	if oc.Issuer == u.pk && oc.Subject != u.pk {
+		u.add(entity, "issuer")
+	}
+	if oc.SigningKeys.Contains(u.pk) {
+		u.add(entity, "signing key")
+	}
+
+	accounts, err := s.ListSubContainers(store.Accounts)
+	if err != nil {
+		return err
+	}
+	for _, a := range accounts {
+		ac, err := s.ReadAccountClaim(a)
+		if err != nil {
+			if store.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		u.accountUsages(ac)
+
+		users, err := s.ListEntries(store.Accounts, a, store.Users)
+		if err != nil {
+			return err
+		}
+		for _, n := range users {
+			uc, err := s.ReadUserClaim(a, n)
+			if err != nil {
+				return err
+			}
+			entity := fmt.Sprintf("user %s/%s", a, uc.Name)
+			if uc.Subject == u.pk {
+				u.add(entity, "subject")
+			}
+			if uc.Issuer == u.pk {
+				u.add(entity, "issuer")
+			}
+			if uc.IssuerAccount == u.pk {
+				u.add(entity, "issuer account")
+			}
+		}
+	}
+	return nil
+}
+
+func (u *keyUsages) accountUsages(ac *jwt.AccountClaims) {
+	entity := fmt.Sprintf("account %s", ac.Name)
+	if ac.Subject == u.pk {
+		u.add(entity, "subject")
+	}
+	if ac.Issuer == u.pk {
+		u.add(entity, "issuer")
+	}
+	if ac.SigningKeys.Contains(u.pk) {
+		u.add(entity, "signing key")
+	}
+	if _, ok := ac.Revocations[u.pk]; ok {
+		u.add(entity, "revoked user")
+	}
+	for _, e := range ac.Exports {
+		if _, ok := e.Revocations[u.pk]; ok {
+			u.add(entity, "revoked activation for export %q", e.Subject)
+		}
+	}
+	for _, i := range ac.Imports {
+		if i.Account == u.pk {
+			u.add(entity, "source account of import %q", i.Subject)
+		}
+		if i.Token == "" {
+			continue
+		}
+		act, err := jwt.DecodeActivationClaims(i.Token)
+		if err != nil {
+			continue
+		}
+		if act.Subject == u.pk {
+			u.add(entity, "subject of the activation for import %q", i.Subject)
+		}
+		if act.Issuer == u.pk {
+			u.add(entity, "issuer of the activation for import %q", i.Subject)
+		}
+		if act.IssuerAccount == u.pk {
+			u.add(entity, "issuer account of the activation for import %q", i.Subject)
+		}
+	}
+}
+
+func (u *keyUsages) keyStoreUsages() error {
+	ks := store.NewKeyStore("")
+	keys, err := ks.AllKeys()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, k := range keys {
+		if k == u.pk {
+			u.add("keystore", "private key %#q", AbbrevHomePaths(ks.GetKeyPath(k)))
+			break
+		}
+	}
+
+	dir := filepath.Join(store.GetKeysDir(), store.CredsDir)
+	return filepath.Walk(dir, func(fp string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || filepath.Ext(fp) != store.CredsExtension {
+			return nil
+		}
+		d, err := ioutil.ReadFile(fp)
+		if err != nil {
+			return err
+		}
+		var where []string
+		if kp, err := jwt.ParseDecoratedNKey(d); err == nil {
+			if pk, err := kp.PublicKey(); err == nil && pk == u.pk {
+				where = append(where, "seed")
+			}
+		}
+		if token, err := jwt.ParseDecoratedJWT(d); err == nil {
+			if uc, err := jwt.DecodeUserClaims(token); err == nil {
+				if uc.Issuer == u.pk {
+					where = append(where, "issuer")
+				}
+				if uc.IssuerAccount == u.pk {
+					where = append(where, "issuer account")
+				}
+			}
+		}
+		if len(where) > 0 {
+			u.add("creds", "%s in %#q", strings.Join(where, ", "), AbbrevHomePaths(fp))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_KeysUsage(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	ts.AddExport(t, "A", jwt.Stream, "s", false)
+	ts.AddAccount(t, "B")
+	ts.AddImport(t, "A", "s", "B")
+	ts.AddUser(t, "A", "U")
+	_, spk, _ := CreateAccountKey(t)
+	_, _, err := ExecuteCmd(createEditAccount(), "--name", "A", "--sk", spk)
+	require.NoError(t, err)
+
+	apk := ts.GetAccountPublicKey(t, "A")
+	stdout, _, err := ExecuteCmd(createKeysUsageCmd(), apk)
+	require.NoError(t, err)
+	require.Contains(t, stdout, "account A")
+	require.Contains(t, stdout, "subject")
+	require.Contains(t, stdout, "user A/U")
+	require.Contains(t, stdout, `source account of import "s"`)
+	require.Contains(t, stdout, "issuer of the activation")
+	require.Contains(t, stdout, "private key")
+	require.Contains(t, stdout, "issuer in")
+
+	bpk := ts.GetAccountPublicKey(t, "B")
+	stdout, _, err = ExecuteCmd(createKeysUsageCmd(), bpk)
+	require.NoError(t, err)
+	require.Contains(t, stdout, "subject of the activation")
+
+	stdout, _, err = ExecuteCmd(createKeysUsageCmd(), spk)
+	require.NoError(t, err)
+	require.Contains(t, stdout, "signing key")
+
+	upk := ts.GetUserPublicKey(t, "A", "U")
+	_, _, err = ExecuteCmd(createRevokeUserCmd(), "--account", "A", "--name", "U")
+	require.NoError(t, err)
+	stdout, _, err = ExecuteCmd(createKeysUsageCmd(), upk)
+	require.NoError(t, err)
+	require.Contains(t, stdout, "revoked user")
+	require.Contains(t, stdout, "seed in")
+}
+
+func Test_KeysUsageNotUsed(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	_, upk, _ := CreateUserKey(t)
+	_, stderr, err := ExecuteCmd(createKeysUsageCmd(), upk)
+	require.NoError(t, err)
+	require.Contains(t, stderr, "is not used")
+
+	_, _, err = ExecuteCmd(createKeysUsageCmd(), "foo")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not a public key")
+}
@@ -57,7 +57,7 @@ func createListOperatorsCmd() *cobra.Command {
 				return errors.New("no store set - `env --store <dir>`")
 			}
 			operators := config.ListOperators()
-			if len(operators) == 0 {
+			if len(operators) == 0 && !StructuredOutput() {
 				fmt.Println("no operators defined - init an environment")
 			} else {
 				sort.Strings(operators)
@@ -82,7 +82,7 @@ func createListOperatorsCmd() *cobra.Command {
 					}
 					i.claims = c
 				}
-				cmd.Println(listEntities("Operators", infos, config.Operator))
+				return printEntities(cmd, "Operators", infos, config.Operator)
 			}
 
 			return nil
@@ -133,8 +133,7 @@ func createListAccountsCmd() *cobra.Command {
 				}
 				i.claims = ac
 			}
-			cmd.Println(listEntities("Accounts", infos, config.Account))
-			return nil
+			return printEntities(cmd, "Accounts", infos, config.Account)
 		},
 	}
 
@@ -199,8 +198,7 @@ func createListUsersCmd() *cobra.Command {
 				}
 				i.claims = uc
 			}
-			cmd.Println(listEntities("Users", infos, config.Account))
-			return nil
+			return printEntities(cmd, "Users", infos, config.Account)
 		},
 	}
 
@@ -210,6 +208,34 @@ func createListUsersCmd() *cobra.Command {
 	return cmd
 }
 
+// listedEntity is the structured form of a listed operator, account or user
+type listedEntity struct {
+	Name      string `json:"name"`
+	JwtName   string `json:"jwt_name,omitempty"`
+	PublicKey string `json:"public_key,omitempty"`
+	Current   bool   `json:"current,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func printEntities(cmd *cobra.Command, title string, infos []*listEntry, current string) error {
+	if !StructuredOutput() {
+		cmd.Println(listEntities(title, infos, current))
+		return nil
+	}
+	entities := []listedEntity{}
+	for _, v := range infos {
+		e := listedEntity{Name: v.name, Current: v.name == current}
+		if v.err != nil || v.claims == nil {
+			e.Error = fmt.Sprintf("error loading jwt - %v", v.err)
+		} else if c := v.claims.Claims(); c != nil {
+			e.JwtName = c.Name
+			e.PublicKey = c.Subject
+		}
+		entities = append(entities, e)
+	}
+	return WriteOutput(entities)
+}
+
 func listEntities(title string, infos []*listEntry, current string) string {
 	table := tablewriter.CreateTable()
 	table.UTF8Box()
@@ -0,0 +1,235 @@
+/*
+ * Copyright 2018-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+	"github.com/xlab/tablewriter"
+)
+
+func createListExpiringCmd() *cobra.Command {
+	var params ListExpiringParams
+	cmd := &cobra.Command{
+		Use:   "expiring",
+		Short: "List the operator, accounts, users and activations expiring soon",
+		Long: `List the operator, accounts, users and activations expiring soon
+
+The operator, the account, its users and the activation tokens embedded
+in its imports are listed if they expire within the specified duration.
+JWTs that already expired are listed too. Specify --all-accounts to list
+the entities of all accounts.`,
+		Example: `nsc list expiring
+nsc list expiring --within 30d
+nsc list expiring --within 2w --all-accounts`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().StringVarP(&params.within, "within", "", "30d", "list JWTs expiring within the duration - #m(inutes), #h(ours), #d(ays), #w(eeks), #M(onths), #y(ears) or yyyy-mm-dd")
+	cmd.Flags().BoolVarP(&params.allAccounts, "all-accounts", "A", false, "list the entities of all accounts (exclusive of --account)")
+	params.AccountContextParams.BindFlags(cmd)
+	return cmd
+}
+
+func init() {
+	listCmd.AddCommand(createListExpiringCmd())
+}
+
+type ListExpiringParams struct {
+	AccountContextParams
+	ExpiringParams
+}
+
+func (p *ListExpiringParams) SetDefaults(ctx ActionCtx) error {
+	return p.ExpiringParams.SetDefaults(ctx, &p.AccountContextParams)
+}
+
+func (p *ListExpiringParams) PreInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *ListExpiringParams) Load(ctx ActionCtx) error {
+	return p.ExpiringParams.Load(ctx, &p.AccountContextParams)
+}
+
+func (p *ListExpiringParams) PostInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *ListExpiringParams) Validate(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *ListExpiringParams) ReadOnly() bool {
+	return true
+}
+
+func (p *ListExpiringParams) Run(ctx ActionCtx) (store.Status, error) {
+	if StructuredOutput() {
+		return nil, WriteOutput(p.entities)
+	}
+	if len(p.entities) == 0 {
+		return nil, Write("--", []byte(fmt.Sprintf("no jwts expire within %s\n", p.within)))
+	}
+	table := tablewriter.CreateTable()
+	table.UTF8Box()
+	table.AddTitle(fmt.Sprintf("JWTs Expiring Within %s", p.within))
+	table.AddHeaders("Kind", "Name", "Account", "Signing Key", "Expires", "")
+	for _, e := range p.entities {
+		sk := ""
+		if e.SigningKey {
+			sk = "*"
+		}
+		table.AddRow(e.Kind, e.Name, e.Account, sk, RenderDate(e.Expires), HumanizedDate(e.Expires))
+	}
+	return nil, Write("--", []byte(table.Render()))
+}
+
+// ExpiringParams collects the JWTs expiring within a duration for
+// the operator and the selected accounts
+type ExpiringParams struct {
+	within      string
+	allAccounts bool
+	cutoff      int64
+	entities    []*expiringEntity
+}
+
+// expiringEntity is a JWT with an expiration
+type expiringEntity struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Account    string `json:"account,omitempty"`
+	PublicKey  string `json:"public_key"`
+	Issuer     string `json:"issuer"`
+	SigningKey bool   `json:"signing_key,omitempty"`
+	Expires    int64  `json:"expires"`
+	Expired    bool   `json:"expired,omitempty"`
+	claims     jwt.Claims
+}
+
+func (p *ExpiringParams) SetDefaults(ctx ActionCtx, account *AccountContextParams) error {
+	if p.allAccounts && account.Name != "" {
+		return errors.New("specify only one of --account or --all-accounts")
+	}
+	if err := account.SetDefaults(ctx); err != nil {
+		return err
+	}
+	if p.within == "" || p.within == "0" {
+		return errors.New("--within must specify a duration")
+	}
+	var err error
+	p.cutoff, err = ParseExpiry(p.within)
+	return err
+}
+
+// accounts returns the names of the selected accounts
+func (p *ExpiringParams) accounts(ctx ActionCtx, account *AccountContextParams) ([]string, error) {
+	if p.allAccounts {
+		return GetConfig().ListAccounts()
+	}
+	if account.Name == "" {
+		return nil, nil
+	}
+	if err := account.Validate(ctx); err != nil {
+		return nil, err
+	}
+	return []string{account.Name}, nil
+}
+
+func (p *ExpiringParams) expiring(c jwt.Claims) bool {
+	exp := c.Claims().Expires
+	return exp > 0 && exp <= p.cutoff
+}
+
+func (p *ExpiringParams) add(kind string, name string, account string, c jwt.Claims, signingKey bool) {
+	if !p.expiring(c) {
+		return
+	}
+	cd := c.Claims()
+	p.entities = append(p.entities, &expiringEntity{
+		Kind:       kind,
+		Name:       name,
+		Account:    account,
+		PublicKey:  cd.Subject,
+		Issuer:     cd.Issuer,
+		SigningKey: signingKey,
+		Expires:    cd.Expires,
+		Expired:    cd.Expires < time.Now().Unix(),
+		claims:     c,
+	})
+}
+
+// Load collects the expiring JWTs sorted by expiration
+func (p *ExpiringParams) Load(ctx ActionCtx, account *AccountContextParams) error {
+	s := ctx.StoreCtx().Store
+	oc, err := s.ReadOperatorClaim()
+	if err != nil {
+		return err
+	}
+	p.add("operator", oc.Name, "", oc, oc.Issuer != oc.Subject)
+
+	accounts, err := p.accounts(ctx, account)
+	if err != nil {
+		return err
+	}
+	for _, a := range accounts {
+		ac, err := s.ReadAccountClaim(a)
+		if err != nil {
+			if store.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		p.add("account", a, a, ac, ac.Issuer != oc.Subject)
+
+		users, err := s.ListEntries(store.Accounts, a, store.Users)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			uc, err := s.ReadUserClaim(a, u)
+			if err != nil {
+				return err
+			}
+			p.add("user", u, a, uc, uc.Issuer != ac.Subject)
+		}
+
+		// activations hosted on a url are not retrieved
+		for _, im := range ac.Imports {
+			if im.Token == "" || IsURL(im.Token) {
+				continue
+			}
+			act, err := jwt.DecodeActivationClaims(im.Token)
+			if err != nil {
+				continue
+			}
+			p.add("activation", im.Name, a, act, act.IssuerAccount != "")
+		}
+	}
+	sort.SliceStable(p.entities, func(i, j int) bool {
+		return p.entities[i].Expires < p.entities[j].Expires
+	})
+	return nil
+}
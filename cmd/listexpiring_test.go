@@ -0,0 +1,109 @@
+/*
+ * Copyright 2018-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ListExpiring(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	sk, spk, _ := CreateAccountKey(t)
+	_, _, err := ExecuteCmd(createEditAccount(), "--sk", spk)
+	require.NoError(t, err)
+
+	addSignedUser(t, "A", "U", sk, "--expiry", "10d")
+	_, _, err = ExecuteCmd(CreateAddUserCmd(), "--name", "V", "--expiry", "1y")
+	require.NoError(t, err)
+	ts.AddUser(t, "A", "W")
+
+	stdout, _, err := ExecuteCmd(createListExpiringCmd())
+	require.NoError(t, err)
+	require.Contains(t, stdout, "JWTs Expiring Within 30d")
+
+	entities := listExpiring(t)
+	require.Len(t, entities, 1)
+	require.Equal(t, "U", entities[0].Name)
+	require.Equal(t, spk, entities[0].Issuer)
+	require.True(t, entities[0].SigningKey)
+
+	entities = listExpiring(t, "--within", "2y")
+	require.Len(t, entities, 2)
+	require.Equal(t, "U", entities[0].Name)
+	require.Equal(t, "V", entities[1].Name)
+	require.False(t, entities[1].SigningKey)
+}
+
+func listExpiring(t *testing.T, args ...string) []expiringEntity {
+	args = append(args, "--output", "json")
+	stdout, _, err := ExecuteCmd(HoistRootFlags(createListExpiringCmd()), args...)
+	require.NoError(t, err)
+	var entities []expiringEntity
+	require.NoError(t, json.Unmarshal([]byte(stdout), &entities))
+	return entities
+}
+
+func Test_ListExpiringNothing(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+
+	stdout, _, err := ExecuteCmd(createListExpiringCmd())
+	require.NoError(t, err)
+	require.Contains(t, stdout, "no jwts expire within 30d")
+}
+
+func Test_ListExpiringActivations(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddExport(t, "A", jwt.Stream, "a.>", false)
+	ts.AddAccount(t, "B")
+
+	flags := []string{"--account", "A", "--target-account", ts.GetAccountPublicKey(t, "B"), "--subject", "a.>", "--expiry", "5d"}
+	stdout, _, err := ExecuteCmd(createGenerateActivationCmd(), flags...)
+	require.NoError(t, err)
+	token, err := jwt.ParseDecoratedJWT([]byte(stdout))
+	require.NoError(t, err)
+	fp := filepath.Join(ts.Dir, "token.jwt")
+	require.NoError(t, ioutil.WriteFile(fp, []byte(token), 0600))
+	_, _, err = ExecuteCmd(createAddImportCmd(), "--account", "B", "--token", fp, "--name", "act")
+	require.NoError(t, err)
+
+	entities := listExpiring(t, "--all-accounts")
+	require.Len(t, entities, 1)
+	require.Equal(t, "activation", entities[0].Kind)
+	require.Equal(t, "act", entities[0].Name)
+	require.Equal(t, "B", entities[0].Account)
+	require.Equal(t, ts.GetAccountPublicKey(t, "A"), entities[0].Issuer)
+}
+
+func Test_ListExpiringAccountOrAll(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	_, stderr, err := ExecuteCmd(createListExpiringCmd(), "--account", "A", "--all-accounts")
+	require.Error(t, err)
+	require.Contains(t, stderr, "specify only one")
+}
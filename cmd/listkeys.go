@@ -117,15 +117,58 @@ func (p *ListKeysParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *ListKeysParams) ReadOnly() bool {
+	return true
+}
+
 func (p *ListKeysParams) Run(ctx ActionCtx) (store.Status, error) {
 	var err error
 	var keys Keys
 
 	keys.KeyList, err = p.KeyCollectorParams.Run(ctx)
 	keys.MessageFn = p.Report
+	keys.DataFn = p.Data
 	return keys, err
 }
 
+// listedKey is the structured form of a listed key
+type listedKey struct {
+	Entity       string `json:"entity"`
+	Kind         string `json:"kind"`
+	PublicKey    string `json:"public_key"`
+	Signing      bool   `json:"signing_key,omitempty"`
+	Stored       bool   `json:"stored"`
+	Invalid      bool   `json:"invalid,omitempty"`
+	Unreferenced bool   `json:"unreferenced,omitempty"`
+	Seed         string `json:"seed,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+func (p *ListKeysParams) Data(ks Keys) interface{} {
+	keys := []listedKey{}
+	for _, k := range ks.KeyList {
+		lk := listedKey{
+			Entity:       k.Name,
+			Kind:         k.ExpectedKind.String(),
+			PublicKey:    k.Pub,
+			Signing:      k.Signing,
+			Stored:       k.HasKey(),
+			Invalid:      k.Invalid,
+			Unreferenced: k.Name == "?",
+		}
+		if p.Seeds && lk.Stored && !k.Invalid {
+			seed, err := p.KS.GetSeed(k.Pub)
+			if err != nil {
+				lk.Error = fmt.Sprintf("error reading seed: %v", err)
+			} else {
+				lk.Seed = seed
+			}
+		}
+		keys = append(keys, lk)
+	}
+	return keys
+}
+
 func (p *ListKeysParams) Report(ks Keys) string {
 	if ks.Len() == 0 {
 		return "no keys matched query"
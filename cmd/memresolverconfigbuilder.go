@@ -19,11 +19,11 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"path/filepath"
 	"sort"
 
 	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 )
 
@@ -79,38 +79,16 @@ func (cb *MemResolverConfigBuilder) Add(rawClaim []byte) error {
 }
 
 func (cb *MemResolverConfigBuilder) GenerateConfig() ([]byte, error) {
-	var buf bytes.Buffer
-
-	opk := cb.pubToName["__OPERATOR__"]
-	if opk == "" {
-		return nil, errors.New("operator is not set")
-	}
-	buf.WriteString(fmt.Sprintf("// Operator %q\n", cb.pubToName[opk]))
-	buf.WriteString(fmt.Sprintf("operator: %s\n\n", cb.operator))
-
-	if cb.sysAccount != "" {
-		buf.WriteString(fmt.Sprintf("system_account: %s\n\n", cb.sysAccount))
-	}
-
-	var keys []string
-	for k := range cb.claims {
-		keys = append(keys, k)
+	var accounts []string
+	for _, v := range cb.claims {
+		accounts = append(accounts, v)
 	}
-	sort.Strings(keys)
-	buf.WriteString("resolver: MEMORY\n\n")
-	buf.WriteString("resolver_preload: {\n")
-	for _, k := range keys {
-		v := cb.claims[k]
-		buf.WriteString(fmt.Sprintf("  // Account %q\n", cb.pubToName[k]))
-		buf.WriteString(fmt.Sprintf("  %s: %s\n\n", k, v))
-	}
-	buf.WriteString("}\n")
-	return buf.Bytes(), nil
+	return api.MemResolverConfig(cb.operator, accounts, cb.sysAccount)
 }
 
 func (cb *MemResolverConfigBuilder) writeFile(dir string, name string, token string) (string, error) {
 	fp := filepath.Join(dir, store.JwtName(name))
-	err := ioutil.WriteFile(fp, []byte(token), 0666)
+	err := WriteFile(fp, []byte(token), 0666)
 	return fp, err
 }
 
@@ -160,7 +138,7 @@ func (cb *MemResolverConfigBuilder) GenerateDir() ([]byte, error) {
 	}
 	buf.WriteString("}\n")
 
-	err = ioutil.WriteFile(filepath.Join(cb.dir, "resolver.conf"), buf.Bytes(), 0666)
+	err = WriteFile(filepath.Join(cb.dir, "resolver.conf"), buf.Bytes(), 0666)
 	if err != nil {
 		return nil, err
 	}
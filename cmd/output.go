@@ -0,0 +1,217 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	TextOutput = "text"
+	JsonOutput = "json"
+	YamlOutput = "yaml"
+)
+
+// OutputFlag is the format of the command output
+var OutputFlag = outputFormat(TextOutput)
+
+type outputFormat string
+
+func (o *outputFormat) String() string {
+	return string(*o)
+}
+
+func (o *outputFormat) Set(v string) error {
+	switch v {
+	case TextOutput, JsonOutput, YamlOutput:
+		*o = outputFormat(v)
+		return nil
+	}
+	return fmt.Errorf("output format must be one of %s, %s or %s", TextOutput, JsonOutput, YamlOutput)
+}
+
+func (o *outputFormat) Type() string {
+	return "format"
+}
+
+// StructuredOutput returns true if the output is JSON or YAML
+func StructuredOutput() bool {
+	return OutputFlag == JsonOutput || OutputFlag == YamlOutput
+}
+
+// Structured is implemented by results that have a machine-readable form
+// other than their status tree
+type Structured interface {
+	Structured() interface{}
+}
+
+// structuredResult is the status of an action that writes its text
+// output itself, its structured output is the data
+type structuredResult struct {
+	data interface{}
+}
+
+func (r structuredResult) Code() store.StatusCode {
+	return store.OK
+}
+
+func (r structuredResult) Message() string {
+	return ""
+}
+
+func (r structuredResult) Structured() interface{} {
+	return r.data
+}
+
+// statusOutput is the structured form of the result of an action
+type statusOutput struct {
+	*store.StatusData
+	Summary string          `json:"summary,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	DryRun  bool            `json:"dry_run,omitempty"`
+	Changes []*store.Change `json:"changes,omitempty"`
+}
+
+// errorOutput is the structured form of a failed command
+type errorOutput struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// reportedError is an error that was already written as part of the output
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+// FormatOutput encodes the value as JSON or YAML. Field names in YAML are
+// the same as in JSON.
+func FormatOutput(v interface{}) ([]byte, error) {
+	d, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling: %v", err)
+	}
+	return jsonAsOutput(d)
+}
+
+// jsonAsOutput converts a JSON document to the output format
+func jsonAsOutput(d []byte) ([]byte, error) {
+	return jsonAs(d, OutputFlag)
+}
+
+// jsonAs converts a JSON document to the format
+func jsonAs(d []byte, format outputFormat) ([]byte, error) {
+	if format != YamlOutput {
+		return d, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("error parsing json: %v", err)
+	}
+	y, err := yaml.Marshal(yamlValue(v))
+	if err != nil {
+		return nil, fmt.Errorf("error formatting yaml: %v", err)
+	}
+	return bytes.TrimSuffix(y, []byte{'\n'}), nil
+}
+
+// yamlValue keeps JSON numbers as numbers, timestamps would otherwise
+// be formatted in exponent notation
+func yamlValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case json.Number:
+		if i, err := vv.Int64(); err == nil {
+			return i
+		}
+		f, _ := vv.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range vv {
+			vv[k] = yamlValue(e)
+		}
+	case []interface{}:
+		for i, e := range vv {
+			vv[i] = yamlValue(e)
+		}
+	}
+	return v
+}
+
+// WriteOutput writes the value to stdout as JSON or YAML
+func WriteOutput(v interface{}) error {
+	d, err := FormatOutput(v)
+	if err != nil {
+		return err
+	}
+	return Write("--", append(d, '\n'))
+}
+
+// writeStatusOutput writes the result of an action and the changes a dry
+// run didn't make. If the action failed the returned error was already
+// reported.
+func writeStatusOutput(rs store.Status, changes []*store.Change, err error) error {
+	if s, ok := rs.(Structured); ok {
+		if err != nil {
+			return err
+		}
+		return WriteOutput(s.Structured())
+	}
+	if rs == nil && !DryRunFlag {
+		return err
+	}
+	out := statusOutput{StatusData: &store.StatusData{Status: store.OK.String()}, DryRun: DryRunFlag, Changes: changes}
+	if rs != nil {
+		out.StatusData = store.ToStatusData(rs)
+	}
+	if sum, ok := rs.(store.Summarizer); ok {
+		m, serr := sum.Summary()
+		if serr != nil && err == nil {
+			err = serr
+		}
+		out.Summary = m
+	}
+	if err != nil {
+		out.Error = err.Error()
+		out.Status = store.ERR.String()
+	}
+	if werr := WriteOutput(out); werr != nil {
+		return werr
+	}
+	if err != nil {
+		return &reportedError{err: err}
+	}
+	return nil
+}
+
+// writeErrorOutput writes a structured error unless it was already reported
+func writeErrorOutput(err error) {
+	var re *reportedError
+	if err == nil || errors.As(err, &re) {
+		return
+	}
+	_ = WriteOutput(errorOutput{Status: store.ERR.String(), Message: err.Error()})
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_OutputFlagValues(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	_, _, err := ExecuteCmd(HoistRootFlags(CreateAddAccountCmd()), "--name", "A", "--output", "xml")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "output format must be one of text, json or yaml")
+}
+
+func Test_OutputJsonReport(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	stdout, stderr, err := ExecuteCmd(HoistRootFlags(CreateAddAccountCmd()), "--name", "A", "--output", "json")
+	require.NoError(t, err)
+	require.Empty(t, stderr)
+
+	var out statusOutput
+	require.NoError(t, json.Unmarshal([]byte(stdout), &out))
+	require.Equal(t, "ok", out.Status)
+	require.NotEmpty(t, out.Details)
+	for _, d := range out.Details {
+		require.Equal(t, "ok", d.Status)
+	}
+	require.Empty(t, out.Error)
+}
+
+func Test_OutputYamlDescribe(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	stdout, _, err := ExecuteCmd(rootCmd, "describe", "account", "--output", "yaml")
+	require.NoError(t, err)
+
+	var m map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(stdout), &m))
+	require.Equal(t, ts.GetAccountPublicKey(t, "A"), m["sub"])
+	require.Equal(t, "A", m["name"])
+	// timestamps are not converted to floats
+	require.IsType(t, 0, m["iat"])
+}
+
+func Test_OutputJsonDescribeJwt(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	fp := filepath.Join(ts.GetStoresRoot(), "O", store.Accounts, "A", "A.jwt")
+	stdout, _, err := ExecuteCmd(rootCmd, "describe", "jwt", "--file", fp, "--output", "json")
+	require.NoError(t, err)
+
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &m))
+	require.Equal(t, ts.GetAccountPublicKey(t, "A"), m["sub"])
+}
+
+func Test_OutputJsonListKeys(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	ts.AddUser(t, "A", "U")
+
+	stdout, _, err := ExecuteCmd(HoistRootFlags(createListKeysCmd()), "--all", "--output", "json")
+	require.NoError(t, err)
+
+	var keys []listedKey
+	require.NoError(t, json.Unmarshal([]byte(stdout), &keys))
+	require.Len(t, keys, 3)
+	kinds := make(map[string]string)
+	for _, k := range keys {
+		require.True(t, k.Stored)
+		kinds[k.PublicKey] = k.Kind
+	}
+	require.Equal(t, "operator", kinds[ts.GetOperatorPublicKey(t)])
+	require.Equal(t, "account", kinds[ts.GetAccountPublicKey(t, "A")])
+	require.Equal(t, "user", kinds[ts.GetUserPublicKey(t, "A", "U")])
+}
+
+func Test_OutputJsonListAccounts(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	ts.AddAccount(t, "B")
+
+	stdout, _, err := ExecuteCmd(HoistRootFlags(createListAccountsCmd()), "--output", "json")
+	require.NoError(t, err)
+
+	var entities []listedEntity
+	require.NoError(t, json.Unmarshal([]byte(stdout), &entities))
+	require.Len(t, entities, 2)
+	require.Equal(t, "A", entities[0].Name)
+	require.Equal(t, ts.GetAccountPublicKey(t, "A"), entities[0].PublicKey)
+	require.False(t, entities[0].Current)
+	require.Equal(t, "B", entities[1].Name)
+	require.True(t, entities[1].Current)
+}
+
+func Test_OutputJsonFailedReport(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	OutputFlag = JsonOutput
+	defer ResetSharedFlags()
+
+	r := store.NewDetailedReport(true)
+	r.AddError("failed")
+	stdout, err := captureStdout(func() error {
+		return writeStatusOutput(r, nil, nil)
+	})
+	require.Error(t, err)
+	var out statusOutput
+	require.NoError(t, json.Unmarshal([]byte(stdout), &out))
+	require.Equal(t, "error", out.Status)
+	require.Equal(t, "all jobs failed", out.Error)
+
+	// the error was already reported
+	stdout, _ = captureStdout(func() error {
+		writeErrorOutput(err)
+		return nil
+	})
+	require.Empty(t, stdout)
+
+	stdout, _ = captureStdout(func() error {
+		writeErrorOutput(errors.New("bad"))
+		return nil
+	})
+	var eo errorOutput
+	require.NoError(t, json.Unmarshal([]byte(stdout), &eo))
+	require.Equal(t, errorOutput{Status: "error", Message: "bad"}, eo)
+}
+
+func captureStdout(fn func() error) (string, error) {
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := fn()
+	_ = w.Close()
+	os.Stdout = old
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	return buf.String(), err
+}
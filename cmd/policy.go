@@ -0,0 +1,240 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+)
+
+// PolicyName is the name of the policy file in the operator directory
+const PolicyName = "policy.json"
+
+// PolicyOverrideFlag stores JWTs that violate the store policy
+var PolicyOverrideFlag bool
+
+// Policy are rules for the JWTs stored in an operator store. The policy
+// is read from the policy.json file in the operator directory:
+//
+//	{
+//	  "max_user_expiry": "90d",
+//	  "source_network_account_tags": ["prod"],
+//	  "deny_allow_pub_all": true,
+//	  "require_service_latency": true,
+//	  "require_account_conns": true,
+//	  "require_account_data": true
+//	}
+type Policy struct {
+	// MaxUserExpiry is the longest users can be valid for, users must expire
+	MaxUserExpiry string `json:"max_user_expiry,omitempty"`
+	// SourceNetworkAccountTags requires users in accounts with any of the tags to set source networks
+	SourceNetworkAccountTags []string `json:"source_network_account_tags,omitempty"`
+	// DenyAllowPubAll rejects users allowed to publish to `>`
+	DenyAllowPubAll bool `json:"deny_allow_pub_all,omitempty"`
+	// RequireServiceLatency requires service exports to enable latency sampling
+	RequireServiceLatency bool `json:"require_service_latency,omitempty"`
+	// RequireAccountConns requires accounts to limit the number of connections
+	RequireAccountConns bool `json:"require_account_conns,omitempty"`
+	// RequireAccountData requires accounts to limit the data
+	RequireAccountData bool `json:"require_account_data,omitempty"`
+}
+
+// LoadPolicy reads the policy of the store, or returns nil if it doesn't have one
+func LoadPolicy(s *store.Store) (*Policy, error) {
+	if !s.Has(PolicyName) {
+		return nil, nil
+	}
+	d, err := s.Read(PolicyName)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.DisallowUnknownFields()
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("error parsing the store policy %q: %v", PolicyName, err)
+	}
+	if p.MaxUserExpiry != "" {
+		exp, err := ParseExpiry(p.MaxUserExpiry)
+		if err != nil || exp <= time.Now().Unix() {
+			return nil, fmt.Errorf("store policy max_user_expiry %q must be a duration such as '90d'", p.MaxUserExpiry)
+		}
+	}
+	return &p, nil
+}
+
+// storePolicy returns the policy of the store unless it is overridden
+func storePolicy(ctx ActionCtx) (*Policy, error) {
+	s := ctx.StoreCtx().Store
+	if s == nil || s.Dir == "" || PolicyOverrideFlag {
+		return nil, nil
+	}
+	return LoadPolicy(s)
+}
+
+// checkPolicy returns a store.RejectedError with the policy violations of
+// the claims an action is about to store. Actions call it from Validate
+// with every claim they store, so that nothing is stored, including keys,
+// when the policy rejects one of them.
+func checkPolicy(ctx ActionCtx, claims ...jwt.Claims) error {
+	p, err := storePolicy(ctx)
+	if err != nil || p == nil {
+		return err
+	}
+	r := p.newReport()
+	for _, c := range claims {
+		p.checkClaims(ctx.StoreCtx().Store, c, r)
+	}
+	if r.HasErrors() {
+		return &store.RejectedError{Report: r}
+	}
+	return nil
+}
+
+// enforcePolicy checks the JWTs stored by the action against the store
+// policy unless it is overridden, in case the action stores claims it
+// didn't check in Validate. The returned function stops the checks.
+func enforcePolicy(ctx ActionCtx) (func(), error) {
+	p, err := storePolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return func() {}, nil
+	}
+	s := ctx.StoreCtx().Store
+	old := s.Check
+	s.Check = func(data []byte) *store.Report {
+		return p.Check(s, data)
+	}
+	return func() { s.Check = old }, nil
+}
+
+// rejectedReport returns the report of a JWT the store rejected
+func rejectedReport(err error) store.Status {
+	var re *store.RejectedError
+	if errors.As(err, &re) {
+		return re.Report
+	}
+	return nil
+}
+
+func (p *Policy) newReport() *store.Report {
+	r := store.NewDetailedReport(false)
+	r.Label = "the jwt violates the store policy (--override-policy stores it)"
+	r.Opt = store.ALL
+	return r
+}
+
+// Check reports the policy violations of the JWT
+func (p *Policy) Check(s *store.Store, data []byte) *store.Report {
+	r := p.newReport()
+	ct, err := s.ClaimType(data)
+	if err != nil {
+		return nil
+	}
+	switch *ct {
+	case jwt.UserClaim:
+		uc, err := jwt.DecodeUserClaims(string(data))
+		if err != nil {
+			return nil
+		}
+		p.checkClaims(s, uc, r)
+	case jwt.AccountClaim:
+		ac, err := jwt.DecodeAccountClaims(string(data))
+		if err != nil {
+			return nil
+		}
+		p.checkClaims(s, ac, r)
+	}
+	return r
+}
+
+func (p *Policy) checkClaims(s *store.Store, c jwt.Claims, r *store.Report) {
+	switch v := c.(type) {
+	case *jwt.UserClaims:
+		p.checkUser(s, v, r)
+	case *jwt.AccountClaims:
+		p.checkAccount(v, r)
+	}
+}
+
+func (p *Policy) checkUser(s *store.Store, uc *jwt.UserClaims, r *store.Report) {
+	if p.MaxUserExpiry != "" {
+		limit, err := ParseExpiry(p.MaxUserExpiry)
+		if err == nil && (uc.Expires == 0 || uc.Expires > limit) {
+			r.AddError("user %q must expire within %s", uc.Name, p.MaxUserExpiry)
+		}
+	}
+	if p.DenyAllowPubAll && uc.Pub.Allow.Contains(">") {
+		r.AddError("user %q must not be allowed to publish to \">\"", uc.Name)
+	}
+	if len(p.SourceNetworkAccountTags) > 0 && uc.Src == "" {
+		if ac := issuerAccount(s, uc); ac != nil {
+			for _, t := range p.SourceNetworkAccountTags {
+				if ac.Tags.Contains(t) {
+					r.AddError("user %q must set source networks in account %q tagged %q", uc.Name, ac.Name, t)
+					break
+				}
+			}
+		}
+	}
+}
+
+// issuerAccount returns the account of the user from the store
+func issuerAccount(s *store.Store, uc *jwt.UserClaims) *jwt.AccountClaims {
+	issuer := uc.Issuer
+	if uc.IssuerAccount != "" {
+		issuer = uc.IssuerAccount
+	}
+	e, err := s.LookupKey(issuer)
+	if err == nil && e == nil {
+		// the account may have been added without updating the index
+		if x, err := s.RebuildIndex(); err == nil {
+			e = x.Lookup(issuer)
+		}
+	}
+	if e == nil || e.Kind != jwt.AccountClaim {
+		return nil
+	}
+	ac, err := s.ReadAccountClaim(e.Name)
+	if err != nil {
+		return nil
+	}
+	return ac
+}
+
+func (p *Policy) checkAccount(ac *jwt.AccountClaims, r *store.Report) {
+	if p.RequireAccountConns && ac.Limits.Conn < 0 {
+		r.AddError("account %q must limit the number of connections (--conns)", ac.Name)
+	}
+	if p.RequireAccountData && ac.Limits.Data < 0 {
+		r.AddError("account %q must limit the data (--data)", ac.Name)
+	}
+	if p.RequireServiceLatency {
+		for _, e := range ac.Exports {
+			if e.IsService() && e.Latency == nil {
+				r.AddError("service export %q in account %q must enable latency sampling (--latency and --sampling)", e.Subject, ac.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,306 @@
+/*
+ * Copyright 2018-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func writePolicy(t *testing.T, ts *TestStore, p Policy) {
+	d, err := json.Marshal(p)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ts.Store.Dir, PolicyName), d, 0600))
+}
+
+func Test_PolicyUserExpiry(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	writePolicy(t, ts, Policy{MaxUserExpiry: "90d"})
+
+	_, stderr, err := ExecuteCmd(CreateAddUserCmd(), "--name", "U")
+	require.Error(t, err)
+	require.Contains(t, stderr, `user "U" must expire within 90d`)
+	require.False(t, ts.Store.Has(store.Accounts, "A", store.Users, store.JwtName("U")))
+
+	_, _, err = ExecuteCmd(CreateAddUserCmd(), "--name", "V", "--expiry", "1y")
+	require.Error(t, err)
+
+	_, _, err = ExecuteCmd(CreateAddUserCmd(), "--name", "W", "--expiry", "30d")
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createEditUserCmd(), "--name", "W", "--expiry", "0")
+	require.Error(t, err)
+	uc, err := ts.Store.ReadUserClaim("A", "W")
+	require.NoError(t, err)
+	require.NotZero(t, uc.Expires)
+}
+
+func Test_PolicyOverride(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	writePolicy(t, ts, Policy{MaxUserExpiry: "90d"})
+
+	_, _, err := ExecuteCmd(HoistRootFlags(CreateAddUserCmd()), "--name", "U", "--override-policy")
+	require.NoError(t, err)
+	require.True(t, ts.Store.Has(store.Accounts, "A", store.Users, store.JwtName("U")))
+}
+
+func Test_PolicyAllowPubAll(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+	writePolicy(t, ts, Policy{DenyAllowPubAll: true})
+
+	_, stderr, err := ExecuteCmd(createEditUserCmd(), "--name", "U", "--allow-pub", ">")
+	require.Error(t, err)
+	require.Contains(t, stderr, `user "U" must not be allowed to publish to ">"`)
+
+	_, _, err = ExecuteCmd(createEditUserCmd(), "--name", "U", "--allow-pub", "foo.>")
+	require.NoError(t, err)
+}
+
+func Test_PolicySourceNetwork(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	ts.AddAccount(t, "B")
+	_, _, err := ExecuteCmd(createEditAccount(), "--name", "A", "--tag", "prod")
+	require.NoError(t, err)
+	writePolicy(t, ts, Policy{SourceNetworkAccountTags: []string{"prod"}})
+
+	_, stderr, err := ExecuteCmd(CreateAddUserCmd(), "--account", "A", "--name", "U")
+	require.Error(t, err)
+	require.Contains(t, stderr, `user "U" must set source networks in account "A" tagged "prod"`)
+
+	_, _, err = ExecuteCmd(CreateAddUserCmd(), "--account", "A", "--name", "V", "--source-network", "192.0.2.0/24")
+	require.NoError(t, err)
+	uc, err := ts.Store.ReadUserClaim("A", "V")
+	require.NoError(t, err)
+	require.Equal(t, "192.0.2.0/24", uc.Src)
+
+	_, _, err = ExecuteCmd(CreateAddUserCmd(), "--account", "B", "--name", "W")
+	require.NoError(t, err)
+}
+
+func Test_PolicyServiceLatency(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	writePolicy(t, ts, Policy{RequireServiceLatency: true})
+
+	_, stderr, err := ExecuteCmd(createAddExportCmd(), "--subject", "q", "--service")
+	require.Error(t, err)
+	require.Contains(t, stderr, `service export "q" in account "A" must enable latency sampling`)
+
+	_, _, err = ExecuteCmd(createAddExportCmd(), "--subject", "q", "--service", "--latency", "lat", "--sampling", "50")
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createAddExportCmd(), "--subject", "s")
+	require.NoError(t, err)
+}
+
+func Test_PolicyAccountLimits(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	writePolicy(t, ts, Policy{RequireAccountConns: true, RequireAccountData: true})
+
+	_, stderr, err := ExecuteCmd(createEditAccount(), "--conns", "10")
+	require.Error(t, err)
+	require.Contains(t, stderr, `account "A" must limit the data`)
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), ac.Limits.Conn)
+
+	_, _, err = ExecuteCmd(createEditAccount(), "--conns", "10", "--data", "1M")
+	require.NoError(t, err)
+}
+
+func Test_PolicyAddAccountRejected(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	writePolicy(t, ts, Policy{RequireAccountConns: true})
+	keys, err := ts.KeyStore.AllKeys()
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(CreateAddAccountCmd(), "--name", "A")
+	require.Error(t, err)
+	require.Contains(t, stderr, `account "A" must limit the number of connections (--conns)`)
+	require.False(t, ts.Store.HasAccount("A"))
+	after, err := ts.KeyStore.AllKeys()
+	require.NoError(t, err)
+	require.ElementsMatch(t, keys, after)
+
+	_, _, err = ExecuteCmd(CreateAddAccountCmd(), "--name", "A", "--conns", "10")
+	require.NoError(t, err)
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), ac.Limits.Conn)
+	require.True(t, ts.KeyStore.HasPrivateKey(ac.Subject))
+
+	_, _, err = ExecuteCmd(HoistRootFlags(CreateAddAccountCmd()), "--name", "B", "--override-policy")
+	require.NoError(t, err)
+	require.True(t, ts.Store.HasAccount("B"))
+}
+
+func Test_PolicyAddUserRejected(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	writePolicy(t, ts, Policy{DenyAllowPubAll: true})
+	keys, err := ts.KeyStore.AllKeys()
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(CreateAddUserCmd(), "--name", "U", "--allow-pub", ">")
+	require.Error(t, err)
+	after, err := ts.KeyStore.AllKeys()
+	require.NoError(t, err)
+	require.ElementsMatch(t, keys, after)
+	require.False(t, ts.Store.Has(store.Accounts, "A", store.Users, store.JwtName("U")))
+}
+
+func Test_PolicyRejectedJSON(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	writePolicy(t, ts, Policy{RequireAccountConns: true, RequireAccountData: true})
+
+	stdout, _, err := ExecuteCmd(HoistRootFlags(createEditAccount()), "--tag", "x", "--output", "json")
+	require.Error(t, err)
+	var out struct {
+		Status  string `json:"status"`
+		Details []struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"details"`
+		} `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &out))
+	require.Equal(t, "error", out.Status)
+	var violations []string
+	for _, d := range out.Details {
+		for _, v := range d.Details {
+			violations = append(violations, v.Message)
+		}
+	}
+	require.Contains(t, violations, `account "A" must limit the number of connections (--conns)`)
+	require.Contains(t, violations, `account "A" must limit the data (--data)`)
+}
+
+func Test_PolicyEditOverride(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	writePolicy(t, ts, Policy{RequireAccountConns: true})
+
+	_, stderr, err := ExecuteCmd(createEditAccount(), "--tag", "x")
+	require.Error(t, err)
+	require.NotContains(t, stderr, `edited account "A"`)
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Empty(t, ac.Tags)
+
+	_, _, err = ExecuteCmd(HoistRootFlags(createEditAccount()), "--tag", "x", "--override-policy")
+	require.NoError(t, err)
+	ac, err = ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, []string{"x"}, []string(ac.Tags))
+}
+
+func Test_PolicyBadFile(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ts.Store.Dir, PolicyName), []byte(`{"max_user_expiry": "forever"}`), 0600))
+
+	_, stderr, err := ExecuteCmd(CreateAddUserCmd(), "--name", "U")
+	require.Error(t, err)
+	require.Contains(t, stderr, "max_user_expiry")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ts.Store.Dir, PolicyName), []byte(`{"max_user_expiration": "90d"}`), 0600))
+	_, stderr, err = ExecuteCmd(CreateAddUserCmd(), "--name", "U")
+	require.Error(t, err)
+	require.Contains(t, stderr, "unknown field")
+}
+
+func Test_PolicyApplyRejectedBeforeChanges(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	writePolicy(t, ts, Policy{DenyAllowPubAll: true})
+
+	fp := writeTopology(t, ts, `
+accounts:
+  - name: A
+  - name: B
+    users:
+      - name: u
+        allow_pub: [">"]
+`)
+	_, stderr, err := ExecuteCmd(createApplyCmd(), "--file", fp, "--yes")
+	require.Error(t, err)
+	require.Contains(t, stderr, `user "u" must not be allowed to publish to ">"`)
+	require.False(t, ts.Store.HasAccount("A"))
+	require.False(t, ts.Store.HasAccount("B"))
+}
+
+func Test_PolicyReissueRejectedBeforeChanges(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+	_, _, err := ExecuteCmd(CreateAddUserCmd(), "--name", "V", "--allow-pub", ">")
+	require.NoError(t, err)
+	before, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	writePolicy(t, ts, Policy{DenyAllowPubAll: true})
+
+	_, stderr, err := ExecuteCmd(createReissueUsersCmd())
+	require.Error(t, err)
+	require.Contains(t, stderr, `user "V" must not be allowed to publish to ">"`)
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, before.ID, uc.ID)
+}
+
+func Test_PolicyRenewRejectedBeforeChanges(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	_, _, err := ExecuteCmd(CreateAddUserCmd(), "--name", "U", "--expiry", "10d")
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(CreateAddUserCmd(), "--name", "V", "--expiry", "20d")
+	require.NoError(t, err)
+	before, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	writePolicy(t, ts, Policy{MaxUserExpiry: "90d"})
+
+	_, stderr, err := ExecuteCmd(createRenewCmd(), "--expiry", "1y")
+	require.Error(t, err)
+	require.Contains(t, stderr, `must expire within 90d`)
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, before.Expires, uc.Expires)
+}
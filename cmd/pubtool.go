@@ -105,6 +105,10 @@ func (p *PubParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *PubParams) ReadOnly() bool {
+	return true
+}
+
 func (p *PubParams) Run(ctx ActionCtx) (store.Status, error) {
 	opts := createDefaultToolOptions("nsc_pub", ctx)
 	opts = append(opts, nats.UserCredentials(p.credsPath))
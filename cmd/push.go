@@ -205,6 +205,10 @@ func (p *PushCmdParams) getSelectedAccounts() ([]string, error) {
 	}
 }
 
+func (p *PushCmdParams) ReadOnly() bool {
+	return true
+}
+
 func (p *PushCmdParams) Run(ctx ActionCtx) (store.Status, error) {
 	ctx.CurrentCmd().SilenceUsage = true
 	var err error
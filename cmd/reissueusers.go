@@ -0,0 +1,267 @@
+/*
+ * Copyright 2018-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createReissueUsersCmd() *cobra.Command {
+	var params ReissueUsersParams
+	cmd := &cobra.Command{
+		Use:   "users",
+		Short: "Re-sign the users of an account",
+		Long: `Re-sign the users of an account
+
+The existing claims of each selected user are signed again, refreshing
+the issue date. Without --signer each user is signed with the key that
+issued it, otherwise with the specified account key or signing key. The
+key must be in the keystore or provided as a seed or path to one.
+
+Creds files are regenerated for users with a private key in the keystore.
+
+Users are selected with --selector, which accepts tag=<tag> and
+name=<user>. Users must match one of the values of every selector,
+'--selector name=U,name=V' selects both U and V.`,
+		Example: `nsc reissue users --account A
+nsc reissue users --all-accounts
+nsc reissue users --account A --signer <public key, seed or path to the signing key>
+nsc reissue users --account A --selector tag=ops
+nsc reissue users --account A --dry-run`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().BoolVarP(&params.allAccounts, "all-accounts", "A", false, "re-issue the users of all accounts (exclusive of --account)")
+	cmd.Flags().StringVarP(&params.signer, "signer", "", "", "account key or signing key to sign the users with")
+	cmd.Flags().StringSliceVarP(&params.selectors, "selector", "", nil, "select users by tag=<tag> or name=<user> - comma separated list or option can be specified multiple times")
+	params.AccountContextParams.BindFlags(cmd)
+	return cmd
+}
+
+func init() {
+	reissueCmd.AddCommand(createReissueUsersCmd())
+}
+
+type ReissueUsersParams struct {
+	AccountContextParams
+	allAccounts bool
+	signer      string
+	selectors   []string
+	signerKP    nkeys.KeyPair
+	accounts    []*reissuedAccount
+}
+
+// reissuedAccount is an account and its selected users
+type reissuedAccount struct {
+	name  string
+	claim *jwt.AccountClaims
+	users []*jwt.UserClaims
+}
+
+func (p *ReissueUsersParams) SetDefaults(ctx ActionCtx) error {
+	if p.allAccounts && p.AccountContextParams.Name != "" {
+		return errors.New("specify only one of --account or --all-accounts")
+	}
+	if _, err := parseSelectors(p.selectors); err != nil {
+		return err
+	}
+	return p.AccountContextParams.SetDefaults(ctx)
+}
+
+func (p *ReissueUsersParams) PreInteractive(ctx ActionCtx) error {
+	if p.allAccounts {
+		return nil
+	}
+	return p.AccountContextParams.Edit(ctx)
+}
+
+func (p *ReissueUsersParams) Load(ctx ActionCtx) error {
+	var names []string
+	if p.allAccounts {
+		var err error
+		names, err = GetConfig().ListAccounts()
+		if err != nil {
+			return err
+		}
+		sort.Strings(names)
+	} else {
+		if err := p.AccountContextParams.Validate(ctx); err != nil {
+			return err
+		}
+		names = []string{p.AccountContextParams.Name}
+	}
+
+	selectors, err := parseSelectors(p.selectors)
+	if err != nil {
+		return err
+	}
+	s := ctx.StoreCtx().Store
+	for _, n := range names {
+		ac, err := s.ReadAccountClaim(n)
+		if err != nil {
+			return err
+		}
+		ra := &reissuedAccount{name: n, claim: ac}
+		users, err := s.ListEntries(store.Accounts, n, store.Users)
+		if err != nil {
+			return err
+		}
+		sort.Strings(users)
+		for _, u := range users {
+			uc, err := s.ReadUserClaim(n, u)
+			if err != nil {
+				return err
+			}
+			if selectors.match(uc) {
+				ra.users = append(ra.users, uc)
+			}
+		}
+		p.accounts = append(p.accounts, ra)
+	}
+	return nil
+}
+
+func (p *ReissueUsersParams) PostInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+// resolveSigner resolves the signer from a seed, a path to a seed,
+// or a public key with a seed in the keystore
+func (p *ReissueUsersParams) resolveSigner(ctx ActionCtx) error {
+	var err error
+	if nkeys.IsValidPublicAccountKey(p.signer) {
+		p.signerKP, err = storedKey(ctx, p.signer)
+	} else {
+		p.signerKP, err = store.ResolveKey(p.signer)
+	}
+	if err != nil {
+		return err
+	}
+	if p.signerKP == nil || !store.KeyPairTypeOk(nkeys.PrefixByteAccount, p.signerKP) {
+		return fmt.Errorf("%q is not an account key", p.signer)
+	}
+	if !canSign(p.signerKP) {
+		return fmt.Errorf("the private key for %q is required", p.signer)
+	}
+	return nil
+}
+
+func (p *ReissueUsersParams) Validate(ctx ActionCtx) error {
+	if p.signer != "" {
+		if err := p.resolveSigner(ctx); err != nil {
+			return err
+		}
+		pk, err := p.signerKP.PublicKey()
+		if err != nil {
+			return err
+		}
+		for _, a := range p.accounts {
+			if pk != a.claim.Subject && !a.claim.SigningKeys.Contains(pk) {
+				return fmt.Errorf("%q is not the key or a signing key of account %q", pk, a.name)
+			}
+		}
+	}
+	var claims []jwt.Claims
+	for _, a := range p.accounts {
+		for _, uc := range a.users {
+			claims = append(claims, uc)
+		}
+	}
+	return checkPolicy(ctx, claims...)
+}
+
+func (p *ReissueUsersParams) Run(ctx ActionCtx) (store.Status, error) {
+	r := store.NewDetailedReport(true)
+	r.ReportSum = false
+	count := 0
+	for _, a := range p.accounts {
+		if len(a.users) == 0 {
+			continue
+		}
+		ar := r.AddOK("account %q", a.name)
+		for _, uc := range a.users {
+			count++
+			kp := p.signerKP
+			if kp == nil {
+				var err error
+				if kp, err = storedKey(ctx, uc.Issuer); err != nil {
+					ar.AddError("error re-issuing user %q: %v", uc.Name, err)
+					continue
+				}
+			}
+			if err := reissueUser(ctx, a.name, a.claim, uc, kp, ar); err != nil {
+				ar.AddError("error re-issuing user %q: %v", uc.Name, err)
+			}
+		}
+	}
+	if count == 0 {
+		r.AddOK("no users matched")
+	}
+	return r, nil
+}
+
+// userSelectors are the values users must match by selector name
+type userSelectors map[string][]string
+
+func parseSelectors(values []string) (userSelectors, error) {
+	selectors := make(userSelectors)
+	for _, v := range values {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("selector %q must be in the form tag=<tag> or name=<user>", v)
+		}
+		switch kv[0] {
+		case "tag", "name":
+			selectors[kv[0]] = append(selectors[kv[0]], kv[1])
+		default:
+			return nil, fmt.Errorf("unknown selector %q - selectors are tag and name", kv[0])
+		}
+	}
+	return selectors, nil
+}
+
+// match returns true if the user matches a value of every selector
+func (s userSelectors) match(uc *jwt.UserClaims) bool {
+	for k, values := range s {
+		matched := false
+		for _, v := range values {
+			switch k {
+			case "tag":
+				matched = uc.Tags.Contains(v)
+			case "name":
+				matched = uc.Name == v
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReissueUsers(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	ts.AddUser(t, "A", "U")
+	_, _, err := ExecuteCmd(createEditUserCmd(), "--name", "U", "--allow-pub", "foo", "--tag", "ops")
+	require.NoError(t, err)
+	ts.AddUser(t, "A", "V")
+	before, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+
+	// the issue date must change
+	time.Sleep(time.Second)
+	_, stderr, err := ExecuteCmd(createReissueUsersCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, `re-issued user "U"`)
+	require.Contains(t, stderr, `re-issued user "V"`)
+
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.True(t, uc.IssuedAt > before.IssuedAt)
+	require.Equal(t, before.Issuer, uc.Issuer)
+	require.Equal(t, before.Pub, uc.Pub)
+	require.Equal(t, before.Tags, uc.Tags)
+
+	// the creds file has the new jwt
+	d, err := ioutil.ReadFile(ts.KeyStore.GetUserCredsPath("A", "U"))
+	require.NoError(t, err)
+	token, err := jwt.ParseDecoratedJWT(d)
+	require.NoError(t, err)
+	cc, err := jwt.DecodeUserClaims(token)
+	require.NoError(t, err)
+	require.Equal(t, uc.ID, cc.ID)
+}
+
+func Test_ReissueUsersSigner(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+	ts.AddUser(t, "B", "V")
+	_, spk, skp := CreateAccountKey(t)
+	_, err := ts.KeyStore.Store(skp)
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createEditAccount(), "--name", "A", "--sk", spk)
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createReissueUsersCmd(), "--account", "A", "--signer", spk)
+	require.NoError(t, err)
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, spk, uc.Issuer)
+	require.Equal(t, ts.GetAccountPublicKey(t, "A"), uc.IssuerAccount)
+
+	_, stderr, err := ExecuteCmd(createReissueUsersCmd(), "--all-accounts", "--signer", spk)
+	require.Error(t, err)
+	require.Contains(t, stderr, `is not the key or a signing key of account "B"`)
+}
+
+func Test_ReissueUsersSelector(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+	ts.AddUser(t, "A", "V")
+	ts.AddUser(t, "B", "W")
+	_, _, err := ExecuteCmd(createEditUserCmd(), "--account", "A", "--name", "U", "--tag", "ops")
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createEditUserCmd(), "--account", "B", "--name", "W", "--tag", "ops")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createReissueUsersCmd(), "--all-accounts", "--selector", "tag=ops")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `re-issued user "U"`)
+	require.NotContains(t, stderr, `re-issued user "V"`)
+	require.Contains(t, stderr, `re-issued user "W"`)
+
+	_, stderr, err = ExecuteCmd(createReissueUsersCmd(), "--all-accounts", "--selector", "tag=dev")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "no users matched")
+
+	_, stderr, err = ExecuteCmd(createReissueUsersCmd(), "--account", "A", "--selector", "name=U", "--selector", "name=V")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `re-issued user "U"`)
+	require.Contains(t, stderr, `re-issued user "V"`)
+
+	_, stderr, err = ExecuteCmd(createReissueUsersCmd(), "--all-accounts", "--selector", "tag=ops,name=V")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "no users matched")
+
+	_, stderr, err = ExecuteCmd(createReissueUsersCmd(), "--selector", "role=ops")
+	require.Error(t, err)
+	require.Contains(t, stderr, "unknown selector")
+}
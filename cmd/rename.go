@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename",
+	Short: "Rename accounts and users",
+}
+
+func init() {
+	GetRootCmd().AddCommand(renameCmd)
+}
+
+// RenameParams are the current and new names of the renamed entity,
+// specified as flags or as the first and second arguments
+type RenameParams struct {
+	name    string
+	newName string
+}
+
+func (p *RenameParams) BindFlags(kind string, cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&p.name, "name", "n", "", fmt.Sprintf("%s to rename", kind))
+	cmd.Flags().StringVarP(&p.newName, "new-name", "", "", fmt.Sprintf("new name for the %s", kind))
+}
+
+func (p *RenameParams) SetDefaults(ctx ActionCtx) {
+	args := ctx.Args()
+	if p.name == "" && len(args) > 0 {
+		p.name = args[0]
+		args = args[1:]
+	}
+	if p.newName == "" && len(args) > 0 {
+		p.newName = args[0]
+	}
+}
+
+func (p *RenameParams) Validate(ctx ActionCtx) error {
+	if p.newName == "" {
+		ctx.CurrentCmd().SilenceUsage = false
+		return errors.New("a new name is required")
+	}
+	if p.newName == p.name {
+		return fmt.Errorf("the new name is the same as the current name %q", p.name)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	cli "github.com/nats-io/cliprompts/v2"
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createRenameAccountCmd() *cobra.Command {
+	var params RenameAccountParams
+	cmd := &cobra.Command{
+		Use:   "account",
+		Short: "Rename an account",
+		Long: `Rename an account
+
+The account JWT is re-signed with the new name, the account directory,
+its users and their creds files are moved to the new name. If the account
+is the current account, the context is updated.`,
+		Example: `nsc rename account A B
+nsc rename account --name A --new-name B`,
+		Args:         MaxArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// the account context is changed to the renamed account while running
+			params.current = GetConfig().Account
+			return RunAction(cmd, args, &params)
+		},
+	}
+	params.RenameParams.BindFlags("account", cmd)
+	return cmd
+}
+
+func init() {
+	renameCmd.AddCommand(createRenameAccountCmd())
+}
+
+type RenameAccountParams struct {
+	AccountContextParams
+	SignerParams
+	RenameParams
+	current string
+	claim   *jwt.AccountClaims
+	names   []string
+	users   map[string][]byte
+}
+
+func (p *RenameAccountParams) SetDefaults(ctx ActionCtx) error {
+	p.RenameParams.SetDefaults(ctx)
+	p.AccountContextParams.Name = p.RenameParams.name
+	if err := p.AccountContextParams.SetDefaults(ctx); err != nil {
+		return err
+	}
+	p.SignerParams.SetDefaults(nkeys.PrefixByteOperator, true, ctx)
+	return nil
+}
+
+func (p *RenameAccountParams) PreInteractive(ctx ActionCtx) error {
+	var err error
+	if err = p.AccountContextParams.Edit(ctx); err != nil {
+		return err
+	}
+	p.newName, err = cli.Prompt("new account name", p.newName, cli.NewLengthValidator(1))
+	return err
+}
+
+func (p *RenameAccountParams) Load(ctx ActionCtx) error {
+	var err error
+	if err = p.AccountContextParams.Validate(ctx); err != nil {
+		return err
+	}
+	p.name = p.AccountContextParams.Name
+	s := ctx.StoreCtx().Store
+	p.claim, err = s.ReadAccountClaim(p.name)
+	if err != nil {
+		return err
+	}
+	p.names, err = s.ListEntries(store.Accounts, p.name, store.Users)
+	if err != nil {
+		return err
+	}
+	p.users = make(map[string][]byte)
+	for _, u := range p.names {
+		p.users[u], err = s.ReadRawUserClaim(p.name, u)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *RenameAccountParams) PostInteractive(ctx ActionCtx) error {
+	return p.SignerParams.Edit(ctx)
+}
+
+func (p *RenameAccountParams) Validate(ctx ActionCtx) error {
+	if err := p.RenameParams.Validate(ctx); err != nil {
+		return err
+	}
+	s := ctx.StoreCtx().Store
+	if s.Has(store.Accounts, p.newName) {
+		return fmt.Errorf("account %q already exists", p.newName)
+	}
+	ks := ctx.StoreCtx().KeyStore
+	for _, u := range p.names {
+		if ks.GetUserCredsPath(p.newName, u) != "" {
+			return fmt.Errorf("creds file %#q already exists", AbbrevHomePaths(ks.CalcUserCredsPath(p.newName, u)))
+		}
+	}
+	if err := p.SignerParams.Resolve(ctx); err != nil {
+		return err
+	}
+	// the users are moved without being re-issued
+	ac := *p.claim
+	ac.Name = p.newName
+	return checkPolicy(ctx, &ac)
+}
+
+func (p *RenameAccountParams) Run(ctx ActionCtx) (store.Status, error) {
+	ctx.CurrentCmd().SilenceUsage = true
+	r := store.NewDetailedReport(true)
+	r.ReportSum = false
+	s := ctx.StoreCtx().Store
+	ks := ctx.StoreCtx().KeyStore
+
+	p.claim.Name = p.newName
+	token, err := p.claim.Encode(p.signerKP)
+	if err != nil {
+		return nil, err
+	}
+	StoreAccountAndUpdateStatus(ctx, token, r)
+	if r.HasErrors() {
+		return r, nil
+	}
+	r.AddOK("account %q renamed to %q", p.name, p.newName)
+
+	// user JWTs reference the account by its public key, they only move
+	for _, u := range p.names {
+		if err := s.Write(p.users[u], store.Accounts, p.newName, store.Users, store.JwtName(u)); err != nil {
+			r.AddError("error moving user %q: %v", u, err)
+			return r, nil
+		}
+		if err := s.Delete(store.Accounts, p.name, store.Users, store.JwtName(u)); err != nil {
+			r.AddError("error removing user %q from account %q: %v", u, p.name, err)
+			return r, nil
+		}
+		fp, err := ks.MoveUserCreds(p.name, u, p.newName, u)
+		if err != nil {
+			r.AddError("error moving creds file for user %q: %v", u, err)
+		} else if fp != "" {
+			r.AddOK("moved creds file for user %q to %#q", u, AbbrevHomePaths(fp))
+		}
+	}
+	if len(p.names) > 0 {
+		_ = s.Delete(store.Accounts, p.name, store.Users)
+	}
+	if err := s.Delete(store.Accounts, p.name, store.JwtName(p.name)); err != nil {
+		r.AddError("error removing the jwt for account %q: %v", p.name, err)
+		return r, nil
+	}
+	if err := s.Delete(store.Accounts, p.name); err != nil {
+		r.AddWarning("unable to remove directory for account %q: %v", p.name, err)
+	}
+
+	// update the context
+	config := GetConfig()
+	if p.current == p.name {
+		if err := config.SetAccount(p.newName); err != nil {
+			r.AddWarning("unable to set the current account to %q: %v", p.newName, err)
+		} else {
+			r.AddOK("current account set to %q", p.newName)
+		}
+	} else if config.Account != p.current {
+		_ = config.SetAccountTemp(p.current)
+	}
+	return r, nil
+}
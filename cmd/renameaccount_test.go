@@ -0,0 +1,117 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RenameAccount(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddUser(t, "A", "U")
+	ts.AddUser(t, "A", "V")
+	ts.AddAccount(t, "C")
+	require.NoError(t, GetConfig().SetAccount("A"))
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.NotEmpty(t, ts.KeyStore.GetUserCredsPath("A", "U"))
+
+	_, _, err = ExecuteCmd(createRenameAccountCmd(), "A", "B")
+	require.NoError(t, err)
+
+	require.False(t, ts.Store.Has(store.Accounts, "A"))
+	bc, err := ts.Store.ReadAccountClaim("B")
+	require.NoError(t, err)
+	require.Equal(t, "B", bc.Name)
+	require.Equal(t, ac.Subject, bc.Subject)
+
+	users, err := ts.Store.ListEntries(store.Accounts, "B", store.Users)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"U", "V"}, users)
+	uc, err := ts.Store.ReadUserClaim("B", "U")
+	require.NoError(t, err)
+	require.Equal(t, ac.Subject, uc.Issuer)
+
+	require.Empty(t, ts.KeyStore.GetUserCredsPath("A", "U"))
+	require.NotEmpty(t, ts.KeyStore.GetUserCredsPath("B", "U"))
+	require.NotEmpty(t, ts.KeyStore.GetUserCredsPath("B", "V"))
+	_, err = os.Stat(ts.KeyStore.CalcUserCredsPath("A", "U"))
+	require.True(t, os.IsNotExist(err))
+
+	require.Equal(t, "B", GetConfig().Account)
+	e, err := ts.Store.LookupKey(ac.Subject)
+	require.NoError(t, err)
+	require.Equal(t, "B", e.Name)
+}
+
+func Test_RenameAccountNotCurrent(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	ts.AddAccount(t, "C")
+	require.NoError(t, GetConfig().SetAccount("C"))
+
+	_, _, err := ExecuteCmd(createRenameAccountCmd(), "--name", "A", "--new-name", "B")
+	require.NoError(t, err)
+	require.Equal(t, "C", GetConfig().Account)
+	require.True(t, ts.Store.HasAccount("B"))
+}
+
+func Test_RenameAccountExists(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	ts.AddAccount(t, "B")
+
+	_, _, err := ExecuteCmd(createRenameAccountCmd(), "A", "B")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "already exists")
+
+	_, _, err = ExecuteCmd(createRenameAccountCmd(), "A", "A")
+	require.Error(t, err)
+
+	_, _, err = ExecuteCmd(createRenameAccountCmd(), "A")
+	require.Error(t, err)
+	require.True(t, ts.Store.HasAccount("A"))
+}
+
+func Test_RenameAccountManaged(t *testing.T) {
+	as, m := RunTestAccountServer(t)
+	defer as.Close()
+
+	ts := NewTestStoreWithOperatorJWT(t, string(m["operator"]))
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createRenameAccountCmd(), "A", "B")
+	require.NoError(t, err)
+
+	pushed, err := jwt.DecodeAccountClaims(string(m[ac.Subject]))
+	require.NoError(t, err)
+	require.Equal(t, "B", pushed.Name)
+	require.True(t, ts.Store.HasAccount("B"))
+	require.False(t, ts.Store.Has(store.Accounts, "A"))
+}
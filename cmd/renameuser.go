@@ -0,0 +1,194 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	cli "github.com/nats-io/cliprompts/v2"
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createRenameUserCmd() *cobra.Command {
+	var params RenameUserParams
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Rename a user",
+		Long: `Rename a user
+
+The user JWT is re-signed with the new name and moved, if the
+user key is in the keystore the creds file is regenerated.`,
+		Example: `nsc rename user U V
+nsc rename user --account A --name U --new-name V`,
+		Args:         MaxArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	params.AccountContextParams.BindFlags(cmd)
+	params.RenameParams.BindFlags("user", cmd)
+	return cmd
+}
+
+func init() {
+	renameCmd.AddCommand(createRenameUserCmd())
+}
+
+type RenameUserParams struct {
+	AccountContextParams
+	SignerParams
+	RenameParams
+	claim *jwt.UserClaims
+}
+
+func (p *RenameUserParams) SetDefaults(ctx ActionCtx) error {
+	p.RenameParams.SetDefaults(ctx)
+	if err := p.AccountContextParams.SetDefaults(ctx); err != nil {
+		return err
+	}
+	p.SignerParams.SetDefaults(nkeys.PrefixByteAccount, true, ctx)
+	return nil
+}
+
+func (p *RenameUserParams) PreInteractive(ctx ActionCtx) error {
+	var err error
+	if err = p.AccountContextParams.Edit(ctx); err != nil {
+		return err
+	}
+	if p.name == "" {
+		p.name, err = ctx.StoreCtx().PickUser(p.AccountContextParams.Name)
+		if err != nil {
+			return err
+		}
+	}
+	p.newName, err = cli.Prompt("new user name", p.newName, cli.NewLengthValidator(1))
+	return err
+}
+
+func (p *RenameUserParams) Load(ctx ActionCtx) error {
+	var err error
+	if err = p.AccountContextParams.Validate(ctx); err != nil {
+		return err
+	}
+	if p.name == "" {
+		n := ctx.StoreCtx().DefaultUser(p.AccountContextParams.Name)
+		if n != nil {
+			p.name = *n
+		}
+	}
+	if p.name == "" {
+		ctx.CurrentCmd().SilenceUsage = false
+		return errors.New("user name is required")
+	}
+	p.claim, err = ctx.StoreCtx().Store.ReadUserClaim(p.AccountContextParams.Name, p.name)
+	return err
+}
+
+func (p *RenameUserParams) PostInteractive(ctx ActionCtx) error {
+	return p.SignerParams.Edit(ctx)
+}
+
+func (p *RenameUserParams) Validate(ctx ActionCtx) error {
+	if err := p.RenameParams.Validate(ctx); err != nil {
+		return err
+	}
+	account := p.AccountContextParams.Name
+	if ctx.StoreCtx().Store.Has(store.Accounts, account, store.Users, store.JwtName(p.newName)) {
+		return fmt.Errorf("user %q already exists in account %q", p.newName, account)
+	}
+	ks := ctx.StoreCtx().KeyStore
+	if ks.GetUserCredsPath(account, p.newName) != "" {
+		return fmt.Errorf("creds file %#q already exists", AbbrevHomePaths(ks.CalcUserCredsPath(account, p.newName)))
+	}
+	if err := p.SignerParams.Resolve(ctx); err != nil {
+		return err
+	}
+	uc := *p.claim
+	uc.Name = p.newName
+	return checkPolicy(ctx, &uc)
+}
+
+func (p *RenameUserParams) Run(ctx ActionCtx) (store.Status, error) {
+	ctx.CurrentCmd().SilenceUsage = true
+	r := store.NewDetailedReport(true)
+	r.ReportSum = false
+	s := ctx.StoreCtx().Store
+	ks := ctx.StoreCtx().KeyStore
+	account := p.AccountContextParams.Name
+
+	ac, err := s.ReadAccountClaim(account)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := p.signerKP.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	// signer doesn't match - so we set IssuerAccount to the account
+	p.claim.IssuerAccount = ""
+	if pk != ac.Subject {
+		p.claim.IssuerAccount = ac.Subject
+	}
+	p.claim.Name = p.newName
+	token, err := p.claim.Encode(p.signerKP)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.StoreRaw([]byte(token)); err != nil {
+		r.AddFromError(err)
+		return r, nil
+	}
+	if err := s.Delete(store.Accounts, account, store.Users, store.JwtName(p.name)); err != nil {
+		r.AddError("error removing the jwt for user %q: %v", p.name, err)
+		return r, nil
+	}
+	r.AddOK("user %q renamed to %q", p.name, p.newName)
+
+	if ks.HasPrivateKey(p.claim.Subject) {
+		ukp, err := ks.GetKeyPair(p.claim.Subject)
+		if err != nil {
+			r.AddError("unable to read keypair: %v", err)
+			return r, nil
+		}
+		d, err := GenerateConfig(s, account, p.newName, ukp)
+		if err != nil {
+			r.AddError("unable to save creds: %v", err)
+			return r, nil
+		}
+		fp, err := ks.MaybeStoreUserCreds(account, p.newName, d)
+		if err != nil {
+			r.AddError("error storing creds: %v", err)
+			return r, nil
+		}
+		r.AddOK("generated user creds file %#q", AbbrevHomePaths(fp))
+		if err := ks.RemoveUserCreds(account, p.name); err != nil {
+			r.AddWarning("unable to remove the creds file for %q: %v", p.name, err)
+		}
+	} else {
+		fp, err := ks.MoveUserCreds(account, p.name, account, p.newName)
+		if err != nil {
+			r.AddError("error moving creds file: %v", err)
+		} else if fp != "" {
+			r.AddWarning("moved creds file to %#q - it contains the jwt with the previous name, the user private key is not available to regenerate it", AbbrevHomePaths(fp))
+		}
+	}
+	return r, nil
+}
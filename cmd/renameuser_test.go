@@ -0,0 +1,81 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RenameUser(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddUser(t, "A", "U")
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createRenameUserCmd(), "--account", "A", "U", "V")
+	require.NoError(t, err)
+
+	require.False(t, ts.Store.Has(store.Accounts, "A", store.Users, store.JwtName("U")))
+	vc, err := ts.Store.ReadUserClaim("A", "V")
+	require.NoError(t, err)
+	require.Equal(t, "V", vc.Name)
+	require.Equal(t, uc.Subject, vc.Subject)
+
+	require.Empty(t, ts.KeyStore.GetUserCredsPath("A", "U"))
+	fp := ts.KeyStore.GetUserCredsPath("A", "V")
+	require.NotEmpty(t, fp)
+	d, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	token, err := jwt.ParseDecoratedJWT(d)
+	require.NoError(t, err)
+	cc, err := jwt.DecodeUserClaims(token)
+	require.NoError(t, err)
+	require.Equal(t, "V", cc.Name)
+}
+
+func Test_RenameUserExists(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddUser(t, "A", "U")
+	ts.AddUser(t, "A", "V")
+
+	_, _, err := ExecuteCmd(createRenameUserCmd(), "--name", "U", "--new-name", "V")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "already exists")
+}
+
+func Test_RenameUserWithoutKey(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddUser(t, "A", "U")
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.NoError(t, ts.KeyStore.Remove(uc.Subject))
+
+	_, stderr, err := ExecuteCmd(createRenameUserCmd(), "U", "V")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "previous name")
+	require.Empty(t, ts.KeyStore.GetUserCredsPath("A", "U"))
+	require.NotEmpty(t, ts.KeyStore.GetUserCredsPath("A", "V"))
+}
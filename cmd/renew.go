@@ -0,0 +1,223 @@
+/*
+ * Copyright 2018-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createRenewCmd() *cobra.Command {
+	var params RenewParams
+	cmd := &cobra.Command{
+		Use:   "renew",
+		Short: "Re-issue expiring JWTs with a new expiration",
+		Long: `Re-issue expiring JWTs with a new expiration
+
+The operator, account and users listed by 'nsc list expiring' with the
+same flags are re-issued with the new expiry. All other claims are kept,
+and each JWT is signed with the key that issued it, which must be in the
+keystore. Accounts of managed operators are signed with their own key. Creds files are regenerated for users with a private key in the
+keystore, and accounts of managed operators are pushed to the account server.
+
+Specify --user to renew a single user regardless of its expiration.
+
+Activation tokens are issued by the exporting account and are not renewed,
+generate new ones with 'nsc generate activation'.`,
+		Example: `nsc renew --expiry 1y
+nsc renew --within 2w --expiry 6M --all-accounts
+nsc renew --account A --user U --expiry 30d
+nsc renew --expiry 1y --dry-run`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().StringVarP(&params.expiry, "expiry", "", "", "new expiry ('0' is always, '2M' is two months) - yyyy-mm-dd, #m(inutes), #h(ours), #d(ays), #w(eeks), #M(onths), #y(ears)")
+	cmd.Flags().StringVarP(&params.within, "within", "", "30d", "renew JWTs expiring within the duration - #m(inutes), #h(ours), #d(ays), #w(eeks), #M(onths), #y(ears) or yyyy-mm-dd")
+	cmd.Flags().BoolVarP(&params.allAccounts, "all-accounts", "A", false, "renew the entities of all accounts (exclusive of --account)")
+	cmd.Flags().StringVarP(&params.user, "user", "u", "", "renew only the specified user")
+	params.AccountContextParams.BindFlags(cmd)
+	return cmd
+}
+
+func init() {
+	GetRootCmd().AddCommand(createRenewCmd())
+}
+
+type RenewParams struct {
+	AccountContextParams
+	ExpiringParams
+	SignerParams
+	expiry  string
+	expires int64
+	user    string
+	claims  map[string]*jwt.AccountClaims
+}
+
+func (p *RenewParams) SetDefaults(ctx ActionCtx) error {
+	if p.expiry == "" {
+		ctx.CurrentCmd().SilenceUsage = false
+		return errors.New("specify the new expiry with --expiry")
+	}
+	if p.user != "" && p.allAccounts {
+		return errors.New("specify only one of --user or --all-accounts")
+	}
+	// accounts of managed operators are signed with their own key
+	p.SignerParams.SetDefaults(nkeys.PrefixByteOperator, true, ctx)
+	return p.ExpiringParams.SetDefaults(ctx, &p.AccountContextParams)
+}
+
+func (p *RenewParams) PreInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *RenewParams) Load(ctx ActionCtx) error {
+	if p.user == "" {
+		return p.ExpiringParams.Load(ctx, &p.AccountContextParams)
+	}
+	if err := p.AccountContextParams.Validate(ctx); err != nil {
+		return err
+	}
+	account := p.AccountContextParams.Name
+	s := ctx.StoreCtx().Store
+	if !s.Has(store.Accounts, account, store.Users, store.JwtName(p.user)) {
+		return fmt.Errorf("user %q not found in account %q", p.user, account)
+	}
+	uc, err := s.ReadUserClaim(account, p.user)
+	if err != nil {
+		return err
+	}
+	p.entities = []*expiringEntity{{Kind: "user", Name: p.user, Account: account, claims: uc}}
+	return nil
+}
+
+func (p *RenewParams) PostInteractive(ctx ActionCtx) error {
+	return nil
+}
+
+func (p *RenewParams) Validate(ctx ActionCtx) error {
+	var err error
+	p.expires, err = ParseExpiry(p.expiry)
+	if err != nil {
+		return err
+	}
+	if p.expires != 0 && p.expires < time.Now().Unix() {
+		return fmt.Errorf("expiry %q is in the past", p.expiry)
+	}
+	var claims []jwt.Claims
+	for _, e := range p.entities {
+		switch c := e.claims.(type) {
+		case *jwt.AccountClaims:
+			ac := *c
+			ac.Expires = p.expires
+			claims = append(claims, &ac)
+		case *jwt.UserClaims:
+			uc := *c
+			uc.Expires = p.expires
+			claims = append(claims, &uc)
+		}
+	}
+	return checkPolicy(ctx, claims...)
+}
+
+func (p *RenewParams) account(ctx ActionCtx, name string) (*jwt.AccountClaims, error) {
+	if ac := p.claims[name]; ac != nil {
+		return ac, nil
+	}
+	ac, err := ctx.StoreCtx().Store.ReadAccountClaim(name)
+	if err != nil {
+		return nil, err
+	}
+	p.claims[name] = ac
+	return ac, nil
+}
+
+func (p *RenewParams) renew(ctx ActionCtx, e *expiringEntity, r *store.Report) error {
+	if e.Kind == "activation" {
+		r.AddWarning("activation %q in account %q must be generated again by the exporting account", e.Name, e.Account)
+		return nil
+	}
+	signer := e.claims.Claims().Issuer
+	switch e.claims.(type) {
+	case *jwt.OperatorClaims:
+		if ctx.StoreCtx().Store.IsManaged() {
+			r.AddWarning("operator %q is managed, its jwt is renewed by the account server", e.Name)
+			return nil
+		}
+	case *jwt.AccountClaims:
+		if p.SignerParams.kind == nkeys.PrefixByteAccount {
+			signer = e.claims.Claims().Subject
+		}
+	}
+	kp, err := storedKey(ctx, signer)
+	if err != nil {
+		return err
+	}
+	e.claims.Claims().Expires = p.expires
+	switch c := e.claims.(type) {
+	case *jwt.OperatorClaims:
+		token, err := c.Encode(kp)
+		if err != nil {
+			return err
+		}
+		if err := ctx.StoreCtx().Store.StoreRaw([]byte(token)); err != nil {
+			return err
+		}
+		r.AddOK("renewed operator %q", c.Name)
+	case *jwt.AccountClaims:
+		token, err := c.Encode(kp)
+		if err != nil {
+			return err
+		}
+		ar := r.AddOK("renewed account %q", e.Name)
+		StoreAccountAndUpdateStatus(ctx, token, ar)
+		p.claims[e.Name] = c
+	case *jwt.UserClaims:
+		ac, err := p.account(ctx, e.Account)
+		if err != nil {
+			return err
+		}
+		if err := reissueUser(ctx, e.Account, ac, c, kp, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *RenewParams) Run(ctx ActionCtx) (store.Status, error) {
+	r := store.NewDetailedReport(true)
+	r.ReportSum = false
+	if p.claims == nil {
+		p.claims = make(map[string]*jwt.AccountClaims)
+	}
+	for _, e := range p.entities {
+		if err := p.renew(ctx, e, r); err != nil {
+			r.AddError("error renewing %s %q: %v", e.Kind, e.Name, err)
+		}
+	}
+	if len(p.entities) == 0 {
+		r.AddOK("no jwts expire within %s", p.within)
+	}
+	return r, nil
+}
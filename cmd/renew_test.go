@@ -0,0 +1,156 @@
+/*
+ * Copyright 2018-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Renew(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	sk, spk, skp := CreateAccountKey(t)
+	_, err := ts.KeyStore.Store(skp)
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createEditAccount(), "--sk", spk)
+	require.NoError(t, err)
+
+	addSignedUser(t, "A", "U", sk, "--allow-pub", "foo", "--expiry", "10d")
+	_, _, err = ExecuteCmd(CreateAddUserCmd(), "--name", "V", "--expiry", "20d")
+	require.NoError(t, err)
+	ts.AddUser(t, "A", "W")
+	before, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	w, err := ts.Store.ReadUserClaim("A", "W")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createRenewCmd(), "--expiry", "1y")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `re-issued user "U"`)
+	require.Contains(t, stderr, `re-issued user "V"`)
+	require.NotContains(t, stderr, `re-issued user "W"`)
+
+	expires := time.Now().AddDate(0, 11, 0).Unix()
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.True(t, uc.Expires > expires)
+	require.Equal(t, spk, uc.Issuer)
+	require.Equal(t, before.Pub, uc.Pub)
+
+	v, err := ts.Store.ReadUserClaim("A", "V")
+	require.NoError(t, err)
+	require.True(t, v.Expires > expires)
+	require.Equal(t, ts.GetAccountPublicKey(t, "A"), v.Issuer)
+
+	uc, err = ts.Store.ReadUserClaim("A", "W")
+	require.NoError(t, err)
+	require.Equal(t, w.ID, uc.ID)
+
+	// the creds file has the new jwt
+	d, err := ioutil.ReadFile(ts.KeyStore.GetUserCredsPath("A", "U"))
+	require.NoError(t, err)
+	token, err := jwt.ParseDecoratedJWT(d)
+	require.NoError(t, err)
+	cc, err := jwt.DecodeUserClaims(token)
+	require.NoError(t, err)
+	require.True(t, cc.Expires > expires)
+}
+
+func Test_RenewAccount(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	_, _, err := ExecuteCmd(CreateAddAccountCmd(), "--name", "A", "--expiry", "5d")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createRenewCmd(), "--expiry", "6M")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `renewed account "A"`)
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.True(t, ac.Expires > time.Now().AddDate(0, 5, 0).Unix())
+}
+
+func Test_RenewUser(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+
+	_, _, err := ExecuteCmd(createRenewCmd(), "--user", "U", "--expiry", "30d")
+	require.NoError(t, err)
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.True(t, uc.Expires > time.Now().AddDate(0, 0, 29).Unix())
+
+	_, _, err = ExecuteCmd(createRenewCmd(), "--user", "X", "--expiry", "30d")
+	require.Error(t, err)
+}
+
+func Test_RenewMissingKey(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	sk, spk, _ := CreateAccountKey(t)
+	_, _, err := ExecuteCmd(createEditAccount(), "--sk", spk)
+	require.NoError(t, err)
+	addSignedUser(t, "A", "U", sk, "--expiry", "10d")
+
+	_, stderr, err := ExecuteCmd(createRenewCmd(), "--expiry", "1y")
+	require.Error(t, err)
+	require.Contains(t, stderr, "is not in the keystore")
+}
+
+func Test_RenewRequiresExpiry(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+
+	_, stderr, err := ExecuteCmd(createRenewCmd())
+	require.Error(t, err)
+	require.Contains(t, stderr, "specify the new expiry")
+
+	_, stderr, err = ExecuteCmd(createRenewCmd(), "--expiry", "2000-01-01")
+	require.Error(t, err)
+	require.Contains(t, stderr, "is in the past")
+}
+
+func Test_RenewManagedAccount(t *testing.T) {
+	as, m := RunTestAccountServer(t)
+	defer as.Close()
+
+	ts := NewTestStoreWithOperatorJWT(t, string(m["operator"]))
+	defer ts.Done(t)
+	_, _, err := ExecuteCmd(CreateAddAccountCmd(), "--name", "A", "--expiry", "5d")
+	require.NoError(t, err)
+	apk := ts.GetAccountPublicKey(t, "A")
+
+	_, stderr, err := ExecuteCmd(createRenewCmd(), "--expiry", "6M")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `renewed account "A"`)
+
+	ac, err := jwt.DecodeAccountClaims(string(m[apk]))
+	require.NoError(t, err)
+	require.True(t, ac.Expires > time.Now().AddDate(0, 5, 0).Unix())
+	ac, err = ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.True(t, ac.Expires > time.Now().AddDate(0, 5, 0).Unix())
+}
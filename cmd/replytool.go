@@ -110,6 +110,10 @@ func (p *RepParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *RepParams) ReadOnly() bool {
+	return true
+}
+
 func (p *RepParams) Run(ctx ActionCtx) (store.Status, error) {
 	opts := createDefaultToolOptions("nscreply", ctx)
 	opts = append(opts, nats.UserCredentials(p.credsPath))
@@ -113,6 +113,10 @@ func (p *ReqParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *ReqParams) ReadOnly() bool {
+	return true
+}
+
 func (p *ReqParams) Run(ctx ActionCtx) (store.Status, error) {
 	opts := createDefaultToolOptions("nsc_req", ctx)
 	opts = append(opts, nats.UserCredentials(p.credsPath))
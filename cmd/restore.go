@@ -0,0 +1,271 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	cli "github.com/nats-io/cliprompts/v2"
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore deleted accounts and users from the trash",
+}
+
+func init() {
+	GetRootCmd().AddCommand(restoreCmd)
+	restoreCmd.AddCommand(createRestoreCmd(jwt.AccountClaim))
+	restoreCmd.AddCommand(createRestoreCmd(jwt.UserClaim))
+}
+
+func createRestoreCmd(kind string) *cobra.Command {
+	var params RestoreParams
+	params.kind = kind
+	example := `nsc restore account -n name
+nsc restore account --id <trash id>`
+	if kind == jwt.UserClaim {
+		example = `nsc restore user -n name
+nsc restore user -a account -n name
+nsc restore user --id <trash id>`
+	}
+	cmd := &cobra.Command{
+		Use:   kind,
+		Short: fmt.Sprintf("Restore a deleted %s", kind),
+		Long: fmt.Sprintf(`Restore a deleted %s
+
+The most recently deleted %s with the name is restored, together with
+the nkeys and creds files that were moved to the trash. Use --id to
+restore a specific entry listed by 'nsc trash list'.`, kind, kind),
+		Example:      example,
+		Args:         MaxArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().StringVarP(&params.name, "name", "n", "", fmt.Sprintf("name of the %s to restore", kind))
+	cmd.Flags().StringVarP(&params.id, "id", "", "", "ID of the trash entry to restore")
+	if kind == jwt.UserClaim {
+		params.AccountContextParams.BindFlags(cmd)
+	}
+	return cmd
+}
+
+type RestoreParams struct {
+	AccountContextParams
+	kind  string
+	name  string
+	id    string
+	entry *store.TrashEntry
+}
+
+func (p *RestoreParams) SetDefaults(ctx ActionCtx) error {
+	p.name = NameFlagOrArgument(p.name, ctx)
+	if p.kind == jwt.UserClaim && p.id == "" {
+		return p.AccountContextParams.SetDefaults(ctx)
+	}
+	return nil
+}
+
+// candidates returns the trash entries for the kind of entity, newest first
+func (p *RestoreParams) candidates(ctx ActionCtx) ([]*store.TrashEntry, error) {
+	entries, err := ctx.StoreCtx().Store.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+	var matches []*store.TrashEntry
+	for _, e := range entries {
+		if e.Kind != p.kind {
+			continue
+		}
+		if p.kind == jwt.UserClaim && e.Account != p.AccountContextParams.Name {
+			continue
+		}
+		if p.name != "" && e.Name != p.name {
+			continue
+		}
+		matches = append(matches, e)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Deleted > matches[j].Deleted
+	})
+	return matches, nil
+}
+
+func (p *RestoreParams) PreInteractive(ctx ActionCtx) error {
+	if p.id != "" {
+		return nil
+	}
+	if p.kind == jwt.UserClaim {
+		if err := p.AccountContextParams.Edit(ctx); err != nil {
+			return err
+		}
+	}
+	entries, err := p.candidates(ctx)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("the trash doesn't contain a deleted %s", p.kind)
+	}
+	var labels []string
+	for _, e := range entries {
+		labels = append(labels, fmt.Sprintf("%s deleted on %s", e.Name, e.DeletedAt().Format(time.RFC3339)))
+	}
+	i, err := cli.Select(fmt.Sprintf("select the %s to restore", p.kind), "", labels)
+	if err != nil {
+		return err
+	}
+	p.id = entries[i].ID
+	return nil
+}
+
+func (p *RestoreParams) Load(ctx ActionCtx) error {
+	var err error
+	if p.id != "" {
+		p.entry, err = ctx.StoreCtx().Store.ReadTrashEntry(p.id)
+		if err != nil {
+			return err
+		}
+		if p.entry.Kind != p.kind {
+			return fmt.Errorf("trash entry %q is for %s", p.id, trashEntryName(p.entry))
+		}
+		return nil
+	}
+	if p.name == "" {
+		ctx.CurrentCmd().SilenceUsage = false
+		return fmt.Errorf("%s name or --id is required", p.kind)
+	}
+	entries, err := p.candidates(ctx)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		if p.kind == jwt.UserClaim {
+			return fmt.Errorf("the trash doesn't contain user %q in account %q", p.name, p.AccountContextParams.Name)
+		}
+		return fmt.Errorf("the trash doesn't contain %s %q", p.kind, p.name)
+	}
+	p.entry = entries[0]
+	return nil
+}
+
+func (p *RestoreParams) PostInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *RestoreParams) Validate(ctx ActionCtx) error {
+	s := ctx.StoreCtx().Store
+	e := p.entry
+	if len(e.Files) == 0 {
+		return fmt.Errorf("trash entry %q doesn't contain any JWTs", e.ID)
+	}
+	for _, f := range e.Files {
+		if s.Has(filepath.FromSlash(f)) {
+			return fmt.Errorf("unable to restore %s - %#q exists", trashEntryName(e), f)
+		}
+	}
+	if e.Kind != jwt.UserClaim {
+		return nil
+	}
+	// the account may have been recreated with different keys
+	ac, err := s.ReadAccountClaim(e.Account)
+	if err != nil {
+		return fmt.Errorf("unable to restore %s: %v", trashEntryName(e), err)
+	}
+	d, err := s.ReadTrashFile(e, e.Files[0])
+	if err != nil {
+		return err
+	}
+	uc, err := jwt.DecodeUserClaims(string(d))
+	if err != nil {
+		return fmt.Errorf("error decoding user %q in the trash: %v", e.Name, err)
+	}
+	if uc.Issuer != ac.Subject && !ac.SigningKeys.Contains(uc.Issuer) {
+		return fmt.Errorf("unable to restore %s - it was issued by %q which is neither the account key nor one of its signing keys", trashEntryName(e), uc.Issuer)
+	}
+	return nil
+}
+
+func (p *RestoreParams) Run(ctx ActionCtx) (store.Status, error) {
+	s := ctx.StoreCtx().Store
+	ks := ctx.StoreCtx().KeyStore
+	e := p.entry
+	r := store.NewDetailedReport(true)
+
+	// accounts are restored before their users
+	files := append([]string(nil), e.Files...)
+	sort.SliceStable(files, func(i, j int) bool {
+		return strings.Count(files[i], "/") < strings.Count(files[j], "/")
+	})
+	for _, f := range files {
+		d, err := s.ReadTrashFile(e, f)
+		if err != nil {
+			r.AddFromError(err)
+			return r, err
+		}
+		if err := s.Write(d, filepath.FromSlash(f)); err != nil {
+			r.AddFromError(err)
+			return r, err
+		}
+		r.AddOK("restored %#q", f)
+	}
+	for _, k := range e.Keys {
+		if err := ks.RestoreKey(e, k); err != nil {
+			r.AddError("unable to restore key %q: %v", k, err)
+		} else {
+			r.AddOK("restored key %q", k)
+		}
+	}
+	for _, c := range e.Creds {
+		if err := ks.RestoreUserCreds(e, c); err != nil {
+			r.AddError("unable to restore creds file for user %q in account %q: %v", c.User, c.Account, err)
+		} else {
+			r.AddOK("restored creds file for user %q in account %q", c.User, c.Account)
+		}
+	}
+	if r.HasNoErrors() {
+		if err := ks.RemoveTrash(e); err != nil {
+			r.AddWarning("unable to remove the keystore trash for entry %q: %v", e.ID, err)
+		}
+		if err := s.RemoveTrashEntry(e); err != nil {
+			r.AddWarning("unable to remove trash entry %q: %v", e.ID, err)
+		}
+	}
+
+	switch e.Kind {
+	case jwt.AccountClaim:
+		if s.IsManaged() {
+			r.AddWarning("the account server still has the expired JWT for account %q - edit the account to update it", e.Name)
+		}
+	case jwt.UserClaim:
+		if ac, err := s.ReadAccountClaim(e.Account); err == nil {
+			if uc, err := s.ReadUserClaim(e.Account, e.Name); err == nil && ac.Revocations[uc.Subject] != 0 {
+				r.AddWarning("user %q is revoked by account %q", e.Name, e.Account)
+			}
+		}
+	}
+	r.AddOK("restored %s", trashEntryName(e))
+	return r, nil
+}
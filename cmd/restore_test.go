@@ -0,0 +1,144 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RestoreUser(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	creds := ts.KeyStore.CalcUserCredsPath("A", "U")
+
+	_, _, err = ExecuteCmd(createDeleteUserCmd(), "--name", "U", "--rm-nkey", "--rm-creds")
+	require.NoError(t, err)
+	require.False(t, ts.Store.Has("accounts", "A", "users", "U.jwt"))
+	require.False(t, ts.KeyStore.HasPrivateKey(uc.Subject))
+	_, err = os.Stat(creds)
+	require.True(t, os.IsNotExist(err))
+
+	_, stderr, err := ExecuteCmd(createRestoreCmd("user"), "--name", "U")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "restored user \"U\" in account \"A\"")
+
+	ruc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, uc.ID, ruc.ID)
+	require.True(t, ts.KeyStore.HasPrivateKey(uc.Subject))
+	require.FileExists(t, creds)
+
+	entries, err := ts.Store.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
+
+func Test_RestoreRevokedUserWarns(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+
+	_, _, err := ExecuteCmd(createDeleteUserCmd(), "--name", "U", "--revoke")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createRestoreCmd("user"), "U")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "is revoked by account")
+}
+
+func Test_RestoreAccount(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+	ts.AddUser(t, "A", "V")
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(createDeleteAccountCmd(), "A", "--rm-nkey", "--rm-creds")
+	require.NoError(t, err)
+	require.False(t, ts.Store.HasAccount("A"))
+	require.False(t, ts.KeyStore.HasPrivateKey(ac.Subject))
+
+	_, _, err = ExecuteCmd(createRestoreCmd("account"), "A")
+	require.NoError(t, err)
+
+	rac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	// the account is restored as it was before it was expired
+	require.Equal(t, ac.ID, rac.ID)
+	require.Zero(t, rac.Expires)
+	require.True(t, ts.KeyStore.HasPrivateKey(ac.Subject))
+	users, err := ts.Store.ListEntries("accounts", "A", "users")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"U", "V"}, users)
+	require.True(t, ts.KeyStore.HasPrivateKey(uc.Subject))
+	require.FileExists(t, ts.KeyStore.CalcUserCredsPath("A", "V"))
+
+	e, err := ts.Store.LookupKey(uc.Subject)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	require.Equal(t, "A", e.Account)
+}
+
+func Test_RestoreAccountExists(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	_, _, err := ExecuteCmd(createDeleteAccountCmd(), "A")
+	require.NoError(t, err)
+	ts.AddAccount(t, "A")
+
+	_, _, err = ExecuteCmd(createRestoreCmd("account"), "A")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exists")
+}
+
+func Test_RestoreUserFromRecreatedAccount(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+
+	_, _, err := ExecuteCmd(createDeleteAccountCmd(), "A")
+	require.NoError(t, err)
+	ts.AddAccount(t, "A")
+	entries, err := ts.Store.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	_, _, err = ExecuteCmd(createRestoreCmd("user"), "--id", entries[0].ID)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is for account \"A\"")
+}
+
+func Test_RestoreNotInTrash(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+
+	_, _, err := ExecuteCmd(createRestoreCmd("user"), "X")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the trash doesn't contain user \"X\" in account \"A\"")
+}
@@ -186,10 +186,17 @@ func (p *RevokeListActivationParams) PostInteractive(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *RevokeListActivationParams) ReadOnly() bool {
+	return true
+}
+
 func (p *RevokeListActivationParams) Run(ctx ActionCtx) (store.Status, error) {
 	if p.export == nil {
 		return nil, fmt.Errorf("unable to locate export")
 	}
+	if StructuredOutput() {
+		return nil, writeRevocations(p.export.Revocations)
+	}
 
 	table := tablewriter.CreateTable()
 	table.UTF8Box()
@@ -17,6 +17,7 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/nats-io/jwt"
@@ -91,7 +92,14 @@ func (p *RevokeListUserParams) PostInteractive(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *RevokeListUserParams) ReadOnly() bool {
+	return true
+}
+
 func (p *RevokeListUserParams) Run(ctx ActionCtx) (store.Status, error) {
+	if StructuredOutput() {
+		return nil, writeRevocations(p.claim.Revocations)
+	}
 	table := tablewriter.CreateTable()
 	table.UTF8Box()
 
@@ -112,3 +120,25 @@ func (p *RevokeListUserParams) Run(ctx ActionCtx) (store.Status, error) {
 
 	return nil, Write("--", []byte(table.Render()))
 }
+
+// listedRevocation is the structured form of a revocation
+type listedRevocation struct {
+	PublicKey string `json:"public_key"`
+	Before    int64  `json:"revoked_before"`
+}
+
+// revocationList returns the revocations sorted by public key
+func revocationList(revocations jwt.RevocationList) []listedRevocation {
+	list := []listedRevocation{}
+	for pk, at := range revocations {
+		list = append(list, listedRevocation{PublicKey: pk, Before: at})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].PublicKey < list[j].PublicKey
+	})
+	return list
+}
+
+func writeRevocations(revocations jwt.RevocationList) error {
+	return WriteOutput(revocationList(revocations))
+}
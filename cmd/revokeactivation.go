@@ -23,6 +23,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -211,18 +212,16 @@ func (p *RevokeActivationParams) Run(ctx ActionCtx) (store.Status, error) {
 		return nil, fmt.Errorf("unable to locate export")
 	}
 
-	if p.at == 0 {
-		p.export.Revoke(p.accountKey.publicKey)
-	} else {
-		p.export.RevokeAt(p.accountKey.publicKey, time.Unix(int64(p.at), 0))
+	var at time.Time
+	if p.at != 0 {
+		at = time.Unix(int64(p.at), 0)
 	}
-
-	token, err := p.claim.Encode(p.signerKP)
-	if err != nil {
+	r := store.NewDetailedReport(true)
+	rs, err := apiFor(ctx).RevokeActivation(p.AccountContextParams.Name, string(p.export.Subject), p.export.Type,
+		p.accountKey.publicKey, at, &api.SignOptions{Signer: p.signerKP})
+	if err := addAccountStatus(r, rs, err); err != nil {
 		return nil, err
 	}
-	r := store.NewDetailedReport(true)
-	StoreAccountAndUpdateStatus(ctx, token, r)
 	if r.HasNoErrors() {
 		r.AddOK("revoked activation %s for account %s", p.export.Name, p.accountKey.publicKey)
 	}
@@ -23,6 +23,7 @@ import (
 	cli "github.com/nats-io/cliprompts/v2"
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/api"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/spf13/cobra"
 )
@@ -148,19 +149,15 @@ func (p *RevokeUserParams) PostInteractive(ctx ActionCtx) error {
 }
 
 func (p *RevokeUserParams) Run(ctx ActionCtx) (store.Status, error) {
-	if p.at == 0 {
-		p.claim.Revoke(p.userPubKey)
-	} else {
-		p.claim.RevokeAt(p.userPubKey, time.Unix(int64(p.at), 0))
+	var at time.Time
+	if p.at != 0 {
+		at = time.Unix(int64(p.at), 0)
 	}
-
-	token, err := p.claim.Encode(p.signerKP)
-	if err != nil {
+	r := store.NewDetailedReport(true)
+	rs, err := apiFor(ctx).RevokeUser(p.AccountContextParams.Name, p.user, at, &api.SignOptions{Signer: p.signerKP})
+	if err := addAccountStatus(r, rs, err); err != nil {
 		return nil, err
 	}
-
-	r := store.NewDetailedReport(true)
-	StoreAccountAndUpdateStatus(ctx, token, r)
 	if r.HasNoErrors() {
 		r.AddOK("revoked user %s", p.userPubKey)
 	}
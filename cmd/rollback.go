@@ -0,0 +1,174 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	cli "github.com/nats-io/cliprompts/v2"
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore a previous version of an operator, account or user JWT",
+}
+
+func init() {
+	GetRootCmd().AddCommand(rollbackCmd)
+	rollbackCmd.AddCommand(createRollbackCmd(jwt.OperatorClaim))
+	rollbackCmd.AddCommand(createRollbackCmd(jwt.AccountClaim))
+	rollbackCmd.AddCommand(createRollbackCmd(jwt.UserClaim))
+}
+
+func createRollbackCmd(kind string) *cobra.Command {
+	var params RollbackParams
+	params.kind = kind
+	cmd := &cobra.Command{
+		Use:          kind,
+		Short:        fmt.Sprintf("Restore the %s JWT recorded at a revision", kind),
+		Args:         MaxArgs(1),
+		SilenceUsage: true,
+		Example: fmt.Sprintf(`nsc history %s (to list the revisions)
+nsc rollback %s --revision <id>`, kind, kind),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	params.bindFlags(cmd)
+	cmd.Flags().StringVarP(&params.revision, "revision", "r", "", "revision to restore")
+	return cmd
+}
+
+type RollbackParams struct {
+	VersionedEntityParams
+	revision      string
+	token         string
+	claim         *jwt.GenericClaims
+	credsFilePath string
+}
+
+func (p *RollbackParams) SetDefaults(ctx ActionCtx) error {
+	return p.VersionedEntityParams.SetDefaults(ctx)
+}
+
+func (p *RollbackParams) PreInteractive(ctx ActionCtx) error {
+	var err error
+	if err = p.VersionedEntityParams.Edit(ctx); err != nil {
+		return err
+	}
+	p.revision, err = cli.Prompt("revision to restore", p.revision, cli.NewLengthValidator(1))
+	return err
+}
+
+func (p *RollbackParams) Load(ctx ActionCtx) error {
+	if err := p.VersionedEntityParams.Validate(ctx); err != nil {
+		return err
+	}
+	if p.revision == "" {
+		ctx.CurrentCmd().SilenceUsage = false
+		return errors.New("a revision is required")
+	}
+	d, err := ctx.StoreCtx().Store.ReadRevision(p.revision, p.Path(ctx)...)
+	if err != nil {
+		return fmt.Errorf("error reading %s %q at revision %q: %v", p.kind, p.EntityName(ctx), p.revision, err)
+	}
+	p.token = string(d)
+	p.claim, err = jwt.DecodeGeneric(p.token)
+	if err != nil {
+		return fmt.Errorf("error decoding %s %q at revision %q: %v", p.kind, p.EntityName(ctx), p.revision, err)
+	}
+	return nil
+}
+
+func (p *RollbackParams) PostInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *RollbackParams) Validate(ctx ActionCtx) error {
+	if string(p.claim.Type) != p.kind {
+		return fmt.Errorf("revision %q doesn't contain an %s JWT", p.revision, p.kind)
+	}
+	if p.claim.Name != p.EntityName(ctx) {
+		return fmt.Errorf("revision %q contains %s %q not %q", p.revision, p.kind, p.claim.Name, p.EntityName(ctx))
+	}
+	s := ctx.StoreCtx().Store
+	if s.Has(p.Path(ctx)...) {
+		d, err := s.Read(p.Path(ctx)...)
+		if err != nil {
+			return err
+		}
+		current, err := jwt.DecodeGeneric(string(d))
+		if err != nil {
+			return err
+		}
+		if current.Subject != p.claim.Subject {
+			return fmt.Errorf("revision %q is for a different %s key %q", p.revision, p.kind, p.claim.Subject)
+		}
+		if current.ID == p.claim.ID {
+			return fmt.Errorf("%s %q is already at revision %q", p.kind, p.EntityName(ctx), p.revision)
+		}
+	}
+	return nil
+}
+
+func (p *RollbackParams) Run(ctx ActionCtx) (store.Status, error) {
+	ctx.CurrentCmd().SilenceUsage = true
+	r := store.NewDetailedReport(true)
+	r.ReportSum = false
+	s := ctx.StoreCtx().Store
+
+	switch p.kind {
+	case jwt.AccountClaim:
+		StoreAccountAndUpdateStatus(ctx, p.token, r)
+	default:
+		if err := s.StoreRaw([]byte(p.token)); err != nil {
+			r.AddFromError(err)
+		}
+	}
+	if r.HasErrors() {
+		return r, nil
+	}
+	r.AddOK("restored %s %q from revision %q", p.kind, p.EntityName(ctx), p.revision)
+
+	if p.kind == jwt.UserClaim {
+		ks := ctx.StoreCtx().KeyStore
+		if ks.HasPrivateKey(p.claim.Subject) {
+			ukp, err := ks.GetKeyPair(p.claim.Subject)
+			if err != nil {
+				r.AddError("unable to read keypair: %v", err)
+				return r, nil
+			}
+			d, err := GenerateConfig(s, p.AccountContextParams.Name, p.name, ukp)
+			if err != nil {
+				r.AddError("unable to save creds: %v", err)
+			} else {
+				p.credsFilePath, err = ks.MaybeStoreUserCreds(p.AccountContextParams.Name, p.name, d)
+				if err != nil {
+					r.AddError("error storing creds: %v", err)
+				} else {
+					r.AddOK("generated user creds file %#q", AbbrevHomePaths(p.credsFilePath))
+				}
+			}
+		} else {
+			r.AddOK("skipped generating creds file - user private key is not available")
+		}
+	}
+	return r, nil
+}
@@ -78,6 +78,8 @@ func GetStoreForOperator(operator string) (*store.Store, error) {
 	if config.Account != "" {
 		ngsStore.DefaultAccount = config.Account
 	}
+	// the store redacts keys before recording the command line
+	ngsStore.Args = append([]string{GetToolName()}, os.Args[1:]...)
 	return ngsStore, nil
 }
 
@@ -121,19 +123,14 @@ var rootCmd = &cobra.Command{
 	Use:   "nsc",
 	Short: "nsc creates NATS operators, accounts, users, and manage their permissions.",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		if cmd.Name() == "migrate" && cmd.Parent().Name() == "keys" {
+		if isUpgradeCmd(cmd) {
 			return nil
 		}
 		// check if we need to perform any kind of migration
-		needsUpdate, err := store.KeysNeedMigration()
-		if err != nil {
-			return err
-		}
-		if needsUpdate {
+		if err := checkStoreVersions(); err != nil {
 			cmd.SilenceUsage = true
-			return fmt.Errorf("the keystore %#q needs migration - type `%s keys migrate` to update", AbbrevHomePaths(store.GetKeysDir()), os.Args[0])
+			return err
 		}
-
 		return nil
 	},
 }
@@ -143,6 +140,9 @@ var rootCmd = &cobra.Command{
 func Execute() {
 	err := ExecuteWithWriter(rootCmd.OutOrStderr())
 	if err != nil {
+		if StructuredOutput() {
+			writeErrorOutput(err)
+		}
 		os.Exit(1)
 	}
 }
@@ -167,8 +167,12 @@ func init() {
 // hostFlags adds persistent flags that would be added by the cobra framework
 // but are not because the unit tests are testing the command directly
 func HoistRootFlags(cmd *cobra.Command) *cobra.Command {
-	cmd.PersistentFlags().StringVarP(&KeyPathFlag, "private-key", "K", "", "private key")
+	cmd.PersistentFlags().StringVarP(&KeyPathFlag, "private-key", "K", "", "private key, path to a private key, 'exec:<signer program>' or 'agent:<public key>'")
 	cmd.PersistentFlags().BoolVarP(&InteractiveFlag, "interactive", "i", false, "ask questions for various settings")
+	cmd.PersistentFlags().BoolVarP(&DryRunFlag, "dry-run", "", false, "show the changes the command would make without making them")
+	cmd.PersistentFlags().BoolVarP(&PolicyOverrideFlag, "override-policy", "", false, fmt.Sprintf("store JWTs that violate the store policy (%s in the operator directory)", PolicyName))
+	cmd.PersistentFlags().VarP(&OutputFlag, "output", "", fmt.Sprintf("output format: %s, %s or %s", TextOutput, JsonOutput, YamlOutput))
+	cmd.PersistentFlags().DurationVarP(&store.LockTimeout, "lock-timeout", "", store.LockTimeout, fmt.Sprintf("time to wait for other nsc processes to release the operator or keystore (or set %s)", store.LockTimeoutEnv))
 	return cmd
 }
 
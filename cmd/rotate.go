@@ -0,0 +1,147 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Rotate keys and re-issue the JWTs signed with them",
+}
+
+func init() {
+	GetRootCmd().AddCommand(rotateCmd)
+}
+
+// rotationKey returns the key specified by the flag value, or a new key
+// of the kind if the value is empty
+func rotationKey(kind nkeys.PrefixByte, value string) (kp nkeys.KeyPair, generated bool, err error) {
+	if value == "" {
+		switch kind {
+		case nkeys.PrefixByteOperator:
+			kp, err = nkeys.CreateOperator()
+		case nkeys.PrefixByteAccount:
+			kp, err = nkeys.CreateAccount()
+		default:
+			err = fmt.Errorf("unsupported key kind %s", kind.String())
+		}
+		return kp, true, err
+	}
+	kp, err = store.ResolveKey(value)
+	if err != nil {
+		return nil, false, err
+	}
+	if kp == nil || !store.KeyPairTypeOk(kind, kp) {
+		return nil, false, fmt.Errorf("%q is not an %s key", value, kind.String())
+	}
+	return kp, false, nil
+}
+
+// storeRotationKey stores the new key in the keystore if its seed is available
+func storeRotationKey(ctx ActionCtx, kp nkeys.KeyPair, generated bool, r *store.Report) error {
+	if _, err := kp.Seed(); err != nil {
+		return nil
+	}
+	pk, err := kp.PublicKey()
+	if err != nil {
+		return err
+	}
+	if _, err := ctx.StoreCtx().KeyStore.Store(kp); err != nil {
+		return err
+	}
+	if generated {
+		r.AddOK("generated and stored signing key %q", pk)
+	} else {
+		r.AddOK("stored signing key %q", pk)
+	}
+	return nil
+}
+
+// canSign returns true if the key pair can sign, it has a seed or
+// the seed is held by an external signer
+func canSign(kp nkeys.KeyPair) bool {
+	if store.IsExternalKey(kp) {
+		return true
+	}
+	_, err := kp.Seed()
+	return err == nil
+}
+
+// storedKey returns the key pair for the public key from the keystore
+func storedKey(ctx ActionCtx, pk string) (nkeys.KeyPair, error) {
+	kp, err := ctx.StoreCtx().KeyStore.GetKeyPair(pk)
+	if err != nil {
+		return nil, err
+	}
+	if kp == nil {
+		return nil, fmt.Errorf("the private key for %q is not in the keystore", pk)
+	}
+	return kp, nil
+}
+
+// reissueUser signs the user JWT with the account key or one of its
+// signing keys. Permissions, limits and expiry are preserved. The creds
+// file is regenerated if the user key is in the keystore.
+func reissueUser(ctx ActionCtx, account string, ac *jwt.AccountClaims, uc *jwt.UserClaims, signer nkeys.KeyPair, r *store.Report) error {
+	s := ctx.StoreCtx().Store
+	ks := ctx.StoreCtx().KeyStore
+	pk, err := signer.PublicKey()
+	if err != nil {
+		return err
+	}
+	// signer doesn't match - so we set IssuerAccount to the account
+	uc.IssuerAccount = ""
+	if pk != ac.Subject {
+		uc.IssuerAccount = ac.Subject
+	}
+	token, err := uc.Encode(signer)
+	if err != nil {
+		return err
+	}
+	if err := s.StoreRaw([]byte(token)); err != nil {
+		return err
+	}
+	r.AddOK("re-issued user %q", uc.Name)
+
+	if ks.HasPrivateKey(uc.Subject) {
+		ukp, err := ks.GetKeyPair(uc.Subject)
+		if err != nil {
+			r.AddError("unable to read the key for user %q: %v", uc.Name, err)
+			return nil
+		}
+		d, err := GenerateConfig(s, account, uc.Name, ukp)
+		if err != nil {
+			r.AddError("unable to generate the creds file for user %q: %v", uc.Name, err)
+			return nil
+		}
+		fp, err := ks.MaybeStoreUserCreds(account, uc.Name, d)
+		if err != nil {
+			r.AddError("error storing the creds file for user %q: %v", uc.Name, err)
+			return nil
+		}
+		r.AddOK("generated user creds file %#q", AbbrevHomePaths(fp))
+	} else if fp := ks.GetUserCredsPath(account, uc.Name); fp != "" {
+		r.AddWarning("creds file %#q contains the previous jwt for user %q - the user private key is not available to regenerate it", AbbrevHomePaths(fp), uc.Name)
+	}
+	return nil
+}
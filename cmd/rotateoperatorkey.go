@@ -0,0 +1,293 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createRotateOperatorKeyCmd() *cobra.Command {
+	var params RotateOperatorKeyParams
+	cmd := &cobra.Command{
+		Use:   "operator-key",
+		Short: "Replace an operator signing key or the operator identity key",
+		Long: `Replace an operator signing key or the operator identity key
+
+The new key is added to the operator, every account signed with the old
+key is re-signed with the new one, and the old key is removed from the
+operator once all accounts were re-signed. When the identity key is
+replaced, the old identity key remains a signing key of the operator
+until then. Updated accounts are pushed to the account server of
+managed operators.
+
+The new key is generated and stored unless --new-key specifies it. A
+memory resolver configuration with the new operator is written to
+--config-file. Use --dry-run to preview the changes.`,
+		Example: `nsc rotate operator-key
+nsc rotate operator-key --sk <old signing key>
+nsc rotate operator-key --identity --config-file server.conf
+nsc rotate operator-key --identity --new-key <seed or path to seed>
+nsc rotate operator-key --dry-run`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().StringVarP(&params.oldKey, "sk", "", "", "public key of the operator signing key to replace")
+	cmd.Flags().BoolVarP(&params.identity, "identity", "", false, "replace the operator identity key")
+	cmd.Flags().StringVarP(&params.newKey, "new-key", "", "", "new operator key or path to it, generated if not specified")
+	cmd.Flags().StringVarP(&params.configFile, "config-file", "", "--", "memory resolver configuration file, '--' is standard output")
+	cmd.Flags().StringVarP(&params.sysAccount, "sys-account", "", "", "system account name for the configuration")
+	cmd.Flags().BoolVarP(&params.force, "force", "F", false, "overwrite the configuration file if it exists")
+	return cmd
+}
+
+func init() {
+	rotateCmd.AddCommand(createRotateOperatorKeyCmd())
+}
+
+type RotateOperatorKeyParams struct {
+	oldKey     string
+	identity   bool
+	newKey     string
+	configFile string
+	sysAccount string
+	force      bool
+	generated  bool
+	claim      *jwt.OperatorClaims
+	newKP      nkeys.KeyPair
+	operatorKP nkeys.KeyPair
+	accounts   []*jwt.AccountClaims
+}
+
+func (p *RotateOperatorKeyParams) SetDefaults(ctx ActionCtx) error {
+	if p.identity && p.oldKey != "" {
+		ctx.CurrentCmd().SilenceUsage = false
+		return errors.New("--identity and --sk are exclusive")
+	}
+	return nil
+}
+
+func (p *RotateOperatorKeyParams) PreInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *RotateOperatorKeyParams) Load(ctx ActionCtx) error {
+	var err error
+	s := ctx.StoreCtx().Store
+	p.claim, err = s.ReadOperatorClaim()
+	if err != nil {
+		return err
+	}
+	if p.identity {
+		p.oldKey = p.claim.Subject
+	}
+	if p.oldKey == "" {
+		switch len(p.claim.SigningKeys) {
+		case 0:
+			ctx.CurrentCmd().SilenceUsage = false
+			return fmt.Errorf("operator %q doesn't have signing keys - use --identity to replace the identity key", p.claim.Name)
+		case 1:
+			p.oldKey = p.claim.SigningKeys[0]
+		default:
+			ctx.CurrentCmd().SilenceUsage = false
+			return fmt.Errorf("operator %q has %d signing keys - specify the key to replace with --sk", p.claim.Name, len(p.claim.SigningKeys))
+		}
+	}
+
+	names, err := s.ListSubContainers(store.Accounts)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		ac, err := s.ReadAccountClaim(n)
+		if err != nil {
+			return err
+		}
+		if ac.Issuer == p.oldKey {
+			p.accounts = append(p.accounts, ac)
+		}
+	}
+	return nil
+}
+
+func (p *RotateOperatorKeyParams) PostInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *RotateOperatorKeyParams) Validate(ctx ActionCtx) error {
+	var err error
+	if !p.identity && !p.claim.SigningKeys.Contains(p.oldKey) {
+		return fmt.Errorf("%q is not a signing key of operator %q", p.oldKey, p.claim.Name)
+	}
+	p.newKP, p.generated, err = rotationKey(nkeys.PrefixByteOperator, p.newKey)
+	if err != nil {
+		return err
+	}
+	pk, err := p.newKP.PublicKey()
+	if err != nil {
+		return err
+	}
+	if pk == p.oldKey {
+		return errors.New("the new key is the key being replaced")
+	}
+	if pk == p.claim.Subject || (p.identity && p.claim.SigningKeys.Contains(pk)) {
+		return fmt.Errorf("%q is already a key of operator %q", pk, p.claim.Name)
+	}
+	if (p.identity || len(p.accounts) > 0) && !canSign(p.newKP) {
+		return fmt.Errorf("the private key for %q is required to sign the operator and accounts", pk)
+	}
+	if p.configFile != "--" && !p.force {
+		if _, err := os.Stat(p.configFile); err == nil {
+			return fmt.Errorf("%#q already exists - use --force to overwrite it", p.configFile)
+		}
+	}
+	if p.sysAccount != "" {
+		if _, err := ctx.StoreCtx().Store.ReadAccountClaim(p.sysAccount); err != nil {
+			return fmt.Errorf("error reading account %q: %v", p.sysAccount, err)
+		}
+	}
+
+	// the new identity key signs the operator, a signing key is added
+	// by the identity key
+	if p.identity {
+		p.operatorKP = p.newKP
+	} else {
+		p.operatorKP, err = ctx.StoreCtx().ResolveKey(nkeys.PrefixByteOperator, KeyPathFlag)
+		if err != nil {
+			return err
+		}
+		if p.operatorKP == nil || !store.Match(p.claim.Subject, p.operatorKP) {
+			return fmt.Errorf("the operator identity key %q is required to update the operator", p.claim.Subject)
+		}
+	}
+	var claims []jwt.Claims
+	for _, ac := range p.accounts {
+		claims = append(claims, ac)
+	}
+	return checkPolicy(ctx, claims...)
+}
+
+// storeOperator signs and stores the operator JWT
+func (p *RotateOperatorKeyParams) storeOperator(ctx ActionCtx) error {
+	token, err := p.claim.Encode(p.operatorKP)
+	if err != nil {
+		return err
+	}
+	return ctx.StoreCtx().Store.StoreRaw([]byte(token))
+}
+
+func (p *RotateOperatorKeyParams) Run(ctx ActionCtx) (store.Status, error) {
+	r := store.NewDetailedReport(true)
+	r.ReportSum = false
+	s := ctx.StoreCtx().Store
+	pk, err := p.newKP.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storeRotationKey(ctx, p.newKP, p.generated, r); err != nil {
+		return nil, err
+	}
+
+	// the old key stays valid until the accounts are re-signed
+	if p.identity {
+		p.claim.Subject = pk
+		p.claim.SigningKeys.Add(p.oldKey)
+	} else {
+		p.claim.SigningKeys.Add(pk)
+	}
+	if err := p.storeOperator(ctx); err != nil {
+		r.AddError("error updating operator %q: %v", p.claim.Name, err)
+		return r, nil
+	}
+	if p.identity {
+		r.AddOK("replaced the identity key of operator %q with %q", p.claim.Name, pk)
+	} else {
+		r.AddOK("added signing key %q to operator %q", pk, p.claim.Name)
+	}
+
+	for _, ac := range p.accounts {
+		ar := r.AddOK("account %q", ac.Name)
+		ar.AddOK("issuer %q moved to %q", p.oldKey, pk)
+		token, err := ac.Encode(p.newKP)
+		if err != nil {
+			ar.AddError("error signing account %q: %v", ac.Name, err)
+			continue
+		}
+		StoreAccountAndUpdateStatus(ctx, token, ar)
+		if ar.HasNoErrors() {
+			ar.AddOK("re-signed account %q", ac.Name)
+		}
+	}
+	if len(p.accounts) == 0 {
+		r.AddOK("no accounts were signed with %q", p.oldKey)
+	}
+
+	if r.HasErrors() {
+		r.AddWarning("kept key %q in operator %q - not all accounts were re-signed", p.oldKey, p.claim.Name)
+		return r, nil
+	}
+	p.claim.SigningKeys.Remove(p.oldKey)
+	if err := p.storeOperator(ctx); err != nil {
+		r.AddError("error removing key %q from operator %q: %v", p.oldKey, p.claim.Name, err)
+		return r, nil
+	}
+	r.AddOK("removed key %q from operator %q", p.oldKey, p.claim.Name)
+
+	if s.IsManaged() {
+		r.AddWarning("the account server for operator %q must be updated with the new operator jwt", p.claim.Name)
+		return r, nil
+	}
+	p.writeConfig(ctx, r)
+	return r, nil
+}
+
+// writeConfig writes a memory resolver configuration with the rotated keys
+func (p *RotateOperatorKeyParams) writeConfig(ctx ActionCtx, r *store.Report) {
+	g := NewMemResolverConfigBuilder()
+	if p.sysAccount != "" {
+		ac, err := ctx.StoreCtx().Store.ReadAccountClaim(p.sysAccount)
+		if err != nil {
+			r.AddError("error reading account %q: %v", p.sysAccount, err)
+			return
+		}
+		if err := g.SetSystemAccount(ac.Subject); err != nil {
+			r.AddFromError(err)
+			return
+		}
+	}
+	d, err := generateServerConfig(ctx.StoreCtx().Store, g)
+	if err != nil {
+		r.AddError("error generating the server configuration: %v", err)
+		return
+	}
+	if err := Write(p.configFile, d); err != nil {
+		r.AddError("error writing the server configuration: %v", err)
+		return
+	}
+	if !IsStdOut(p.configFile) {
+		r.AddOK("wrote server configuration to %#q", AbbrevHomePaths(p.configFile))
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RotateOperatorSigningKey(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	_, spk, skp := CreateOperatorKey(t)
+	_, err := ts.KeyStore.Store(skp)
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createEditOperatorCmd(), "--sk", spk)
+	require.NoError(t, err)
+	ts.AddAccountWithSigner(t, "A", skp)
+	ts.AddAccount(t, "B")
+	b, err := ts.Store.ReadAccountClaim("B")
+	require.NoError(t, err)
+
+	conf := filepath.Join(ts.Dir, "server.conf")
+	_, stderr, err := ExecuteCmd(createRotateOperatorKeyCmd(), "--config-file", conf)
+	require.NoError(t, err)
+	require.Contains(t, stderr, `account "A"`)
+	require.NotContains(t, stderr, `account "B"`)
+
+	oc, err := ts.Store.ReadOperatorClaim()
+	require.NoError(t, err)
+	require.Len(t, oc.SigningKeys, 1)
+	npk := oc.SigningKeys[0]
+	require.NotEqual(t, spk, npk)
+	require.True(t, ts.KeyStore.HasPrivateKey(npk))
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, npk, ac.Issuer)
+	ac, err = ts.Store.ReadAccountClaim("B")
+	require.NoError(t, err)
+	require.Equal(t, b.ID, ac.ID)
+
+	d, err := ioutil.ReadFile(conf)
+	require.NoError(t, err)
+	require.Contains(t, string(d), "resolver: MEMORY")
+
+	_, _, err = ExecuteCmd(createValidateCommand())
+	require.NoError(t, err)
+}
+
+func Test_RotateOperatorIdentity(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	ts.AddUser(t, "A", "U")
+	opk := ts.GetOperatorPublicKey(t)
+
+	stdout, _, err := ExecuteCmd(createRotateOperatorKeyCmd(), "--identity")
+	require.NoError(t, err)
+
+	oc, err := ts.Store.ReadOperatorClaim()
+	require.NoError(t, err)
+	require.NotEqual(t, opk, oc.Subject)
+	require.Equal(t, oc.Subject, oc.Issuer)
+	require.Empty(t, oc.SigningKeys)
+	require.True(t, ts.KeyStore.HasPrivateKey(oc.Subject))
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, oc.Subject, ac.Issuer)
+
+	// the configuration trusts the new operator
+	token, err := ts.Store.Read(store.JwtName("O"))
+	require.NoError(t, err)
+	require.Contains(t, stdout, string(token))
+
+	_, _, err = ExecuteCmd(createValidateCommand())
+	require.NoError(t, err)
+}
+
+func Test_RotateOperatorKeyDryRun(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	before, err := ts.Store.ReadOperatorClaim()
+	require.NoError(t, err)
+
+	stdout, stderr, err := ExecuteCmd(HoistRootFlags(createRotateOperatorKeyCmd()), "--identity", "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stdout, "resolver: MEMORY")
+	require.Contains(t, stderr, "[dry-run] the following changes were not made")
+	require.Contains(t, stderr, `~ iss: "`+before.Subject+`" -> "`)
+	require.Contains(t, stderr, `~ sub: "`+before.Subject+`" -> "`)
+
+	oc, err := ts.Store.ReadOperatorClaim()
+	require.NoError(t, err)
+	require.Equal(t, before.ID, oc.ID)
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, before.Subject, ac.Issuer)
+}
+
+func Test_RotateOperatorKeyRequiresKey(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	_, _, err := ExecuteCmd(createRotateOperatorKeyCmd())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "use --identity")
+
+	_, _, err = ExecuteCmd(createRotateOperatorKeyCmd(), "--identity", "--sk", ts.GetOperatorPublicKey(t))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exclusive")
+
+	_, upk, _ := CreateUserKey(t)
+	_, _, err = ExecuteCmd(createRotateOperatorKeyCmd(), "--identity", "--new-key", upk)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not an operator key")
+}
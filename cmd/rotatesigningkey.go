@@ -0,0 +1,230 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	cli "github.com/nats-io/cliprompts/v2"
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createRotateSigningKeyCmd() *cobra.Command {
+	var params RotateSigningKeyParams
+	cmd := &cobra.Command{
+		Use:   "signing-key",
+		Short: "Replace an account signing key and re-issue its users",
+		Long: `Replace an account signing key and re-issue its users
+
+A new signing key is added to the account, every user signed with the
+old signing key is re-issued with the new one, and the old signing key
+is removed from the account. Permissions, limits and expiry of the users
+are preserved. Creds files are regenerated for users with a private key
+in the keystore.
+
+The new signing key is generated and stored unless --new-sk specifies
+it. Use --dry-run to preview the changes.`,
+		Example: `nsc rotate signing-key --account A
+nsc rotate signing-key --account A --sk <old signing key>
+nsc rotate signing-key --account A --new-sk <seed or path to seed>
+nsc rotate signing-key --account A --dry-run`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().StringVarP(&params.oldKey, "sk", "", "", "public key of the signing key to replace")
+	cmd.Flags().StringVarP(&params.newKey, "new-sk", "", "", "new signing key or path to it, generated if not specified")
+	params.AccountContextParams.BindFlags(cmd)
+	return cmd
+}
+
+func init() {
+	rotateCmd.AddCommand(createRotateSigningKeyCmd())
+}
+
+type RotateSigningKeyParams struct {
+	AccountContextParams
+	SignerParams
+	oldKey    string
+	newKey    string
+	generated bool
+	newKP     nkeys.KeyPair
+	claim     *jwt.AccountClaims
+	users     []*jwt.UserClaims
+}
+
+func (p *RotateSigningKeyParams) SetDefaults(ctx ActionCtx) error {
+	if err := p.AccountContextParams.SetDefaults(ctx); err != nil {
+		return err
+	}
+	p.SignerParams.SetDefaults(nkeys.PrefixByteOperator, true, ctx)
+	return nil
+}
+
+func (p *RotateSigningKeyParams) PreInteractive(ctx ActionCtx) error {
+	if err := p.AccountContextParams.Edit(ctx); err != nil {
+		return err
+	}
+	if p.oldKey != "" {
+		return nil
+	}
+	ac, err := ctx.StoreCtx().Store.ReadAccountClaim(p.AccountContextParams.Name)
+	if err != nil {
+		return err
+	}
+	if len(ac.SigningKeys) > 1 {
+		i, err := cli.Select("select the signing key to replace", "", ac.SigningKeys)
+		if err != nil {
+			return err
+		}
+		p.oldKey = ac.SigningKeys[i]
+	}
+	return nil
+}
+
+func (p *RotateSigningKeyParams) Load(ctx ActionCtx) error {
+	var err error
+	if err = p.AccountContextParams.Validate(ctx); err != nil {
+		return err
+	}
+	account := p.AccountContextParams.Name
+	s := ctx.StoreCtx().Store
+	p.claim, err = s.ReadAccountClaim(account)
+	if err != nil {
+		return err
+	}
+	if p.oldKey == "" {
+		switch len(p.claim.SigningKeys) {
+		case 0:
+			return fmt.Errorf("account %q doesn't have signing keys", account)
+		case 1:
+			p.oldKey = p.claim.SigningKeys[0]
+		default:
+			ctx.CurrentCmd().SilenceUsage = false
+			return fmt.Errorf("account %q has %d signing keys - specify the key to replace with --sk", account, len(p.claim.SigningKeys))
+		}
+	}
+
+	users, err := s.ListEntries(store.Accounts, account, store.Users)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		uc, err := s.ReadUserClaim(account, u)
+		if err != nil {
+			return err
+		}
+		if uc.Issuer == p.oldKey {
+			p.users = append(p.users, uc)
+		}
+	}
+	return nil
+}
+
+func (p *RotateSigningKeyParams) PostInteractive(ctx ActionCtx) error {
+	return p.SignerParams.Edit(ctx)
+}
+
+func (p *RotateSigningKeyParams) Validate(ctx ActionCtx) error {
+	var err error
+	if !p.claim.SigningKeys.Contains(p.oldKey) {
+		return fmt.Errorf("%q is not a signing key of account %q", p.oldKey, p.AccountContextParams.Name)
+	}
+	p.newKP, p.generated, err = rotationKey(nkeys.PrefixByteAccount, p.newKey)
+	if err != nil {
+		return err
+	}
+	pk, err := p.newKP.PublicKey()
+	if err != nil {
+		return err
+	}
+	if pk == p.oldKey {
+		return errors.New("the new signing key is the key being replaced")
+	}
+	if pk == p.claim.Subject {
+		return errors.New("the new signing key is the account identity key")
+	}
+	if len(p.users) > 0 && !canSign(p.newKP) {
+		return fmt.Errorf("the private key for %q is required to re-issue %d users", pk, len(p.users))
+	}
+	if err := p.SignerParams.Resolve(ctx); err != nil {
+		return err
+	}
+	claims := []jwt.Claims{p.claim}
+	for _, uc := range p.users {
+		claims = append(claims, uc)
+	}
+	return checkPolicy(ctx, claims...)
+}
+
+func (p *RotateSigningKeyParams) Run(ctx ActionCtx) (store.Status, error) {
+	r := store.NewDetailedReport(true)
+	r.ReportSum = false
+	account := p.AccountContextParams.Name
+	pk, err := p.newKP.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storeRotationKey(ctx, p.newKP, p.generated, r); err != nil {
+		return nil, err
+	}
+
+	// the new key is added first so the re-issued users are valid
+	if !p.claim.SigningKeys.Contains(pk) {
+		p.claim.SigningKeys.Add(pk)
+		token, err := p.claim.Encode(p.signerKP)
+		if err != nil {
+			return nil, err
+		}
+		StoreAccountAndUpdateStatus(ctx, token, r)
+		if r.HasErrors() {
+			return r, nil
+		}
+		r.AddOK("added signing key %q to account %q", pk, account)
+	}
+
+	for _, uc := range p.users {
+		if err := reissueUser(ctx, account, p.claim, uc, p.newKP, r); err != nil {
+			r.AddError("error re-issuing user %q: %v", uc.Name, err)
+		}
+	}
+	if len(p.users) == 0 {
+		r.AddOK("no users were signed with %q", p.oldKey)
+	}
+
+	// removing the old key invalidates users that were not re-issued
+	if r.HasErrors() {
+		r.AddWarning("kept signing key %q in account %q - not all users were re-issued", p.oldKey, account)
+		return r, nil
+	}
+	p.claim.SigningKeys.Remove(p.oldKey)
+	token, err := p.claim.Encode(p.signerKP)
+	if err != nil {
+		return nil, err
+	}
+	StoreAccountAndUpdateStatus(ctx, token, r)
+	if r.HasNoErrors() {
+		r.AddOK("removed signing key %q from account %q", p.oldKey, account)
+	}
+	return r, nil
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+// addSignedUser adds a user to the account signed with the seed
+func addSignedUser(t *testing.T, account string, name string, seed []byte, args ...string) {
+	args = append([]string{"--name", name, "--account", account, "-K", string(seed)}, args...)
+	_, _, err := ExecuteCmd(HoistRootFlags(CreateAddUserCmd()), args...)
+	require.NoError(t, err)
+}
+
+func Test_RotateSigningKey(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	sk, spk, _ := CreateAccountKey(t)
+	_, _, err := ExecuteCmd(createEditAccount(), "--sk", spk)
+	require.NoError(t, err)
+
+	addSignedUser(t, "A", "U", sk, "--allow-pub", "foo", "--expiry", "30d")
+	addSignedUser(t, "A", "V", sk)
+	ts.AddUser(t, "A", "W")
+	before, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	w, err := ts.Store.ReadUserClaim("A", "W")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createRotateSigningKeyCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, `re-issued user "U"`)
+	require.Contains(t, stderr, `re-issued user "V"`)
+	require.NotContains(t, stderr, `re-issued user "W"`)
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Len(t, ac.SigningKeys, 1)
+	npk := ac.SigningKeys[0]
+	require.NotEqual(t, spk, npk)
+	require.True(t, ts.KeyStore.HasPrivateKey(npk))
+
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, npk, uc.Issuer)
+	require.Equal(t, ac.Subject, uc.IssuerAccount)
+	require.Equal(t, before.Pub, uc.Pub)
+	require.Equal(t, before.Expires, uc.Expires)
+
+	// the creds file has the new jwt
+	d, err := ioutil.ReadFile(ts.KeyStore.GetUserCredsPath("A", "U"))
+	require.NoError(t, err)
+	token, err := jwt.ParseDecoratedJWT(d)
+	require.NoError(t, err)
+	cc, err := jwt.DecodeUserClaims(token)
+	require.NoError(t, err)
+	require.Equal(t, npk, cc.Issuer)
+
+	// users signed by the account are untouched
+	uc, err = ts.Store.ReadUserClaim("A", "W")
+	require.NoError(t, err)
+	require.Equal(t, w.ID, uc.ID)
+}
+
+func Test_RotateSigningKeyDryRun(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	sk, spk, _ := CreateAccountKey(t)
+	_, _, err := ExecuteCmd(createEditAccount(), "--sk", spk)
+	require.NoError(t, err)
+	addSignedUser(t, "A", "U", sk)
+	before, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+
+	keys, err := ts.KeyStore.AllKeys()
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(createRotateSigningKeyCmd()), "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "[dry-run] the following changes were not made")
+	require.Contains(t, stderr, filepath.Join("A", "users", "U.jwt"))
+	require.Contains(t, stderr, `~ iss: "`+spk+`" -> "`)
+	require.Contains(t, stderr, `~ nats.signing_keys: ["`+spk+`"] -> ["`)
+
+	after, err := ts.KeyStore.AllKeys()
+	require.NoError(t, err)
+	require.Equal(t, keys, after)
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, jwt.StringList{spk}, ac.SigningKeys)
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, before.ID, uc.ID)
+}
+
+func Test_RotateSigningKeyNewKey(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+	sk, spk, _ := CreateAccountKey(t)
+	nsk, npk, _ := CreateAccountKey(t)
+	_, _, err := ExecuteCmd(createEditAccount(), "--sk", spk)
+	require.NoError(t, err)
+	addSignedUser(t, "A", "U", sk)
+
+	// a public key cannot re-issue the users
+	_, _, err = ExecuteCmd(createRotateSigningKeyCmd(), "--sk", spk, "--new-sk", npk)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "private key")
+
+	_, _, err = ExecuteCmd(createRotateSigningKeyCmd(), "--sk", spk, "--new-sk", string(nsk))
+	require.NoError(t, err)
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, jwt.StringList{npk}, ac.SigningKeys)
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	require.Equal(t, npk, uc.Issuer)
+}
+
+func Test_RotateSigningKeyRequiresKey(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	_, _, err := ExecuteCmd(createRotateSigningKeyCmd())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "doesn't have signing keys")
+
+	_, spk, _ := CreateAccountKey(t)
+	_, tpk, _ := CreateAccountKey(t)
+	_, _, err = ExecuteCmd(createEditAccount(), "--sk", spk, "--sk", tpk)
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createRotateSigningKeyCmd())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "specify the key to replace with --sk")
+}
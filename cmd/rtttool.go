@@ -103,6 +103,10 @@ func (p *RttParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *RttParams) ReadOnly() bool {
+	return true
+}
+
 func (p *RttParams) Run(ctx ActionCtx) (store.Status, error) {
 	opts := createDefaultToolOptions("nsc_rtt", ctx)
 	opts = append(opts, nats.UserCredentials(p.credsPath))
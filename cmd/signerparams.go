@@ -55,9 +55,11 @@ func (p *SignerParams) SelectFromSigners(ctx ActionCtx, signers []string) error
 	var choices []string
 
 	for _, s := range signers {
-		fp := ctx.StoreCtx().KeyStore.GetKeyPath(s)
+		ks := ctx.StoreCtx().KeyStore
+		fp := ks.GetKeyPath(s)
+		// keys held by the key agent may not have a file
 		_, err := os.Stat(fp)
-		if err == nil {
+		if err == nil || ks.HasPrivateKey(s) {
 			keys = append(keys, fp)
 			choices = append(choices, s)
 		} else {
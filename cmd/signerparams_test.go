@@ -16,7 +16,12 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -204,3 +209,90 @@ func Test_SignerParamsHomePath(t *testing.T) {
 	_, _, err = ExecuteCmd(createSignerCmd(nkeys.PrefixByteOperator, false, ts.OperatorKey), "-K", tfn)
 	require.NoError(t, err)
 }
+
+const stubSignerEnv = "NSC_TEST_STUB_SIGNER"
+
+// TestStubSignerProcess is run as the signer program for 'exec:' references
+func TestStubSignerProcess(t *testing.T) {
+	if os.Getenv(stubSignerEnv) == "" {
+		return
+	}
+	args := os.Args
+	for i, a := range args {
+		if a == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	if err := stubSign(args, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// stubSign signs like tools/stub-signer with the seed in the file
+// named by the first argument
+func stubSign(args []string, in io.Reader, out io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("usage: <seed file> public-key|sign")
+	}
+	d, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	kp, err := nkeys.FromSeed(bytes.TrimSpace(d))
+	if err != nil {
+		return fmt.Errorf("error reading seed %#q: %v", args[0], err)
+	}
+	switch args[1] {
+	case "public-key":
+		pk, err := kp.PublicKey()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, pk)
+		return err
+	case "sign":
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		sig, err := kp.Sign(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, base64.StdEncoding.EncodeToString(sig))
+		return err
+	}
+	return fmt.Errorf("unknown operation %q", args[1])
+}
+
+func Test_SignerParamsExec(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	// the operator key is only available to the signer
+	dest := filepath.Join(ts.Dir, filepath.Base(ts.OperatorKeyPath))
+	require.NoError(t, os.Rename(ts.OperatorKeyPath, dest))
+	require.NoError(t, os.Setenv(stubSignerEnv, "true"))
+	defer os.Unsetenv(stubSignerEnv)
+	ref := fmt.Sprintf("exec:%s -test.run=^TestStubSignerProcess$ -- %s", os.Args[0], dest)
+
+	_, _, err := ExecuteCmd(createSignerCmd(nkeys.PrefixByteOperator, false, ts.OperatorKey), "-K", ref)
+	require.NoError(t, err)
+
+	_, _, err = ExecuteCmd(HoistRootFlags(createEditAccount()), "--tag", "x", "-K", ref)
+	require.NoError(t, err)
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Contains(t, ac.Tags, "x")
+	opk, err := ts.OperatorKey.PublicKey()
+	require.NoError(t, err)
+	require.Equal(t, opk, ac.Issuer)
+
+	// the signer must hold the expected key
+	_, _, err = ExecuteCmd(createSignerCmd(nkeys.PrefixByteAccount, false, nil), "-K", ref)
+	require.Error(t, err)
+}
@@ -0,0 +1,436 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nkeys"
+)
+
+// NscAgentSockEnv names the environment variable with the path to the
+// socket of the key agent
+const NscAgentSockEnv = "NSC_AGENT_SOCK"
+
+// AgentSocketName is the name of the agent socket in the keystore directory
+const AgentSocketName = "agent.sock"
+
+// ErrAgentKey is returned when the seed of a key held by the agent is requested
+var ErrAgentKey = errors.New("the seed is held by the key agent")
+
+// AgentSocketPath returns the path of the key agent socket
+func AgentSocketPath() string {
+	if v := os.Getenv(NscAgentSockEnv); v != "" {
+		return v
+	}
+	return filepath.Join(GetKeysDir(), AgentSocketName)
+}
+
+type agentRequest struct {
+	Op   string `json:"op"`
+	Key  string `json:"key,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+type agentResponse struct {
+	Keys      []string `json:"keys,omitempty"`
+	Signature []byte   `json:"signature,omitempty"`
+	Sealed    []byte   `json:"sealed,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Agent holds unlocked keys in memory and signs with them on request.
+// Requests are JSON lines on a Unix socket. Once no request is made
+// for the timeout, the keys are wiped and the agent stops.
+type Agent struct {
+	sync.Mutex
+	keys       map[string]nkeys.KeyPair
+	passphrase string
+	timeout    time.Duration
+	listener   net.Listener
+	path       string
+	timer      *time.Timer
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewAgent creates an agent for the keys. If the keystore is encrypted,
+// the passphrase allows the agent to encrypt new keys for its clients.
+func NewAgent(keys []nkeys.KeyPair, passphrase string, timeout time.Duration) (*Agent, error) {
+	a := &Agent{keys: make(map[string]nkeys.KeyPair), passphrase: passphrase, timeout: timeout, done: make(chan struct{})}
+	for _, kp := range keys {
+		pk, err := kp.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		a.keys[pk] = kp
+	}
+	return a, nil
+}
+
+// Keys returns the public keys held by the agent
+func (a *Agent) Keys() []string {
+	a.Lock()
+	defer a.Unlock()
+	var keys []string
+	for k := range a.keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Listen creates the socket at path. The socket is only accessible
+// by the current user.
+func (a *Agent) Listen(path string) error {
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return fmt.Errorf("a key agent is already listening on %#q", path)
+	}
+	// a stale socket from an agent that didn't stop cleanly
+	_ = os.Remove(path)
+	if err := MaybeMakeDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	// the socket is created in a directory only the current user can
+	// access, and moved into place once it is restricted to the user
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".agent")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, AgentSocketName)
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return err
+	}
+	// the socket is removed by Close at its final path
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		l.Close()
+		return err
+	}
+	a.listener = l
+	a.path = path
+	if a.timeout > 0 {
+		a.timer = time.AfterFunc(a.timeout, a.Close)
+	}
+	return nil
+}
+
+// Serve handles requests until the agent is closed or times out
+func (a *Agent) Serve() error {
+	for {
+		c, err := a.listener.Accept()
+		if err != nil {
+			select {
+			case <-a.done:
+				return nil
+			default:
+				return err
+			}
+		}
+		go a.handle(c)
+	}
+}
+
+// Done is closed when the agent stops
+func (a *Agent) Done() <-chan struct{} {
+	return a.done
+}
+
+// Close wipes the keys and removes the socket
+func (a *Agent) Close() {
+	a.closeOnce.Do(func() {
+		a.Lock()
+		for _, kp := range a.keys {
+			kp.Wipe()
+		}
+		a.keys = make(map[string]nkeys.KeyPair)
+		a.passphrase = ""
+		if a.timer != nil {
+			a.timer.Stop()
+		}
+		a.Unlock()
+		close(a.done)
+		if a.listener != nil {
+			a.listener.Close()
+			_ = os.Remove(a.path)
+		}
+	})
+}
+
+func (a *Agent) handle(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	enc := json.NewEncoder(c)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req agentRequest
+		var resp agentResponse
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %v", err)
+		} else {
+			resp = a.process(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (a *Agent) process(req agentRequest) agentResponse {
+	a.Lock()
+	defer a.Unlock()
+	if a.timer != nil {
+		a.timer.Reset(a.timeout)
+	}
+	var resp agentResponse
+	switch req.Op {
+	case "list":
+		for k := range a.keys {
+			resp.Keys = append(resp.Keys, k)
+		}
+		sort.Strings(resp.Keys)
+	case "sign":
+		kp, ok := a.keys[req.Key]
+		if !ok {
+			resp.Error = fmt.Sprintf("key %q is not held by the agent", req.Key)
+			break
+		}
+		sig, err := kp.Sign(req.Data)
+		if err != nil {
+			resp.Error = err.Error()
+			break
+		}
+		resp.Signature = sig
+	case "seal":
+		if a.passphrase == "" {
+			resp.Error = "the agent cannot encrypt keys"
+			break
+		}
+		e, err := readEncryption()
+		if err == nil {
+			var sk *SealedKey
+			sk, err = seal(a.passphrase, e.Check.KDF, req.Key, req.Data)
+			if err == nil {
+				resp.Sealed, err = json.MarshalIndent(sk, "", " ")
+			}
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+	default:
+		resp.Error = fmt.Sprintf("unknown operation %q", req.Op)
+	}
+	return resp
+}
+
+// AgentClient makes requests to a key agent
+type AgentClient struct {
+	sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// DialAgent connects to the agent listening on the path
+func DialAgent(path string) (*AgentClient, error) {
+	c, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &AgentClient{conn: c, r: bufio.NewReader(c)}, nil
+}
+
+func (c *AgentClient) request(req agentRequest) (*agentResponse, error) {
+	c.Lock()
+	defer c.Unlock()
+	d, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(append(d, '\n')); err != nil {
+		return nil, fmt.Errorf("error contacting the key agent: %v", err)
+	}
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("error reading from the key agent: %v", err)
+	}
+	var resp agentResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("key agent: %s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// List returns the public keys held by the agent
+func (c *AgentClient) List() ([]string, error) {
+	resp, err := c.request(agentRequest{Op: "list"})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// Sign signs the data with the key held by the agent
+func (c *AgentClient) Sign(pub string, data []byte) ([]byte, error) {
+	resp, err := c.request(agentRequest{Op: "sign", Key: pub, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// Seal encrypts a seed with the passphrase of the keystore held by the agent
+func (c *AgentClient) Seal(pub string, seed []byte) ([]byte, error) {
+	resp, err := c.request(agentRequest{Op: "seal", Key: pub, Data: seed})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Sealed, nil
+}
+
+// Close disconnects from the agent
+func (c *AgentClient) Close() error {
+	return c.conn.Close()
+}
+
+// AgentKeyPair is a KeyPair that signs with a key held by the agent.
+// The seed and private key are not available.
+type AgentKeyPair struct {
+	client *AgentClient
+	pub    string
+}
+
+func (kp *AgentKeyPair) Seed() ([]byte, error) {
+	return nil, ErrAgentKey
+}
+
+func (kp *AgentKeyPair) PublicKey() (string, error) {
+	return kp.pub, nil
+}
+
+func (kp *AgentKeyPair) PrivateKey() ([]byte, error) {
+	return nil, ErrAgentKey
+}
+
+func (kp *AgentKeyPair) Sign(input []byte) ([]byte, error) {
+	return kp.client.Sign(kp.pub, input)
+}
+
+func (kp *AgentKeyPair) Verify(input []byte, sig []byte) error {
+	pk, err := nkeys.FromPublicKey(kp.pub)
+	if err != nil {
+		return err
+	}
+	return pk.Verify(input, sig)
+}
+
+func (kp *AgentKeyPair) Wipe() {}
+
+// IsAgentKey returns true if the key pair signs with the key agent
+func IsAgentKey(kp nkeys.KeyPair) bool {
+	_, ok := kp.(*AgentKeyPair)
+	return ok
+}
+
+// the connection to the agent is shared by the process
+var agent = struct {
+	sync.Mutex
+	path   string
+	client *AgentClient
+	keys   map[string]bool
+}{}
+
+// ResetAgent drops the connection to the key agent
+func ResetAgent() {
+	agent.Lock()
+	defer agent.Unlock()
+	if agent.client != nil {
+		agent.client.Close()
+	}
+	agent.client = nil
+	agent.keys = nil
+	agent.path = ""
+}
+
+// agentClient returns a connection to the running agent, nil if there's none
+func agentClient() *AgentClient {
+	path := AgentSocketPath()
+	agent.Lock()
+	defer agent.Unlock()
+	if agent.client != nil && agent.path == path {
+		return agent.client
+	}
+	if agent.client != nil {
+		agent.client.Close()
+		agent.client = nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	c, err := DialAgent(path)
+	if err != nil {
+		return nil
+	}
+	keys, err := c.List()
+	if err != nil {
+		c.Close()
+		return nil
+	}
+	agent.path = path
+	agent.client = c
+	agent.keys = make(map[string]bool)
+	for _, k := range keys {
+		agent.keys[k] = true
+	}
+	return c
+}
+
+// agentKeyPair returns a key pair signing with the agent if the agent holds the key
+func agentKeyPair(pub string) nkeys.KeyPair {
+	c := agentClient()
+	if c == nil {
+		return nil
+	}
+	agent.Lock()
+	ok := agent.keys[pub]
+	agent.Unlock()
+	if !ok {
+		return nil
+	}
+	return &AgentKeyPair{client: c, pub: pub}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nkeys"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestAgent serves the keys on a socket in a new directory
+func startTestAgent(t *testing.T, passphrase string, timeout time.Duration, keys ...nkeys.KeyPair) (*Agent, string) {
+	fp := filepath.Join(MakeTempDir(t), AgentSocketName)
+	a, err := NewAgent(keys, passphrase, timeout)
+	require.NoError(t, err)
+	require.NoError(t, a.Listen(fp))
+	go a.Serve()
+	return a, fp
+}
+
+func TestAgent_Sign(t *testing.T) {
+	_, apk, akp := CreateAccountKey(t)
+	a, fp := startTestAgent(t, "", time.Minute, akp)
+	defer a.Close()
+	require.Equal(t, []string{apk}, a.Keys())
+
+	fi, err := os.Stat(fp)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	// the directory the socket was created in is removed
+	infos, err := ioutil.ReadDir(filepath.Dir(fp))
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, AgentSocketName, infos[0].Name())
+
+	c, err := DialAgent(fp)
+	require.NoError(t, err)
+	defer c.Close()
+	keys, err := c.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{apk}, keys)
+
+	sig, err := c.Sign(apk, []byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, akp.Verify([]byte("hello"), sig))
+
+	_, upk, _ := CreateUserKey(t)
+	_, err = c.Sign(upk, []byte("hello"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not held by the agent")
+
+	_, err = c.Seal(apk, []byte("seed"))
+	require.Error(t, err)
+}
+
+func TestAgent_KeyPair(t *testing.T) {
+	defer setupEncryptedKeyStore(t)()
+	ks := NewKeyStore("O")
+	_, apk, akp := CreateAccountKey(t)
+	fp, err := ks.Store(akp)
+	require.NoError(t, err)
+	_, err = ks.EncryptKeyStore("a passphrase")
+	require.NoError(t, err)
+	ClearPassphrase()
+
+	a, sock := startTestAgent(t, "a passphrase", time.Minute, akp)
+	defer a.Close()
+	require.NoError(t, os.Setenv(NscAgentSockEnv, sock))
+	defer os.Unsetenv(NscAgentSockEnv)
+	defer ResetAgent()
+
+	// the encrypted key resolves to the agent
+	kp, err := ks.GetKeyPair(apk)
+	require.NoError(t, err)
+	require.True(t, IsAgentKey(kp))
+	kp, err = ResolveKey(fp)
+	require.NoError(t, err)
+	require.True(t, IsAgentKey(kp))
+	require.True(t, ks.HasPrivateKey(apk))
+
+	sig, err := kp.Sign([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, kp.Verify([]byte("hello"), sig))
+	_, err = kp.Seed()
+	require.Equal(t, ErrAgentKey, err)
+
+	// the seed is not available without the passphrase
+	_, err = ks.GetSeed(apk)
+	require.Equal(t, ErrKeyStoreLocked, err)
+
+	// new keys are encrypted by the agent
+	_, upk, ukp := CreateUserKey(t)
+	ufp, err := ks.Store(ukp)
+	require.NoError(t, err)
+	d, err := ioutil.ReadFile(ufp)
+	require.NoError(t, err)
+	require.True(t, IsSealed(d))
+	SetPassphrase("a passphrase")
+	seed, err := ks.GetSeed(upk)
+	require.NoError(t, err)
+	useed, err := ukp.Seed()
+	require.NoError(t, err)
+	require.Equal(t, string(useed), seed)
+}
+
+func TestAgent_Timeout(t *testing.T) {
+	_, _, akp := CreateAccountKey(t)
+	a, fp := startTestAgent(t, "", 100*time.Millisecond, akp)
+	select {
+	case <-a.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent didn't time out")
+	}
+	require.Empty(t, a.Keys())
+	_, err := os.Stat(fp)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestAgent_AlreadyRunning(t *testing.T) {
+	_, _, akp := CreateAccountKey(t)
+	a, fp := startTestAgent(t, "", time.Minute, akp)
+	defer a.Close()
+
+	b, err := NewAgent([]nkeys.KeyPair{akp}, "", time.Minute)
+	require.NoError(t, err)
+	err = b.Listen(fp)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "already listening")
+}
@@ -0,0 +1,329 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backend persists the assets of a Store. Names are paths relative
+// to the root of the store, "" being the root itself.
+type Backend interface {
+	// Has returns true if the named file or container exists
+	Has(name string) bool
+	// Read returns the contents of the named file
+	Read(name string) ([]byte, error)
+	// Write stores data under the named file creating any parent containers
+	Write(name string, data []byte) error
+	// Delete removes the named file or empty container
+	Delete(name string) error
+	// List returns the entries in the named container sorted by name
+	List(name string) ([]os.FileInfo, error)
+	// MkdirAll creates the named container and any of its parents
+	MkdirAll(name string) error
+}
+
+// FSBackend stores assets as files under a directory - this is the
+// layout nsc has always used
+type FSBackend struct {
+	Dir string
+}
+
+func NewFSBackend(dir string) *FSBackend {
+	return &FSBackend{Dir: dir}
+}
+
+func (b *FSBackend) resolve(name string) string {
+	return filepath.Join(b.Dir, name)
+}
+
+func (b *FSBackend) Has(name string) bool {
+	if _, err := fsys().stat(b.resolve(name)); os.IsNotExist(err) {
+		return false
+	}
+	return true
+}
+
+func (b *FSBackend) Read(name string) ([]byte, error) {
+	fp := b.resolve(name)
+	d, err := fsys().readFile(fp)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %#q: %v", fp, err)
+	}
+	return d, nil
+}
+
+func (b *FSBackend) Write(name string, data []byte) error {
+	fp := b.resolve(name)
+	if err := fsys().mkdirAll(filepath.Dir(fp)); err != nil {
+		return err
+	}
+	return fsys().writeFile(fp, data)
+}
+
+func (b *FSBackend) Delete(name string) error {
+	return fsys().remove(b.resolve(name))
+}
+
+func (b *FSBackend) List(name string) ([]os.FileInfo, error) {
+	return fsys().readDir(b.resolve(name))
+}
+
+func (b *FSBackend) MkdirAll(name string) error {
+	return fsys().mkdirAll(b.resolve(name))
+}
+
+// fileSystem reads and writes the files of stores and keystores. Errors
+// are the same as the ones returned by the os package.
+type fileSystem interface {
+	stat(fp string) (os.FileInfo, error)
+	readFile(fp string) ([]byte, error)
+	writeFile(fp string, data []byte) error
+	remove(fp string) error
+	removeAll(fp string) error
+	readDir(fp string) ([]os.FileInfo, error)
+	mkdirAll(fp string) error
+	rename(from string, to string) error
+}
+
+// fsys returns the file system of stores and keystores, while a dry
+// run is active changes are kept in memory
+func fsys() fileSystem {
+	if d := ActiveDryRun(); d != nil {
+		return d.overlay
+	}
+	return disk
+}
+
+var disk diskFS
+
+// diskFS is the file system of the host
+type diskFS struct{}
+
+func (diskFS) stat(fp string) (os.FileInfo, error) {
+	return os.Stat(fp)
+}
+
+func (diskFS) readFile(fp string) ([]byte, error) {
+	return ioutil.ReadFile(fp)
+}
+
+func (diskFS) writeFile(fp string, data []byte) error {
+	return ioutil.WriteFile(fp, data, 0600)
+}
+
+func (diskFS) remove(fp string) error {
+	return os.Remove(fp)
+}
+
+func (diskFS) removeAll(fp string) error {
+	return os.RemoveAll(fp)
+}
+
+func (diskFS) readDir(fp string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(fp)
+}
+
+func (diskFS) mkdirAll(fp string) error {
+	return os.MkdirAll(fp, 0700)
+}
+
+func (diskFS) rename(from string, to string) error {
+	return os.Rename(from, to)
+}
+
+// Exists returns true if the file or directory exists, including
+// the ones created by an active dry run
+func Exists(fp string) bool {
+	_, err := fsys().stat(fp)
+	return err == nil
+}
+
+// ReadDir returns the entries in the directory sorted by name, including
+// the changes made by an active dry run
+func ReadDir(dir string) ([]os.FileInfo, error) {
+	return fsys().readDir(dir)
+}
+
+// MemBackend keeps assets in memory, it is useful for embedding
+// nsc operations or for tests that don't need a directory
+type MemBackend struct {
+	sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+func NewMemBackend() *MemBackend {
+	return &MemBackend{files: make(map[string][]byte), dirs: map[string]bool{"": true}}
+}
+
+func (b *MemBackend) clean(name string) string {
+	name = filepath.ToSlash(filepath.Clean(name))
+	name = strings.Trim(name, "/")
+	if name == "." {
+		return ""
+	}
+	return name
+}
+
+func (b *MemBackend) parent(name string) string {
+	i := strings.LastIndex(name, "/")
+	if i == -1 {
+		return ""
+	}
+	return name[:i]
+}
+
+func (b *MemBackend) mkdirAll(name string) error {
+	for n := name; ; n = b.parent(n) {
+		if _, ok := b.files[n]; ok {
+			return fmt.Errorf("%#q already exists and it is not a dir", n)
+		}
+		b.dirs[n] = true
+		if n == "" {
+			return nil
+		}
+	}
+}
+
+func (b *MemBackend) Has(name string) bool {
+	b.Lock()
+	defer b.Unlock()
+	name = b.clean(name)
+	_, ok := b.files[name]
+	return ok || b.dirs[name]
+}
+
+func (b *MemBackend) Read(name string) ([]byte, error) {
+	b.Lock()
+	defer b.Unlock()
+	name = b.clean(name)
+	d, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("error reading %#q: %v", name, os.ErrNotExist)
+	}
+	return append([]byte(nil), d...), nil
+}
+
+func (b *MemBackend) Write(name string, data []byte) error {
+	b.Lock()
+	defer b.Unlock()
+	name = b.clean(name)
+	if b.dirs[name] {
+		return fmt.Errorf("%#q is a directory", name)
+	}
+	if err := b.mkdirAll(b.parent(name)); err != nil {
+		return err
+	}
+	b.files[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (b *MemBackend) Delete(name string) error {
+	b.Lock()
+	defer b.Unlock()
+	name = b.clean(name)
+	if _, ok := b.files[name]; ok {
+		delete(b.files, name)
+		return nil
+	}
+	if !b.dirs[name] {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if len(b.children(name)) > 0 {
+		return fmt.Errorf("%#q is not empty", name)
+	}
+	if name != "" {
+		delete(b.dirs, name)
+	}
+	return nil
+}
+
+func (b *MemBackend) List(name string) ([]os.FileInfo, error) {
+	b.Lock()
+	defer b.Unlock()
+	name = b.clean(name)
+	if !b.dirs[name] {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	infos := b.children(name)
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	return infos, nil
+}
+
+func (b *MemBackend) MkdirAll(name string) error {
+	b.Lock()
+	defer b.Unlock()
+	return b.mkdirAll(b.clean(name))
+}
+
+func (b *MemBackend) children(name string) []os.FileInfo {
+	var infos []os.FileInfo
+	for k := range b.dirs {
+		if k != "" && b.parent(k) == name {
+			infos = append(infos, &memFileInfo{name: path.Base(k), dir: true})
+		}
+	}
+	for k, v := range b.files {
+		if b.parent(k) == name {
+			infos = append(infos, &memFileInfo{name: path.Base(k), size: int64(len(v))})
+		}
+	}
+	return infos
+}
+
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i *memFileInfo) Name() string {
+	return i.name
+}
+
+func (i *memFileInfo) Size() int64 {
+	return i.size
+}
+
+func (i *memFileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0700
+	}
+	return 0600
+}
+
+func (i *memFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (i *memFileInfo) IsDir() bool {
+	return i.dir
+}
+
+func (i *memFileInfo) Sys() interface{} {
+	return nil
+}
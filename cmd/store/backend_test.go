@@ -0,0 +1,149 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemBackend_ReadWrite(t *testing.T) {
+	b := NewMemBackend()
+	require.True(t, b.Has(""))
+	require.False(t, b.Has("a"))
+
+	require.NoError(t, b.Write(filepath.Join("a", "b", "c.jwt"), []byte("hello")))
+	require.True(t, b.Has("a"))
+	require.True(t, b.Has(filepath.Join("a", "b")))
+	require.True(t, b.Has(filepath.Join("a", "b", "c.jwt")))
+
+	d, err := b.Read(filepath.Join("a", "b", "c.jwt"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(d))
+
+	_, err = b.Read(filepath.Join("a", "b", "x.jwt"))
+	require.Error(t, err)
+
+	require.Error(t, b.Write("a", []byte("x")))
+}
+
+func TestMemBackend_ListDelete(t *testing.T) {
+	b := NewMemBackend()
+	require.NoError(t, b.Write(filepath.Join("a", "z.jwt"), []byte("z")))
+	require.NoError(t, b.Write(filepath.Join("a", "y.jwt"), []byte("y")))
+	require.NoError(t, b.MkdirAll(filepath.Join("a", "x")))
+
+	infos, err := b.List("a")
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+	require.Equal(t, "x", infos[0].Name())
+	require.True(t, infos[0].IsDir())
+	require.Equal(t, "y.jwt", infos[1].Name())
+	require.False(t, infos[1].IsDir())
+	require.Equal(t, "z.jwt", infos[2].Name())
+
+	_, err = b.List("b")
+	require.True(t, os.IsNotExist(err))
+
+	require.Error(t, b.Delete("a"))
+	require.NoError(t, b.Delete(filepath.Join("a", "x")))
+	require.NoError(t, b.Delete(filepath.Join("a", "y.jwt")))
+	require.NoError(t, b.Delete(filepath.Join("a", "z.jwt")))
+	require.NoError(t, b.Delete("a"))
+	require.False(t, b.Has("a"))
+
+	err = b.Delete("a")
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestMemStore(t *testing.T) {
+	_, _, kp := CreateOperatorKey(t)
+	_, apub, akp := CreateAccountKey(t)
+	_, upub, _ := CreateUserKey(t)
+
+	s, err := CreateStoreWithBackend(NewMemBackend(), &NamedKey{Name: "O", KP: kp})
+	require.NoError(t, err)
+	require.Empty(t, s.Dir)
+	require.True(t, s.Has(NSCFile))
+	require.True(t, s.Has(Accounts))
+
+	ac := jwt.NewAccountClaims(apub)
+	ac.Name = "A"
+	cd, err := ac.Encode(kp)
+	require.NoError(t, err)
+	_, err = s.StoreClaim([]byte(cd))
+	require.NoError(t, err)
+
+	uc := jwt.NewUserClaims(upub)
+	uc.Name = "U"
+	ud, err := uc.Encode(akp)
+	require.NoError(t, err)
+	_, err = s.StoreClaim([]byte(ud))
+	require.NoError(t, err)
+
+	ss, err := LoadStoreWithBackend(s.Backend)
+	require.NoError(t, err)
+	require.Equal(t, "O", ss.GetName())
+
+	accounts, err := ss.ListSubContainers(Accounts)
+	require.NoError(t, err)
+	require.Equal(t, []string{"A"}, accounts)
+
+	users, err := ss.ListEntries(Accounts, "A", Users)
+	require.NoError(t, err)
+	require.Equal(t, []string{"U"}, users)
+
+	rac, err := ss.ReadAccountClaim("A")
+	require.NoError(t, err)
+	require.Equal(t, apub, rac.Subject)
+
+	ctx, err := ss.GetContext()
+	require.NoError(t, err)
+	require.Equal(t, "A", ctx.Account.Name)
+}
+
+func TestMemStore_NotEmpty(t *testing.T) {
+	_, _, kp := CreateOperatorKey(t)
+	b := NewMemBackend()
+	require.NoError(t, b.Write("foo", []byte("bar")))
+	_, err := CreateStoreWithBackend(b, &NamedKey{Name: "O", KP: kp})
+	require.Error(t, err)
+
+	_, err = LoadStoreWithBackend(NewMemBackend())
+	require.Error(t, err)
+}
+
+func TestFSStore_Backend(t *testing.T) {
+	_, _, kp := CreateOperatorKey(t)
+	dir := MakeTempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := CreateStore("O", dir, &NamedKey{Name: "O", KP: kp})
+	require.NoError(t, err)
+	fb, ok := s.Backend.(*FSBackend)
+	require.True(t, ok)
+	require.Equal(t, s.Dir, fb.Dir)
+
+	ss, err := LoadStore(s.Dir)
+	require.NoError(t, err)
+	fb, ok = ss.Backend.(*FSBackend)
+	require.True(t, ok)
+	require.Equal(t, filepath.Join(dir, "O"), fb.Dir)
+}
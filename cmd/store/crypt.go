@@ -0,0 +1,511 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nkeys"
+	"golang.org/x/crypto/scrypt"
+)
+
+// NKeysPassphraseEnv names the environment variable with the passphrase
+// of an encrypted keystore
+const NKeysPassphraseEnv = "NKEYS_PASSPHRASE"
+
+// KeyStoreEncryptionFile marks an encrypted keystore. It holds the
+// key derivation parameters used for new keys and a passphrase check.
+const KeyStoreEncryptionFile = ".encryption"
+
+const sealedVersion = 1
+
+// checkValue is sealed in the encryption file to verify passphrases
+const checkValue = "nsc keystore"
+
+// ErrKeyStoreLocked is returned when an encrypted key is read and no passphrase is available
+var ErrKeyStoreLocked = fmt.Errorf("keystore is encrypted - set %s or run interactively to enter the passphrase", NKeysPassphraseEnv)
+
+// ErrBadPassphrase is returned when a sealed key cannot be opened with the passphrase
+var ErrBadPassphrase = errors.New("incorrect keystore passphrase")
+
+// PassphraseSource is called to obtain the keystore passphrase when
+// NKEYS_PASSPHRASE is not set. The cli sets it to prompt the user.
+var PassphraseSource func() (string, error)
+
+// KDFParams are the scrypt parameters used to derive the encryption
+// key from the passphrase
+type KDFParams struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// DefaultKDF are the cost parameters for new keystore passphrases
+var DefaultKDF = KDFParams{Name: "scrypt", N: 1 << 15, R: 8, P: 1}
+
+func newKDFParams() (KDFParams, error) {
+	p := DefaultKDF
+	p.Salt = make([]byte, 16)
+	if _, err := rand.Read(p.Salt); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// SealedKey is an nkey seed encrypted with AES-256-GCM using a key
+// derived from the keystore passphrase. The public key is kept in the
+// clear so keys can be identified without the passphrase.
+type SealedKey struct {
+	Version    int       `json:"version"`
+	PublicKey  string    `json:"public_key"`
+	KDF        KDFParams `json:"kdf"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+}
+
+// KeyStoreEncryption is the contents of the encryption file
+type KeyStoreEncryption struct {
+	Version int       `json:"version"`
+	Check   SealedKey `json:"check"`
+}
+
+// IsSealed returns true if the data is an encrypted seed
+func IsSealed(d []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(d), []byte("{"))
+}
+
+func parseSealed(d []byte) (*SealedKey, error) {
+	var sk SealedKey
+	if err := json.Unmarshal(d, &sk); err != nil {
+		return nil, fmt.Errorf("error parsing encrypted key: %v", err)
+	}
+	if sk.Version != sealedVersion {
+		return nil, fmt.Errorf("unsupported encrypted key version %d", sk.Version)
+	}
+	return &sk, nil
+}
+
+type derivedKey struct {
+	passphrase string
+	kdf        string
+}
+
+var crypt = struct {
+	sync.Mutex
+	passphrase string
+	keys       map[derivedKey][]byte
+}{keys: make(map[derivedKey][]byte)}
+
+func deriveKey(passphrase string, p KDFParams) ([]byte, error) {
+	if p.Name != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation function %q", p.Name)
+	}
+	id := derivedKey{passphrase: passphrase, kdf: fmt.Sprintf("%x/%d/%d/%d", p.Salt, p.N, p.R, p.P)}
+	crypt.Lock()
+	defer crypt.Unlock()
+	if k, ok := crypt.keys[id]; ok {
+		return k, nil
+	}
+	k, err := scrypt.Key([]byte(passphrase), p.Salt, p.N, p.R, p.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	crypt.keys[id] = k
+	return k, nil
+}
+
+func seal(passphrase string, p KDFParams, pub string, data []byte) (*SealedKey, error) {
+	k, err := deriveKey(passphrase, p)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(k)
+	if err != nil {
+		return nil, err
+	}
+	sk := &SealedKey{Version: sealedVersion, PublicKey: pub, KDF: p}
+	sk.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(sk.Nonce); err != nil {
+		return nil, err
+	}
+	// the public key is authenticated so sealed seeds cannot be swapped
+	sk.Ciphertext = gcm.Seal(nil, sk.Nonce, data, []byte(pub))
+	return sk, nil
+}
+
+func (sk *SealedKey) open(passphrase string) ([]byte, error) {
+	k, err := deriveKey(passphrase, sk.KDF)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(k)
+	if err != nil {
+		return nil, err
+	}
+	d, err := gcm.Open(nil, sk.Nonce, sk.Ciphertext, []byte(sk.PublicKey))
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return d, nil
+}
+
+func newGCM(k []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
+
+// SetPassphrase sets the passphrase used by this process to open encrypted keys
+func SetPassphrase(passphrase string) {
+	crypt.Lock()
+	defer crypt.Unlock()
+	crypt.passphrase = passphrase
+}
+
+// ClearPassphrase forgets the passphrase and the keys derived from it
+func ClearPassphrase() {
+	crypt.Lock()
+	defer crypt.Unlock()
+	crypt.passphrase = ""
+	crypt.keys = make(map[derivedKey][]byte)
+}
+
+// passphrase returns the keystore passphrase from the process, the
+// environment or PassphraseSource. Passphrases entered by the user
+// are verified and kept for the life of the process.
+func passphrase() (string, error) {
+	crypt.Lock()
+	pp := crypt.passphrase
+	crypt.Unlock()
+	if pp != "" {
+		return pp, nil
+	}
+	if pp = os.Getenv(NKeysPassphraseEnv); pp != "" {
+		return pp, nil
+	}
+	if PassphraseSource == nil {
+		return "", ErrKeyStoreLocked
+	}
+	pp, err := PassphraseSource()
+	if err != nil {
+		return "", err
+	}
+	if pp == "" {
+		return "", ErrKeyStoreLocked
+	}
+	if err := VerifyPassphrase(pp); err != nil {
+		return "", err
+	}
+	SetPassphrase(pp)
+	return pp, nil
+}
+
+// hasPassphrase returns true if the passphrase is known without asking the user
+func hasPassphrase() bool {
+	crypt.Lock()
+	defer crypt.Unlock()
+	return crypt.passphrase != "" || os.Getenv(NKeysPassphraseEnv) != ""
+}
+
+func encryptionPath() string {
+	return filepath.Join(GetKeysDir(), KeyStoreEncryptionFile)
+}
+
+// IsEncryptedKeyStore returns true if keys stored in the keystore are encrypted
+func IsEncryptedKeyStore() bool {
+	_, err := os.Stat(encryptionPath())
+	return err == nil
+}
+
+func readEncryption() (*KeyStoreEncryption, error) {
+	d, err := ioutil.ReadFile(encryptionPath())
+	if err != nil {
+		return nil, err
+	}
+	var e KeyStoreEncryption
+	if err := json.Unmarshal(d, &e); err != nil {
+		return nil, fmt.Errorf("error parsing %#q: %v", encryptionPath(), err)
+	}
+	if e.Version != sealedVersion {
+		return nil, fmt.Errorf("unsupported keystore encryption version %d", e.Version)
+	}
+	return &e, nil
+}
+
+func writeEncryption(passphrase string, p KDFParams) (*KeyStoreEncryption, error) {
+	check, err := seal(passphrase, p, "", []byte(checkValue))
+	if err != nil {
+		return nil, err
+	}
+	e := &KeyStoreEncryption{Version: sealedVersion, Check: *check}
+	d, err := json.MarshalIndent(e, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(encryptionPath(), d); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// VerifyPassphrase returns an error if the passphrase doesn't match the
+// passphrase of the encrypted keystore
+func VerifyPassphrase(passphrase string) error {
+	e, err := readEncryption()
+	if err != nil {
+		return err
+	}
+	d, err := e.Check.open(passphrase)
+	if err != nil {
+		return err
+	}
+	if string(d) != checkValue {
+		return ErrBadPassphrase
+	}
+	return nil
+}
+
+// sealSeed encrypts a seed with the current keystore passphrase
+func sealSeed(pub string, seed []byte) ([]byte, error) {
+	e, err := readEncryption()
+	if err != nil {
+		return nil, err
+	}
+	// a running agent can encrypt without asking for the passphrase
+	if !hasPassphrase() {
+		if c := agentClient(); c != nil {
+			if d, err := c.Seal(pub, seed); err == nil {
+				return d, nil
+			}
+		}
+	}
+	pp, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	sk, err := seal(pp, e.Check.KDF, pub, seed)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(sk, "", " ")
+}
+
+// openSeed decrypts a sealed seed
+func openSeed(d []byte) ([]byte, error) {
+	sk, err := parseSealed(d)
+	if err != nil {
+		return nil, err
+	}
+	pp, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	seed, err := sk.open(pp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt key %q: %v", sk.PublicKey, err)
+	}
+	return seed, nil
+}
+
+// writeFileAtomic replaces the file with data
+func writeFileAtomic(fp string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(fp), filepath.Base(fp)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), fp)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// keyFiles returns the paths of the nkeys in the keystore
+func keyFiles() ([]string, error) {
+	var files []string
+	dir := filepath.Join(GetKeysDir(), KeysDir)
+	err := filepath.Walk(dir, func(fp string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(fp) == NKeyExtension {
+			files = append(files, fp)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// resealKeys re-encrypts every key in the keystore with the passphrase. Keys are
+// opened with one of the old passphrases, plain seeds are encrypted. All files
+// are prepared before any is replaced. Returns the public keys that were sealed.
+func resealKeys(passphrase string, p KDFParams, old ...string) ([]string, error) {
+	files, err := keyFiles()
+	if err != nil {
+		return nil, err
+	}
+	type update struct {
+		fp   string
+		data []byte
+	}
+	var updates []update
+	var keys []string
+	for _, fp := range files {
+		d, err := ioutil.ReadFile(fp)
+		if err != nil {
+			return nil, err
+		}
+		seed := bytes.TrimSpace(d)
+		if IsSealed(d) {
+			sk, err := parseSealed(d)
+			if err != nil {
+				return nil, fmt.Errorf("%#q: %v", fp, err)
+			}
+			seed = nil
+			for _, pp := range append(old, passphrase) {
+				if seed, err = sk.open(pp); err == nil {
+					break
+				}
+			}
+			if seed == nil {
+				return nil, fmt.Errorf("unable to decrypt %#q: %v", fp, ErrBadPassphrase)
+			}
+		}
+		kp, err := nkeys.FromSeed(seed)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %#q: %v", fp, err)
+		}
+		pub, err := kp.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		sk, err := seal(passphrase, p, pub, seed)
+		if err != nil {
+			return nil, err
+		}
+		sd, err := json.MarshalIndent(sk, "", " ")
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, update{fp: fp, data: sd})
+		keys = append(keys, pub)
+	}
+	for _, u := range updates {
+		if err := writeFileAtomic(u.fp, u.data); err != nil {
+			return nil, fmt.Errorf("error writing %#q: %v", u.fp, err)
+		}
+	}
+	return keys, nil
+}
+
+// EncryptKeyStore encrypts the keys in the keystore with the passphrase.
+// If the keystore is already encrypted, the passphrase must match and any
+// keys that are still in the clear are encrypted. Returns the keys encrypted.
+// Creds files are not encrypted, they are read by NATS clients.
+func (k *KeyStore) EncryptKeyStore(passphrase string) ([]string, error) {
+	if strings.TrimSpace(passphrase) == "" {
+		return nil, errors.New("passphrase cannot be empty")
+	}
+	if err := makeKeyStore(GetKeysDir()); err != nil {
+		return nil, err
+	}
+	unlock, err := k.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var e *KeyStoreEncryption
+	if IsEncryptedKeyStore() {
+		if err := VerifyPassphrase(passphrase); err != nil {
+			return nil, err
+		}
+		if e, err = readEncryption(); err != nil {
+			return nil, err
+		}
+	} else {
+		p, err := newKDFParams()
+		if err != nil {
+			return nil, err
+		}
+		if e, err = writeEncryption(passphrase, p); err != nil {
+			return nil, err
+		}
+	}
+	SetPassphrase(passphrase)
+	return resealKeys(passphrase, e.Check.KDF)
+}
+
+// ChangePassphrase re-encrypts the keys in the keystore with a new passphrase
+func (k *KeyStore) ChangePassphrase(old string, passphrase string) ([]string, error) {
+	if strings.TrimSpace(passphrase) == "" {
+		return nil, errors.New("passphrase cannot be empty")
+	}
+	unlock, err := k.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if !IsEncryptedKeyStore() {
+		return nil, errors.New("keystore is not encrypted")
+	}
+	if err := VerifyPassphrase(old); err != nil {
+		return nil, err
+	}
+	p, err := newKDFParams()
+	if err != nil {
+		return nil, err
+	}
+	// keys are re-encrypted before the passphrase check changes, so an
+	// interrupted change can be repeated with the same passphrases
+	keys, err := resealKeys(passphrase, p, old)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writeEncryption(passphrase, p); err != nil {
+		return nil, err
+	}
+	SetPassphrase(passphrase)
+	return keys, nil
+}
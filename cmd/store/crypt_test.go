@@ -0,0 +1,194 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// setupEncryptedKeyStore points the keystore to a new directory and
+// lowers the cost of the key derivation for tests
+func setupEncryptedKeyStore(t *testing.T) func() {
+	dir := MakeTempDir(t)
+	old := os.Getenv(NKeysPathEnv)
+	require.NoError(t, os.Setenv(NKeysPathEnv, dir))
+	kdf := DefaultKDF
+	DefaultKDF.N = 1 << 10
+	ClearPassphrase()
+	return func() {
+		ClearPassphrase()
+		DefaultKDF = kdf
+		require.NoError(t, os.Unsetenv(NKeysPassphraseEnv))
+		require.NoError(t, os.Setenv(NKeysPathEnv, old))
+	}
+}
+
+func TestCrypt_EncryptKeyStore(t *testing.T) {
+	defer setupEncryptedKeyStore(t)()
+	ks := NewKeyStore("O")
+
+	_, opk, okp := CreateOperatorKey(t)
+	fp, err := ks.Store(okp)
+	require.NoError(t, err)
+
+	keys, err := ks.EncryptKeyStore("a passphrase")
+	require.NoError(t, err)
+	require.Equal(t, []string{opk}, keys)
+	require.True(t, IsEncryptedKeyStore())
+
+	d, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	require.True(t, IsSealed(d))
+	seed, err := okp.Seed()
+	require.NoError(t, err)
+	require.NotContains(t, string(d), string(seed))
+
+	// new keys are stored encrypted
+	_, apk, akp := CreateAccountKey(t)
+	afp, err := ks.Store(akp)
+	require.NoError(t, err)
+	d, err = ioutil.ReadFile(afp)
+	require.NoError(t, err)
+	require.True(t, IsSealed(d))
+	// storing the same key again is fine
+	_, err = ks.Store(akp)
+	require.NoError(t, err)
+
+	// without a passphrase keys can be found but not read
+	ClearPassphrase()
+	require.True(t, ks.HasPrivateKey(opk))
+	pk, err := ks.GetPublicKey(apk)
+	require.NoError(t, err)
+	require.Equal(t, apk, pk)
+	_, err = ks.GetKeyPair(opk)
+	require.Equal(t, ErrKeyStoreLocked, err)
+
+	require.NoError(t, os.Setenv(NKeysPassphraseEnv, "a passphrase"))
+	kp, err := ks.GetKeyPair(opk)
+	require.NoError(t, err)
+	require.True(t, Match(opk, kp))
+	s, err := ks.GetSeed(apk)
+	require.NoError(t, err)
+	aseed, err := akp.Seed()
+	require.NoError(t, err)
+	require.Equal(t, string(aseed), s)
+
+	// key files can be referenced directly
+	kp, err = ResolveKey(afp)
+	require.NoError(t, err)
+	require.True(t, Match(apk, kp))
+
+	require.NoError(t, os.Setenv(NKeysPassphraseEnv, "wrong"))
+	_, err = ks.GetKeyPair(opk)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrBadPassphrase.Error())
+}
+
+func TestCrypt_EncryptTwice(t *testing.T) {
+	defer setupEncryptedKeyStore(t)()
+	ks := NewKeyStore("O")
+
+	_, _, okp := CreateOperatorKey(t)
+	_, err := ks.Store(okp)
+	require.NoError(t, err)
+	_, err = ks.EncryptKeyStore("a passphrase")
+	require.NoError(t, err)
+
+	_, err = ks.EncryptKeyStore("another passphrase")
+	require.Error(t, err)
+
+	// a key written in the clear is encrypted
+	_, apk, akp := CreateAccountKey(t)
+	seed, err := akp.Seed()
+	require.NoError(t, err)
+	fp := ks.GetKeyPath(apk)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fp), 0700))
+	require.NoError(t, ioutil.WriteFile(fp, seed, 0600))
+
+	keys, err := ks.EncryptKeyStore("a passphrase")
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	d, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	require.True(t, IsSealed(d))
+}
+
+func TestCrypt_ChangePassphrase(t *testing.T) {
+	defer setupEncryptedKeyStore(t)()
+	ks := NewKeyStore("O")
+
+	_, opk, okp := CreateOperatorKey(t)
+	_, err := ks.Store(okp)
+	require.NoError(t, err)
+
+	_, err = ks.ChangePassphrase("a passphrase", "new passphrase")
+	require.Error(t, err)
+
+	_, err = ks.EncryptKeyStore("a passphrase")
+	require.NoError(t, err)
+
+	_, err = ks.ChangePassphrase("wrong", "new passphrase")
+	require.Equal(t, ErrBadPassphrase, err)
+
+	keys, err := ks.ChangePassphrase("a passphrase", "new passphrase")
+	require.NoError(t, err)
+	require.Equal(t, []string{opk}, keys)
+
+	require.Equal(t, ErrBadPassphrase, VerifyPassphrase("a passphrase"))
+	require.NoError(t, VerifyPassphrase("new passphrase"))
+
+	ClearPassphrase()
+	require.NoError(t, os.Setenv(NKeysPassphraseEnv, "new passphrase"))
+	kp, err := ks.GetKeyPair(opk)
+	require.NoError(t, err)
+	require.True(t, Match(opk, kp))
+}
+
+func TestCrypt_PassphraseSource(t *testing.T) {
+	defer setupEncryptedKeyStore(t)()
+	defer func() { PassphraseSource = nil }()
+	ks := NewKeyStore("O")
+
+	_, opk, okp := CreateOperatorKey(t)
+	_, err := ks.Store(okp)
+	require.NoError(t, err)
+	_, err = ks.EncryptKeyStore("a passphrase")
+	require.NoError(t, err)
+	ClearPassphrase()
+
+	calls := 0
+	PassphraseSource = func() (string, error) {
+		calls++
+		return "a passphrase", nil
+	}
+	_, err = ks.GetKeyPair(opk)
+	require.NoError(t, err)
+	_, err = ks.GetKeyPair(opk)
+	require.NoError(t, err)
+	// the passphrase is kept by the process
+	require.Equal(t, 1, calls)
+
+	ClearPassphrase()
+	PassphraseSource = func() (string, error) {
+		return "wrong", nil
+	}
+	_, err = ks.GetKeyPair(opk)
+	require.Error(t, err)
+}
@@ -0,0 +1,508 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Operations recorded by a dry run
+const (
+	CreateOp = "create"
+	UpdateOp = "update"
+	RemoveOp = "remove"
+	PushOp   = "push"
+)
+
+// Change is a change that a dry run didn't make
+type Change struct {
+	Op     string         `json:"op"`
+	Path   string         `json:"path"`
+	Fields []*FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange is a claim field changed in a JWT. Nested fields
+// are named by their path, ie `nats.limits.conn`.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// DryRun captures the changes made while it is active. Files of stores,
+// the keystore and the files written by commands are kept in memory by an
+// overlay of the file system, nothing is written to disk, and pushes to
+// the account server are recorded but not made.
+type DryRun struct {
+	sync.Mutex
+	overlay *overlayFS
+	extra   []*Change
+}
+
+var dryRun struct {
+	sync.Mutex
+	current *DryRun
+}
+
+// StartDryRun makes a new dry run active
+func StartDryRun() (*DryRun, error) {
+	d := &DryRun{overlay: newOverlayFS()}
+	dryRun.Lock()
+	dryRun.current = d
+	dryRun.Unlock()
+	return d, nil
+}
+
+// ActiveDryRun returns the active dry run or nil
+func ActiveDryRun() *DryRun {
+	dryRun.Lock()
+	defer dryRun.Unlock()
+	return dryRun.current
+}
+
+// Close deactivates the dry run and drops the changes
+func (d *DryRun) Close() error {
+	dryRun.Lock()
+	if dryRun.current == d {
+		dryRun.current = nil
+	}
+	dryRun.Unlock()
+	d.overlay.reset()
+	return nil
+}
+
+// skipped returns true for files that are not compared: locks are not
+// taken, versioning is disabled and the index is a cache
+func skipped(fp string) bool {
+	switch filepath.Base(fp) {
+	case LockName, IndexName:
+		return true
+	}
+	for _, n := range strings.Split(filepath.ToSlash(fp), "/") {
+		if n == ".git" {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFile records a file outside of the stores that would be written
+func (d *DryRun) AddFile(fp string, data []byte) error {
+	return d.overlay.writeFile(fp, data)
+}
+
+// ReadFile returns the contents of the file including the changes made
+// by the dry run
+func (d *DryRun) ReadFile(fp string) ([]byte, error) {
+	return d.overlay.readFile(fp)
+}
+
+func (d *DryRun) addPush(u string) {
+	d.Lock()
+	defer d.Unlock()
+	d.extra = append(d.extra, &Change{Op: PushOp, Path: u})
+}
+
+// Changes compares the files in the overlay with the files on disk
+func (d *DryRun) Changes() ([]*Change, error) {
+	d.Lock()
+	defer d.Unlock()
+	changes, err := d.overlay.changes()
+	if err != nil {
+		return nil, err
+	}
+	return append(changes, d.extra...), nil
+}
+
+// overlayFS is a file system that keeps the changes made to the
+// files on disk in memory. Paths are absolute.
+type overlayFS struct {
+	sync.Mutex
+	files   map[string][]byte
+	dirs    map[string]bool
+	removed map[string]bool
+}
+
+func newOverlayFS() *overlayFS {
+	o := &overlayFS{}
+	o.reset()
+	return o
+}
+
+func (o *overlayFS) reset() {
+	o.Lock()
+	defer o.Unlock()
+	o.files = make(map[string][]byte)
+	o.dirs = make(map[string]bool)
+	o.removed = make(map[string]bool)
+}
+
+func notExist(op string, fp string) error {
+	return &os.PathError{Op: op, Path: fp, Err: os.ErrNotExist}
+}
+
+// parents returns the parent directories of the path, closest first
+func parents(fp string) []string {
+	var dirs []string
+	for p := filepath.Dir(fp); p != fp; fp, p = p, filepath.Dir(p) {
+		dirs = append(dirs, p)
+	}
+	return dirs
+}
+
+// isRemoved returns true if the path or one of its parents was removed
+func (o *overlayFS) isRemoved(fp string) bool {
+	if o.removed[fp] {
+		return true
+	}
+	for _, p := range parents(fp) {
+		if o.dirs[p] {
+			return false
+		}
+		if o.removed[p] {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *overlayFS) lstat(fp string) (os.FileInfo, error) {
+	if d, ok := o.files[fp]; ok {
+		return &memFileInfo{name: filepath.Base(fp), size: int64(len(d))}, nil
+	}
+	if o.dirs[fp] {
+		return &memFileInfo{name: filepath.Base(fp), dir: true}, nil
+	}
+	if o.isRemoved(fp) {
+		return nil, notExist("stat", fp)
+	}
+	return os.Stat(fp)
+}
+
+func (o *overlayFS) stat(fp string) (os.FileInfo, error) {
+	fp, err := filepath.Abs(fp)
+	if err != nil {
+		return nil, err
+	}
+	o.Lock()
+	defer o.Unlock()
+	return o.lstat(fp)
+}
+
+func (o *overlayFS) readFile(fp string) ([]byte, error) {
+	fp, err := filepath.Abs(fp)
+	if err != nil {
+		return nil, err
+	}
+	o.Lock()
+	defer o.Unlock()
+	if d, ok := o.files[fp]; ok {
+		return append([]byte(nil), d...), nil
+	}
+	if o.dirs[fp] || o.isRemoved(fp) {
+		return nil, notExist("open", fp)
+	}
+	return ioutil.ReadFile(fp)
+}
+
+func (o *overlayFS) mkdirs(fp string) {
+	for _, p := range append([]string{fp}, parents(fp)...) {
+		if o.dirs[p] {
+			return
+		}
+		removed := o.isRemoved(p)
+		fi, err := os.Stat(p)
+		onDisk := err == nil && fi.IsDir()
+		if onDisk && !removed {
+			return
+		}
+		if onDisk {
+			// the directory is created again without its entries
+			infos, _ := ioutil.ReadDir(p)
+			for _, i := range infos {
+				o.removed[filepath.Join(p, i.Name())] = true
+			}
+		}
+		delete(o.removed, p)
+		o.dirs[p] = true
+	}
+}
+
+func (o *overlayFS) writeFile(fp string, data []byte) error {
+	fp, err := filepath.Abs(fp)
+	if err != nil {
+		return err
+	}
+	o.Lock()
+	defer o.Unlock()
+	if fi, err := o.lstat(fp); err == nil && fi.IsDir() {
+		return fmt.Errorf("%#q is a directory", fp)
+	}
+	o.mkdirs(filepath.Dir(fp))
+	delete(o.removed, fp)
+	o.files[fp] = append([]byte(nil), data...)
+	return nil
+}
+
+func (o *overlayFS) mkdirAll(fp string) error {
+	fp, err := filepath.Abs(fp)
+	if err != nil {
+		return err
+	}
+	o.Lock()
+	defer o.Unlock()
+	if fi, err := o.lstat(fp); err == nil && !fi.IsDir() {
+		return fmt.Errorf("%#q already exists and it is not a dir", fp)
+	}
+	o.mkdirs(fp)
+	return nil
+}
+
+func (o *overlayFS) list(fp string) ([]os.FileInfo, error) {
+	fi, err := o.lstat(fp)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%#q is not a directory", fp)
+	}
+	entries := make(map[string]os.FileInfo)
+	infos, err := ioutil.ReadDir(fp)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, i := range infos {
+		if !o.removed[filepath.Join(fp, i.Name())] {
+			entries[i.Name()] = i
+		}
+	}
+	for k := range o.dirs {
+		if filepath.Dir(k) == fp && k != fp {
+			entries[filepath.Base(k)] = &memFileInfo{name: filepath.Base(k), dir: true}
+		}
+	}
+	for k, v := range o.files {
+		if filepath.Dir(k) == fp {
+			entries[filepath.Base(k)] = &memFileInfo{name: filepath.Base(k), size: int64(len(v))}
+		}
+	}
+	var list []os.FileInfo
+	for _, i := range entries {
+		list = append(list, i)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list, nil
+}
+
+func (o *overlayFS) readDir(fp string) ([]os.FileInfo, error) {
+	fp, err := filepath.Abs(fp)
+	if err != nil {
+		return nil, err
+	}
+	o.Lock()
+	defer o.Unlock()
+	return o.list(fp)
+}
+
+func (o *overlayFS) delete(fp string) {
+	delete(o.files, fp)
+	delete(o.dirs, fp)
+	if _, err := os.Stat(fp); err == nil {
+		o.removed[fp] = true
+	}
+}
+
+func (o *overlayFS) remove(fp string) error {
+	fp, err := filepath.Abs(fp)
+	if err != nil {
+		return err
+	}
+	o.Lock()
+	defer o.Unlock()
+	fi, err := o.lstat(fp)
+	if err != nil {
+		return notExist("remove", fp)
+	}
+	if fi.IsDir() {
+		infos, err := o.list(fp)
+		if err != nil {
+			return err
+		}
+		if len(infos) > 0 {
+			return fmt.Errorf("%#q is not empty", fp)
+		}
+	}
+	o.delete(fp)
+	return nil
+}
+
+func (o *overlayFS) removeAll(fp string) error {
+	fp, err := filepath.Abs(fp)
+	if err != nil {
+		return err
+	}
+	o.Lock()
+	defer o.Unlock()
+	if _, err := o.lstat(fp); err != nil {
+		return nil
+	}
+	for k := range o.files {
+		if strings.HasPrefix(k, fp+string(filepath.Separator)) {
+			delete(o.files, k)
+		}
+	}
+	for k := range o.dirs {
+		if strings.HasPrefix(k, fp+string(filepath.Separator)) {
+			delete(o.dirs, k)
+		}
+	}
+	o.delete(fp)
+	return nil
+}
+
+func (o *overlayFS) rename(from string, to string) error {
+	d, err := o.readFile(from)
+	if err != nil {
+		return err
+	}
+	if err := o.writeFile(to, d); err != nil {
+		return err
+	}
+	return o.remove(from)
+}
+
+// changes compares the files in memory with the files on disk
+func (o *overlayFS) changes() ([]*Change, error) {
+	o.Lock()
+	defer o.Unlock()
+	var changes []*Change
+	for fp, data := range o.files {
+		if skipped(fp) {
+			continue
+		}
+		old, err := ioutil.ReadFile(fp)
+		switch {
+		case os.IsNotExist(err) || (err == nil && o.isRemoved(fp)):
+			changes = append(changes, &Change{Op: CreateOp, Path: fp, Fields: fileDiff(fp, nil, data)})
+		case err != nil:
+			return nil, err
+		case !bytes.Equal(old, data):
+			changes = append(changes, &Change{Op: UpdateOp, Path: fp, Fields: fileDiff(fp, old, data)})
+		}
+	}
+	seen := make(map[string]bool)
+	for dir := range o.removed {
+		err := filepath.Walk(dir, func(fp string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() || !fi.Mode().IsRegular() || skipped(fp) || seen[fp] {
+				return nil
+			}
+			seen[fp] = true
+			if _, ok := o.files[fp]; !ok {
+				changes = append(changes, &Change{Op: RemoveOp, Path: fp})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func fileDiff(fp string, old []byte, data []byte) []*FieldChange {
+	if !IsJwtName(fp) {
+		return nil
+	}
+	return ClaimDiff(old, data)
+}
+
+// claimFields returns the fields in the body of a JWT keyed by their path
+func claimFields(token []byte) map[string]interface{} {
+	fields := make(map[string]interface{})
+	chunks := strings.Split(strings.TrimSpace(string(token)), ".")
+	if len(chunks) != 3 {
+		return fields
+	}
+	d, err := base64.RawURLEncoding.DecodeString(chunks[1])
+	if err != nil {
+		return fields
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(d, &m); err != nil {
+		return fields
+	}
+	flatten("", m, fields)
+	return fields
+}
+
+func flatten(prefix string, m map[string]interface{}, fields map[string]interface{}) {
+	for k, v := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if vm, ok := v.(map[string]interface{}); ok && len(vm) > 0 {
+			flatten(k, vm, fields)
+			continue
+		}
+		fields[k] = v
+	}
+}
+
+// ClaimDiff returns the fields that differ between two JWTs. The JWT ID
+// and the issue time change every time a JWT is signed and are not compared.
+func ClaimDiff(old []byte, token []byte) []*FieldChange {
+	before := claimFields(old)
+	after := claimFields(token)
+	var names []string
+	for n := range before {
+		names = append(names, n)
+	}
+	for n := range after {
+		if _, ok := before[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	var changes []*FieldChange
+	for _, n := range names {
+		if n == "jti" || n == "iat" {
+			continue
+		}
+		o, n2 := before[n], after[n]
+		if !reflect.DeepEqual(o, n2) {
+			changes = append(changes, &FieldChange{Field: n, Old: o, New: n2})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun_ClaimDiff(t *testing.T) {
+	_, _, okp := CreateOperatorKey(t)
+	_, _, a := encodeTestAccount(t, okp, "A")
+	_, _, b := encodeTestAccount(t, okp, "B")
+
+	require.Empty(t, ClaimDiff([]byte(a), []byte(a)))
+
+	fields := make(map[string]*FieldChange)
+	for _, f := range ClaimDiff([]byte(a), []byte(b)) {
+		fields[f.Field] = f
+	}
+	require.Contains(t, fields, "name")
+	require.Equal(t, "A", fields["name"].Old)
+	require.Equal(t, "B", fields["name"].New)
+	require.Contains(t, fields, "sub")
+	require.NotContains(t, fields, "jti")
+	require.NotContains(t, fields, "iat")
+
+	// a new JWT has no old values
+	for _, f := range ClaimDiff(nil, []byte(a)) {
+		require.Nil(t, f.Old)
+	}
+}
+
+func TestDryRun_Changes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dry_run_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, LockName), []byte(""), 0600))
+
+	d, err := StartDryRun()
+	require.NoError(t, err)
+	require.Equal(t, d, ActiveDryRun())
+
+	b := NewFSBackend(dir)
+	require.NoError(t, b.Write("a.txt", []byte("aa")))
+	require.NoError(t, b.Delete("b.txt"))
+	require.NoError(t, b.Write("c.txt", []byte("c")))
+	require.NoError(t, d.AddFile(filepath.Join(dir, "d.txt"), []byte("d")))
+
+	// reads see the changes
+	data, err := b.Read("a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "aa", string(data))
+	require.False(t, b.Has("b.txt"))
+	infos, err := b.List(".")
+	require.NoError(t, err)
+	var names []string
+	for _, i := range infos {
+		names = append(names, i.Name())
+	}
+	require.ElementsMatch(t, []string{"a.txt", "c.txt", "d.txt", LockName}, names)
+
+	changes, err := d.Changes()
+	require.NoError(t, err)
+	require.Len(t, changes, 4)
+	require.Equal(t, UpdateOp, changes[0].Op)
+	require.Equal(t, filepath.Join(dir, "a.txt"), changes[0].Path)
+	require.Equal(t, RemoveOp, changes[1].Op)
+	require.Equal(t, CreateOp, changes[2].Op)
+	require.Equal(t, filepath.Join(dir, "c.txt"), changes[2].Path)
+	require.Equal(t, CreateOp, changes[3].Op)
+	require.Equal(t, filepath.Join(dir, "d.txt"), changes[3].Path)
+
+	// nothing is written to disk
+	data, err = ioutil.ReadFile(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+	_, err = os.Stat(filepath.Join(dir, "b.txt"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "c.txt"))
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, d.Close())
+	require.Nil(t, ActiveDryRun())
+	data, err = b.Read("a.txt")
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+}
+
+func TestDryRun_RemoveAndCreateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dry_run_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "x.txt"), []byte("x"), 0600))
+
+	d, err := StartDryRun()
+	require.NoError(t, err)
+	defer d.Close()
+
+	b := NewFSBackend(dir)
+	require.Error(t, b.Delete("a"))
+	require.NoError(t, fsys().removeAll(filepath.Join(dir, "a")))
+	require.False(t, b.Has(filepath.Join("a", "x.txt")))
+	require.NoError(t, b.Write(filepath.Join("a", "y.txt"), []byte("y")))
+	require.False(t, b.Has(filepath.Join("a", "x.txt")))
+	infos, err := b.List("a")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, "y.txt", infos[0].Name())
+
+	changes, err := d.Changes()
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, RemoveOp, changes[0].Op)
+	require.Equal(t, filepath.Join(dir, "a", "x.txt"), changes[0].Path)
+	require.Equal(t, CreateOp, changes[1].Op)
+	require.Equal(t, filepath.Join(dir, "a", "y.txt"), changes[1].Path)
+}
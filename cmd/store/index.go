@@ -0,0 +1,392 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/nats-io/jwt"
+)
+
+const IndexName = ".index.json"
+const IndexVersion = 1
+
+// IndexEntry describes the entity that uses a public key
+type IndexEntry struct {
+	// Key is the public key
+	Key string `json:"-"`
+	// Kind is the claim type of the entity - operator, account or user
+	Kind string `json:"kind"`
+	// Name is the name of the entity
+	Name string `json:"name"`
+	// Account is the name of the account for users
+	Account string `json:"account,omitempty"`
+	// Path is the store path to the JWT of the entity
+	Path string `json:"path"`
+	// Subject is set for signing keys to the identity key of the entity
+	Subject string `json:"subject,omitempty"`
+	// SigningKeys is set for identity keys to the signing keys of the entity
+	SigningKeys []string `json:"signing_keys,omitempty"`
+}
+
+// IsSigningKey returns true if the entry is for a signing key
+func (e *IndexEntry) IsSigningKey() bool {
+	return e.Subject != ""
+}
+
+// Keys returns the identity key of the entity followed by its signing keys
+func (e *IndexEntry) Keys() []string {
+	keys := []string{e.Key}
+	return append(keys, e.SigningKeys...)
+}
+
+// Index maps the public keys referenced by the JWTs in a store
+// to the entities that use them. The index lives in the store and
+// is updated as JWTs are written, so that lookups by key don't
+// require decoding every JWT in the store.
+type Index struct {
+	Version int                    `json:"version"`
+	Keys    map[string]*IndexEntry `json:"keys"`
+	// identity key of the JWT at a path
+	paths map[string]string
+}
+
+func NewIndex() *Index {
+	return &Index{Version: IndexVersion, Keys: make(map[string]*IndexEntry), paths: make(map[string]string)}
+}
+
+func parseIndex(data []byte) (*Index, error) {
+	x := NewIndex()
+	if err := json.Unmarshal(data, x); err != nil {
+		return nil, err
+	}
+	if x.Version != IndexVersion {
+		return nil, fmt.Errorf("unsupported index version %d", x.Version)
+	}
+	if x.Keys == nil {
+		x.Keys = make(map[string]*IndexEntry)
+	}
+	for k, e := range x.Keys {
+		e.Key = k
+		if !e.IsSigningKey() {
+			x.paths[e.Path] = k
+		}
+	}
+	return x, nil
+}
+
+func (x *Index) bytes() ([]byte, error) {
+	return json.MarshalIndent(x, "", " ")
+}
+
+// Lookup returns the entry for the specified public key or nil
+func (x *Index) Lookup(pub string) *IndexEntry {
+	return x.Keys[pub]
+}
+
+// Entity returns the entry of the identity key of the JWT at the specified
+// store path or nil
+func (x *Index) Entity(name ...string) *IndexEntry {
+	k, ok := x.paths[indexPath(name...)]
+	if !ok {
+		return nil
+	}
+	return x.Keys[k]
+}
+
+// Len returns the number of indexed keys
+func (x *Index) Len() int {
+	return len(x.Keys)
+}
+
+// List returns all the entries sorted by path and key
+func (x *Index) List() []*IndexEntry {
+	var entries []*IndexEntry
+	for _, e := range x.Keys {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Path == entries[j].Path {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+func indexPath(name ...string) string {
+	return filepath.ToSlash(filepath.Join(name...))
+}
+
+// remove drops the entries for the JWT at the specified path,
+// or for all the JWTs under it if the path is a directory
+func (x *Index) remove(fp string) {
+	p := indexPath(fp)
+	for k, e := range x.Keys {
+		if e.Path == p || strings.HasPrefix(e.Path, p+"/") {
+			delete(x.Keys, k)
+		}
+	}
+	for ep := range x.paths {
+		if ep == p || strings.HasPrefix(ep, p+"/") {
+			delete(x.paths, ep)
+		}
+	}
+}
+
+// update replaces the entries for the JWT stored at the specified path
+func (x *Index) update(fp string, data []byte) error {
+	x.remove(fp)
+	gc, err := jwt.DecodeGeneric(string(data))
+	if err != nil {
+		return err
+	}
+	p := indexPath(fp)
+	e := &IndexEntry{Key: gc.Subject, Kind: string(gc.Type), Name: gc.Name, Path: p}
+	switch gc.Type {
+	case jwt.OperatorClaim:
+		oc, err := jwt.DecodeOperatorClaims(string(data))
+		if err != nil {
+			return err
+		}
+		e.SigningKeys = oc.SigningKeys
+	case jwt.AccountClaim:
+		ac, err := jwt.DecodeAccountClaims(string(data))
+		if err != nil {
+			return err
+		}
+		e.SigningKeys = ac.SigningKeys
+	case jwt.UserClaim:
+		// users are stored in accounts/<account>/users/<user>.jwt
+		segs := strings.Split(p, "/")
+		if len(segs) == 4 && segs[0] == Accounts && segs[2] == Users {
+			e.Account = segs[1]
+		}
+	default:
+		return fmt.Errorf("unsupported claim type %q", gc.Type)
+	}
+	x.Keys[e.Key] = e
+	x.paths[p] = e.Key
+	for _, sk := range e.SigningKeys {
+		x.Keys[sk] = &IndexEntry{Key: sk, Kind: e.Kind, Name: e.Name, Account: e.Account, Path: p, Subject: e.Key}
+	}
+	return nil
+}
+
+// Index returns the index of public keys in the store. If the store
+// doesn't have an index, it is built.
+func (s *Store) Index() (*Index, error) {
+	s.indexMu.Lock()
+	x := s.readIndex()
+	s.indexMu.Unlock()
+	if x != nil {
+		return x, nil
+	}
+	if err := s.AcquireLock(); err != nil {
+		return nil, err
+	}
+	defer s.ReleaseLock()
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	if x = s.readIndex(); x != nil {
+		return x, nil
+	}
+	x, err := s.rebuildIndex()
+	if err != nil {
+		return nil, err
+	}
+	// a read-only store can still use the index
+	_ = s.saveIndex(x)
+	return x, nil
+}
+
+// readIndex returns the current index or nil if the store
+// doesn't have a valid one
+func (s *Store) readIndex() *Index {
+	if s.index != nil {
+		return s.index
+	}
+	if !s.Has(IndexName) {
+		return nil
+	}
+	d, err := s.Read(IndexName)
+	if err != nil {
+		return nil
+	}
+	x, err := parseIndex(d)
+	if err != nil {
+		return nil
+	}
+	s.index = x
+	return x
+}
+
+// RebuildIndex recreates the index of public keys from the JWTs in the store
+func (s *Store) RebuildIndex() (*Index, error) {
+	if err := s.AcquireLock(); err != nil {
+		return nil, err
+	}
+	defer s.ReleaseLock()
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	x, err := s.rebuildIndex()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveIndex(x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (s *Store) rebuildIndex() (*Index, error) {
+	x := NewIndex()
+	add := func(name ...string) error {
+		d, err := s.Read(name...)
+		if err != nil {
+			return err
+		}
+		// JWTs that cannot be decoded are not indexed
+		_ = x.update(filepath.Join(name...), d)
+		return nil
+	}
+	if fn := JwtName(s.GetName()); s.Has(fn) {
+		if err := add(fn); err != nil {
+			return nil, err
+		}
+	}
+	accounts, err := s.ListSubContainers(Accounts)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		if err := add(Accounts, a, JwtName(a)); err != nil {
+			return nil, err
+		}
+		users, err := s.ListEntries(Accounts, a, Users)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			if err := add(Accounts, a, Users, JwtName(u)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	s.index = x
+	return x, nil
+}
+
+func (s *Store) saveIndex(x *Index) error {
+	d, err := x.bytes()
+	if err != nil {
+		return err
+	}
+	if err := s.write(d, IndexName); err != nil {
+		return fmt.Errorf("error writing index: %v", err)
+	}
+	return nil
+}
+
+// updateIndex records the JWT written to fp, or forgets the entries
+// under fp if data is nil. The index is a cache - if it cannot be
+// updated it is dropped and rebuilt when needed.
+func (s *Store) updateIndex(fp string, data []byte) {
+	if !IsJwtName(fp) && data != nil {
+		return
+	}
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	x := s.readIndex()
+	if x == nil {
+		// built on first use
+		return
+	}
+	var err error
+	if data == nil {
+		x.remove(fp)
+	} else {
+		err = x.update(fp, data)
+	}
+	if err == nil {
+		err = s.saveIndex(x)
+	}
+	if err != nil {
+		s.index = nil
+		_ = s.delete(IndexName)
+	}
+}
+
+// LookupKey returns the entry for the entity using the specified public key,
+// nil if the key is not used by any operator, account or user JWT in the store.
+func (s *Store) LookupKey(pub string) (*IndexEntry, error) {
+	x, err := s.Index()
+	if err != nil {
+		return nil, err
+	}
+	e := x.Lookup(pub)
+	if e != nil && !s.isCurrent(e) {
+		// something modified the store behind our back
+		if x, err = s.RebuildIndex(); err != nil {
+			return nil, err
+		}
+		e = x.Lookup(pub)
+	}
+	return e, nil
+}
+
+// isCurrent returns true if the JWT at the path of the entry
+// still produces the entry
+func (s *Store) isCurrent(e *IndexEntry) bool {
+	fp := filepath.FromSlash(e.Path)
+	if !s.Has(fp) {
+		return false
+	}
+	d, err := s.Read(fp)
+	if err != nil {
+		return false
+	}
+	x := NewIndex()
+	if err := x.update(fp, d); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(x.Lookup(e.Key), e)
+}
+
+// IndexedEntity returns the index entry for the JWT at the specified path,
+// or nil if there's no JWT at the path.
+func (s *Store) IndexedEntity(name ...string) (*IndexEntry, error) {
+	if !s.Has(name...) {
+		return nil, nil
+	}
+	x, err := s.Index()
+	if err != nil {
+		return nil, err
+	}
+	if e := x.Entity(name...); e != nil && s.isCurrent(e) {
+		return e, nil
+	}
+	// the JWT was added or rewritten behind our back
+	if x, err = s.RebuildIndex(); err != nil {
+		return nil, err
+	}
+	return x.Entity(name...), nil
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestAccount(t *testing.T, okp nkeys.KeyPair, name string, signingKeys ...string) (string, nkeys.KeyPair, string) {
+	_, pub, kp := CreateAccountKey(t)
+	ac := jwt.NewAccountClaims(pub)
+	ac.Name = name
+	ac.SigningKeys.Add(signingKeys...)
+	token, err := ac.Encode(okp)
+	require.NoError(t, err)
+	return pub, kp, token
+}
+
+func encodeTestUser(t *testing.T, akp nkeys.KeyPair, name string) (string, string) {
+	_, pub, _ := CreateUserKey(t)
+	uc := jwt.NewUserClaims(pub)
+	uc.Name = name
+	token, err := uc.Encode(akp)
+	require.NoError(t, err)
+	return pub, token
+}
+
+func TestIndex_UpdatedOnWrite(t *testing.T) {
+	_, opub, okp := CreateOperatorKey(t)
+	s := CreateTestStoreForOperator(t, "O", okp)
+
+	_, spub, skp := CreateAccountKey(t)
+	apub, _, token := encodeTestAccount(t, okp, "A", spub)
+	require.NoError(t, s.StoreRaw([]byte(token)))
+	// users signed with the signing key are stored with the account
+	upub, token := encodeTestUser(t, skp, "U")
+	require.NoError(t, s.StoreRaw([]byte(token)))
+	require.True(t, s.Has(Accounts, "A", Users, JwtName("U")))
+
+	require.FileExists(t, filepath.Join(s.Dir, IndexName))
+	x, err := s.Index()
+	require.NoError(t, err)
+	require.Equal(t, 4, x.Len())
+	require.Equal(t, jwt.OperatorClaim, x.Lookup(opub).Kind)
+
+	e := x.Lookup(spub)
+	require.NotNil(t, e)
+	require.True(t, e.IsSigningKey())
+	require.Equal(t, "A", e.Name)
+	require.Equal(t, apub, e.Subject)
+
+	e = x.Entity(Accounts, "A", JwtName("A"))
+	require.NotNil(t, e)
+	require.Equal(t, []string{apub, spub}, e.Keys())
+
+	e = x.Lookup(upub)
+	require.NotNil(t, e)
+	require.Equal(t, jwt.UserClaim, e.Kind)
+	require.Equal(t, "A", e.Account)
+	require.Equal(t, "accounts/A/users/U.jwt", e.Path)
+
+	// the index is persisted
+	ss, err := LoadStore(s.Dir)
+	require.NoError(t, err)
+	x, err = ss.Index()
+	require.NoError(t, err)
+	require.Equal(t, 4, x.Len())
+
+	require.NoError(t, ss.Delete(Accounts, "A", Users, JwtName("U")))
+	require.Nil(t, x.Lookup(upub))
+	require.Equal(t, 3, x.Len())
+}
+
+func TestIndex_ReadAccountClaimByKey(t *testing.T) {
+	_, _, okp := CreateOperatorKey(t)
+	s := CreateTestStoreForOperator(t, "O", okp)
+
+	_, spub, _ := CreateAccountKey(t)
+	apub, _, token := encodeTestAccount(t, okp, "A", spub)
+	require.NoError(t, s.StoreRaw([]byte(token)))
+
+	ac, err := s.ReadAccountClaim(apub)
+	require.NoError(t, err)
+	require.Equal(t, "A", ac.Name)
+
+	ac, err = s.ReadAccountClaim(spub)
+	require.NoError(t, err)
+	require.Equal(t, "A", ac.Name)
+
+	_, bpub, _ := CreateAccountKey(t)
+	_, err = s.ReadAccountClaim(bpub)
+	require.Error(t, err)
+	require.True(t, IsNotExist(err))
+}
+
+func TestIndex_Stale(t *testing.T) {
+	_, _, okp := CreateOperatorKey(t)
+	s := CreateTestStoreForOperator(t, "O", okp)
+
+	apub, akp, token := encodeTestAccount(t, okp, "A")
+	require.NoError(t, s.StoreRaw([]byte(token)))
+	x, err := s.Index()
+	require.NoError(t, err)
+	require.NotNil(t, x.Lookup(apub))
+
+	// add an account behind the store's back
+	bpub, bkp, token := encodeTestAccount(t, okp, "B")
+	require.NoError(t, os.MkdirAll(filepath.Join(s.Dir, Accounts, "B"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(s.Dir, Accounts, "B", JwtName("B")), []byte(token), 0600))
+	// and remove one
+	require.NoError(t, os.RemoveAll(filepath.Join(s.Dir, Accounts, "A")))
+
+	s, err = LoadStore(s.Dir)
+	require.NoError(t, err)
+	e, err := s.LookupKey(apub)
+	require.NoError(t, err)
+	require.Nil(t, e)
+
+	ctx, err := s.GetContext()
+	require.NoError(t, err)
+	keys, err := ctx.GetAccountKeys("B")
+	require.NoError(t, err)
+	require.Equal(t, []string{bpub}, keys)
+
+	// a user for the unindexed account can be stored
+	_, token = encodeTestUser(t, bkp, "U")
+	require.NoError(t, s.StoreRaw([]byte(token)))
+	require.True(t, s.Has(Accounts, "B", Users, JwtName("U")))
+
+	_, token = encodeTestUser(t, akp, "V")
+	require.Error(t, s.StoreRaw([]byte(token)))
+}
+
+func TestIndex_RewrittenInPlace(t *testing.T) {
+	_, _, okp := CreateOperatorKey(t)
+	s := CreateTestStoreForOperator(t, "O", okp)
+
+	_, spub, _ := CreateAccountKey(t)
+	apub, _, token := encodeTestAccount(t, okp, "A", spub)
+	require.NoError(t, s.StoreRaw([]byte(token)))
+	e, err := s.LookupKey(spub)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+
+	// rewrite the account behind the store's back with another signing key
+	_, tpub, _ := CreateAccountKey(t)
+	ac := jwt.NewAccountClaims(apub)
+	ac.Name = "A"
+	ac.SigningKeys.Add(tpub)
+	token, err = ac.Encode(okp)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(s.Dir, Accounts, "A", JwtName("A")), []byte(token), 0600))
+
+	s, err = LoadStore(s.Dir)
+	require.NoError(t, err)
+	e, err = s.LookupKey(spub)
+	require.NoError(t, err)
+	require.Nil(t, e)
+	e, err = s.LookupKey(tpub)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	require.Equal(t, apub, e.Subject)
+
+	e, err = s.IndexedEntity(Accounts, "A", JwtName("A"))
+	require.NoError(t, err)
+	require.Equal(t, []string{apub, tpub}, e.Keys())
+}
+
+func TestIndex_Corrupt(t *testing.T) {
+	_, _, okp := CreateOperatorKey(t)
+	s := CreateTestStoreForOperator(t, "O", okp)
+	apub, _, token := encodeTestAccount(t, okp, "A")
+	require.NoError(t, s.StoreRaw([]byte(token)))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(s.Dir, IndexName), []byte("garbage"), 0600))
+	s, err := LoadStore(s.Dir)
+	require.NoError(t, err)
+	e, err := s.LookupKey(apub)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	require.Equal(t, "A", e.Name)
+}
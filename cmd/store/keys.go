@@ -35,6 +35,7 @@ const NKeyExtension = ".nk"
 const CredsExtension = ".creds"
 const CredsDir = "creds"
 const KeysDir = "keys"
+const TrashDir = "trash"
 
 type NamedKey struct {
 	Name string
@@ -64,6 +65,9 @@ func ResolveKey(value string) (nkeys.KeyPair, error) {
 	if value == "" {
 		return nil, nil
 	}
+	if IsSignerRef(value) {
+		return ResolveSignerRef(value)
+	}
 	d := []byte(value)
 	kp, err := resolveAsKey(d)
 	if err != nil {
@@ -77,12 +81,28 @@ func ResolveKey(value string) (nkeys.KeyPair, error) {
 
 type KeyStore struct {
 	Env string
+	// Dir if set is the directory of the keystore, otherwise the keystore
+	// is in GetKeysDir(). Keys in a keystore in Dir are not encrypted.
+	Dir string
 }
 
 func NewKeyStore(environmentName string) KeyStore {
 	return KeyStore{Env: environmentName}
 }
 
+// root returns the directory of the keystore
+func (k *KeyStore) root() string {
+	if k.Dir != "" {
+		return k.Dir
+	}
+	return GetKeysDir()
+}
+
+// encrypted returns true if keys are stored encrypted
+func (k *KeyStore) encrypted() bool {
+	return k.Dir == "" && IsEncryptedKeyStore()
+}
+
 func IsOldKeyRing(dir string) (bool, error) {
 	var err error
 	dir, err = homedir.Expand(dir)
@@ -104,8 +124,8 @@ func IsOldKeyRing(dir string) (bool, error) {
 		if err != nil {
 			return err
 		}
-		// this is the keys/creds dir - ignore them
-		if (rel == KeysDir || rel == CredsDir) && info.IsDir() {
+		// this is the keys/creds/trash dir - ignore them
+		if (rel == KeysDir || rel == CredsDir || rel == TrashDir) && info.IsDir() {
 			// walking new dirs
 			return filepath.SkipDir
 		}
@@ -168,7 +188,12 @@ func Migrate() (string, error) {
 
 func (k *KeyStore) AllKeys() ([]string, error) {
 	var keys []string
-	err := filepath.Walk(GetKeysDir(), func(src string, info os.FileInfo, err error) error {
+	dir := k.root()
+	err := filepath.Walk(dir, func(src string, info os.FileInfo, err error) error {
+		// deleted keys are not listed
+		if info != nil && info.IsDir() && src == filepath.Join(dir, TrashDir) {
+			return filepath.SkipDir
+		}
 		ext := filepath.Ext(src)
 		switch ext {
 		case NKeyExtension:
@@ -180,6 +205,18 @@ func (k *KeyStore) AllKeys() ([]string, error) {
 	return keys, err
 }
 
+// lock prevents other processes from modifying the keystore until
+// the returned function is called
+func (k *KeyStore) lock() (func(), error) {
+	fp := filepath.Join(k.root(), LockName)
+	if err := AcquireFileLock(fp); err != nil {
+		return nil, fmt.Errorf("unable to lock the keystore: %v", err)
+	}
+	return func() {
+		_ = ReleaseFileLock(fp)
+	}, nil
+}
+
 func (k *KeyStore) credsName(n string) string {
 	return fmt.Sprintf("%s%s", n, CredsExtension)
 }
@@ -189,12 +226,12 @@ func (k *KeyStore) keyName(n string) string {
 }
 
 func (k *KeyStore) CalcUserCredsPath(account string, user string) string {
-	return filepath.Join(GetKeysDir(), CredsDir, k.Env, account, k.credsName(user))
+	return filepath.Join(k.root(), CredsDir, k.Env, account, k.credsName(user))
 }
 
 func (k *KeyStore) GetUserCredsPath(account string, user string) string {
 	fp := k.CalcUserCredsPath(account, user)
-	if _, err := os.Stat(fp); err != nil {
+	if _, err := fsys().stat(fp); err != nil {
 		return ""
 	}
 	return fp
@@ -211,13 +248,22 @@ func (k *KeyStore) MaybeStoreUserCreds(account string, user string, data []byte)
 		return "", fmt.Errorf("unable to get the public key from the seed in the creds: %v", err)
 	}
 
-	_, err = k.GetKeyPair(pk)
-	if os.IsNotExist(err) {
-		return "", errors.New("unable to store creds file - user's seed file is not in the keystore")
+	// encrypted keys are not opened to check them
+	if k.sealedKey(pk) == nil {
+		_, err = k.GetKeyPair(pk)
+		if os.IsNotExist(err) {
+			return "", errors.New("unable to store creds file - user's seed file is not in the keystore")
+		}
+		if err != nil {
+			return "", fmt.Errorf("unable to store creds file - error examining user's seed file: %v", err)
+		}
 	}
+
+	unlock, err := k.lock()
 	if err != nil {
-		return "", fmt.Errorf("unable to store creds file - error examining user's seed file: %v", err)
+		return "", err
 	}
+	defer unlock()
 
 	fp := k.CalcUserCredsPath(account, user)
 	dir := filepath.Dir(fp)
@@ -225,7 +271,62 @@ func (k *KeyStore) MaybeStoreUserCreds(account string, user string, data []byte)
 		return "", err
 	}
 
-	return fp, ioutil.WriteFile(fp, data, 0600)
+	return fp, fsys().writeFile(fp, data)
+}
+
+// MoveUserCreds moves the creds file for a user to the path for the new
+// account and user names. The path to the moved file is returned, or an
+// empty string if the user didn't have a creds file.
+func (k *KeyStore) MoveUserCreds(account string, user string, newAccount string, newUser string) (string, error) {
+	unlock, err := k.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	from := k.GetUserCredsPath(account, user)
+	if from == "" {
+		return "", nil
+	}
+	to := k.CalcUserCredsPath(newAccount, newUser)
+	if _, err := fsys().stat(to); err == nil {
+		return "", fmt.Errorf("creds file %#q already exists", to)
+	}
+	if err := MaybeMakeDir(filepath.Dir(to)); err != nil {
+		return "", err
+	}
+	if err := fsys().rename(from, to); err != nil {
+		return "", err
+	}
+	removeIfEmpty(filepath.Dir(from))
+	return to, nil
+}
+
+// RemoveUserCreds removes the creds file for a user if it exists
+func (k *KeyStore) RemoveUserCreds(account string, user string) error {
+	unlock, err := k.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	fp := k.GetUserCredsPath(account, user)
+	if fp == "" {
+		return nil
+	}
+	if err := fsys().remove(fp); err != nil {
+		return err
+	}
+	removeIfEmpty(filepath.Dir(fp))
+	return nil
+}
+
+// removeIfEmpty attempts to remove an empty directory
+func removeIfEmpty(dir string) {
+	infos, err := fsys().readDir(dir)
+	if err == nil && len(infos) == 0 {
+		_ = fsys().remove(dir)
+	}
 }
 
 func (k *KeyStore) keypath(kp nkeys.KeyPair) (string, error) {
@@ -255,7 +356,7 @@ func (k *KeyStore) GetKeyPath(pubkey string) string {
 	}
 	kind := pubkey[0:1]
 	shard := pubkey[1:3]
-	return filepath.Join(GetKeysDir(), KeysDir, kind, shard, fmt.Sprintf("%s%s", pubkey, NKeyExtension))
+	return filepath.Join(k.root(), KeysDir, kind, shard, fmt.Sprintf("%s%s", pubkey, NKeyExtension))
 }
 
 func (k *KeyStore) GetKeyPair(pubkey string) (nkeys.KeyPair, error) {
@@ -263,28 +364,59 @@ func (k *KeyStore) GetKeyPair(pubkey string) (nkeys.KeyPair, error) {
 }
 
 func (k *KeyStore) GetPublicKey(pubkey string) (string, error) {
+	if sk := k.sealedKey(pubkey); sk != nil {
+		return sk.PublicKey, nil
+	}
 	return k.getPublicKey(k.GetKeyPair(pubkey))
 }
 
+// sealedKey returns the encrypted key for the public key if it is in the keystore
+func (k *KeyStore) sealedKey(pubkey string) *SealedKey {
+	d, err := fsys().readFile(k.GetKeyPath(pubkey))
+	if err != nil || !IsSealed(d) {
+		return nil
+	}
+	sk, err := parseSealed(d)
+	if err != nil {
+		return nil
+	}
+	return sk
+}
+
 func (k *KeyStore) HasPrivateKey(pubkey string) bool {
+	// encrypted keys are checked without the passphrase
+	if sk := k.sealedKey(pubkey); sk != nil {
+		return sk.PublicKey == pubkey
+	}
 	kp, err := k.GetKeyPair(pubkey)
 	if kp == nil || err != nil {
 		return false
 	}
+	if IsAgentKey(kp) {
+		return true
+	}
 	_, err = kp.Seed()
 	return err == nil
 }
 
 func (k *KeyStore) GetSeed(pubkey string) (string, error) {
-	return k.getSeed(k.GetKeyPair(pubkey))
+	// the agent doesn't hand out seeds, they are read from the file
+	d, err := dataFromFile(k.GetKeyPath(pubkey))
+	if err != nil {
+		return "", err
+	}
+	return k.getSeed(resolveAsKey(d))
 }
 
 func (k *KeyStore) getPublicKey(kp nkeys.KeyPair, err error) (string, error) {
+	if err != nil || kp == nil {
+		return "", err
+	}
 	return kp.PublicKey()
 }
 
 func (k *KeyStore) getSeed(kp nkeys.KeyPair, err error) (string, error) {
-	if err != nil {
+	if err != nil || kp == nil {
 		return "", err
 	}
 	d, err := kp.Seed()
@@ -295,36 +427,42 @@ func (k *KeyStore) getSeed(kp nkeys.KeyPair, err error) (string, error) {
 }
 
 func (k *KeyStore) Remove(pubkey string) error {
+	unlock, err := k.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	kp := k.GetKeyPath(pubkey)
-	_, err := os.Stat(kp)
+	_, err = fsys().stat(kp)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
 		return err
 	}
-	if err := os.Remove(kp); err != nil {
+	if err := fsys().remove(kp); err != nil {
 		return err
 	}
 	pd := filepath.Dir(kp)
-	infos, err := ioutil.ReadDir(pd)
+	infos, err := fsys().readDir(pd)
 	// nothing to do from here, but attempt to cleanup
 	// empty directories - go won't delete empty dirs
 	// but we check anyway
 	if err == nil && len(infos) == 0 {
-		os.Remove(pd)
+		fsys().remove(pd)
 	}
 	return nil
 }
 
 func AddGitIgnore(dir string) error {
 	if dir != "" {
-		_, err := os.Stat(dir)
+		_, err := fsys().stat(dir)
 		if err != nil {
 			return nil
 		}
 		ignoreFile := filepath.Join(dir, ".gitignore")
-		_, err = os.Stat(ignoreFile)
+		_, err = fsys().stat(ignoreFile)
 		if os.IsNotExist(err) {
 			d := `# ignore all nk files 
 **/*.nk
@@ -332,16 +470,22 @@ func AddGitIgnore(dir string) error {
 # ignore all creds files
 **/*.creds
 `
-			return ioutil.WriteFile(ignoreFile, []byte(d), 0600)
+			return fsys().writeFile(ignoreFile, []byte(d))
 		}
 	}
 	return nil
 }
 
 func (k *KeyStore) Store(kp nkeys.KeyPair) (string, error) {
-	if err := makeKeyStore(GetKeysDir()); err != nil {
+	if err := makeKeyStore(k.root()); err != nil {
 		return "", err
 	}
+	unlock, err := k.lock()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
 	fp, err := k.keypath(kp)
 	if err != nil {
 		return "", err
@@ -356,10 +500,20 @@ func (k *KeyStore) Store(kp nkeys.KeyPair) (string, error) {
 		return "", err
 	}
 
-	_, err = os.Stat(fp)
+	_, err = fsys().stat(fp)
 	if err != nil {
 		if os.IsNotExist(err) {
-			err := ioutil.WriteFile(fp, seed, 0600)
+			data := seed
+			if k.encrypted() {
+				pub, err := kp.PublicKey()
+				if err != nil {
+					return "", err
+				}
+				if data, err = sealSeed(pub, seed); err != nil {
+					return "", fmt.Errorf("error encrypting %#q: %v", fp, err)
+				}
+			}
+			err := fsys().writeFile(fp, data)
 			if err != nil {
 				return "", fmt.Errorf("error writing %#q: %v", fp, err)
 			}
@@ -367,7 +521,7 @@ func (k *KeyStore) Store(kp nkeys.KeyPair) (string, error) {
 		}
 	}
 
-	d, err := ioutil.ReadFile(fp)
+	d, err := dataFromFile(fp)
 	if err != nil {
 		return "", fmt.Errorf("error reading %#q: %v", fp, err)
 	}
@@ -403,14 +557,21 @@ func dataFromFile(path string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if _, err := os.Stat(path); err != nil {
+	if _, err := fsys().stat(path); err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
 		} else {
 			return nil, err
 		}
 	}
-	return ioutil.ReadFile(path)
+	d, err := fsys().readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if IsSealed(d) {
+		return openSeed(d)
+	}
+	return d, nil
 }
 
 func keyFromFile(path string) (nkeys.KeyPair, error) {
@@ -421,6 +582,9 @@ func keyFromFile(path string) (nkeys.KeyPair, error) {
 	if err != nil {
 		return nil, err
 	}
+	if kp := agentKeyForFile(path); kp != nil {
+		return kp, nil
+	}
 	d, err := dataFromFile(path)
 	if err != nil {
 		return nil, err
@@ -432,6 +596,27 @@ func keyFromFile(path string) (nkeys.KeyPair, error) {
 	return kp, nil
 }
 
+// agentKeyForFile returns a key pair signing with the key agent if the
+// file is encrypted or missing, and the agent holds the key
+func agentKeyForFile(path string) nkeys.KeyPair {
+	d, err := fsys().readFile(path)
+	if err == nil {
+		if !IsSealed(d) {
+			return nil
+		}
+		sk, err := parseSealed(d)
+		if err != nil {
+			return nil
+		}
+		return agentKeyPair(sk.PublicKey)
+	}
+	n := filepath.Base(path)
+	if os.IsNotExist(err) && filepath.Ext(n) == NKeyExtension {
+		return agentKeyPair(strings.TrimSuffix(n, NKeyExtension))
+	}
+	return nil
+}
+
 func resolveAsKey(d []byte) (nkeys.KeyPair, error) {
 	if d == nil {
 		return nil, nil
@@ -504,7 +689,7 @@ func KeyType(kp nkeys.KeyPair) (nkeys.PrefixByte, error) {
 }
 
 func dirExists(fp string) (bool, error) {
-	fi, err := os.Stat(fp)
+	fi, err := fsys().stat(fp)
 	if os.IsNotExist(err) {
 		return false, nil
 	}
@@ -627,9 +812,9 @@ func migrateCreds(ksroot string, src string, to string) (string, error) {
 }
 
 func MaybeMakeDir(dir string) error {
-	fi, err := os.Stat(dir)
+	fi, err := fsys().stat(dir)
 	if err != nil && os.IsNotExist(err) {
-		if err := os.MkdirAll(dir, 0700); err != nil {
+		if err := fsys().mkdirAll(dir); err != nil {
 			return fmt.Errorf("error creating %#q: %v", dir, err)
 		}
 	} else if err != nil {
@@ -644,7 +829,7 @@ func Write(name string, data []byte) error {
 	if err := MaybeMakeDir(filepath.Dir(name)); err != nil {
 		return err
 	}
-	return ioutil.WriteFile(name, data, 0600)
+	return fsys().writeFile(name, data)
 }
 
 func ExtractSeed(s string) (nkeys.KeyPair, error) {
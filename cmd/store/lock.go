@@ -0,0 +1,319 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const LockName = ".nsc.lock"
+const LockTimeoutEnv = "NSC_LOCK_TIMEOUT"
+const LockStaleEnv = "NSC_LOCK_STALE"
+
+// LockTimeout is how long to wait for a lock held by another process
+var LockTimeout = durationFromEnv(LockTimeoutEnv, 30*time.Second)
+
+// LockStaleAfter is the age after which a lock held by a process on
+// a different host is considered abandoned. Locks held by processes on
+// this host are stale as soon as the process exits.
+var LockStaleAfter = durationFromEnv(LockStaleEnv, 10*time.Minute)
+
+var ErrLockTimeout = errors.New("timeout waiting for lock")
+
+// lockGuardStale is the age after which the guard of a lock is left by
+// a process that crashed, guards are only held while a lock is replaced
+// or removed
+const lockGuardStale = 10 * time.Second
+
+// locks held by this process - locks are re-entrant within a process,
+// goroutines must synchronize with the mutexes on Store
+var heldLocks = struct {
+	sync.Mutex
+	m map[string]*heldLock
+}{m: make(map[string]*heldLock)}
+
+type heldLock struct {
+	n    int
+	data []byte
+}
+
+type lockInfo struct {
+	Pid   int       `json:"pid"`
+	Host  string    `json:"host"`
+	Time  time.Time `json:"time"`
+	Nonce string    `json:"nonce,omitempty"`
+}
+
+func durationFromEnv(name string, d time.Duration) time.Duration {
+	if v := os.Getenv(name); v != "" {
+		if vd, err := time.ParseDuration(v); err == nil {
+			return vd
+		}
+	}
+	return d
+}
+
+func hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// on windows finding the process is enough
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// isStale returns true if the lock at fp was left by a process that is
+// gone, the contents of the lock are returned so that the lock can be
+// replaced only if it wasn't replaced in the meantime
+func isStale(fp string) (bool, []byte) {
+	fi, err := os.Stat(fp)
+	if err != nil {
+		return false, nil
+	}
+	d, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return false, nil
+	}
+	var li lockInfo
+	if err := json.Unmarshal(d, &li); err != nil {
+		// the holder may not have written the lock yet
+		return time.Since(fi.ModTime()) > time.Second*5, d
+	}
+	if li.Host == hostname() {
+		return !processAlive(li.Pid), d
+	}
+	return time.Since(li.Time) > LockStaleAfter, d
+}
+
+// createLock creates the lock file at fp with the contents d, failing
+// if the file exists
+func createLock(fp string, d []byte) error {
+	f, err := os.OpenFile(fp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(d)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fp)
+		return fmt.Errorf("error writing lock %#q: %v", fp, err)
+	}
+	return nil
+}
+
+// tryGuard takes the guard of the lock at fp. Replacing a stale lock and
+// releasing a lock are done holding the guard, so that the contents of
+// the lock can be checked before it is changed.
+func tryGuard(fp string, d []byte) (func(), bool, error) {
+	g := fp + ".guard"
+	err := createLock(g, d)
+	if err == nil {
+		return func() { os.Remove(g) }, true, nil
+	}
+	if !os.IsExist(err) {
+		return nil, false, fmt.Errorf("error creating lock guard %#q: %v", g, err)
+	}
+	if fi, err := os.Stat(g); err == nil && time.Since(fi.ModTime()) > lockGuardStale {
+		os.Remove(g)
+	}
+	return nil, false, nil
+}
+
+// guard takes the guard of the lock at fp, waiting up to LockTimeout
+func guard(fp string, d []byte) (func(), error) {
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		release, ok, err := tryGuard(fp, d)
+		if ok || err != nil {
+			return release, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%v %#q", ErrLockTimeout, fp+".guard")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// replaceStale replaces the stale lock at fp with the contents stale by
+// the lock d. The lock is replaced by renaming a new lock over it, so the
+// lock always exists, and only if it still has the stale contents.
+func replaceStale(fp string, stale []byte, d []byte) (bool, error) {
+	release, ok, err := tryGuard(fp, d)
+	if !ok || err != nil {
+		return false, err
+	}
+	defer release()
+	cur, err := ioutil.ReadFile(fp)
+	if os.IsNotExist(err) {
+		if err := createLock(fp, d); err != nil {
+			if os.IsExist(err) {
+				return false, nil
+			}
+			return false, fmt.Errorf("error creating lock %#q: %v", fp, err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading lock %#q: %v", fp, err)
+	}
+	if !bytes.Equal(cur, stale) {
+		return false, nil
+	}
+	tmp := fmt.Sprintf("%s.%d.%d.tmp", fp, os.Getpid(), time.Now().UnixNano())
+	if err := createLock(tmp, d); err != nil {
+		return false, fmt.Errorf("error replacing stale lock %#q: %v", fp, err)
+	}
+	if err := os.Rename(tmp, fp); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("error replacing stale lock %#q: %v", fp, err)
+	}
+	return true, nil
+}
+
+func lockHolder(fp string) string {
+	d, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return "unknown process"
+	}
+	var li lockInfo
+	if err := json.Unmarshal(d, &li); err != nil {
+		return "unknown process"
+	}
+	return fmt.Sprintf("process %d on %q since %s", li.Pid, li.Host, li.Time.Format(time.RFC3339))
+}
+
+// AcquireFileLock takes an advisory lock on the lock file at fp,
+// waiting up to LockTimeout for other processes to release it.
+// Locks held by crashed processes are replaced. Dry runs don't write
+// to disk, so they don't take locks.
+func AcquireFileLock(fp string) error {
+	if ActiveDryRun() != nil {
+		return nil
+	}
+	if err := MaybeMakeDir(filepath.Dir(fp)); err != nil {
+		return err
+	}
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	d, err := json.Marshal(lockInfo{Pid: os.Getpid(), Host: hostname(), Time: time.Now().UTC(), Nonce: hex.EncodeToString(nonce)})
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		ok, err := tryFileLock(fp, d)
+		if ok || err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%v %#q held by %s", ErrLockTimeout, fp, lockHolder(fp))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// tryFileLock takes the lock at fp if it is free, stale or already held
+// by this process
+func tryFileLock(fp string, d []byte) (bool, error) {
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	if h := heldLocks.m[fp]; h != nil {
+		h.n++
+		return true, nil
+	}
+	err := createLock(fp, d)
+	if err != nil && !os.IsExist(err) {
+		return false, fmt.Errorf("error creating lock %#q: %v", fp, err)
+	}
+	if err != nil {
+		stale, sd := isStale(fp)
+		if !stale {
+			return false, nil
+		}
+		ok, err := replaceStale(fp, sd, d)
+		if !ok || err != nil {
+			return false, err
+		}
+	}
+	heldLocks.m[fp] = &heldLock{n: 1, data: d}
+	return true, nil
+}
+
+// ReleaseFileLock releases a lock taken with AcquireFileLock. The lock
+// is removed only if it is still the lock taken by this process.
+func ReleaseFileLock(fp string) error {
+	if ActiveDryRun() != nil {
+		return nil
+	}
+	heldLocks.Lock()
+	h := heldLocks.m[fp]
+	if h == nil {
+		heldLocks.Unlock()
+		return fmt.Errorf("lock %#q is not held", fp)
+	}
+	if h.n > 1 {
+		h.n--
+		heldLocks.Unlock()
+		return nil
+	}
+	delete(heldLocks.m, fp)
+	heldLocks.Unlock()
+
+	release, err := guard(fp, h.data)
+	if err != nil {
+		return fmt.Errorf("error releasing lock %#q: %v", fp, err)
+	}
+	defer release()
+	cur, err := ioutil.ReadFile(fp)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error releasing lock %#q: %v", fp, err)
+	}
+	if !bytes.Equal(cur, h.data) {
+		return fmt.Errorf("lock %#q was replaced by %s", fp, lockHolder(fp))
+	}
+	if err := os.Remove(fp); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error releasing lock %#q: %v", fp, err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeLockInfo(t *testing.T, fp string, li lockInfo) {
+	d, err := json.Marshal(li)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(fp, d, 0600))
+}
+
+func TestLock_Reentrant(t *testing.T) {
+	fp := filepath.Join(MakeTempDir(t), LockName)
+	require.NoError(t, AcquireFileLock(fp))
+	require.FileExists(t, fp)
+	require.NoError(t, AcquireFileLock(fp))
+
+	require.NoError(t, ReleaseFileLock(fp))
+	require.FileExists(t, fp)
+	require.NoError(t, ReleaseFileLock(fp))
+	_, err := os.Stat(fp)
+	require.True(t, os.IsNotExist(err))
+
+	require.Error(t, ReleaseFileLock(fp))
+}
+
+func TestLock_Timeout(t *testing.T) {
+	old := LockTimeout
+	LockTimeout = 200 * time.Millisecond
+	defer func() { LockTimeout = old }()
+
+	fp := filepath.Join(MakeTempDir(t), LockName)
+	// the parent of the test is alive
+	writeLockInfo(t, fp, lockInfo{Pid: os.Getppid(), Host: hostname(), Time: time.Now()})
+
+	err := AcquireFileLock(fp)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrLockTimeout.Error())
+}
+
+func TestLock_StaleProcess(t *testing.T) {
+	fp := filepath.Join(MakeTempDir(t), LockName)
+	// pids wrap well before this
+	writeLockInfo(t, fp, lockInfo{Pid: 1 << 30, Host: hostname(), Time: time.Now()})
+
+	require.NoError(t, AcquireFileLock(fp))
+	require.NoError(t, ReleaseFileLock(fp))
+}
+
+func TestLock_StaleRemoteHost(t *testing.T) {
+	old := LockTimeout
+	LockTimeout = 200 * time.Millisecond
+	defer func() { LockTimeout = old }()
+
+	fp := filepath.Join(MakeTempDir(t), LockName)
+	writeLockInfo(t, fp, lockInfo{Pid: 1, Host: "elsewhere", Time: time.Now()})
+	require.Error(t, AcquireFileLock(fp))
+
+	writeLockInfo(t, fp, lockInfo{Pid: 1, Host: "elsewhere", Time: time.Now().Add(-2 * LockStaleAfter)})
+	require.NoError(t, AcquireFileLock(fp))
+	require.NoError(t, ReleaseFileLock(fp))
+}
+
+func TestLock_StoreWrite(t *testing.T) {
+	old := LockTimeout
+	LockTimeout = 200 * time.Millisecond
+	defer func() { LockTimeout = old }()
+
+	s := CreateTestStore(t, "O")
+	fp := filepath.Join(s.Dir, LockName)
+
+	require.NoError(t, s.AcquireLock())
+	require.NoError(t, s.Write([]byte("foo"), "foo"))
+	require.FileExists(t, fp)
+	require.NoError(t, s.ReleaseLock())
+	_, err := os.Stat(fp)
+	require.True(t, os.IsNotExist(err))
+
+	writeLockInfo(t, fp, lockInfo{Pid: os.Getppid(), Host: hostname(), Time: time.Now()})
+	require.Error(t, s.Write([]byte("bar"), "foo"))
+	d, err := s.Read("foo")
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(d))
+}
+
+func TestLock_StaleReplaced(t *testing.T) {
+	fp := filepath.Join(MakeTempDir(t), LockName)
+	writeLockInfo(t, fp, lockInfo{Pid: 1 << 30, Host: hostname(), Time: time.Now()})
+	stale, d := isStale(fp)
+	require.True(t, stale)
+
+	// another process replaced the stale lock
+	writeLockInfo(t, fp, lockInfo{Pid: os.Getppid(), Host: hostname(), Time: time.Now()})
+	ok, err := replaceStale(fp, d, []byte("{}"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	stale, _ = isStale(fp)
+	require.False(t, stale)
+	infos, err := ioutil.ReadDir(filepath.Dir(fp))
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+}
+
+func TestLock_StaleGuarded(t *testing.T) {
+	fp := filepath.Join(MakeTempDir(t), LockName)
+	writeLockInfo(t, fp, lockInfo{Pid: 1 << 30, Host: hostname(), Time: time.Now()})
+	_, d := isStale(fp)
+
+	// another process is replacing the stale lock
+	release, ok, err := tryGuard(fp, []byte("{}"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = replaceStale(fp, d, []byte("{}"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	release()
+
+	ok, err = replaceStale(fp, d, []byte(`{"pid":1}`))
+	require.NoError(t, err)
+	require.True(t, ok)
+	cur, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	require.Equal(t, `{"pid":1}`, string(cur))
+}
+
+func TestLock_ReleaseKeepsReplacedLock(t *testing.T) {
+	fp := filepath.Join(MakeTempDir(t), LockName)
+	require.NoError(t, AcquireFileLock(fp))
+	// the lock was found stale and replaced by another process
+	writeLockInfo(t, fp, lockInfo{Pid: os.Getppid(), Host: hostname(), Time: time.Now()})
+
+	err := ReleaseFileLock(fp)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "was replaced")
+	require.FileExists(t, fp)
+	// the guard was released
+	_, err = os.Stat(fp + ".guard")
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestLock_WaitDoesntBlockOtherLocks(t *testing.T) {
+	old := LockTimeout
+	LockTimeout = time.Second
+	defer func() { LockTimeout = old }()
+
+	dir := MakeTempDir(t)
+	held := filepath.Join(dir, "held.lock")
+	writeLockInfo(t, held, lockInfo{Pid: os.Getppid(), Host: hostname(), Time: time.Now()})
+	waiting := make(chan error)
+	go func() {
+		waiting <- AcquireFileLock(held)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	fp := filepath.Join(dir, LockName)
+	require.NoError(t, AcquireFileLock(fp))
+	require.NoError(t, ReleaseFileLock(fp))
+	require.True(t, time.Since(start) < LockTimeout/2)
+	require.Error(t, <-waiting)
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Shamir's secret sharing over GF(2^8). A share is the x coordinate
+// followed by the value of a random polynomial at x for every byte of
+// the secret. The constant terms of the polynomials are the secret.
+
+var gfExp [510]byte
+var gfLog [256]byte
+
+func init() {
+	// 3 generates the multiplicative group of GF(2^8) with the AES polynomial
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfExp[i+255] = x
+		gfLog[x] = byte(i)
+		x = gfMulSlow(x, 3)
+	}
+}
+
+func gfMulSlow(a byte, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		hi := a & 0x80
+		a <<= 1
+		if hi != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfMul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a byte, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// SplitSecret splits the secret into n shares, any threshold of them
+// reconstruct the secret
+func SplitSecret(secret []byte, n int, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("the secret is empty")
+	}
+	if threshold < 2 {
+		return nil, errors.New("the threshold must be at least 2")
+	}
+	if n < threshold {
+		return nil, fmt.Errorf("the number of shares must be at least the threshold %d", threshold)
+	}
+	if n > 255 {
+		return nil, errors.New("the number of shares cannot exceed 255")
+	}
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+	coeffs := make([]byte, threshold-1)
+	for j, s := range secret {
+		if _, err := rand.Read(coeffs); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			x := shares[i][0]
+			// Horner's method, the secret is the constant term
+			var y byte
+			for k := len(coeffs) - 1; k >= 0; k-- {
+				y = gfMul(y, x) ^ coeffs[k]
+			}
+			shares[i][j+1] = gfMul(y, x) ^ s
+		}
+	}
+	return shares, nil
+}
+
+// CombineShares reconstructs the secret from the shares. With fewer
+// shares than the threshold the result is not the secret.
+func CombineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least 2 shares are required")
+	}
+	size := len(shares[0])
+	if size < 2 {
+		return nil, errors.New("invalid share")
+	}
+	seen := make(map[byte]bool)
+	for _, s := range shares {
+		if len(s) != size {
+			return nil, errors.New("shares have different lengths")
+		}
+		if s[0] == 0 || seen[s[0]] {
+			return nil, fmt.Errorf("invalid or duplicate share %d", s[0])
+		}
+		seen[s[0]] = true
+	}
+	secret := make([]byte, size-1)
+	for j := range secret {
+		// Lagrange interpolation at x = 0
+		var v byte
+		for i, si := range shares {
+			num, den := byte(1), byte(1)
+			for k, sk := range shares {
+				if i == k {
+					continue
+				}
+				num = gfMul(num, sk[0])
+				den = gfMul(den, si[0]^sk[0])
+			}
+			v ^= gfMul(si[j+1], gfDiv(num, den))
+		}
+		secret[j] = v
+	}
+	return secret, nil
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShamir_Combine(t *testing.T) {
+	seed, _, _ := CreateOperatorKey(t)
+	shares, err := SplitSecret(seed, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+
+	// any 3 shares restore the secret
+	for _, idx := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var parts [][]byte
+		for _, i := range idx {
+			parts = append(parts, shares[i])
+		}
+		secret, err := CombineShares(parts)
+		require.NoError(t, err)
+		require.Equal(t, seed, secret)
+	}
+
+	// 2 shares don't
+	secret, err := CombineShares(shares[:2])
+	require.NoError(t, err)
+	require.NotEqual(t, seed, secret)
+}
+
+func TestShamir_Errors(t *testing.T) {
+	_, err := SplitSecret([]byte("x"), 2, 3)
+	require.Error(t, err)
+	_, err = SplitSecret([]byte("x"), 3, 1)
+	require.Error(t, err)
+	_, err = SplitSecret(nil, 3, 2)
+	require.Error(t, err)
+
+	shares, err := SplitSecret([]byte("secret"), 3, 2)
+	require.NoError(t, err)
+	_, err = CombineShares([][]byte{shares[0], shares[0]})
+	require.Error(t, err)
+	_, err = CombineShares([][]byte{shares[0], shares[1][:3]})
+	require.Error(t, err)
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nats-io/nkeys"
+)
+
+// ExecSignerPrefix starts a key reference to a signer program
+const ExecSignerPrefix = "exec:"
+
+// AgentSignerPrefix starts a key reference to a key held by the key agent
+const AgentSignerPrefix = "agent:"
+
+// SignerTimeout limits how long a signer program can take
+var SignerTimeout = time.Minute
+
+// ErrExternalKey is returned when the seed of a key held by a signer program is requested
+var ErrExternalKey = errors.New("the seed is held by an external signer")
+
+// IsSignerRef returns true if the value references an external signer
+func IsSignerRef(value string) bool {
+	return strings.HasPrefix(value, ExecSignerPrefix) || strings.HasPrefix(value, AgentSignerPrefix)
+}
+
+// ResolveSignerRef returns a key pair for a key reference:
+//
+//	exec:/path/to/signer [args...]
+//	agent:<public key>
+//
+// The signer program and its arguments are separated by spaces, values
+// containing spaces are quoted with single or double quotes.
+// The signer program is run with the arguments followed by `public-key`
+// to print the public key it signs for, or by `sign` to sign the bytes
+// on its stdin. Signatures are printed base64 encoded on stdout. A
+// non-zero exit fails the request, the stderr output is the error.
+func ResolveSignerRef(value string) (nkeys.KeyPair, error) {
+	switch {
+	case strings.HasPrefix(value, AgentSignerPrefix):
+		pk := strings.TrimPrefix(value, AgentSignerPrefix)
+		if _, err := nkeys.FromPublicKey(pk); err != nil {
+			return nil, fmt.Errorf("invalid key reference %q: %v", value, err)
+		}
+		if kp := agentKeyPair(pk); kp != nil {
+			return kp, nil
+		}
+		return nil, fmt.Errorf("key %q is not held by the key agent at %#q", pk, AgentSocketPath())
+	case strings.HasPrefix(value, ExecSignerPrefix):
+		args, err := splitArgs(strings.TrimPrefix(value, ExecSignerPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key reference %q: %v", value, err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("invalid key reference %q: the signer program is missing", value)
+		}
+		kp := &ExecKeyPair{Path: args[0], Args: args[1:]}
+		out, err := kp.run("public-key", nil)
+		if err != nil {
+			return nil, err
+		}
+		pk := strings.TrimSpace(string(out))
+		if _, err := nkeys.FromPublicKey(pk); err != nil {
+			return nil, fmt.Errorf("signer %#q returned an invalid public key: %v", kp.Path, err)
+		}
+		kp.pub = pk
+		return kp, nil
+	}
+	return nil, fmt.Errorf("invalid key reference %q", value)
+}
+
+// splitArgs splits a command line on spaces, single or double quotes
+// group text with spaces into one argument. Backslashes are not escapes
+// so that Windows paths can be used.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	inArg := false
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case unicode.IsSpace(c):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// ExecKeyPair is a KeyPair that signs by running a signer program,
+// for keys held in an HSM or a KMS. The seed and private key are not
+// available.
+type ExecKeyPair struct {
+	Path string
+	Args []string
+	pub  string
+}
+
+func (kp *ExecKeyPair) run(op string, in []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), SignerTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, kp.Path, append(kp.Args, op)...)
+	cmd.Stdin = bytes.NewReader(in)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("signer %#q failed to %s: %s", kp.Path, op, msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+func (kp *ExecKeyPair) Seed() ([]byte, error) {
+	return nil, ErrExternalKey
+}
+
+func (kp *ExecKeyPair) PublicKey() (string, error) {
+	return kp.pub, nil
+}
+
+func (kp *ExecKeyPair) PrivateKey() ([]byte, error) {
+	return nil, ErrExternalKey
+}
+
+func (kp *ExecKeyPair) Sign(input []byte) ([]byte, error) {
+	out, err := kp.run("sign", input)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, fmt.Errorf("signer %#q returned an invalid signature: %v", kp.Path, err)
+	}
+	// a bad signature is caught here rather than by the server
+	if err := kp.Verify(input, sig); err != nil {
+		return nil, fmt.Errorf("signer %#q returned a signature that doesn't verify with %q", kp.Path, kp.pub)
+	}
+	return sig, nil
+}
+
+func (kp *ExecKeyPair) Verify(input []byte, sig []byte) error {
+	pk, err := nkeys.FromPublicKey(kp.pub)
+	if err != nil {
+		return err
+	}
+	return pk.Verify(input, sig)
+}
+
+func (kp *ExecKeyPair) Wipe() {}
+
+// IsExternalKey returns true if the key pair signs with the key agent
+// or a signer program
+func IsExternalKey(kp nkeys.KeyPair) bool {
+	switch kp.(type) {
+	case *AgentKeyPair, *ExecKeyPair:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/stretchr/testify/require"
+)
+
+const stubSignerEnv = "NSC_TEST_STUB_SIGNER"
+
+// TestStubSignerProcess is run as the signer program by stubSignerRef
+func TestStubSignerProcess(t *testing.T) {
+	if os.Getenv(stubSignerEnv) == "" {
+		return
+	}
+	args := os.Args
+	for i, a := range args {
+		if a == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	if err := stubSign(args, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// stubSign signs like tools/stub-signer with the seed in the file
+// named by the first argument
+func stubSign(args []string, in io.Reader, out io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("usage: <seed file> public-key|sign")
+	}
+	d, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	kp, err := nkeys.FromSeed(bytes.TrimSpace(d))
+	if err != nil {
+		return fmt.Errorf("error reading seed %#q: %v", args[0], err)
+	}
+	switch args[1] {
+	case "public-key":
+		pk, err := kp.PublicKey()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, pk)
+		return err
+	case "sign":
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		sig, err := kp.Sign(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, base64.StdEncoding.EncodeToString(sig))
+		return err
+	}
+	return fmt.Errorf("unknown operation %q", args[1])
+}
+
+// stubSignerRef returns a key reference running the test binary as the
+// stub signer for the seed
+func stubSignerRef(t *testing.T, seed []byte) string {
+	fp := filepath.Join(MakeTempDir(t), "key.nk")
+	require.NoError(t, ioutil.WriteFile(fp, seed, 0600))
+	require.NoError(t, os.Setenv(stubSignerEnv, "true"))
+	return fmt.Sprintf("%s%s -test.run=^TestStubSignerProcess$ -- %s", ExecSignerPrefix, os.Args[0], fp)
+}
+
+func TestSigner_Exec(t *testing.T) {
+	defer os.Unsetenv(stubSignerEnv)
+	seed, apk, akp := CreateAccountKey(t)
+	kp, err := ResolveKey(stubSignerRef(t, seed))
+	require.NoError(t, err)
+	require.True(t, IsExternalKey(kp))
+	pk, err := kp.PublicKey()
+	require.NoError(t, err)
+	require.Equal(t, apk, pk)
+	_, err = kp.Seed()
+	require.Equal(t, ErrExternalKey, err)
+
+	sig, err := kp.Sign([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, akp.Verify([]byte("hello"), sig))
+
+	// claims are encoded with the signer
+	_, upk, _ := CreateUserKey(t)
+	uc := jwt.NewUserClaims(upk)
+	token, err := uc.Encode(kp)
+	require.NoError(t, err)
+	uc, err = jwt.DecodeUserClaims(token)
+	require.NoError(t, err)
+	require.Equal(t, apk, uc.Issuer)
+}
+
+func TestSigner_ExecErrors(t *testing.T) {
+	defer os.Unsetenv(stubSignerEnv)
+	_, err := ResolveKey(ExecSignerPrefix)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "signer program is missing")
+
+	_, err = ResolveKey(ExecSignerPrefix + "/does/not/exist")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to public-key")
+
+	_, err = ResolveKey(ExecSignerPrefix + `"/path/to signer`)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unterminated \" quote")
+
+	// the stub reports the error on stderr
+	_, err = ResolveKey(stubSignerRef(t, []byte("not a seed")))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error reading seed")
+}
+
+func TestSigner_ExecPathWithSpaces(t *testing.T) {
+	defer os.Unsetenv(stubSignerEnv)
+	seed, apk, _ := CreateAccountKey(t)
+	dir := filepath.Join(MakeTempDir(t), "signer dir")
+	require.NoError(t, os.Mkdir(dir, 0700))
+	d, err := ioutil.ReadFile(os.Args[0])
+	require.NoError(t, err)
+	signer := filepath.Join(dir, "stub signer")
+	require.NoError(t, ioutil.WriteFile(signer, d, 0700))
+	fp := filepath.Join(dir, "key file.nk")
+	require.NoError(t, ioutil.WriteFile(fp, seed, 0600))
+	require.NoError(t, os.Setenv(stubSignerEnv, "true"))
+
+	kp, err := ResolveKey(fmt.Sprintf(`%s"%s" -test.run=^TestStubSignerProcess$ -- '%s'`, ExecSignerPrefix, signer, fp))
+	require.NoError(t, err)
+	pk, err := kp.PublicKey()
+	require.NoError(t, err)
+	require.Equal(t, apk, pk)
+}
+
+func TestSigner_SplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		args []string
+	}{
+		{"", nil},
+		{" /bin/signer  a b ", []string{"/bin/signer", "a", "b"}},
+		{`"/my signer/s" 'a b' c`, []string{"/my signer/s", "a b", "c"}},
+		{`a"b c"d ''`, []string{"ab cd", ""}},
+		{`C:\signers\s.exe "it's"`, []string{`C:\signers\s.exe`, "it's"}},
+	}
+	for _, tc := range tests {
+		args, err := splitArgs(tc.in)
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.args, args, tc.in)
+	}
+}
+
+func TestSigner_Agent(t *testing.T) {
+	_, apk, akp := CreateAccountKey(t)
+	a, sock := startTestAgent(t, "", time.Minute, akp)
+	defer a.Close()
+	require.NoError(t, os.Setenv(NscAgentSockEnv, sock))
+	defer os.Unsetenv(NscAgentSockEnv)
+	defer ResetAgent()
+
+	kp, err := ResolveKey(AgentSignerPrefix + apk)
+	require.NoError(t, err)
+	require.True(t, IsExternalKey(kp))
+	sig, err := kp.Sign([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, akp.Verify([]byte("hello"), sig))
+
+	_, upk, _ := CreateUserKey(t)
+	_, err = ResolveKey(AgentSignerPrefix + upk)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not held by the key agent")
+}
@@ -40,6 +40,18 @@ const (
 	DetailsOnErrorOrWarning
 )
 
+func (c StatusCode) String() string {
+	switch c {
+	case OK:
+		return "ok"
+	case WARN:
+		return "warning"
+	case ERR:
+		return "error"
+	}
+	return "none"
+}
+
 const okTemplate = "[ OK ] %s"
 const warnTemplate = "[WARN] %s"
 const errTemplate = "[ERR ] %s"
@@ -88,6 +100,11 @@ func NewDetailedReport(summary bool) *Report {
 }
 
 func FromError(err error) Status {
+	// the change was made, only recording it failed
+	var ue *UnrecordedError
+	if errors.As(err, &ue) {
+		return &Report{StatusCode: WARN, Label: err.Error()}
+	}
 	return &Report{StatusCode: ERR, Label: err.Error()}
 }
 
@@ -303,6 +320,34 @@ func (r *Report) Summary() (string, error) {
 	}
 }
 
+// StatusData is the serializable form of a status and its details
+type StatusData struct {
+	Status  string        `json:"status"`
+	Message string        `json:"message,omitempty"`
+	Details []*StatusData `json:"details,omitempty"`
+}
+
+// ToStatusData converts the status tree
+func ToStatusData(s Status) *StatusData {
+	sd := &StatusData{Status: s.Code().String()}
+	switch v := s.(type) {
+	case *Report:
+		sd.Message = v.Label
+		for _, d := range v.Details {
+			sd.Details = append(sd.Details, ToStatusData(d))
+		}
+	case MultiJob:
+		for _, d := range v {
+			sd.Details = append(sd.Details, ToStatusData(d))
+		}
+	case *ServerMessage:
+		sd.Message = v.SrvMessage
+	default:
+		sd.Message = strings.TrimSpace(s.Message())
+	}
+	return sd
+}
+
 func HoistChildren(s Status) []Status {
 	r, ok := s.(*Report)
 	if !ok {
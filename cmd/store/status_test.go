@@ -180,3 +180,24 @@ func Test_ServerMessagesAllwaysShow(t *testing.T) {
 	require.Contains(t, lines[1], "one")
 	require.Contains(t, lines[2], "server says")
 }
+
+func Test_ToStatusData(t *testing.T) {
+	r := NewDetailedReport(true)
+	r.AddOK("one")
+	sr := r.AddWarning("two")
+	sr.AddWarning("nested")
+	r.Add(NewServerMessage("from the server\n"))
+
+	sd := ToStatusData(r)
+	require.Equal(t, "warning", sd.Status)
+	require.Equal(t, "", sd.Message)
+	require.Len(t, sd.Details, 3)
+	require.Equal(t, &StatusData{Status: "ok", Message: "one"}, sd.Details[0])
+	require.Equal(t, "two", sd.Details[1].Message)
+	require.Equal(t, "nested", sd.Details[1].Details[0].Message)
+	require.Equal(t, "from the server", sd.Details[2].Message)
+
+	sd = ToStatusData(MultiJob{OKStatus("a"), ErrorStatus("b")})
+	require.Equal(t, "error", sd.Status)
+	require.Len(t, sd.Details, 2)
+}
@@ -36,6 +36,8 @@ import (
 	"github.com/nats-io/nkeys"
 )
 
+// Version is the layout version of operator stores created by nsc,
+// older stores are upgraded with the steps registered in OperatorUpgrades
 const Version = "1"
 const NSCFile = ".nsc"
 
@@ -52,13 +54,26 @@ type Store struct {
 	Dir            string
 	Info           Info
 	DefaultAccount string
+	Backend        Backend
+	// Versioner if set records every change to the store
+	Versioner Versioner
+	// Args is the command line recorded with changes, the tool
+	// name followed by its arguments
+	Args []string
+	// Check if set is called with every JWT before it is stored,
+	// the JWT is rejected if the report has errors
+	Check func(data []byte) *Report
+
+	indexMu sync.Mutex
+	index   *Index
 }
 
 type Info struct {
-	Managed bool   `json:"managed"`
-	Name    string `json:"name"`
-	Kind    string `json:"kind"`
-	Version string `json:"version"`
+	Managed   bool   `json:"managed"`
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Version   string `json:"version"`
+	Versioned bool   `json:"versioned,omitempty"`
 }
 
 func underlyingError(err error) error {
@@ -122,31 +137,49 @@ func SafeName(n string) string {
 // CreateStore creates a new Store in the specified directory.
 // CreateStore will create the necessary directories and store the public key.
 func CreateStore(env string, operatorsDir string, operator *NamedKey) (*Store, error) {
+	root := filepath.Join(operatorsDir, operator.Name)
+	if _, err := fsys().stat(root); os.IsNotExist(err) {
+		if err := fsys().mkdirAll(root); err != nil {
+			return nil, err
+		}
+	}
+	s, err := CreateStoreWithBackend(NewFSBackend(root), operator)
+	if err != nil {
+		if err == errStoreNotEmpty {
+			return nil, fmt.Errorf("operator %q already exists in %#q", operator.Name, operatorsDir)
+		}
+		return nil, err
+	}
+	s.Dir = root
+	return s, nil
+}
+
+var errStoreNotEmpty = errors.New("store is not empty")
+
+// CreateStoreWithBackend creates a new Store persisted by the specified backend.
+// The backend must be empty.
+func CreateStoreWithBackend(b Backend, operator *NamedKey) (*Store, error) {
 	var err error
 
-	root := filepath.Join(operatorsDir, operator.Name)
 	s := &Store{
-		Dir: root,
+		Backend: b,
 		Info: Info{
 			Name:    operator.Name,
 			Version: Version,
 			Kind:    jwt.OperatorClaim,
 		},
 	}
-
-	if _, err := os.Stat(root); os.IsNotExist(err) {
-		if err := os.MkdirAll(root, 0700); err != nil {
-			return nil, err
-		}
+	if fb, ok := b.(*FSBackend); ok {
+		s.Dir = fb.Dir
 	}
 
-	files, err := ioutil.ReadDir(root)
+	files, err := s.List()
 	if err != nil {
 		return nil, err
 	}
 
 	if len(files) != 0 {
-		return nil, fmt.Errorf("operator %q already exists in %#q", operator.Name, operatorsDir)
+		return nil, errStoreNotEmpty
 	}
 
 	if operator.KP != nil {
@@ -171,9 +204,8 @@ func CreateStore(env string, operatorsDir string, operator *NamedKey) (*Store, e
 	}
 
 	for _, d := range standardDirs {
-		dp := s.resolve(d, "")
-		if err = os.MkdirAll(dp, 0700); err != nil {
-			return nil, fmt.Errorf("error creating %#q: %v", dp, err)
+		if err = b.MkdirAll(d); err != nil {
+			return nil, fmt.Errorf("error creating %#q: %v", s.resolve(d), err)
 		}
 	}
 
@@ -206,14 +238,35 @@ func (s *Store) createOperatorToken(operator *NamedKey) (string, error) {
 // LoadStore loads a store from the specified directory path.
 func LoadStore(dir string) (*Store, error) {
 	sf := filepath.Join(dir, NSCFile)
-	if _, err := os.Stat(sf); os.IsNotExist(err) {
+	if !Exists(sf) {
 		return nil, fmt.Errorf("%#q is not a valid configuration directory", dir)
 	}
+	return LoadStoreWithBackend(NewFSBackend(dir))
+}
 
-	s := &Store{Dir: dir}
+// LoadStoreWithBackend loads a store persisted by the specified backend.
+func LoadStoreWithBackend(b Backend) (*Store, error) {
+	s := &Store{Backend: b}
+	if fb, ok := b.(*FSBackend); ok {
+		s.Dir = fb.Dir
+	}
+	if !s.Has(NSCFile) {
+		return nil, fmt.Errorf("%#q is not a valid configuration directory", s.Dir)
+	}
 	if err := s.loadJson(&s.Info, ".nsc"); err != nil {
 		return nil, fmt.Errorf("error loading '.nsc' file: %v", err)
 	}
+	v, err := parseVersion(s.Info.Version)
+	if err != nil {
+		return nil, err
+	}
+	if v > OperatorUpgrades.Current() {
+		return nil, fmt.Errorf("%v - operator %q is at version %d, this nsc supports version %d", ErrNewerStore, s.Info.Name, v, OperatorUpgrades.Current())
+	}
+	// changes made by a dry run are not recorded
+	if s.Info.Versioned && s.Dir != "" && ActiveDryRun() == nil {
+		s.Versioner = NewGitVersioner(s.Dir)
+	}
 
 	return s, nil
 }
@@ -228,61 +281,149 @@ func (s *Store) resolve(name ...string) string {
 
 // Has returns true if the specified asset exists
 func (s *Store) Has(name ...string) bool {
-	fp := s.resolve(name...)
-	return s.has(fp)
+	return s.Backend.Has(filepath.Join(name...))
 }
 
 func (s *Store) HasAccount(name string) bool {
 	return s.Has(Accounts, name, JwtName(name))
 }
 
-func (s *Store) has(fp string) bool {
-	if _, err := os.Stat(fp); os.IsNotExist(err) {
-		return false
-	}
-	return true
-}
-
 // Read reads the specified file name or subpath from the store
 func (s *Store) Read(name ...string) ([]byte, error) {
 	s.Lock()
 	defer s.Unlock()
-	fp := s.resolve(name...)
-	d, err := ioutil.ReadFile(fp)
-	if err != nil {
-		return nil, fmt.Errorf("error reading %#q: %v", fp, err)
+	return s.Backend.Read(filepath.Join(name...))
+}
+
+// AcquireLock prevents other processes from modifying the store
+// until ReleaseLock is called. Locks are re-entrant and only apply
+// to stores in a directory.
+func (s *Store) AcquireLock() error {
+	if s.Dir == "" {
+		return nil
+	}
+	if err := AcquireFileLock(filepath.Join(s.Dir, LockName)); err != nil {
+		return fmt.Errorf("unable to lock operator %q: %v", s.GetName(), err)
 	}
-	return d, nil
+	return nil
+}
+
+// ReleaseLock releases a lock taken by AcquireLock
+func (s *Store) ReleaseLock() error {
+	if s.Dir == "" {
+		return nil
+	}
+	return ReleaseFileLock(filepath.Join(s.Dir, LockName))
 }
 
 // Write writes the specified file name or subpath in the store
 func (s *Store) Write(data []byte, name ...string) error {
-	s.Lock()
-	defer s.Unlock()
-
-	fp := s.resolve(name...)
-	dp := filepath.Dir(fp)
-
-	if err := os.MkdirAll(dp, 0700); err != nil {
+	if err := s.AcquireLock(); err != nil {
 		return err
 	}
-	return ioutil.WriteFile(fp, data, 0600)
+	defer s.ReleaseLock()
+	fp := filepath.Join(name...)
+	if err := s.write(data, fp); err != nil {
+		return err
+	}
+	s.updateIndex(fp, data)
+	return s.commit("updated", fp, data)
 }
 
-func (s *Store) List(path ...string) ([]os.FileInfo, error) {
+func (s *Store) write(data []byte, fp string) error {
 	s.Lock()
 	defer s.Unlock()
+	return s.Backend.Write(fp, data)
+}
 
-	fp := s.resolve(path...)
-	return ioutil.ReadDir(fp)
+func (s *Store) List(path ...string) ([]os.FileInfo, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.Backend.List(filepath.Join(path...))
 }
 
 // Delete the specified file name or subpath from the store
 func (s *Store) Delete(name ...string) error {
+	if err := s.AcquireLock(); err != nil {
+		return err
+	}
+	defer s.ReleaseLock()
+	fp := filepath.Join(name...)
+	var data []byte
+	if s.Versioner != nil && IsJwtName(fp) {
+		// keep the claim so the change can be described
+		data, _ = s.Read(name...)
+	}
+	if err := s.delete(fp); err != nil {
+		return err
+	}
+	s.updateIndex(fp, nil)
+	return s.commit("deleted", fp, data)
+}
+
+func (s *Store) delete(fp string) error {
 	s.Lock()
 	defer s.Unlock()
-	fp := s.resolve(name...)
-	return os.Remove(fp)
+	return s.Backend.Delete(fp)
+}
+
+// commit records the change made to fp. The change is already in the
+// store, a failure to record it is returned as an *UnrecordedError.
+func (s *Store) commit(verb string, fp string, data []byte) error {
+	if s.Versioner == nil {
+		return nil
+	}
+	if err := s.Versioner.Commit(changeMessage(s.Args, verb, fp, data)); err != nil {
+		return &UnrecordedError{Path: fp, Err: err}
+	}
+	return nil
+}
+
+// IsVersioned returns true if changes to the store are recorded
+func (s *Store) IsVersioned() bool {
+	return s.Versioner != nil
+}
+
+// EnableVersioning starts recording changes to the store in a git
+// repository in the store directory. The current state of the store
+// is recorded as the first revision.
+func (s *Store) EnableVersioning() error {
+	if s.IsVersioned() {
+		return nil
+	}
+	if s.Dir == "" {
+		return errors.New("versioning requires an operator directory")
+	}
+	s.Info.Versioned = true
+	d, err := json.Marshal(s.Info)
+	if err != nil {
+		return fmt.Errorf("error serializing .nsc: %v", err)
+	}
+	if err := s.write(d, NSCFile); err != nil {
+		return fmt.Errorf("error writing .nsc in %#q: %v", s.Dir, err)
+	}
+	v := NewGitVersioner(s.Dir)
+	if err := v.Init(changeMessage(s.Args, "enabled versioning", "", nil)); err != nil {
+		return err
+	}
+	s.Versioner = v
+	return nil
+}
+
+// History returns the recorded revisions of the specified asset, newest first
+func (s *Store) History(name ...string) ([]Revision, error) {
+	if s.Versioner == nil {
+		return nil, ErrNotVersioned
+	}
+	return s.Versioner.History(filepath.Join(name...))
+}
+
+// ReadRevision returns the contents of the specified asset at a revision
+func (s *Store) ReadRevision(revision string, name ...string) ([]byte, error) {
+	if s.Versioner == nil {
+		return nil, ErrNotVersioned
+	}
+	return s.Versioner.Show(revision, filepath.Join(name...))
 }
 
 func (s *Store) ListSubContainers(name ...string) ([]string, error) {
@@ -349,6 +490,14 @@ func PullAccount(u string) (Status, error) {
 }
 
 func PushAccount(u string, data []byte) (Status, error) {
+	if d := ActiveDryRun(); d != nil {
+		d.addPush(u)
+		r := NewDetailedReport(true)
+		r.Label = "push jwt to account server"
+		r.Opt = DetailsOnErrorOrWarning
+		r.AddOK("dry run - the jwt was not pushed to the account server")
+		return r, nil
+	}
 	resp, err := http.Post(u, "application/jwt", bytes.NewReader(data))
 	if err != nil {
 		return nil, err
@@ -387,7 +536,8 @@ func (s *Store) handleManagedAccount(data []byte) (*Report, error) {
 		return r, nil
 	}
 	r.Add(push)
-	if push.Code() == OK {
+	// a dry run stores the self-signed jwt
+	if push.Code() == OK && ActiveDryRun() == nil {
 		pull, err := PullAccount(u.String())
 		if err != nil {
 			r.AddError("error pulling account %q: %v", ac.Name, err)
@@ -397,11 +547,55 @@ func (s *Store) handleManagedAccount(data []byte) (*Report, error) {
 	return r, nil
 }
 
+// check returns the report of the store check if it rejects the JWT
+func (s *Store) check(data []byte) *Report {
+	if s.Check == nil {
+		return nil
+	}
+	if r := s.Check(data); r != nil && r.HasErrors() {
+		return r
+	}
+	return nil
+}
+
+// CheckClaim returns a RejectedError if the store check rejects the JWT.
+// Callers creating keys for a JWT check it before storing them.
+func (s *Store) CheckClaim(data []byte) error {
+	if r := s.check(data); r != nil {
+		return &RejectedError{Report: r}
+	}
+	return nil
+}
+
+// RejectedError is returned when the store check rejects a JWT,
+// the report has the reasons
+type RejectedError struct {
+	Report *Report
+}
+
+func (e *RejectedError) Error() string {
+	var m []string
+	for _, d := range e.Report.Details {
+		if d.Code() != ERR {
+			continue
+		}
+		if dr := ToReport(d); dr != nil {
+			m = append(m, dr.Label)
+		} else {
+			m = append(m, d.Message())
+		}
+	}
+	return fmt.Sprintf("%s: %s", e.Report.Label, strings.Join(m, ", "))
+}
+
 func (s *Store) StoreClaim(data []byte) (Status, error) {
 	ct, err := s.ClaimType(data)
 	if err != nil {
 		return nil, err
 	}
+	if r := s.check(data); r != nil {
+		return r, &RejectedError{Report: r}
+	}
 	if *ct == jwt.AccountClaim && s.IsManaged() {
 		var pull Report
 		var push Report
@@ -420,24 +614,31 @@ func (s *Store) StoreClaim(data []byte) (Status, error) {
 
 		if pull.Code() == OK {
 			// the pull succeeded so we have a JWT
-			if err := s.StoreRaw(pull.Data); err != nil {
+			if err := s.storeRaw(pull.Data); err != nil {
 				pp.AddError("failed to store jwt: %v", err)
 				return pp, err
 			}
 		} else if push.Code() == OK || push.Code() == WARN {
 			// Push OK but failed pull, store self-signed
-			if err := s.StoreRaw(data); err != nil {
+			if err := s.storeRaw(data); err != nil {
 				pp.AddError("failed to store self-signed jwt: %v", err)
 				return pp, err
 			}
 		}
 		return pp, nil
 	} else {
-		return nil, s.StoreRaw(data)
+		return nil, s.storeRaw(data)
 	}
 }
 
 func (s *Store) StoreRaw(data []byte) error {
+	if err := s.CheckClaim(data); err != nil {
+		return err
+	}
+	return s.storeRaw(data)
+}
+
+func (s *Store) storeRaw(data []byte) error {
 	ct, err := s.ClaimType(data)
 	if err != nil {
 		return err
@@ -459,23 +660,10 @@ func (s *Store) StoreRaw(data []byte) error {
 		if uc.IssuerAccount != "" {
 			issuer = uc.IssuerAccount
 		}
-		var account string
-		infos, err := s.List(Accounts)
+		account, err := s.issuerAccount(uc.Issuer)
 		if err != nil {
 			return err
 		}
-		for _, i := range infos {
-			if i.IsDir() {
-				c, err := s.ReadAccountClaim(i.Name())
-				if err != nil {
-					return err
-				}
-				if c.DidSign(uc) {
-					account = i.Name()
-					break
-				}
-			}
-		}
 		if account == "" {
 			return fmt.Errorf("account with public key %q is not in the store", issuer)
 		}
@@ -493,6 +681,27 @@ func (s *Store) StoreRaw(data []byte) error {
 	return s.Write(data, path)
 }
 
+// issuerAccount returns the name of the account that has the specified
+// identity or signing key
+func (s *Store) issuerAccount(issuer string) (string, error) {
+	e, err := s.LookupKey(issuer)
+	if err != nil {
+		return "", err
+	}
+	if e == nil {
+		// the account may have been added without updating the index
+		x, err := s.RebuildIndex()
+		if err != nil {
+			return "", err
+		}
+		e = x.Lookup(issuer)
+	}
+	if e != nil && e.Kind == jwt.AccountClaim {
+		return e.Name, nil
+	}
+	return "", nil
+}
+
 func (s *Store) GetName() string {
 	return s.Info.Name
 }
@@ -590,7 +799,18 @@ func (s *Store) ReadAccountClaim(name string) (*jwt.AccountClaims, error) {
 	return c, nil
 }
 
+// ReadRawAccountClaim returns the JWT for the named account. The account
+// can also be specified by its public key or one of its signing keys.
 func (s *Store) ReadRawAccountClaim(name string) ([]byte, error) {
+	if !s.Has(Accounts, name, JwtName(name)) && nkeys.IsValidPublicAccountKey(name) {
+		e, err := s.LookupKey(name)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil && e.Kind == jwt.AccountClaim {
+			name = e.Name
+		}
+	}
 	if s.Has(Accounts, name, JwtName(name)) {
 		d, err := s.Read(Accounts, name, JwtName(name))
 		if err != nil {
@@ -864,18 +1084,15 @@ func (ctx *Context) PickUser(accountName string) (string, error) {
 // GetAccountKeys returns the public keys for the named account followed
 // by its signing keys
 func (ctx *Context) GetAccountKeys(name string) ([]string, error) {
-	var keys []string
-	ac, err := ctx.Store.ReadAccountClaim(name)
-	if err != nil && !IsNotExist(err) {
+	e, err := ctx.Store.IndexedEntity(Accounts, name, JwtName(name))
+	if err != nil {
 		return nil, err
 	}
-	if ac == nil {
+	if e == nil {
 		// not found
 		return nil, nil
 	}
-	keys = append(keys, ac.Subject)
-	keys = append(keys, ac.SigningKeys...)
-	return keys, nil
+	return e.Keys(), nil
 }
 
 // GetOperatorKeys returns the public keys for the operator
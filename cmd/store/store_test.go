@@ -369,3 +369,40 @@ func TestStore_GetAccountKeys(t *testing.T) {
 	require.Equal(t, apub, keys[0])
 	require.Equal(t, apub2, keys[1])
 }
+
+func TestStoreCheckRejects(t *testing.T) {
+	_, _, okp := CreateOperatorKey(t)
+	s := CreateTestStoreForOperator(t, "O", okp)
+	_, apk, _ := CreateAccountKey(t)
+	ac := jwt.NewAccountClaims(apk)
+	ac.Name = "A"
+	token, err := ac.Encode(okp)
+	require.NoError(t, err)
+
+	s.Check = func(data []byte) *Report {
+		r := NewDetailedReport(false)
+		r.Label = "rejected"
+		r.AddError("no accounts")
+		return r
+	}
+	rs, err := s.StoreClaim([]byte(token))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rejected: no accounts")
+	require.False(t, s.HasAccount("A"))
+	require.NotNil(t, rs)
+	require.Equal(t, ERR, rs.Code())
+	re, ok := err.(*RejectedError)
+	require.True(t, ok)
+	require.Equal(t, "rejected", re.Report.Label)
+	require.Error(t, s.CheckClaim([]byte(token)))
+
+	err = s.StoreRaw([]byte(token))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rejected: no accounts")
+	require.False(t, s.HasAccount("A"))
+
+	s.Check = nil
+	require.NoError(t, s.CheckClaim([]byte(token)))
+	require.NoError(t, s.StoreRaw([]byte(token)))
+	require.True(t, s.HasAccount("A"))
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// StoreTrashDir is the directory in the operator store holding deleted JWTs
+const StoreTrashDir = ".trash"
+
+// TrashManifest describes the contents of a trash entry
+const TrashManifest = "trash.json"
+
+// TrashedCreds identifies a creds file moved to the trash
+type TrashedCreds struct {
+	Account string `json:"account"`
+	User    string `json:"user"`
+}
+
+// TrashEntry records an account or user deleted from the store. The JWTs
+// are kept in the operator store, keys and creds files are moved to the
+// trash in the keystore so seeds never leave the keystore.
+type TrashEntry struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Account string `json:"account,omitempty"`
+	Deleted int64  `json:"deleted"`
+	// Files are the store paths of the deleted JWTs
+	Files []string       `json:"files"`
+	Keys  []string       `json:"keys,omitempty"`
+	Creds []TrashedCreds `json:"creds,omitempty"`
+}
+
+// DeletedAt returns the time the entry was deleted
+func (e *TrashEntry) DeletedAt() time.Time {
+	return time.Unix(e.Deleted, 0).UTC()
+}
+
+// NewTrashEntry creates an entry for the specified kind of entity. The
+// account is only set for users.
+func (s *Store) NewTrashEntry(kind string, name string, account string) *TrashEntry {
+	now := time.Now().UTC()
+	id := fmt.Sprintf("%s_%s_%s", now.Format("20060102T150405Z"), kind, name)
+	if account != "" {
+		id = fmt.Sprintf("%s_%s_%s_%s", now.Format("20060102T150405Z"), kind, account, name)
+	}
+	base := id
+	for i := 2; s.Has(StoreTrashDir, id); i++ {
+		id = fmt.Sprintf("%s_%d", base, i)
+	}
+	return &TrashEntry{ID: id, Kind: kind, Name: name, Account: account, Deleted: now.Unix()}
+}
+
+// TrashFile copies data for the asset at the specified store path into
+// the trash entry. The caller deletes the asset once it is in the trash.
+func (s *Store) TrashFile(e *TrashEntry, data []byte, name ...string) error {
+	fp := filepath.Join(name...)
+	if err := s.write(data, filepath.Join(StoreTrashDir, e.ID, fp)); err != nil {
+		return fmt.Errorf("error moving %#q to the trash: %v", fp, err)
+	}
+	e.Files = append(e.Files, filepath.ToSlash(fp))
+	return nil
+}
+
+// SaveTrashEntry records the contents of the trash entry
+func (s *Store) SaveTrashEntry(e *TrashEntry) error {
+	d, err := json.MarshalIndent(e, "", " ")
+	if err != nil {
+		return err
+	}
+	if err := s.write(d, filepath.Join(StoreTrashDir, e.ID, TrashManifest)); err != nil {
+		return fmt.Errorf("error writing trash entry %q: %v", e.ID, err)
+	}
+	return nil
+}
+
+// ReadTrashEntry returns the trash entry with the specified ID
+func (s *Store) ReadTrashEntry(id string) (*TrashEntry, error) {
+	if id == "" || !s.Has(StoreTrashDir, id, TrashManifest) {
+		return nil, fmt.Errorf("trash entry %q doesn't exist", id)
+	}
+	d, err := s.Read(StoreTrashDir, id, TrashManifest)
+	if err != nil {
+		return nil, err
+	}
+	var e TrashEntry
+	if err := json.Unmarshal(d, &e); err != nil {
+		return nil, fmt.Errorf("error parsing trash entry %q: %v", id, err)
+	}
+	e.ID = id
+	return &e, nil
+}
+
+// ReadTrashFile returns the contents of a JWT in the trash entry
+func (s *Store) ReadTrashFile(e *TrashEntry, fp string) ([]byte, error) {
+	return s.Read(StoreTrashDir, e.ID, filepath.FromSlash(fp))
+}
+
+// ListTrash returns the entries in the trash, oldest first
+func (s *Store) ListTrash() ([]*TrashEntry, error) {
+	if !s.Has(StoreTrashDir) {
+		return nil, nil
+	}
+	infos, err := s.List(StoreTrashDir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*TrashEntry
+	for _, i := range infos {
+		if !i.IsDir() || !s.Has(StoreTrashDir, i.Name(), TrashManifest) {
+			continue
+		}
+		e, err := s.ReadTrashEntry(i.Name())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Deleted < entries[j].Deleted
+	})
+	return entries, nil
+}
+
+// RemoveTrashEntry permanently deletes the JWTs in the trash entry
+func (s *Store) RemoveTrashEntry(e *TrashEntry) error {
+	if err := s.deleteAll(filepath.Join(StoreTrashDir, e.ID)); err != nil {
+		return err
+	}
+	if infos, err := s.List(StoreTrashDir); err == nil && len(infos) == 0 {
+		_ = s.delete(StoreTrashDir)
+	}
+	return nil
+}
+
+// deleteAll removes the named asset and everything under it
+func (s *Store) deleteAll(fp string) error {
+	if !s.Has(fp) {
+		return nil
+	}
+	if infos, err := s.List(fp); err == nil {
+		for _, i := range infos {
+			if err := s.deleteAll(filepath.Join(fp, i.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return s.delete(fp)
+}
+
+func (k *KeyStore) trashDir(id string) string {
+	return filepath.Join(k.root(), TrashDir, k.Env, id)
+}
+
+// TrashKey moves the key to the keystore trash for the entry. Keys
+// that are not in the keystore are ignored. Returns true if the key was moved.
+func (k *KeyStore) TrashKey(e *TrashEntry, pubkey string) (bool, error) {
+	unlock, err := k.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	from := k.GetKeyPath(pubkey)
+	if _, err := fsys().stat(from); os.IsNotExist(err) {
+		return false, nil
+	}
+	to := filepath.Join(k.trashDir(e.ID), KeysDir, k.keyName(pubkey))
+	if err := moveFile(from, to); err != nil {
+		return false, fmt.Errorf("error moving key %q to the trash: %v", pubkey, err)
+	}
+	e.Keys = append(e.Keys, pubkey)
+	return true, nil
+}
+
+// TrashUserCreds moves the creds file for the user to the keystore trash
+// for the entry. Returns true if the user had a creds file.
+func (k *KeyStore) TrashUserCreds(e *TrashEntry, account string, user string) (bool, error) {
+	unlock, err := k.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	from := k.GetUserCredsPath(account, user)
+	if from == "" {
+		return false, nil
+	}
+	to := filepath.Join(k.trashDir(e.ID), CredsDir, account, k.credsName(user))
+	if err := moveFile(from, to); err != nil {
+		return false, fmt.Errorf("error moving creds file %#q to the trash: %v", from, err)
+	}
+	e.Creds = append(e.Creds, TrashedCreds{Account: account, User: user})
+	return true, nil
+}
+
+// RestoreKey moves a key in the trash entry back into the keystore
+func (k *KeyStore) RestoreKey(e *TrashEntry, pubkey string) error {
+	unlock, err := k.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	from := filepath.Join(k.trashDir(e.ID), KeysDir, k.keyName(pubkey))
+	to := k.GetKeyPath(pubkey)
+	if _, err := fsys().stat(to); err == nil {
+		// the same key was stored again
+		return fsys().remove(from)
+	}
+	return moveFile(from, to)
+}
+
+// RestoreUserCreds moves a creds file in the trash entry back into the keystore
+func (k *KeyStore) RestoreUserCreds(e *TrashEntry, c TrashedCreds) error {
+	unlock, err := k.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	from := filepath.Join(k.trashDir(e.ID), CredsDir, c.Account, k.credsName(c.User))
+	to := k.CalcUserCredsPath(c.Account, c.User)
+	if _, err := fsys().stat(to); err == nil {
+		return fmt.Errorf("creds file %#q already exists", to)
+	}
+	return moveFile(from, to)
+}
+
+// RemoveTrash permanently deletes the keys and creds files of the trash entry
+func (k *KeyStore) RemoveTrash(e *TrashEntry) error {
+	unlock, err := k.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	dir := k.trashDir(e.ID)
+	if err := fsys().removeAll(dir); err != nil {
+		return err
+	}
+	removeIfEmpty(filepath.Dir(dir))
+	removeIfEmpty(filepath.Join(k.root(), TrashDir))
+	return nil
+}
+
+// moveFile renames a file creating the target directory, the
+// source directory is removed if it becomes empty
+func moveFile(from string, to string) error {
+	if err := MaybeMakeDir(filepath.Dir(to)); err != nil {
+		return err
+	}
+	if err := fsys().rename(from, to); err != nil {
+		return err
+	}
+	removeIfEmpty(filepath.Dir(from))
+	return nil
+}
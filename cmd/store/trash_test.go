@@ -0,0 +1,65 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package store
+
+import (
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrash_Entries(t *testing.T) {
+	_, _, okp := CreateOperatorKey(t)
+	s := CreateTestStoreForOperator(t, "O", okp)
+
+	_, akp, token := encodeTestAccount(t, okp, "A")
+	require.NoError(t, s.StoreRaw([]byte(token)))
+	upub, token := encodeTestUser(t, akp, "U")
+	require.NoError(t, s.StoreRaw([]byte(token)))
+
+	fp := []string{Accounts, "A", Users, JwtName("U")}
+	e := s.NewTrashEntry(jwt.UserClaim, "U", "A")
+	require.NoError(t, s.TrashFile(e, []byte(token), fp...))
+	require.NoError(t, s.Delete(fp...))
+	require.NoError(t, s.SaveTrashEntry(e))
+	require.Equal(t, []string{"accounts/A/users/U.jwt"}, e.Files)
+
+	// the same user deleted in the same second gets a different entry
+	e2 := s.NewTrashEntry(jwt.UserClaim, "U", "A")
+	require.NotEqual(t, e.ID, e2.ID)
+
+	// trashed JWTs are not indexed
+	ie, err := s.LookupKey(upub)
+	require.NoError(t, err)
+	require.Nil(t, ie)
+	x, err := s.RebuildIndex()
+	require.NoError(t, err)
+	require.Nil(t, x.Lookup(upub))
+
+	entries, err := s.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, e.ID, entries[0].ID)
+	require.Equal(t, "A", entries[0].Account)
+	d, err := s.ReadTrashFile(entries[0], entries[0].Files[0])
+	require.NoError(t, err)
+	require.Equal(t, token, string(d))
+
+	require.NoError(t, s.RemoveTrashEntry(e))
+	require.False(t, s.Has(StoreTrashDir))
+	_, err = s.ReadTrashEntry(e.ID)
+	require.Error(t, err)
+}
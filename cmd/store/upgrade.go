@@ -0,0 +1,359 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nkeys"
+)
+
+const KeyStoreVersionFile = ".version"
+
+// ErrNewerStore is returned for stores written by a newer version of nsc
+var ErrNewerStore = errors.New("store was created by a newer version of nsc")
+
+// UpgradeStep changes the layout of an operator store or keystore
+// from one version to the next
+type UpgradeStep struct {
+	// From is the version upgraded by the step, the result is From+1
+	From int
+	// Description explains what the step does
+	Description string
+	// Apply performs the upgrade on the store in dir
+	Apply func(dir string) error
+}
+
+// Upgrader tracks the ordered upgrade steps for a kind of store
+type Upgrader struct {
+	// Kind is the kind of store upgraded
+	Kind string
+	// Base is the oldest version that can be upgraded
+	Base       int
+	steps      []UpgradeStep
+	version    func(dir string) (int, error)
+	setVersion func(dir string, v int) error
+}
+
+// OperatorUpgrades are the upgrades for operator stores
+var OperatorUpgrades = &Upgrader{
+	Kind:       "operator",
+	Base:       1,
+	version:    operatorStoreVersion,
+	setVersion: setOperatorStoreVersion,
+}
+
+// KeyStoreUpgrades are the upgrades for the keystore
+var KeyStoreUpgrades = &Upgrader{
+	Kind:       "keystore",
+	Base:       0,
+	version:    keyStoreVersion,
+	setVersion: setKeyStoreVersion,
+}
+
+func init() {
+	KeyStoreUpgrades.Register(UpgradeStep{
+		From:        0,
+		Description: "move keys and creds files into the keys and creds directories",
+		Apply:       upgradeKeyStoreLayout,
+	})
+}
+
+// Register adds an upgrade step. Steps must be registered in order.
+func (u *Upgrader) Register(step UpgradeStep) {
+	if step.From != u.Current() {
+		panic(fmt.Sprintf("%s upgrade from version %d registered out of order - expected version %d", u.Kind, step.From, u.Current()))
+	}
+	u.steps = append(u.steps, step)
+}
+
+// Current is the version of the store written by this version of nsc
+func (u *Upgrader) Current() int {
+	return u.Base + len(u.steps)
+}
+
+// Version returns the version of the store in dir
+func (u *Upgrader) Version(dir string) (int, error) {
+	return u.version(dir)
+}
+
+// Pending returns the upgrade steps required by the store in dir in
+// the order they are applied. Stores created by a newer nsc return ErrNewerStore.
+func (u *Upgrader) Pending(dir string) ([]UpgradeStep, error) {
+	v, err := u.version(dir)
+	if err != nil {
+		return nil, err
+	}
+	if v > u.Current() {
+		return nil, fmt.Errorf("%v - %s %#q is at version %d, this nsc supports version %d", ErrNewerStore, u.Kind, dir, v, u.Current())
+	}
+	if v < u.Base {
+		return nil, fmt.Errorf("%s %#q is at unsupported version %d", u.Kind, dir, v)
+	}
+	return u.steps[v-u.Base:], nil
+}
+
+// Upgrade applies the pending upgrade steps to the store in dir. Before
+// any changes are made, the store is backed up into a compressed tar file
+// next to it, the path of the backup is returned.
+func (u *Upgrader) Upgrade(dir string) (string, []UpgradeStep, error) {
+	steps, err := u.Pending(dir)
+	if err != nil || len(steps) == 0 {
+		return "", nil, err
+	}
+	fp := filepath.Join(dir, LockName)
+	if err := AcquireFileLock(fp); err != nil {
+		return "", nil, fmt.Errorf("unable to lock %s %#q: %v", u.Kind, dir, err)
+	}
+	defer ReleaseFileLock(fp)
+	// another process may have upgraded the store while we waited
+	if steps, err = u.Pending(dir); err != nil || len(steps) == 0 {
+		return "", nil, err
+	}
+	v := steps[0].From
+	backup := fmt.Sprintf("%s_v%d_%s.tgz", dir, v, time.Now().UTC().Format("20060102T150405"))
+	if err := Backup(dir, backup); err != nil {
+		return "", nil, fmt.Errorf("error backing up %s %#q: %v", u.Kind, dir, err)
+	}
+	var applied []UpgradeStep
+	for _, s := range steps {
+		if err := s.Apply(dir); err != nil {
+			return backup, applied, fmt.Errorf("error upgrading %s %#q from version %d: %v - a backup is in %#q", u.Kind, dir, s.From, err, backup)
+		}
+		if err := u.setVersion(dir, s.From+1); err != nil {
+			return backup, applied, fmt.Errorf("error recording version %d of %s %#q: %v - a backup is in %#q", s.From+1, u.Kind, dir, err, backup)
+		}
+		applied = append(applied, s)
+	}
+	return backup, applied, nil
+}
+
+func operatorStoreVersion(dir string) (int, error) {
+	d, err := ioutil.ReadFile(filepath.Join(dir, NSCFile))
+	if err != nil {
+		return 0, err
+	}
+	var info Info
+	if err := json.Unmarshal(d, &info); err != nil {
+		return 0, fmt.Errorf("error loading %#q: %v", filepath.Join(dir, NSCFile), err)
+	}
+	return parseVersion(info.Version)
+}
+
+func setOperatorStoreVersion(dir string, v int) error {
+	fp := filepath.Join(dir, NSCFile)
+	d, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return err
+	}
+	// preserve fields this version of nsc doesn't know about
+	var m map[string]interface{}
+	if err := json.Unmarshal(d, &m); err != nil {
+		return err
+	}
+	m["version"] = strconv.Itoa(v)
+	if d, err = json.Marshal(m); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fp, d, 0600)
+}
+
+func parseVersion(v string) (int, error) {
+	// stores created before versions were tracked
+	if v == "" {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid store version %q", v)
+	}
+	return n, nil
+}
+
+func keyStoreVersion(dir string) (int, error) {
+	d, err := ioutil.ReadFile(filepath.Join(dir, KeyStoreVersionFile))
+	if err == nil {
+		n, err := strconv.Atoi(strings.TrimSpace(string(d)))
+		if err != nil {
+			return 0, fmt.Errorf("invalid keystore version %q", strings.TrimSpace(string(d)))
+		}
+		return n, nil
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+	// keystores without a version file are either the original
+	// layout or the one that introduced the keys and creds dirs
+	ok, err := dirExists(dir)
+	if err != nil || !ok {
+		return 1, err
+	}
+	old, err := IsOldKeyRing(dir)
+	if err != nil {
+		return 0, err
+	}
+	if old {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func setKeyStoreVersion(dir string, v int) error {
+	return ioutil.WriteFile(filepath.Join(dir, KeyStoreVersionFile), []byte(strconv.Itoa(v)+"\n"), 0600)
+}
+
+// upgradeKeyStoreLayout moves the keys and creds in the keystore
+// to the keys/<kind>/<shard>/<pubkey>.nk and creds/<operator>/<account>/<user>.creds layout.
+// The original contents are moved aside and only removed once the new layout is in place.
+func upgradeKeyStoreLayout(dir string) error {
+	to, err := ioutil.TempDir(filepath.Dir(dir), filepath.Base(dir)+"_upgrade")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(to)
+	keys, _, err := migrateKeyStore(dir, to)
+	if err != nil {
+		return err
+	}
+	if err := verifyKeys(keys); err != nil {
+		return err
+	}
+	old, err := ioutil.TempDir(filepath.Dir(dir), filepath.Base(dir)+"_old")
+	if err != nil {
+		return err
+	}
+	if err := moveEntries(dir, old); err != nil {
+		if rerr := moveEntries(old, dir); rerr != nil {
+			return fmt.Errorf("%v - the original keystore contents are in %#q", err, old)
+		}
+		_ = os.Remove(old)
+		return err
+	}
+	if err := moveEntries(to, dir); err != nil {
+		rerr := moveEntries(dir, to)
+		if rerr == nil {
+			rerr = moveEntries(old, dir)
+		}
+		if rerr != nil {
+			return fmt.Errorf("%v - the original keystore contents are in %#q", err, old)
+		}
+		_ = os.Remove(old)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+// verifyKeys checks that the key files have the seed of the key they are named after
+func verifyKeys(keys []string) error {
+	for _, fp := range keys {
+		d, err := dataFromFile(fp)
+		if err != nil {
+			return fmt.Errorf("error verifying %#q: %v", fp, err)
+		}
+		kp, err := nkeys.FromSeed(d)
+		if err != nil {
+			return fmt.Errorf("error verifying %#q: %v", fp, err)
+		}
+		pk, err := kp.PublicKey()
+		if err != nil {
+			return fmt.Errorf("error verifying %#q: %v", fp, err)
+		}
+		if filepath.Base(fp) != pk+NKeyExtension {
+			return fmt.Errorf("error verifying %#q: the file has the key %q", fp, pk)
+		}
+	}
+	return nil
+}
+
+// moveEntries renames the entries of the from directory into the to directory, except the lock
+func moveEntries(from string, to string) error {
+	infos, err := ioutil.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, i := range infos {
+		if i.Name() == LockName {
+			continue
+		}
+		if err := os.Rename(filepath.Join(from, i.Name()), filepath.Join(to, i.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backup writes the contents of dir to a gzip compressed tar file
+func Backup(dir string, fp string) error {
+	f, err := os.OpenFile(fp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(dir, func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, src)
+		if err != nil {
+			return err
+		}
+		if rel == "." || info.Name() == LockName {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		h, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		h.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(h); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(tw, in)
+		return err
+	})
+	for _, c := range []io.Closer{tw, gz, f} {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		os.Remove(fp)
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgrade_CurrentVersion(t *testing.T) {
+	require.Equal(t, Version, strconv.Itoa(OperatorUpgrades.Current()))
+	s := CreateTestStore(t, "O")
+	steps, err := OperatorUpgrades.Pending(s.Dir)
+	require.NoError(t, err)
+	require.Empty(t, steps)
+}
+
+func TestUpgrade_Steps(t *testing.T) {
+	dir := MakeTempDir(t)
+	u := &Upgrader{
+		Kind:    "test",
+		Base:    1,
+		version: keyStoreVersion,
+		setVersion: func(dir string, v int) error {
+			return setKeyStoreVersion(dir, v)
+		},
+	}
+	require.NoError(t, setKeyStoreVersion(dir, 1))
+	var applied []int
+	step := func(v int) UpgradeStep {
+		return UpgradeStep{From: v, Description: strconv.Itoa(v), Apply: func(dir string) error {
+			applied = append(applied, v)
+			if v == 3 {
+				return errors.New("failed")
+			}
+			return ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(v)), nil, 0600)
+		}}
+	}
+	u.Register(step(1))
+	u.Register(step(2))
+	require.Panics(t, func() { u.Register(step(2)) })
+	require.Equal(t, 3, u.Current())
+
+	steps, err := u.Pending(dir)
+	require.NoError(t, err)
+	require.Len(t, steps, 2)
+
+	backup, done, err := u.Upgrade(dir)
+	require.NoError(t, err)
+	require.Len(t, done, 2)
+	require.Equal(t, []int{1, 2}, applied)
+	require.FileExists(t, backup)
+	v, err := u.Version(dir)
+	require.NoError(t, err)
+	require.Equal(t, 3, v)
+
+	// failures stop the upgrade at the last good version
+	u.Register(step(3))
+	u.Register(step(4))
+	_, done, err = u.Upgrade(dir)
+	require.Error(t, err)
+	require.Empty(t, done)
+	v, err = u.Version(dir)
+	require.NoError(t, err)
+	require.Equal(t, 3, v)
+
+	require.NoError(t, setKeyStoreVersion(dir, 9))
+	_, err = u.Pending(dir)
+	require.Error(t, err)
+}
+
+func TestUpgrade_Backup(t *testing.T) {
+	dir := MakeTempDir(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "b", "c"), []byte("hello"), 0600))
+	require.NoError(t, AcquireFileLock(filepath.Join(dir, LockName)))
+	defer ReleaseFileLock(filepath.Join(dir, LockName))
+
+	fp := filepath.Join(MakeTempDir(t), "backup.tgz")
+	require.NoError(t, Backup(dir, fp))
+	// backups are never overwritten
+	require.Error(t, Backup(dir, fp))
+
+	f, err := os.Open(fp)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, h.Name)
+		if h.Name == "a/b/c" {
+			d, err := ioutil.ReadAll(tr)
+			require.NoError(t, err)
+			require.Equal(t, "hello", string(d))
+		}
+	}
+	require.Equal(t, []string{"a", "a/b", "a/b/c"}, names)
+}
+
+func TestUpgrade_VerifyKeys(t *testing.T) {
+	dir := MakeTempDir(t)
+	seed, pk, _ := CreateAccountKey(t)
+	_, other, _ := CreateAccountKey(t)
+
+	fp := filepath.Join(dir, pk+NKeyExtension)
+	require.NoError(t, ioutil.WriteFile(fp, seed, 0600))
+	require.NoError(t, verifyKeys([]string{fp}))
+
+	bad := filepath.Join(dir, other+NKeyExtension)
+	require.NoError(t, ioutil.WriteFile(bad, seed, 0600))
+	require.Error(t, verifyKeys([]string{fp, bad}))
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+)
+
+// ErrNotVersioned is returned when history is requested from a store
+// that doesn't record its changes
+var ErrNotVersioned = errors.New("operator store is not versioned")
+
+// UnrecordedError is returned when a change was made to the store
+// but the versioner failed to record it
+type UnrecordedError struct {
+	Path string
+	Err  error
+}
+
+func (e *UnrecordedError) Error() string {
+	return fmt.Sprintf("%#q was changed but the change was not recorded: %v", e.Path, e.Err)
+}
+
+func (e *UnrecordedError) Unwrap() error {
+	return e.Err
+}
+
+// Versioner records changes made to a store
+type Versioner interface {
+	// Init prepares the versioner and records the current state of the store
+	Init(message string) error
+	// Commit records all pending changes in the store
+	Commit(message string) error
+	// History returns the revisions that changed the named asset, newest first
+	History(name string) ([]Revision, error)
+	// Show returns the contents of the named asset at the specified revision
+	Show(revision string, name string) ([]byte, error)
+}
+
+// Revision describes a recorded change
+type Revision struct {
+	ID      string
+	Time    time.Time
+	Command string
+	Kind    string
+	Name    string
+	JwtID   string
+	Message string
+}
+
+// ShortID returns an abbreviated revision ID
+func (r *Revision) ShortID() string {
+	if len(r.ID) > 8 {
+		return r.ID[:8]
+	}
+	return r.ID
+}
+
+const redacted = "[redacted]"
+
+// RedactArgs returns the command line of the tool with the value of the
+// private key flag and any argument that looks like a seed redacted
+func RedactArgs(tool string, argv []string) string {
+	args := []string{tool}
+	for i := 0; i < len(argv); i++ {
+		a := argv[i]
+		switch {
+		case a == "-K" || a == "--private-key":
+			args = append(args, a)
+			if i+1 < len(argv) {
+				i++
+				args = append(args, redacted)
+			}
+			continue
+		case strings.HasPrefix(a, "--private-key="):
+			a = "--private-key=" + redacted
+		case strings.HasPrefix(a, "-K"):
+			a = "-K" + redacted
+		case isSeedArg(a):
+			a = redacted
+		default:
+			if i := strings.Index(a, "="); i > 0 && isSeedArg(a[i+1:]) {
+				a = a[:i+1] + redacted
+			}
+		}
+		if strings.ContainsAny(a, " \t\n\"'") {
+			a = strconv.Quote(a)
+		}
+		args = append(args, a)
+	}
+	return strings.Join(args, " ")
+}
+
+// isSeedArg returns true if the argument is an nkey seed
+func isSeedArg(a string) bool {
+	a = strings.TrimSpace(a)
+	if !strings.HasPrefix(a, "S") {
+		return false
+	}
+	_, err := nkeys.FromSeed([]byte(a))
+	return err == nil
+}
+
+// changeMessage formats the message recorded for a change to the named asset.
+// If the asset is a JWT, the kind, name and the JWT ID of the claim are added.
+// Seeds that made it into the command line are never recorded.
+func changeMessage(args []string, verb string, name string, data []byte) string {
+	cmdline := "nsc"
+	if len(args) > 0 {
+		cmdline = RedactArgs(args[0], args[1:])
+	}
+	var buf bytes.Buffer
+	buf.WriteString(cmdline)
+	buf.WriteString("\n\n")
+	if IsJwtName(name) && data != nil {
+		gc, err := jwt.DecodeGeneric(string(data))
+		if err == nil {
+			fmt.Fprintf(&buf, "kind: %s\n", gc.Type)
+			fmt.Fprintf(&buf, "name: %s\n", gc.Name)
+			fmt.Fprintf(&buf, "jwt: %s\n", gc.ID)
+		}
+	}
+	if name == "" {
+		fmt.Fprintf(&buf, "%s\n", verb)
+	} else {
+		fmt.Fprintf(&buf, "%s: %s\n", verb, filepath.ToSlash(name))
+	}
+	return buf.String()
+}
+
+func parseRevision(id string, at string, message string) Revision {
+	r := Revision{ID: id, Message: strings.TrimSpace(message)}
+	if v, err := strconv.ParseInt(at, 10, 64); err == nil {
+		r.Time = time.Unix(v, 0).UTC()
+	}
+	lines := strings.Split(r.Message, "\n")
+	r.Command = lines[0]
+	for _, l := range lines[1:] {
+		kv := strings.SplitN(l, ": ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "kind":
+			r.Kind = kv[1]
+		case "name":
+			r.Name = kv[1]
+		case "jwt":
+			r.JwtID = kv[1]
+		}
+	}
+	return r
+}
+
+// GitVersioner records the changes to a store in a git repository
+// rooted at the store directory. The git executable must be available.
+type GitVersioner struct {
+	Dir string
+}
+
+func NewGitVersioner(dir string) *GitVersioner {
+	return &GitVersioner{Dir: dir}
+}
+
+func (g *GitVersioner) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", g.Dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		m := strings.TrimSpace(stderr.String())
+		if m == "" {
+			m = err.Error()
+		}
+		return nil, fmt.Errorf("git %s failed: %s", args[0], m)
+	}
+	return out, nil
+}
+
+func (g *GitVersioner) Init(message string) error {
+	if _, err := g.git("init", "-q"); err != nil {
+		return err
+	}
+	// lock files, the key index and the trash are never recorded
+	exclude := filepath.Join(g.Dir, ".git", "info", "exclude")
+	if err := MaybeMakeDir(filepath.Dir(exclude)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(exclude, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(LockName + "\n" + IndexName + "\n" + StoreTrashDir + "/\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	// commits are made on behalf of nsc if no identity is configured
+	if _, err := g.git("config", "user.email"); err != nil {
+		if _, err := g.git("config", "user.email", "nsc@localhost"); err != nil {
+			return err
+		}
+		if _, err := g.git("config", "user.name", "nsc"); err != nil {
+			return err
+		}
+	}
+	return g.Commit(message)
+}
+
+func (g *GitVersioner) Commit(message string) error {
+	if _, err := g.git("add", "-A"); err != nil {
+		return err
+	}
+	// nothing staged, nothing to record
+	if _, err := g.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	_, err := g.git("commit", "-q", "-m", message)
+	return err
+}
+
+func (g *GitVersioner) History(name string) ([]Revision, error) {
+	out, err := g.git("log", "--format=%H%x1f%at%x1f%B%x1e", "--", filepath.ToSlash(name))
+	if err != nil {
+		return nil, err
+	}
+	var revs []Revision
+	for _, e := range strings.Split(string(out), "\x1e") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		f := strings.SplitN(e, "\x1f", 3)
+		if len(f) != 3 {
+			return nil, fmt.Errorf("unexpected git log entry %q", e)
+		}
+		revs = append(revs, parseRevision(f[0], f[1], f[2]))
+	}
+	return revs, nil
+}
+
+func (g *GitVersioner) Show(revision string, name string) ([]byte, error) {
+	return g.git("show", fmt.Sprintf("%s:%s", revision, filepath.ToSlash(name)))
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersioner_ChangeMessage(t *testing.T) {
+	_, _, kp := CreateOperatorKey(t)
+	_, apub, _ := CreateAccountKey(t)
+	ac := jwt.NewAccountClaims(apub)
+	ac.Name = "A"
+	token, err := ac.Encode(kp)
+	require.NoError(t, err)
+
+	m := changeMessage([]string{"nsc", "edit", "account", "A"}, "updated", "accounts/A/A.jwt", []byte(token))
+	r := parseRevision("abcdef0123456789", "1577836800", m)
+	require.Equal(t, "nsc edit account A", r.Command)
+	require.Equal(t, jwt.AccountClaim, r.Kind)
+	require.Equal(t, "A", r.Name)
+	require.Equal(t, ac.ID, r.JwtID)
+	require.Equal(t, "abcdef01", r.ShortID())
+	require.Equal(t, int64(1577836800), r.Time.Unix())
+
+	seed, err := kp.Seed()
+	require.NoError(t, err)
+	m = changeMessage([]string{"nsc", "edit", "account", "A", string(seed)}, "updated", "accounts/A/A.jwt", []byte(token))
+	require.NotContains(t, m, string(seed))
+	require.Equal(t, "nsc edit account A [redacted]", parseRevision("abc", "0", m).Command)
+
+	m = changeMessage(nil, "updated", ".nsc", []byte("{}"))
+	r = parseRevision("abc", "0", m)
+	require.Equal(t, "nsc", r.Command)
+	require.Empty(t, r.Kind)
+	require.Empty(t, r.JwtID)
+}
+
+func TestVersioner_RedactArgs(t *testing.T) {
+	_, pk, kp := CreateAccountKey(t)
+	seed, err := kp.Seed()
+	require.NoError(t, err)
+	s := string(seed)
+
+	require.Equal(t, "nsc edit account -K [redacted]", RedactArgs("nsc", []string{"edit", "account", "-K", s}))
+	require.Equal(t, "nsc -K [redacted]", RedactArgs("nsc", []string{"-K", "/tmp/key.nk"}))
+	require.Equal(t, "nsc --private-key=[redacted]", RedactArgs("nsc", []string{"--private-key=" + s}))
+	require.Equal(t, "nsc -K[redacted]", RedactArgs("nsc", []string{"-K" + s}))
+	require.Equal(t, "nsc import [redacted]", RedactArgs("nsc", []string{"import", s}))
+	require.Equal(t, "nsc --sk=[redacted]", RedactArgs("nsc", []string{"--sk=" + s}))
+	require.Equal(t, "nsc --sk "+pk, RedactArgs("nsc", []string{"--sk", pk}))
+}
+
+func TestVersioner_Store(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	_, _, kp := CreateOperatorKey(t)
+	_, apub, _ := CreateAccountKey(t)
+	s := CreateTestStoreForOperator(t, "O", kp)
+
+	_, err := s.History(Accounts, "A", "A.jwt")
+	require.Equal(t, ErrNotVersioned, err)
+
+	s.Args = []string{"nsc", "test"}
+	require.NoError(t, s.EnableVersioning())
+	require.True(t, s.IsVersioned())
+
+	ss, err := LoadStore(s.Dir)
+	require.NoError(t, err)
+	require.True(t, ss.IsVersioned())
+
+	ac := jwt.NewAccountClaims(apub)
+	ac.Name = "A"
+	token, err := ac.Encode(kp)
+	require.NoError(t, err)
+	require.NoError(t, s.StoreRaw([]byte(token)))
+
+	revs, err := s.History(Accounts, "A", "A.jwt")
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	require.Equal(t, "nsc test", revs[0].Command)
+	require.Equal(t, ac.ID, revs[0].JwtID)
+
+	d, err := s.ReadRevision(revs[0].ID, Accounts, "A", "A.jwt")
+	require.NoError(t, err)
+	require.Equal(t, token, string(d))
+
+	require.NoError(t, s.Delete(Accounts, "A", "A.jwt"))
+	revs, err = s.History(Accounts, "A", "A.jwt")
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	require.Equal(t, "A", revs[0].Name)
+}
+
+type failingVersioner struct{}
+
+func (failingVersioner) Init(string) error   { return nil }
+func (failingVersioner) Commit(string) error { return errors.New("no space left") }
+func (failingVersioner) History(string) ([]Revision, error) {
+	return nil, nil
+}
+func (failingVersioner) Show(string, string) ([]byte, error) {
+	return nil, nil
+}
+
+func TestVersioner_CommitFailed(t *testing.T) {
+	_, _, kp := CreateOperatorKey(t)
+	s := CreateTestStoreForOperator(t, "O", kp)
+	s.Versioner = failingVersioner{}
+
+	_, apub, _ := CreateAccountKey(t)
+	ac := jwt.NewAccountClaims(apub)
+	ac.Name = "A"
+	token, err := ac.Encode(kp)
+	require.NoError(t, err)
+
+	// the account is stored, only recording the change failed
+	err = s.StoreRaw([]byte(token))
+	var ue *UnrecordedError
+	require.True(t, errors.As(err, &ue))
+	require.Equal(t, filepath.Join(Accounts, "A", "A.jwt"), ue.Path)
+	require.True(t, s.Has(Accounts, "A", "A.jwt"))
+	e, err := s.LookupKey(apub)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+}
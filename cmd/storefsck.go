@@ -0,0 +1,365 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createStoreFsckCmd() *cobra.Command {
+	var params StoreFsckParams
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Check the structure of the operator store, keystore and creds files",
+		Long: `Check the structure of the operator store, keystore and creds files
+
+The following problems are reported:
+- JWTs whose name doesn't match their file or directory
+- user JWTs not issued by their account or one of its signing keys
+- account directories without an account JWT
+- imports from accounts that are not in the store
+- keys in the keystore not used by any operator, account or user
+- creds files for users that don't exist or that contain an outdated JWT
+
+With --fix, empty account directories are removed and outdated creds
+files are regenerated. Other problems are only reported.`,
+		Example: `nsc store fsck
+nsc store fsck --fix`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().BoolVarP(&params.fix, "fix", "", false, "repair the problems that can be repaired safely")
+	return cmd
+}
+
+func init() {
+	storeCmd.AddCommand(createStoreFsckCmd())
+}
+
+type StoreFsckParams struct {
+	fix bool
+	// public keys used by all the operators in the store directory
+	inUse map[string]bool
+}
+
+func (p *StoreFsckParams) SetDefaults(_ ActionCtx) error {
+	return nil
+}
+
+func (p *StoreFsckParams) PreInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *StoreFsckParams) Load(ctx ActionCtx) error {
+	// the keystore is shared by all operators
+	p.inUse = make(map[string]bool)
+	config := GetConfig()
+	current := ctx.StoreCtx().Store
+	operators := []string{current.GetName()}
+	for _, o := range config.ListOperators() {
+		if o != current.GetName() {
+			operators = append(operators, o)
+		}
+	}
+	for _, o := range operators {
+		s := current
+		if o != current.GetName() {
+			var err error
+			s, err = config.LoadStore(o)
+			if err != nil {
+				return fmt.Errorf("error loading operator %q: %v", o, err)
+			}
+		}
+		x, err := s.RebuildIndex()
+		if err != nil {
+			return fmt.Errorf("error indexing operator %q: %v", o, err)
+		}
+		for _, e := range x.List() {
+			p.inUse[e.Key] = true
+		}
+	}
+	return nil
+}
+
+func (p *StoreFsckParams) PostInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *StoreFsckParams) Validate(_ ActionCtx) error {
+	return nil
+}
+
+func (p *StoreFsckParams) ReadOnly() bool {
+	return !p.fix
+}
+
+func (p *StoreFsckParams) Run(ctx ActionCtx) (store.Status, error) {
+	r := store.NewDetailedReport(false)
+	r.Opt = store.DetailsOnly
+	p.checkOperator(ctx, r)
+	p.checkAccounts(ctx, r)
+	p.checkKeys(ctx, r)
+	p.checkCreds(ctx, r)
+	if len(r.Details) == 0 {
+		r.AddOK("no problems found")
+	}
+	return r, nil
+}
+
+func (p *StoreFsckParams) checkOperator(ctx ActionCtx, r *store.Report) {
+	s := ctx.StoreCtx().Store
+	if s.IsManaged() && !s.Has(store.JwtName(s.GetName())) {
+		return
+	}
+	oc, err := s.ReadOperatorClaim()
+	if err != nil {
+		r.AddError("error reading operator %q: %v", s.GetName(), err)
+		return
+	}
+	if oc.Name != s.GetName() {
+		r.AddError("operator JWT %#q is for operator %q", store.JwtName(s.GetName()), oc.Name)
+	}
+}
+
+func (p *StoreFsckParams) checkAccounts(ctx ActionCtx, r *store.Report) {
+	s := ctx.StoreCtx().Store
+	infos, err := s.List(store.Accounts)
+	if err != nil {
+		r.AddError("error listing accounts: %v", err)
+		return
+	}
+	for _, i := range infos {
+		if !i.IsDir() {
+			continue
+		}
+		dir := i.Name()
+		if !s.Has(store.Accounts, dir, store.JwtName(dir)) {
+			p.orphanAccountDir(ctx, dir, r)
+			continue
+		}
+		ac, err := s.ReadAccountClaim(dir)
+		if err != nil {
+			r.AddError("error reading account JWT in %#q: %v", filepath.Join(store.Accounts, dir), err)
+			continue
+		}
+		if ac.Name != dir {
+			r.AddError("account directory %#q contains the JWT for account %q", filepath.Join(store.Accounts, dir), ac.Name)
+		}
+		p.checkImports(ctx, dir, ac, r)
+		p.checkUsers(ctx, dir, ac, r)
+	}
+}
+
+func (p *StoreFsckParams) orphanAccountDir(ctx ActionCtx, dir string, r *store.Report) {
+	s := ctx.StoreCtx().Store
+	fp := filepath.Join(store.Accounts, dir)
+	users, err := s.ListEntries(store.Accounts, dir, store.Users)
+	if err != nil {
+		r.AddError("error listing %#q: %v", fp, err)
+		return
+	}
+	if len(users) > 0 {
+		r.AddError("account directory %#q doesn't have an account JWT but contains users %s", fp, strings.Join(users, ", "))
+		return
+	}
+	if !p.fix {
+		r.AddWarning("account directory %#q doesn't have an account JWT", fp)
+		return
+	}
+	if err := p.removeEmptyDir(s, store.Accounts, dir); err != nil {
+		r.AddError("unable to remove account directory %#q without an account JWT: %v", fp, err)
+		return
+	}
+	r.AddOK("removed account directory %#q without an account JWT", fp)
+}
+
+// removeEmptyDir removes a directory that only contains empty directories
+func (p *StoreFsckParams) removeEmptyDir(s *store.Store, name ...string) error {
+	infos, err := s.List(name...)
+	if err != nil {
+		return err
+	}
+	for _, i := range infos {
+		if !i.IsDir() {
+			return fmt.Errorf("directory contains %#q", i.Name())
+		}
+		if err := p.removeEmptyDir(s, append(name, i.Name())...); err != nil {
+			return err
+		}
+	}
+	return s.Delete(name...)
+}
+
+func (p *StoreFsckParams) checkImports(ctx ActionCtx, dir string, ac *jwt.AccountClaims, r *store.Report) {
+	s := ctx.StoreCtx().Store
+	for _, im := range ac.Imports {
+		e, err := s.LookupKey(im.Account)
+		if err != nil {
+			r.AddError("error looking up account %q: %v", im.Account, err)
+			continue
+		}
+		if e == nil || e.Kind != jwt.AccountClaim {
+			r.AddWarning("account %q imports %q from account %q which is not in the store", dir, im.Subject, im.Account)
+		}
+	}
+}
+
+func (p *StoreFsckParams) checkUsers(ctx ActionCtx, account string, ac *jwt.AccountClaims, r *store.Report) {
+	s := ctx.StoreCtx().Store
+	users, err := s.ListEntries(store.Accounts, account, store.Users)
+	if err != nil {
+		r.AddError("error listing users for account %q: %v", account, err)
+		return
+	}
+	for _, u := range users {
+		fp := filepath.Join(store.Accounts, account, store.Users, store.JwtName(u))
+		uc, err := s.ReadUserClaim(account, u)
+		if err != nil {
+			r.AddError("error reading user JWT %#q: %v", fp, err)
+			continue
+		}
+		if uc.Name != u {
+			r.AddError("user JWT %#q is for user %q", fp, uc.Name)
+		}
+		if uc.IssuerAccount != "" && uc.IssuerAccount != ac.Subject {
+			r.AddError("user %q in account %q has issuer account %q", u, account, uc.IssuerAccount)
+		} else if uc.Issuer != ac.Subject && !ac.SigningKeys.Contains(uc.Issuer) {
+			r.AddError("user %q was issued by %q which is neither account %q nor one of its signing keys", u, uc.Issuer, account)
+		}
+	}
+}
+
+func (p *StoreFsckParams) checkKeys(ctx ActionCtx, r *store.Report) {
+	ks := ctx.StoreCtx().KeyStore
+	keys, err := ks.AllKeys()
+	if err != nil {
+		r.AddError("error listing the keystore: %v", err)
+		return
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kind, err := store.PubKeyType(k)
+		if err != nil {
+			continue
+		}
+		switch kind {
+		case nkeys.PrefixByteOperator, nkeys.PrefixByteAccount, nkeys.PrefixByteUser:
+		default:
+			continue
+		}
+		if !p.inUse[k] {
+			r.AddWarning("key %q in %#q is not used by any operator, account or user", k, AbbrevHomePaths(ks.GetKeyPath(k)))
+		}
+	}
+}
+
+func (p *StoreFsckParams) checkCreds(ctx ActionCtx, r *store.Report) {
+	s := ctx.StoreCtx().Store
+	ks := ctx.StoreCtx().KeyStore
+	dir := filepath.Join(store.GetKeysDir(), store.CredsDir, ks.Env)
+	accounts, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			r.AddError("error listing creds files: %v", err)
+		}
+		return
+	}
+	for _, a := range accounts {
+		if !a.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(dir, a.Name()))
+		if err != nil {
+			r.AddError("error listing creds files: %v", err)
+			continue
+		}
+		for _, f := range files {
+			if filepath.Ext(f.Name()) != store.CredsExtension {
+				continue
+			}
+			u := strings.TrimSuffix(f.Name(), store.CredsExtension)
+			p.checkUserCreds(s, ks, a.Name(), u, filepath.Join(dir, a.Name(), f.Name()), r)
+		}
+	}
+}
+
+func (p *StoreFsckParams) checkUserCreds(s *store.Store, ks store.KeyStore, account string, user string, fp string, r *store.Report) {
+	afp := AbbrevHomePaths(fp)
+	d, err := ioutil.ReadFile(fp)
+	if err != nil {
+		r.AddError("error reading creds file %#q: %v", afp, err)
+		return
+	}
+	token, err := jwt.ParseDecoratedJWT(d)
+	if err != nil {
+		r.AddError("error parsing creds file %#q: %v", afp, err)
+		return
+	}
+	if !s.Has(store.Accounts, account, store.Users, store.JwtName(user)) {
+		r.AddWarning("creds file %#q is for user %q in account %q which is not in the store", afp, user, account)
+		return
+	}
+	stored, err := s.ReadRawUserClaim(account, user)
+	if err != nil {
+		r.AddError("error reading user %q: %v", user, err)
+		return
+	}
+	if token == string(stored) {
+		return
+	}
+	uc, err := jwt.DecodeUserClaims(string(stored))
+	if err != nil {
+		r.AddError("error decoding user %q: %v", user, err)
+		return
+	}
+	if cc, err := jwt.DecodeUserClaims(token); err == nil && cc.Subject != uc.Subject {
+		r.AddError("creds file %#q is for user key %q, user %q has key %q", afp, cc.Subject, user, uc.Subject)
+		return
+	}
+	if !p.fix {
+		r.AddWarning("creds file %#q contains an outdated JWT for user %q", afp, user)
+		return
+	}
+	if !ks.HasPrivateKey(uc.Subject) {
+		r.AddError("unable to regenerate outdated creds file %#q - the private key for user %q is not in the keystore", afp, user)
+		return
+	}
+	ukp, err := ks.GetKeyPair(uc.Subject)
+	if err == nil {
+		d, err = GenerateConfig(s, account, user, ukp)
+	}
+	if err == nil {
+		_, err = ks.MaybeStoreUserCreds(account, user, d)
+	}
+	if err != nil {
+		r.AddError("unable to regenerate outdated creds file %#q: %v", afp, err)
+		return
+	}
+	r.AddOK("regenerated outdated creds file %#q", afp)
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StoreFsckClean(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+	ts.AddExport(t, "A", jwt.Stream, "q", false)
+	ts.AddAccount(t, "B")
+	ts.AddImport(t, "A", "q", "B")
+
+	_, stderr, err := ExecuteCmd(createStoreFsckCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, "no problems found")
+}
+
+func Test_StoreFsckProblems(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+	ts.AddUser(t, "A", "V")
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	akp, err := ts.KeyStore.GetKeyPair(ac.Subject)
+	require.NoError(t, err)
+
+	// directory without an account
+	require.NoError(t, os.MkdirAll(filepath.Join(ts.Store.Dir, store.Accounts, "X", store.Users), 0700))
+
+	// user issued by a foreign account
+	_, _, fkp := CreateAccountKey(t)
+	_, wpk, _ := CreateUserKey(t)
+	wc := jwt.NewUserClaims(wpk)
+	wc.Name = "W"
+	token, err := wc.Encode(fkp)
+	require.NoError(t, err)
+	require.NoError(t, ts.Store.Write([]byte(token), store.Accounts, "A", store.Users, store.JwtName("W")))
+
+	// import from an account not in the store
+	_, epk, _ := CreateAccountKey(t)
+	ac.Imports.Add(&jwt.Import{Name: "x", Subject: "x", Account: epk, Type: jwt.Stream})
+	token, err = ac.Encode(ts.OperatorKey)
+	require.NoError(t, err)
+	require.NoError(t, ts.Store.StoreRaw([]byte(token)))
+
+	// unused key
+	_, _, okp := CreateUserKey(t)
+	_, err = ts.KeyStore.Store(okp)
+	require.NoError(t, err)
+	opk, err := okp.PublicKey()
+	require.NoError(t, err)
+
+	// creds with an outdated jwt
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	uc.Tags.Add("changed")
+	token, err = uc.Encode(akp)
+	require.NoError(t, err)
+	require.NoError(t, ts.Store.StoreRaw([]byte(token)))
+
+	// creds for a user that is gone
+	require.NoError(t, ts.Store.Delete(store.Accounts, "A", store.Users, store.JwtName("V")))
+
+	_, stderr, err := ExecuteCmd(createStoreFsckCmd())
+	require.Error(t, err)
+	require.Contains(t, stderr, "doesn't have an account JWT")
+	require.Contains(t, stderr, "user \"W\" was issued by")
+	require.Contains(t, stderr, epk)
+	require.Contains(t, stderr, opk)
+	require.Contains(t, stderr, "outdated JWT for user \"U\"")
+	require.Contains(t, stderr, "user \"V\" in account \"A\" which is not in the store")
+	require.True(t, ts.Store.Has(store.Accounts, "X"))
+
+	_, stderr, err = ExecuteCmd(createStoreFsckCmd(), "--fix")
+	require.Error(t, err)
+	require.Contains(t, stderr, "removed account directory")
+	require.Contains(t, stderr, "regenerated outdated creds file")
+	require.False(t, ts.Store.Has(store.Accounts, "X"))
+	// keys are never removed
+	require.True(t, ts.KeyStore.HasPrivateKey(opk))
+
+	_, stderr, err = ExecuteCmd(createStoreFsckCmd())
+	require.Error(t, err)
+	require.NotContains(t, stderr, "outdated")
+	require.NotContains(t, stderr, "doesn't have an account JWT")
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"sort"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createStoreReindexCmd() *cobra.Command {
+	var params StoreReindexParams
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild the index of public keys in the operator store",
+		Long: `Rebuild the index of public keys in the operator store.
+
+nsc keeps an index mapping the public keys (identity and signing keys)
+referenced by the operator, account and user JWTs to the entity using
+them. The index is updated as nsc modifies the store, rebuild it if
+JWTs were added, removed or modified by other means.`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	return cmd
+}
+
+func init() {
+	storeCmd.AddCommand(createStoreReindexCmd())
+}
+
+type StoreReindexParams struct {
+	previous map[string]store.IndexEntry
+}
+
+func (p *StoreReindexParams) SetDefaults(_ ActionCtx) error {
+	return nil
+}
+
+func (p *StoreReindexParams) PreInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *StoreReindexParams) Load(ctx ActionCtx) error {
+	x, err := ctx.StoreCtx().Store.Index()
+	if err != nil {
+		return err
+	}
+	p.previous = make(map[string]store.IndexEntry)
+	for _, e := range x.List() {
+		p.previous[e.Key] = *e
+	}
+	return nil
+}
+
+func (p *StoreReindexParams) PostInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *StoreReindexParams) Validate(_ ActionCtx) error {
+	return nil
+}
+
+func (p *StoreReindexParams) Run(ctx ActionCtx) (store.Status, error) {
+	r := store.NewDetailedReport(true)
+	x, err := ctx.StoreCtx().Store.RebuildIndex()
+	if err != nil {
+		r.AddFromError(err)
+		return r, nil
+	}
+	for _, e := range x.List() {
+		old, ok := p.previous[e.Key]
+		delete(p.previous, e.Key)
+		if !ok {
+			r.AddOK("indexed missing %s key %q for %#q", e.Kind, e.Key, e.Path)
+		} else if old.Path != e.Path || old.Name != e.Name || old.Subject != e.Subject {
+			r.AddOK("updated %s key %q for %#q", e.Kind, e.Key, e.Path)
+		}
+	}
+	var stale []string
+	for k := range p.previous {
+		stale = append(stale, k)
+	}
+	sort.Strings(stale)
+	for _, k := range stale {
+		e := p.previous[k]
+		r.AddOK("removed stale %s key %q for %#q", e.Kind, k, e.Path)
+	}
+	r.AddOK("indexed %d keys", x.Len())
+	return r, nil
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StoreReindex(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddUser(t, "A", "U")
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createStoreReindexCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, "indexed 3 keys")
+	require.NotContains(t, stderr, "missing")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ts.Store.Dir, store.IndexName), []byte(`{"version":1,"keys":{}}`), 0600))
+	_, stderr, err = ExecuteCmd(createStoreReindexCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, "indexed missing account key")
+	require.Contains(t, stderr, ac.Subject)
+
+	s, err := store.LoadStore(ts.Store.Dir)
+	require.NoError(t, err)
+	e, err := s.LookupKey(ac.Subject)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	require.Equal(t, "A", e.Name)
+}
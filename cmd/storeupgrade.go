@@ -0,0 +1,161 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+)
+
+func createStoreUpgradeCmd() *cobra.Command {
+	var params StoreUpgradeParams
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade the keystore and operator stores to the layout used by this version of nsc",
+		Long: `Upgrade the keystore and operator stores to the layout used by this version of nsc.
+
+Upgrades are applied in order, before upgrading the keystore or an operator
+store, a compressed backup of it is written next to it. Use --dry-run to
+list the pending upgrades without making any changes.`,
+		Example: `nsc store upgrade --dry-run
+nsc store upgrade
+nsc store upgrade --all (upgrade all the operators in the store directory)`,
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return params.Run(cmd)
+		},
+	}
+	cmd.Flags().BoolVarP(&params.all, "all", "A", false, "upgrade all operators, not just the current one")
+	return cmd
+}
+
+func init() {
+	storeCmd.AddCommand(createStoreUpgradeCmd())
+}
+
+type StoreUpgradeParams struct {
+	all bool
+}
+
+type upgradeTarget struct {
+	upgrader *store.Upgrader
+	name     string
+	dir      string
+}
+
+func (p *StoreUpgradeParams) targets() []upgradeTarget {
+	targets := []upgradeTarget{{upgrader: store.KeyStoreUpgrades, name: "keystore", dir: store.GetKeysDir()}}
+	config := GetConfig()
+	if config.StoreRoot == "" {
+		return targets
+	}
+	var operators []string
+	if p.all {
+		operators = config.ListOperators()
+	} else if config.Operator != "" {
+		operators = append(operators, config.Operator)
+	}
+	for _, o := range operators {
+		targets = append(targets, upgradeTarget{upgrader: store.OperatorUpgrades, name: fmt.Sprintf("operator %q", o), dir: filepath.Join(config.StoreRoot, o)})
+	}
+	return targets
+}
+
+func (p *StoreUpgradeParams) Run(cmd *cobra.Command) error {
+	r := store.NewDetailedReport(true)
+	for _, t := range p.targets() {
+		steps, err := t.upgrader.Pending(t.dir)
+		if err != nil {
+			r.AddFromError(err)
+			continue
+		}
+		if len(steps) == 0 {
+			r.AddOK("%s %#q is up to date", t.name, AbbrevHomePaths(t.dir))
+			continue
+		}
+		if DryRunFlag {
+			sr := store.NewReport(store.OK, "%s %#q needs %d upgrades", t.name, AbbrevHomePaths(t.dir), len(steps))
+			for _, s := range steps {
+				sr.AddOK("version %d to %d: %s", s.From, s.From+1, s.Description)
+			}
+			r.Add(sr)
+			continue
+		}
+		backup, applied, err := t.upgrader.Upgrade(t.dir)
+		sr := store.NewReport(store.OK, "upgraded %s %#q", t.name, AbbrevHomePaths(t.dir))
+		if backup != "" {
+			sr.AddOK("backed up to %#q", AbbrevHomePaths(backup))
+		}
+		for _, s := range applied {
+			sr.AddOK("version %d to %d: %s", s.From, s.From+1, s.Description)
+		}
+		if err != nil {
+			sr.AddFromError(err)
+		}
+		r.Add(sr)
+	}
+	cmd.Println(r.Message())
+	_, err := r.Summary()
+	return err
+}
+
+// isUpgradeCmd returns true for commands that must run on stores
+// that need to be upgraded
+func isUpgradeCmd(cmd *cobra.Command) bool {
+	if !cmd.HasParent() {
+		return false
+	}
+	switch cmd.Parent().Name() {
+	case "keys":
+		return cmd.Name() == "migrate"
+	case "store":
+		return cmd.Name() == "upgrade"
+	}
+	return false
+}
+
+// checkStoreVersions returns an error if the keystore or the current
+// operator store need to be upgraded or were created by a newer nsc
+func checkStoreVersions() error {
+	steps, err := store.KeyStoreUpgrades.Pending(store.GetKeysDir())
+	if err != nil {
+		return err
+	}
+	if len(steps) > 0 {
+		return fmt.Errorf("the keystore %#q needs to be upgraded - type `%s store upgrade` to update", AbbrevHomePaths(store.GetKeysDir()), GetToolName())
+	}
+	config := GetConfig()
+	if config.StoreRoot == "" || config.Operator == "" {
+		return nil
+	}
+	dir := filepath.Join(config.StoreRoot, config.Operator)
+	if _, err := os.Stat(filepath.Join(dir, store.NSCFile)); err != nil {
+		return nil
+	}
+	steps, err = store.OperatorUpgrades.Pending(dir)
+	if err != nil {
+		return err
+	}
+	if len(steps) > 0 {
+		return fmt.Errorf("operator %q needs to be upgraded - type `%s store upgrade` to update", config.Operator, GetToolName())
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/stretchr/testify/require"
+)
+
+func setStoreVersion(t *testing.T, dir string, version string) {
+	fp := filepath.Join(dir, store.NSCFile)
+	d, err := ioutil.ReadFile(fp)
+	require.NoError(t, err)
+	var info store.Info
+	require.NoError(t, json.Unmarshal(d, &info))
+	info.Version = version
+	d, err = json.Marshal(info)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(fp, d, 0600))
+}
+
+func Test_StoreUpgradeNotRequiredForIndex(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	// stores created before versions and the index were tracked
+	dir := ts.Store.Dir
+	setStoreVersion(t, dir, "")
+	_ = os.Remove(filepath.Join(dir, store.IndexName))
+	require.NoError(t, checkStoreVersions())
+
+	_, stderr, err := ExecuteCmd(HoistRootFlags(createStoreUpgradeCmd()), "--dry-run")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "is up to date")
+
+	// the index is built when it is needed
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	s, err := store.LoadStore(dir)
+	require.NoError(t, err)
+	e, err := s.LookupKey(ac.Subject)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	require.Equal(t, "A", e.Name)
+
+	backups, err := filepath.Glob(dir + "_v*.tgz")
+	require.NoError(t, err)
+	require.Empty(t, backups)
+}
+
+func Test_StoreUpgradeRefusesNewer(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	setStoreVersion(t, ts.Store.Dir, fmt.Sprintf("%d", store.OperatorUpgrades.Current()+1))
+	_, err := store.LoadStore(ts.Store.Dir)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), store.ErrNewerStore.Error())
+
+	err = checkStoreVersions()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), store.ErrNewerStore.Error())
+
+	_, _, err = ExecuteCmd(createStoreUpgradeCmd())
+	require.Error(t, err)
+}
+
+func Test_StoreUpgradeKeyStore(t *testing.T) {
+	ts := NewEmptyStore(t)
+	defer ts.Done(t)
+
+	oseed, opk, _ := CreateOperatorKey(t)
+	require.NoError(t, storeOldKey(ts, "O", "", "", oseed))
+	useed, upk, _ := CreateUserKey(t)
+	require.NoError(t, storeOldKey(ts, "O", "A", "U", useed))
+	require.NoError(t, storeOldCreds(ts, "O", "A", "U", []byte("user")))
+
+	err := checkStoreVersions()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "keystore")
+
+	_, stderr, err := ExecuteCmd(createStoreUpgradeCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, "upgraded keystore")
+	require.NoError(t, checkStoreVersions())
+
+	ks := store.GetKeysDir()
+	require.FileExists(t, filepath.Join(ks, "keys", "O", opk[1:3], fmt.Sprintf("%s.nk", opk)))
+	require.FileExists(t, filepath.Join(ks, "keys", "U", upk[1:3], fmt.Sprintf("%s.nk", upk)))
+	require.FileExists(t, filepath.Join(ks, "creds", "O", "A", "U.creds"))
+	_, err = os.Stat(filepath.Join(ks, "O", "O.nk"))
+	require.True(t, os.IsNotExist(err))
+	v, err := store.KeyStoreUpgrades.Version(ks)
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+
+	backups, err := filepath.Glob(ks + "_v0_*.tgz")
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	// the original contents are removed once the new layout is in place
+	leftovers, err := filepath.Glob(ks + "_old*")
+	require.NoError(t, err)
+	require.Empty(t, leftovers)
+}
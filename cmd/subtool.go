@@ -114,6 +114,10 @@ func (p *SubParams) Validate(ctx ActionCtx) error {
 	return nil
 }
 
+func (p *SubParams) ReadOnly() bool {
+	return true
+}
+
 func (p *SubParams) Run(ctx ActionCtx) (store.Status, error) {
 	opts := createDefaultToolOptions("nscsub", ctx)
 	opts = append(opts, nats.UserCredentials(p.credsPath))
@@ -0,0 +1,272 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nkeys"
+	"github.com/nats-io/nsc/cmd/store"
+	"gopkg.in/yaml.v2"
+)
+
+// Topology declares the accounts of an operator with their exports,
+// imports and users. Lists are complete - entries that are not declared
+// are removed when the topology is applied.
+type Topology struct {
+	Operator string             `yaml:"operator,omitempty"`
+	Accounts []*TopologyAccount `yaml:"accounts,omitempty"`
+}
+
+type TopologyAccount struct {
+	Name        string            `yaml:"name"`
+	SigningKeys []string          `yaml:"signing_keys,omitempty"`
+	Tags        []string          `yaml:"tags,omitempty"`
+	Limits      TopologyLimits    `yaml:"limits,omitempty"`
+	Exports     []*TopologyExport `yaml:"exports,omitempty"`
+	Imports     []*TopologyImport `yaml:"imports,omitempty"`
+	Users       []*TopologyUser   `yaml:"users,omitempty"`
+}
+
+// TopologyLimits are the limits of an account, limits that are
+// not declared are unlimited
+type TopologyLimits struct {
+	Conns           *int64 `yaml:"conns,omitempty"`
+	LeafConns       *int64 `yaml:"leaf_conns,omitempty"`
+	Subscriptions   *int64 `yaml:"subscriptions,omitempty"`
+	Imports         *int64 `yaml:"imports,omitempty"`
+	Exports         *int64 `yaml:"exports,omitempty"`
+	Data            *int64 `yaml:"data,omitempty"`
+	Payload         *int64 `yaml:"payload,omitempty"`
+	WildcardExports *bool  `yaml:"wildcard_exports,omitempty"`
+}
+
+type TopologyExport struct {
+	Name         string `yaml:"name,omitempty"`
+	Subject      string `yaml:"subject"`
+	Type         string `yaml:"type,omitempty"`
+	Private      bool   `yaml:"private,omitempty"`
+	ResponseType string `yaml:"response_type,omitempty"`
+}
+
+// TopologyImport imports the subject from an account in the topology,
+// or from an account outside of the operator by its public key
+type TopologyImport struct {
+	Name         string `yaml:"name,omitempty"`
+	Account      string `yaml:"account"`
+	Subject      string `yaml:"subject"`
+	LocalSubject string `yaml:"local_subject,omitempty"`
+	Type         string `yaml:"type,omitempty"`
+}
+
+type TopologyUser struct {
+	Name     string   `yaml:"name"`
+	AllowPub []string `yaml:"allow_pub,omitempty"`
+	AllowSub []string `yaml:"allow_sub,omitempty"`
+	DenyPub  []string `yaml:"deny_pub,omitempty"`
+	DenySub  []string `yaml:"deny_sub,omitempty"`
+	Tags     []string `yaml:"tags,omitempty"`
+
+	Limits TopologyUserLimits `yaml:"limits,omitempty"`
+}
+
+// TopologyUserLimits are the limits of a user, limits that are
+// not declared are unlimited
+type TopologyUserLimits struct {
+	Payload *int64   `yaml:"payload,omitempty"`
+	Src     []string `yaml:"src,omitempty"`
+}
+
+// ReadTopology parses a topology in YAML or JSON
+func ReadTopology(data []byte) (*Topology, error) {
+	var t Topology
+	if err := yaml.UnmarshalStrict(data, &t); err != nil {
+		return nil, fmt.Errorf("error parsing the topology: %v", err)
+	}
+	return &t, nil
+}
+
+func exportType(s string) (jwt.ExportType, error) {
+	switch strings.ToLower(s) {
+	case "", jwt.Stream.String():
+		return jwt.Stream, nil
+	case jwt.Service.String():
+		return jwt.Service, nil
+	}
+	return jwt.Unknown, fmt.Errorf("type %q must be %s or %s", s, jwt.Stream.String(), jwt.Service.String())
+}
+
+// Account returns the account with the name, nil if the topology doesn't declare it
+func (t *Topology) Account(name string) *TopologyAccount {
+	for _, a := range t.Accounts {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Export returns the export containing the subject
+func (a *TopologyAccount) Export(subject string) *TopologyExport {
+	for _, e := range a.Exports {
+		if jwt.Subject(subject).IsContainedIn(jwt.Subject(e.Subject)) {
+			return e
+		}
+	}
+	return nil
+}
+
+func validSubject(s string) error {
+	var vr jwt.ValidationResults
+	jwt.Subject(s).Validate(&vr)
+	if len(vr.Issues) > 0 {
+		return errors.New(vr.Issues[0].Description)
+	}
+	return nil
+}
+
+// Validate checks the topology, imports must reference exports in the
+// topology or accounts outside of the operator
+func (t *Topology) Validate(operator string) error {
+	if t.Operator != "" && t.Operator != operator {
+		return fmt.Errorf("the topology is for operator %q - the current operator is %q", t.Operator, operator)
+	}
+	accounts := make(map[string]bool)
+	for _, a := range t.Accounts {
+		if a.Name == "" {
+			return errors.New("accounts require a name")
+		}
+		lcn := strings.ToLower(a.Name)
+		if accounts[lcn] {
+			return fmt.Errorf("account %q is declared more than once", a.Name)
+		}
+		accounts[lcn] = true
+		if err := a.validate(); err != nil {
+			return fmt.Errorf("account %q: %v", a.Name, err)
+		}
+	}
+	for _, a := range t.Accounts {
+		for _, im := range a.Imports {
+			if err := t.validateImport(a, im); err != nil {
+				return fmt.Errorf("account %q: import %q: %v", a.Name, im.Subject, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *TopologyAccount) validate() error {
+	for _, k := range a.SigningKeys {
+		if !store.IsPublicKey(nkeys.PrefixByteAccount, k) {
+			return fmt.Errorf("signing key %q is not an account public key", k)
+		}
+	}
+	subjects := make(map[string]bool)
+	for _, e := range a.Exports {
+		kind, err := exportType(e.Type)
+		if err != nil {
+			return fmt.Errorf("export %q: %v", e.Subject, err)
+		}
+		if subjects[e.Subject] {
+			return fmt.Errorf("export %q is declared more than once", e.Subject)
+		}
+		subjects[e.Subject] = true
+		x := jwt.Export{Subject: jwt.Subject(e.Subject), Type: kind, ResponseType: jwt.ResponseType(e.ResponseType)}
+		if kind == jwt.Service && x.ResponseType == "" {
+			x.ResponseType = jwt.ResponseTypeSingleton
+		}
+		var vr jwt.ValidationResults
+		x.Validate(&vr)
+		if len(vr.Issues) > 0 {
+			return fmt.Errorf("export %q: %s", e.Subject, vr.Issues[0].Description)
+		}
+	}
+	users := make(map[string]bool)
+	for _, u := range a.Users {
+		if u.Name == "" {
+			return errors.New("users require a name")
+		}
+		if users[u.Name] {
+			return fmt.Errorf("user %q is declared more than once", u.Name)
+		}
+		users[u.Name] = true
+		for _, l := range [][]string{u.AllowPub, u.AllowSub, u.DenyPub, u.DenySub} {
+			for _, s := range l {
+				if err := validSubject(s); err != nil {
+					return fmt.Errorf("user %q: %v", u.Name, err)
+				}
+			}
+		}
+		if err := u.Limits.validate(); err != nil {
+			return fmt.Errorf("user %q: %v", u.Name, err)
+		}
+	}
+	return nil
+}
+
+func (l *TopologyUserLimits) validate() error {
+	if l.Payload != nil && *l.Payload < -1 {
+		return fmt.Errorf("payload limit %d is invalid", *l.Payload)
+	}
+	lim := jwt.Limits{Src: strings.Join(l.Src, ",")}
+	var vr jwt.ValidationResults
+	lim.Validate(&vr)
+	if len(vr.Issues) > 0 {
+		return errors.New(vr.Issues[0].Description)
+	}
+	return nil
+}
+
+func (t *Topology) validateImport(a *TopologyAccount, im *TopologyImport) error {
+	kind, err := exportType(im.Type)
+	if err != nil {
+		return err
+	}
+	if err := validSubject(im.Subject); err != nil {
+		return err
+	}
+	if kind == jwt.Service && jwt.Subject(im.Subject).HasWildCards() {
+		return errors.New("imported services cannot have wildcards")
+	}
+	if im.LocalSubject != "" && jwt.Subject(im.LocalSubject).HasWildCards() {
+		return errors.New("the local subject cannot have wildcards")
+	}
+	if im.Account == "" {
+		return errors.New("the account exporting the subject is required")
+	}
+	if im.Account == a.Name {
+		return errors.New("an account cannot import from itself")
+	}
+	src := t.Account(im.Account)
+	if src == nil {
+		if !store.IsPublicKey(nkeys.PrefixByteAccount, im.Account) {
+			return fmt.Errorf("account %q is not declared in the topology", im.Account)
+		}
+		// accounts outside of the operator are not checked
+		return nil
+	}
+	e := src.Export(im.Subject)
+	if e == nil {
+		return fmt.Errorf("account %q doesn't export %q", im.Account, im.Subject)
+	}
+	if ek, _ := exportType(e.Type); ek != kind {
+		return fmt.Errorf("account %q exports %q as a %s", im.Account, e.Subject, ek.String())
+	}
+	return nil
+}
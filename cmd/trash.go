@@ -0,0 +1,265 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/jwt"
+	"github.com/nats-io/nsc/cmd/store"
+	"github.com/spf13/cobra"
+	"github.com/xlab/tablewriter"
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List and purge deleted accounts and users",
+	Long: `List and purge deleted accounts and users.
+
+Deleted accounts and users are moved to the trash. The JWTs are kept
+in the operator directory, nkeys and creds files removed with --rm-nkey
+and --rm-creds are kept in the keystore. Use 'nsc restore' to bring
+them back, and 'nsc trash purge' to delete them permanently.`,
+}
+
+func init() {
+	GetRootCmd().AddCommand(trashCmd)
+	trashCmd.AddCommand(createTrashListCmd())
+	trashCmd.AddCommand(createTrashPurgeCmd())
+}
+
+// trashUser moves the JWT of a user to the trash entry, and optionally
+// its nkey and creds file
+func trashUser(ctx ActionCtx, e *store.TrashEntry, account string, user string, uc *jwt.UserClaims, rmKey bool, rmCreds bool, r *store.Report) error {
+	s := ctx.StoreCtx().Store
+	ks := ctx.StoreCtx().KeyStore
+	fp := []string{store.Accounts, account, store.Users, store.JwtName(user)}
+	d, err := s.Read(fp...)
+	if err != nil {
+		return err
+	}
+	if err := s.TrashFile(e, d, fp...); err != nil {
+		return err
+	}
+	if err := s.Delete(fp...); err != nil {
+		return err
+	}
+	r.AddOK("user deleted")
+
+	if rmKey {
+		trashKey(ctx, e, "private key", uc.Subject, r)
+	}
+	if rmCreds {
+		ok, err := ks.TrashUserCreds(e, account, user)
+		if err != nil {
+			r.AddFromError(err)
+		} else if ok {
+			r.AddOK("moved creds file to the trash")
+		} else {
+			r.AddOK("creds file is not stored")
+		}
+	}
+	return nil
+}
+
+// trashKey moves the key to the trash entry if it is in the keystore
+func trashKey(ctx ActionCtx, e *store.TrashEntry, label string, pubkey string, r *store.Report) {
+	ok, err := ctx.StoreCtx().KeyStore.TrashKey(e, pubkey)
+	if err != nil {
+		r.AddFromError(err)
+	} else if ok {
+		r.AddOK("moved %s to the trash", label)
+	} else {
+		r.AddOK("%s is not stored", label)
+	}
+}
+
+// trashEntryName describes the entity in a trash entry
+func trashEntryName(e *store.TrashEntry) string {
+	if e.Kind == jwt.UserClaim {
+		return fmt.Sprintf("user %q in account %q", e.Name, e.Account)
+	}
+	return fmt.Sprintf("%s %q", e.Kind, e.Name)
+}
+
+func createTrashListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List the deleted accounts and users of the current operator",
+		Args:         MaxArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := GetStore()
+			if err != nil {
+				return err
+			}
+			entries, err := s.ListTrash()
+			if err != nil {
+				return err
+			}
+			if StructuredOutput() {
+				if entries == nil {
+					entries = []*store.TrashEntry{}
+				}
+				return WriteOutput(entries)
+			}
+			if len(entries) == 0 {
+				cmd.Printf("the trash for operator %q is empty\n", s.GetName())
+				return nil
+			}
+			table := tablewriter.CreateTable()
+			table.UTF8Box()
+			table.AddTitle(fmt.Sprintf("Trash for operator %s", s.GetName()))
+			table.AddHeaders("ID", "Kind", "Name", "Account", "Deleted", "Users", "Keys", "Creds")
+			for _, e := range entries {
+				users := 0
+				for _, f := range e.Files {
+					if strings.Contains(f, "/"+store.Users+"/") {
+						users++
+					}
+				}
+				table.AddRow(e.ID, e.Kind, e.Name, e.Account, e.DeletedAt().Format(time.RFC3339),
+					fmt.Sprintf("%d", users), fmt.Sprintf("%d", len(e.Keys)), fmt.Sprintf("%d", len(e.Creds)))
+			}
+			return Write("--", []byte(table.Render()))
+		},
+	}
+	return cmd
+}
+
+func createTrashPurgeCmd() *cobra.Command {
+	var params TrashPurgeParams
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Permanently delete accounts and users in the trash",
+		Long: `Permanently delete accounts and users in the trash
+
+By default entries deleted more than 30 days ago are purged. Purged
+JWTs, nkeys and creds files cannot be recovered.`,
+		Example: `nsc trash purge
+nsc trash purge --older-than 1w
+nsc trash purge <id>
+nsc trash purge --all`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunAction(cmd, args, &params)
+		},
+	}
+	cmd.Flags().StringVarP(&params.olderThan, "older-than", "", "30d", "purge entries deleted before the specified interval (m)inute, (h)our, (d)ay, (w)week, (M)onth, (y)ear or date 'YYYY-MM-DD'")
+	cmd.Flags().BoolVarP(&params.all, "all", "", false, "purge all entries")
+	return cmd
+}
+
+type TrashPurgeParams struct {
+	olderThan string
+	all       bool
+	ids       []string
+	entries   []*store.TrashEntry
+}
+
+func (p *TrashPurgeParams) SetDefaults(ctx ActionCtx) error {
+	p.ids = ctx.Args()
+	return nil
+}
+
+func (p *TrashPurgeParams) PreInteractive(_ ActionCtx) error {
+	return nil
+}
+
+// cutoff returns the time before which entries are purged
+func (p *TrashPurgeParams) cutoff() (int64, error) {
+	// dates are absolute, intervals are in the past
+	if _, err := time.Parse("2006-01-02", p.olderThan); err == nil {
+		return ParseExpiry(p.olderThan)
+	}
+	v, err := ParseExpiry("-" + strings.TrimPrefix(p.olderThan, "-"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid --older-than %q: %v", p.olderThan, err)
+	}
+	if v == 0 {
+		return 0, errors.New("--older-than must be an interval or date - use --all to purge all entries")
+	}
+	return v, nil
+}
+
+func (p *TrashPurgeParams) Load(ctx ActionCtx) error {
+	s := ctx.StoreCtx().Store
+	if len(p.ids) > 0 {
+		for _, id := range p.ids {
+			e, err := s.ReadTrashEntry(id)
+			if err != nil {
+				return err
+			}
+			p.entries = append(p.entries, e)
+		}
+		return nil
+	}
+	entries, err := s.ListTrash()
+	if err != nil {
+		return err
+	}
+	if p.all {
+		p.entries = entries
+		return nil
+	}
+	cutoff, err := p.cutoff()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Deleted < cutoff {
+			p.entries = append(p.entries, e)
+		}
+	}
+	return nil
+}
+
+func (p *TrashPurgeParams) PostInteractive(_ ActionCtx) error {
+	return nil
+}
+
+func (p *TrashPurgeParams) Validate(ctx ActionCtx) error {
+	if len(p.ids) > 0 && (p.all || ctx.CurrentCmd().Flags().Changed("older-than")) {
+		return errors.New("trash entry IDs cannot be combined with --all or --older-than")
+	}
+	return nil
+}
+
+func (p *TrashPurgeParams) Run(ctx ActionCtx) (store.Status, error) {
+	r := store.NewDetailedReport(true)
+	if len(p.entries) == 0 {
+		r.AddOK("no trash entries to purge")
+		return r, nil
+	}
+	s := ctx.StoreCtx().Store
+	ks := ctx.StoreCtx().KeyStore
+	for _, e := range p.entries {
+		// keys go first, a JWT without its entry cannot be restored
+		if err := ks.RemoveTrash(e); err != nil {
+			r.AddError("error purging the keys of trash entry %q: %v", e.ID, err)
+			continue
+		}
+		if err := s.RemoveTrashEntry(e); err != nil {
+			r.AddError("error purging trash entry %q: %v", e.ID, err)
+			continue
+		}
+		r.AddOK("purged %s deleted on %s [%s]", trashEntryName(e), e.DeletedAt().Format(time.RFC3339), e.ID)
+	}
+	return r, nil
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TrashList(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+
+	_, stderr, err := ExecuteCmd(createTrashListCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, "is empty")
+
+	_, _, err = ExecuteCmd(createDeleteUserCmd(), "U", "--rm-nkey")
+	require.NoError(t, err)
+
+	entries, err := ts.Store.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	out, _, err := ExecuteCmd(createTrashListCmd())
+	require.NoError(t, err)
+	require.Contains(t, out, entries[0].ID)
+}
+
+func Test_TrashPurge(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+	ts.AddUser(t, "A", "V")
+
+	uc, err := ts.Store.ReadUserClaim("A", "U")
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createDeleteUserCmd(), "U", "--rm-nkey", "--rm-creds")
+	require.NoError(t, err)
+	_, _, err = ExecuteCmd(createDeleteUserCmd(), "V")
+	require.NoError(t, err)
+
+	// age the entry for U
+	entries, err := ts.Store.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		if e.Name == "U" {
+			e.Deleted = time.Now().AddDate(0, 0, -40).Unix()
+			require.NoError(t, ts.Store.SaveTrashEntry(e))
+		}
+	}
+
+	_, stderr, err := ExecuteCmd(createTrashPurgeCmd())
+	require.NoError(t, err)
+	require.Contains(t, stderr, "purged user \"U\" in account \"A\"")
+	require.NotContains(t, stderr, "\"V\"")
+
+	entries, err = ts.Store.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "V", entries[0].Name)
+	_, err = os.Stat(ts.KeyStore.GetKeyPath(uc.Subject))
+	require.True(t, os.IsNotExist(err))
+
+	_, _, err = ExecuteCmd(createRestoreCmd("user"), "U")
+	require.Error(t, err)
+
+	_, _, err = ExecuteCmd(createTrashPurgeCmd(), "--all")
+	require.NoError(t, err)
+	require.False(t, ts.Store.Has(".trash"))
+}
+
+func Test_TrashPurgeByID(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddUser(t, "A", "U")
+
+	_, _, err := ExecuteCmd(createDeleteUserCmd(), "U")
+	require.NoError(t, err)
+	entries, err := ts.Store.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	_, _, err = ExecuteCmd(createTrashPurgeCmd(), entries[0].ID, "--all")
+	require.Error(t, err)
+
+	_, _, err = ExecuteCmd(createTrashPurgeCmd(), entries[0].ID)
+	require.NoError(t, err)
+	entries, err = ts.Store.ListTrash()
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
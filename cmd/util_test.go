@@ -64,6 +64,9 @@ func ResetSharedFlags() {
 	Json = false
 	Raw = false
 	JsonPath = ""
+	OutputFlag = TextOutput
+	DryRunFlag = false
+	PolicyOverrideFlag = false
 }
 
 func NewEmptyStore(t *testing.T) *TestStore {
@@ -236,6 +239,7 @@ func (ts *TestStore) Done(t *testing.T) {
 		ts.ports = nil
 	}
 	cli.ResetPromptLib()
+	store.ClearPassphrase()
 	if t.Failed() {
 		t.Log("test artifacts:", ts.Dir)
 	}
@@ -312,7 +316,14 @@ func (ts *TestStore) AddImport(t *testing.T, srcAccount string, subject string,
 		require.NoError(t, f.Close())
 		flags = append(flags, "--token", f.Name())
 	} else {
-		flags = append(flags, "--src-account", srcAccount, "--remote-subject", subject)
+		flags = append(flags, "--src-account", ts.GetAccountPublicKey(t, srcAccount), "--remote-subject", subject)
+		ac, err := ts.Store.ReadAccountClaim(srcAccount)
+		require.NoError(t, err)
+		for _, ex := range ac.Exports {
+			if string(ex.Subject) == subject && ex.IsService() {
+				flags = append(flags, "--service")
+			}
+		}
 	}
 	_, _, err := ExecuteCmd(createAddImportCmd(), flags...)
 	require.NoError(t, err)
@@ -19,6 +19,8 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/nats-io/jwt"
 	"github.com/nats-io/nsc/cmd/store"
@@ -34,7 +36,8 @@ func createValidateCommand() *cobra.Command {
 		Example: "validate",
 		Use: `validate (current operator/current account/account users)
 validate -a <accountName> (current operator/<accountName>/account users)
-validate -A (current operator/all accounts/all users)`,
+validate -A (current operator/all accounts/all users)
+validate --permissions (also report user permissions that have no effect)`,
 		Args: MaxArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = false
@@ -42,20 +45,20 @@ validate -A (current operator/all accounts/all users)`,
 				// this error was not during the sync operation return as it is
 				return err
 			}
-			cmd.Println(params.render(fmt.Sprintf("Operator %q", GetConfig().Operator), params.operator))
-			sort.Strings(params.accounts)
-			for _, v := range params.accounts {
-				cmd.Println(params.render(fmt.Sprintf("Account %q", v), params.accountValidations[v]))
-			}
-
 			if params.foundErrors() {
 				cmd.SilenceUsage = true
-				return errors.New("validation found errors")
+				err := errors.New("validation found errors")
+				if StructuredOutput() {
+					// the issues were written as the output
+					return &reportedError{err: err}
+				}
+				return err
 			}
 			return nil
 		},
 	}
 	cmd.Flags().BoolVarP(&params.allAccounts, "all-accounts", "A", false, "validate all accounts under the current operator (exclusive of -a)")
+	cmd.Flags().BoolVarP(&params.permissions, "permissions", "", false, "report user permissions that have no effect")
 	params.AccountContextParams.BindFlags(cmd)
 	return cmd
 }
@@ -67,6 +70,7 @@ func init() {
 type ValidateCmdParams struct {
 	AccountContextParams
 	allAccounts        bool
+	permissions        bool
 	operator           *jwt.ValidationResults
 	accounts           []string
 	accountValidations map[string]*jwt.ValidationResults
@@ -137,6 +141,11 @@ func (p *ValidateCmdParams) Validate(ctx ActionCtx) error {
 		return err
 	}
 
+	exporters, err := p.loadAccounts(ctx)
+	if err != nil {
+		return err
+	}
+
 	for _, v := range p.accounts {
 		ac, err := ctx.StoreCtx().Store.ReadAccountClaim(v)
 		if err != nil {
@@ -155,15 +164,18 @@ func (p *ValidateCmdParams) Validate(ctx ActionCtx) error {
 			}
 			p.accountValidations[v].AddError("Account is not issued by operator or operator signing keys")
 		}
+		p.validateImports(v, ac, exporters)
 		users, err := ctx.StoreCtx().Store.ListEntries(store.Accounts, v, store.Users)
 		if err != nil {
 			return err
 		}
+		var claims []*jwt.UserClaims
 		for _, u := range users {
 			uc, err := ctx.StoreCtx().Store.ReadUserClaim(v, u)
 			if err != nil {
 				return err
 			}
+			claims = append(claims, uc)
 			if uvr := p.validateJWT(uc); uvr != nil {
 				for _, vi := range uvr.Issues {
 					if p.accountValidations[v] == nil {
@@ -180,11 +192,142 @@ func (p *ValidateCmdParams) Validate(ctx ActionCtx) error {
 				p.accountValidations[v].AddError("user %q is not issued by account or account signing keys", u)
 			}
 		}
+		if p.permissions {
+			p.lintPermissions(v, ac, claims)
+		}
+	}
+
+	return nil
+}
+
+// loadAccounts returns all the accounts in the store keyed by their public key
+func (p *ValidateCmdParams) loadAccounts(ctx ActionCtx) (map[string]*jwt.AccountClaims, error) {
+	names, err := GetConfig().ListAccounts()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[string]*jwt.AccountClaims)
+	for _, n := range names {
+		ac, err := ctx.StoreCtx().Store.ReadAccountClaim(n)
+		if err != nil {
+			if store.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		accounts[ac.Subject] = ac
+	}
+	return accounts, nil
+}
+
+func (p *ValidateCmdParams) issues(account string) *jwt.ValidationResults {
+	if p.accountValidations[account] == nil {
+		p.accountValidations[account] = &jwt.ValidationResults{}
+	}
+	return p.accountValidations[account]
+}
+
+// validateImports checks the imports in the account against the exports
+// and activations of the accounts in the store
+func (p *ValidateCmdParams) validateImports(name string, ac *jwt.AccountClaims, accounts map[string]*jwt.AccountClaims) {
+	for i, im := range ac.Imports {
+		remote, _ := importedSubjects(im)
+		label := fmt.Sprintf("%s import %q", im.Type, remote)
+		exporter := accounts[im.Account]
+		if exporter == nil {
+			p.issues(name).AddError("%s: account %q is not in the store", label, im.Account)
+		} else if e := p.matchingExport(name, label, exporter, im); e != nil {
+			p.validateActivation(name, label, im, exporter, e)
+		}
+		for _, o := range ac.Imports[i+1:] {
+			if subjectsOverlap(localSubject(im), localSubject(o)) {
+				p.issues(name).AddError("%s: local subject %q overlaps with %q", label, localSubject(im), localSubject(o))
+			}
+		}
 	}
+}
 
+// matchingExport returns the export in the exporter satisfying the import,
+// adding an issue to the importing account if there's none
+func (p *ValidateCmdParams) matchingExport(name string, label string, exporter *jwt.AccountClaims, im *jwt.Import) *jwt.Export {
+	remote, _ := importedSubjects(im)
+	var candidates []*jwt.Export
+	for _, e := range exporter.Exports {
+		if e.Type == im.Type {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		p.issues(name).AddError("%s: account %q has no %s exports", label, exporter.Name, im.Type)
+		return nil
+	}
+	// prefer an exact match over a wildcard export
+	for _, e := range candidates {
+		if string(e.Subject) == remote {
+			return e
+		}
+	}
+	for _, e := range candidates {
+		if jwt.Subject(remote).IsContainedIn(e.Subject) {
+			return e
+		}
+	}
+	p.issues(name).AddError("%s: subject is not contained in a %s export of account %q", label, im.Type, exporter.Name)
 	return nil
 }
 
+// validateActivation checks the activation token on an import of a private export
+func (p *ValidateCmdParams) validateActivation(name string, label string, im *jwt.Import, exporter *jwt.AccountClaims, e *jwt.Export) {
+	if !e.TokenReq {
+		return
+	}
+	if im.Token == "" {
+		p.issues(name).AddError("%s: export %q in account %q requires an activation token", label, e.Subject, exporter.Name)
+		return
+	}
+	act, err := NewImportDescriber(*im).LoadActivation()
+	if err != nil {
+		p.issues(name).AddError("%s: activation token could not be decoded: %v", label, err)
+		return
+	}
+	if !exporter.DidSign(act) {
+		p.issues(name).AddError("%s: activation is not issued by account %q or its signing keys", label, exporter.Name)
+	}
+	if act.Expires > 0 && time.Unix(act.Expires, 0).Before(time.Now()) {
+		p.issues(name).AddTimeCheck("%s: activation is expired", label)
+	}
+	if e.IsRevokedAt(act.Subject, time.Unix(act.IssuedAt, 0)) {
+		p.issues(name).AddError("%s: activation has been revoked by account %q", label, exporter.Name)
+	}
+}
+
+// localSubject returns the subject an import is mapped to in the importing account
+func localSubject(im *jwt.Import) string {
+	remote, local := importedSubjects(im)
+	if local == "" {
+		return remote
+	}
+	if im.IsStream() {
+		return fmt.Sprintf("%s.%s", local, remote)
+	}
+	return local
+}
+
+// subjectsOverlap returns true if some subject matches both a and b
+func subjectsOverlap(a string, b string) bool {
+	at := strings.Split(a, ".")
+	bt := strings.Split(b, ".")
+	for i := 0; i < len(at) && i < len(bt); i++ {
+		if at[i] == ">" || bt[i] == ">" {
+			return true
+		}
+		if at[i] != bt[i] && at[i] != "*" && bt[i] != "*" {
+			return false
+		}
+	}
+	return len(at) == len(bt)
+}
+
 func (p *ValidateCmdParams) getSelectedAccounts() ([]string, error) {
 	if p.allAccounts {
 		a, err := GetConfig().ListAccounts()
@@ -198,8 +341,61 @@ func (p *ValidateCmdParams) getSelectedAccounts() ([]string, error) {
 	return nil, nil
 }
 
+func (p *ValidateCmdParams) ReadOnly() bool {
+	return true
+}
+
 func (p *ValidateCmdParams) Run(ctx ActionCtx) (store.Status, error) {
-	return nil, nil
+	sort.Strings(p.accounts)
+	return validateResult{p}, nil
+}
+
+// validateResult renders the issues found as tables, or as the list
+// of the validated entities in structured output
+type validateResult struct {
+	p *ValidateCmdParams
+}
+
+// validatedEntity is the structured form of the issues of an entity
+type validatedEntity struct {
+	Kind   string           `json:"kind"`
+	Name   string           `json:"name"`
+	Issues []validatedIssue `json:"issues"`
+}
+
+type validatedIssue struct {
+	Description string `json:"description"`
+	Blocking    bool   `json:"blocking,omitempty"`
+	TimeCheck   bool   `json:"time_check,omitempty"`
+}
+
+func (r validateResult) Code() store.StatusCode {
+	return store.OK
+}
+
+func (r validateResult) Message() string {
+	tables := []string{r.p.render(fmt.Sprintf("Operator %q", GetConfig().Operator), r.p.operator)}
+	for _, v := range r.p.accounts {
+		tables = append(tables, r.p.render(fmt.Sprintf("Account %q", v), r.p.accountValidations[v]))
+	}
+	return strings.Join(tables, "\n")
+}
+
+func (r validateResult) Structured() interface{} {
+	entity := func(kind string, name string, vr *jwt.ValidationResults) validatedEntity {
+		e := validatedEntity{Kind: kind, Name: name, Issues: []validatedIssue{}}
+		if vr != nil {
+			for _, i := range vr.Issues {
+				e.Issues = append(e.Issues, validatedIssue{Description: i.Description, Blocking: i.Blocking, TimeCheck: i.TimeCheck})
+			}
+		}
+		return e
+	}
+	entities := []validatedEntity{entity("operator", GetConfig().Operator, r.p.operator)}
+	for _, v := range r.p.accounts {
+		entities = append(entities, entity("account", v, r.p.accountValidations[v]))
+	}
+	return entities
 }
 
 func (p *ValidateCmdParams) foundErrors() bool {
@@ -16,10 +16,13 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/nats-io/jwt"
 	"github.com/nats-io/nsc/cmd/store"
 	"github.com/stretchr/testify/require"
 )
@@ -197,3 +200,181 @@ func Test_ValidateInteractive(t *testing.T) {
 	require.NoError(t, err)
 	require.Contains(t, stderr, "Account \"B\"")
 }
+
+func storeAccountClaim(t *testing.T, ts *TestStore, ac *jwt.AccountClaims) {
+	token, err := ac.Encode(ts.OperatorKey)
+	require.NoError(t, err)
+	_, err = ts.Store.StoreClaim([]byte(token))
+	require.NoError(t, err)
+}
+
+func Test_ValidateImports(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddExport(t, "A", jwt.Stream, "a.>", false)
+	ts.AddExport(t, "A", jwt.Service, "q", true)
+	ts.AddAccount(t, "B")
+	ts.AddImport(t, "A", "a.>", "B")
+	ts.AddImport(t, "A", "q", "B")
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--all-accounts")
+	require.NoError(t, err)
+	require.NotContains(t, stderr, "stream import")
+	require.NotContains(t, stderr, "service import")
+}
+
+func Test_ValidateImportMissingAccount(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "B")
+	_, apk, _ := CreateAccountKey(t)
+	ac, err := ts.Store.ReadAccountClaim("B")
+	require.NoError(t, err)
+	ac.Imports.Add(&jwt.Import{Name: "x", Subject: "x", Account: apk, Type: jwt.Stream})
+	storeAccountClaim(t, ts, ac)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--account", "B")
+	require.Error(t, err)
+	require.Contains(t, stderr, "is not in the store")
+}
+
+func Test_ValidateImportNoMatchingExport(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddExport(t, "A", jwt.Stream, "a.>", true)
+	ts.AddAccount(t, "B")
+	ac, err := ts.Store.ReadAccountClaim("B")
+	require.NoError(t, err)
+	apk := ts.GetAccountPublicKey(t, "A")
+	ac.Imports.Add(&jwt.Import{Name: "b", Subject: "b.>", Account: apk, Type: jwt.Stream})
+	ac.Imports.Add(&jwt.Import{Name: "q", Subject: "q", To: "a.q", Account: apk, Type: jwt.Service})
+	storeAccountClaim(t, ts, ac)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--account", "B")
+	require.Error(t, err)
+	require.Contains(t, stderr, "stream import \"b.>\": subject is not contained in a stream export of account \"A\"")
+	require.Contains(t, stderr, "service import \"a.q\": account \"A\" has no service exports")
+}
+
+func Test_ValidateImportActivationIssuer(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddExport(t, "A", jwt.Stream, "a.>", false)
+	ts.AddAccount(t, "B")
+	ts.AddImport(t, "A", "a.>", "B")
+
+	ac, err := ts.Store.ReadAccountClaim("B")
+	require.NoError(t, err)
+	act := jwt.NewActivationClaims(ac.Subject)
+	act.ImportSubject = "a.>"
+	act.ImportType = jwt.Stream
+	_, _, kp := CreateAccountKey(t)
+	ac.Imports[0].Token, err = act.Encode(kp)
+	require.NoError(t, err)
+	storeAccountClaim(t, ts, ac)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--account", "B")
+	require.Error(t, err)
+	require.Contains(t, stderr, "activation is not issued by account \"A\"")
+}
+
+func Test_ValidateImportActivationExpired(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddExport(t, "A", jwt.Stream, "a.>", false)
+	ts.AddAccount(t, "B")
+	ts.AddImport(t, "A", "a.>", "B")
+
+	ac, err := ts.Store.ReadAccountClaim("B")
+	require.NoError(t, err)
+	act := jwt.NewActivationClaims(ac.Subject)
+	act.ImportSubject = "a.>"
+	act.ImportType = jwt.Stream
+	act.Expires = time.Now().Add(-time.Hour).Unix()
+	ac.Imports[0].Token, err = act.Encode(ts.GetAccountKey(t, "A"))
+	require.NoError(t, err)
+	storeAccountClaim(t, ts, ac)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--account", "B")
+	require.Error(t, err)
+	require.Contains(t, stderr, "activation is expired")
+}
+
+func Test_ValidateImportActivationRevoked(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddExport(t, "A", jwt.Stream, "a.>", false)
+	ts.AddAccount(t, "B")
+	ts.AddImport(t, "A", "a.>", "B")
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	ac.Exports[0].RevokeAt(ts.GetAccountPublicKey(t, "B"), time.Now().Add(time.Minute))
+	storeAccountClaim(t, ts, ac)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--account", "B")
+	require.Error(t, err)
+	require.Contains(t, stderr, "activation has been revoked by account \"A\"")
+}
+
+func Test_ValidateImportsOverlap(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddExport(t, "A", jwt.Stream, "a.>", true)
+	ts.AddExport(t, "C", jwt.Stream, "a.b", true)
+	ts.AddAccount(t, "B")
+	ts.AddImport(t, "A", "a.>", "B")
+	ts.AddImport(t, "C", "a.b", "B")
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--account", "B")
+	require.Error(t, err)
+	require.Contains(t, stderr, "local subject \"a.>\" overlaps with \"a.b\"")
+}
+
+func Test_SubjectsOverlap(t *testing.T) {
+	require.True(t, subjectsOverlap("a.b", "a.b"))
+	require.True(t, subjectsOverlap("a.*", "a.b"))
+	require.True(t, subjectsOverlap("a.>", "a.b.c"))
+	require.True(t, subjectsOverlap("*.b", "a.*"))
+	require.False(t, subjectsOverlap("a.b", "a.c"))
+	require.False(t, subjectsOverlap("a.*", "a.b.c"))
+	require.False(t, subjectsOverlap("a.>", "a"))
+}
+
+func Test_ValidateJsonOutput(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+	ts.AddAccount(t, "A")
+
+	ac, err := ts.Store.ReadAccountClaim("A")
+	require.NoError(t, err)
+	before, err := ParseExpiry("1999-12-01")
+	require.NoError(t, err)
+	ac.Expires = before
+	token, err := ac.Encode(ts.OperatorKey)
+	require.NoError(t, err)
+	require.NoError(t, ts.Store.StoreRaw([]byte(token)))
+
+	stdout, stderr, err := ExecuteCmd(HoistRootFlags(createValidateCommand()), "--output", "json")
+	require.Error(t, err)
+	require.NotContains(t, stderr, "No issues found")
+
+	var entities []validatedEntity
+	require.NoError(t, json.Unmarshal([]byte(stdout), &entities))
+	require.Len(t, entities, 2)
+	require.Equal(t, "operator", entities[0].Kind)
+	require.Equal(t, "O", entities[0].Name)
+	require.Empty(t, entities[0].Issues)
+	require.Equal(t, "account", entities[1].Kind)
+	require.Equal(t, "A", entities[1].Name)
+	require.Len(t, entities[1].Issues, 1)
+	require.Equal(t, "claim is expired", entities[1].Issues[0].Description)
+	require.True(t, entities[1].Issues[0].TimeCheck)
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nats-io/jwt"
+)
+
+// lintPermissions adds warnings for user permissions that have no effect
+// given the rest of the user's permissions and the account's topology
+func (p *ValidateCmdParams) lintPermissions(name string, ac *jwt.AccountClaims, users []*jwt.UserClaims) {
+	sources := deliverableSubjects(ac, users)
+	for _, uc := range users {
+		var issues []string
+		issues = append(issues, lintPermission("pub", uc.Pub)...)
+		issues = append(issues, lintPermission("sub", uc.Sub)...)
+		for _, s := range uc.Sub.Allow {
+			if shadowingSubject(s, uc.Sub.Deny) == "" && !overlapsAny(s, sources) {
+				issues = append(issues, fmt.Sprintf("allow sub %q can't be delivered by any import, export or user in the account", s))
+			}
+		}
+		if uc.Resp != nil {
+			if !canSubscribe(uc.Sub) {
+				issues = append(issues, "response permissions are set but the user can't subscribe")
+			}
+			if !exportsServices(ac) {
+				issues = append(issues, fmt.Sprintf("response permissions are set but account %q exports no services", ac.Name))
+			}
+		}
+		for _, v := range issues {
+			p.issues(name).AddWarning("user %q: %s", uc.Name, v)
+		}
+	}
+}
+
+// lintPermission returns the allow entries shadowed by a deny, and
+// the entries covered by a wildcard entry in the same list
+func lintPermission(kind string, perm jwt.Permission) []string {
+	var issues []string
+	for _, s := range perm.Allow {
+		if d := shadowingSubject(s, perm.Deny); d != "" {
+			issues = append(issues, fmt.Sprintf("allow %s %q is shadowed by deny %q", kind, s, d))
+		}
+	}
+	lists := []struct {
+		name    string
+		entries jwt.StringList
+	}{{"allow", perm.Allow}, {"deny", perm.Deny}}
+	for _, l := range lists {
+		for i, s := range l.entries {
+			for j, o := range l.entries {
+				if i != j && s != o && jwt.Subject(s).IsContainedIn(jwt.Subject(o)) {
+					issues = append(issues, fmt.Sprintf("%s %s %q is redundant with %q", l.name, kind, s, o))
+					break
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// shadowingSubject returns the first subject in deny containing the subject
+func shadowingSubject(subject string, deny jwt.StringList) string {
+	for _, d := range deny {
+		if jwt.Subject(subject).IsContainedIn(jwt.Subject(d)) {
+			return d
+		}
+	}
+	return ""
+}
+
+// canSubscribe returns true if some subject is allowed by the permission
+func canSubscribe(perm jwt.Permission) bool {
+	allow := perm.Allow
+	if len(allow) == 0 {
+		allow = jwt.StringList{">"}
+	}
+	for _, s := range allow {
+		if shadowingSubject(s, perm.Deny) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func exportsServices(ac *jwt.AccountClaims) bool {
+	for _, e := range ac.Exports {
+		if e.IsService() {
+			return true
+		}
+	}
+	return false
+}
+
+// deliverableSubjects returns the subjects messages can arrive on in the account:
+// stream imports, the subjects of its exports, responses to service imports
+// and the subjects the users in the account are explicitly allowed to publish.
+// Users without a pub allow list don't count, as they would make every
+// subscription deliverable
+func deliverableSubjects(ac *jwt.AccountClaims, users []*jwt.UserClaims) []string {
+	var subjects []string
+	responses := false
+	for _, im := range ac.Imports {
+		if im.IsService() {
+			responses = true
+		} else {
+			subjects = append(subjects, localSubject(im))
+		}
+	}
+	for _, e := range ac.Exports {
+		subjects = append(subjects, string(e.Subject))
+	}
+	for _, uc := range users {
+		for _, s := range uc.Pub.Allow {
+			if shadowingSubject(s, uc.Pub.Deny) == "" {
+				subjects = append(subjects, s)
+			}
+		}
+		if uc.Resp != nil {
+			responses = true
+		}
+	}
+	if responses {
+		subjects = append(subjects, "_INBOX.>")
+	}
+	return subjects
+}
+
+func overlapsAny(subject string, subjects []string) bool {
+	for _, s := range subjects {
+		if subjectsOverlap(subject, s) {
+			return true
+		}
+	}
+	return false
+}
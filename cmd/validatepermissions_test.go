@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018-2019 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/nats-io/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ValidatePermissionsShadowedAndRedundant(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	ts.AddUser(t, "A", "U")
+	_, _, err := ExecuteCmd(createEditUserCmd(), "--name", "U", "--allow-pub", "a.>,a.b,c", "--deny-pub", "c.>,c")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--permissions")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `user "U": allow pub "c" is shadowed by deny "c"`)
+	require.Contains(t, stderr, `user "U": allow pub "a.b" is redundant with "a.>"`)
+	require.NotContains(t, stderr, `"c.>" is redundant`)
+}
+
+func Test_ValidatePermissionsRequiresFlag(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	ts.AddUser(t, "A", "U")
+	_, _, err := ExecuteCmd(createEditUserCmd(), "--name", "U", "--allow-pub", "a", "--deny-pub", "a")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand())
+	require.NoError(t, err)
+	require.NotContains(t, stderr, "shadowed")
+}
+
+func Test_ValidatePermissionsUndeliverableSub(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddExport(t, "B", jwt.Stream, "s.>", true)
+	ts.AddAccount(t, "A")
+	ts.AddImport(t, "B", "s.>", "A")
+	ts.AddUser(t, "A", "U")
+	_, _, err := ExecuteCmd(createEditUserCmd(), "--name", "U", "--allow-pub", "p.>", "--allow-sub", "p.a,s.a,x")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--account", "A", "--permissions")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `user "U": allow sub "x" can't be delivered`)
+	require.NotContains(t, stderr, `"p.a" can't be delivered`)
+	require.NotContains(t, stderr, `"s.a" can't be delivered`)
+}
+
+func Test_ValidatePermissionsUndeliverableSubDefaultPermissions(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	ts.AddExport(t, "A", jwt.Stream, "e.>", true)
+	ts.AddUser(t, "A", "P")
+	ts.AddUser(t, "A", "U")
+	_, _, err := ExecuteCmd(createEditUserCmd(), "--name", "U", "--allow-sub", "e.a,x")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--account", "A", "--permissions")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `user "U": allow sub "x" can't be delivered`)
+	require.NotContains(t, stderr, `"e.a" can't be delivered`)
+}
+
+func Test_ValidatePermissionsResponses(t *testing.T) {
+	ts := NewTestStore(t, "O")
+	defer ts.Done(t)
+
+	ts.AddAccount(t, "A")
+	ts.AddUser(t, "A", "U")
+	_, _, err := ExecuteCmd(createEditUserCmd(), "--name", "U", "--allow-pub-response", "--deny-sub", ">")
+	require.NoError(t, err)
+
+	_, stderr, err := ExecuteCmd(createValidateCommand(), "--permissions")
+	require.NoError(t, err)
+	require.Contains(t, stderr, `user "U": response permissions are set but the user can't subscribe`)
+	require.Contains(t, stderr, `user "U": response permissions are set but account "A" exports no services`)
+
+	ts.AddExport(t, "A", jwt.Service, "q", true)
+	_, _, err = ExecuteCmd(createEditUserCmd(), "--name", "U", "--rm", ">")
+	require.NoError(t, err)
+
+	_, stderr, err = ExecuteCmd(createValidateCommand(), "--permissions")
+	require.NoError(t, err)
+	require.NotContains(t, stderr, "response permissions")
+}
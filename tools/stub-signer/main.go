@@ -0,0 +1,78 @@
+/*
+ * Copyright 2020 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// stub-signer is the reference external signer for nsc. It signs with
+// a seed file, use it to test signer setups with 'exec:' key references:
+//
+//	nsc edit account -K "exec:stub-signer /path/to/operator.nk"
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/nats-io/nkeys"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run signs with the seed in the file named by the first argument:
+//
+//	stub-signer /path/to/key.nk public-key
+//	stub-signer /path/to/key.nk sign < data
+func run(args []string, in io.Reader, out io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("usage: stub-signer <seed file> public-key|sign")
+	}
+	d, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	kp, err := nkeys.FromSeed(bytes.TrimSpace(d))
+	if err != nil {
+		return fmt.Errorf("error reading seed %#q: %v", args[0], err)
+	}
+	switch args[1] {
+	case "public-key":
+		pk, err := kp.PublicKey()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, pk)
+		return err
+	case "sign":
+		data, err := ioutil.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		sig, err := kp.Sign(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, base64.StdEncoding.EncodeToString(sig))
+		return err
+	}
+	return fmt.Errorf("unknown operation %q", args[1])
+}